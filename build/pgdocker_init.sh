@@ -1,2 +1,13 @@
 #!/bin/sh
-PGPASSWORD=coins psql -h localhost -p 5432 -U coins -d coins -f "init.sql"
\ No newline at end of file
+# apply migrations of database schema to database started by docker-compose
+MY_FN=`readlink -e $0`
+ROOT_DIR=`dirname $MY_FN`/..
+
+export DB_DRIVER=postgresql
+export PGSQL_HOST=localhost
+export PGSQL_NAME=coins
+export PGSQL_USER=coins
+export PGSQL_PASS=coins
+export PGSQL_PORT=5432
+
+cd $ROOT_DIR && go run ./cmd/wallet migrate up
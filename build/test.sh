@@ -9,6 +9,9 @@ export PGSQL_USER=coins
 export PGSQL_PASS=coins
 export PGSQL_PORT=5432
 export CacheExpTime=10
+export DB_DRIVER=postgresql
+# concurrent transfers are run with PostgreSQL also when tests are run with other driver
+export PGSQL_TEST_DSN="user=coins password=coins dbname=coins host=127.0.0.1 port=5432 sslmode=disable"
 
 cd $ROOT_DIR/build
 
@@ -0,0 +1,147 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"gopkg.in/yaml.v2"
+)
+
+// Config - configuration of wallet server
+type Config struct {
+	// HTTPAddr - HTTP listen address
+	HTTPAddr string `yaml:"http_addr"`
+	// RequestTimeout - deadline of handling HTTP request, database queries of request are interrupted after it
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// RatesFile - JSON file with exchange rates for cross-currency transfers
+	RatesFile string `yaml:"rates_file"`
+	// FeesFile - JSON file with fee rules of transfers
+	FeesFile string `yaml:"fees_file"`
+	// AdminToken - token of administrator for privileged requests
+	AdminToken string `yaml:"admin_token"`
+	// HoldsInterval - interval of releasing expired holds
+	HoldsInterval time.Duration `yaml:"holds_interval"`
+	// ScheduleInterval - interval of executing scheduled transfers
+	ScheduleInterval time.Duration `yaml:"schedule_interval"`
+	// DB - configuration of storage driver
+	DB repository.Config `yaml:"db"`
+}
+
+// defaultConfig - return configuration with default values
+func defaultConfig() Config {
+	return Config{
+		HTTPAddr:         ":8081",
+		RequestTimeout:   10 * time.Second,
+		HoldsInterval:    time.Minute,
+		ScheduleInterval: time.Minute,
+		DB:               repository.DefaultConfig(),
+	}
+}
+
+// loadConfig - load configuration from file, environment and command line arguments
+// file is set by argument -config or CONFIG_FILE environment, it can be in YAML or JSON format
+// values of file are overridden by environment and values of environment by arguments which are set
+func loadConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	c := defaultConfig()
+
+	// values of arguments, they are applied only when argument is set
+	a := defaultConfig()
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON file with configuration")
+	fs.StringVar(&a.HTTPAddr, "http.addr", a.HTTPAddr, "HTTP listen address")
+	fs.DurationVar(&a.RequestTimeout, "http.request-timeout", a.RequestTimeout, "deadline of handling HTTP request, 0 for no deadline")
+	fs.StringVar(&a.RatesFile, "rates.file", a.RatesFile, "JSON file with exchange rates for cross-currency transfers")
+	fs.StringVar(&a.FeesFile, "fees.file", a.FeesFile, "JSON file with fee rules of transfers")
+	fs.StringVar(&a.AdminToken, "admin.token", a.AdminToken, "token of administrator for privileged requests")
+	fs.DurationVar(&a.HoldsInterval, "holds.interval", a.HoldsInterval, "interval of releasing expired holds")
+	fs.DurationVar(&a.ScheduleInterval, "schedule.interval", a.ScheduleInterval, "interval of executing scheduled transfers")
+	fs.StringVar(&a.DB.Driver, "db.driver", a.DB.Driver, "storage driver: "+joinDrivers())
+	fs.StringVar(&a.DB.DSN, "db.dsn", a.DB.DSN, "connection string of database, for sqlite path to database file")
+	maxConns := fs.Int("db.max-conns", 0, "maximal size of pool of connections to database, 0 for default")
+	minConns := fs.Int("db.min-conns", 0, "minimal size of pool of connections to database")
+	fs.DurationVar(&a.DB.CacheTTL, "db.cache-ttl", a.DB.CacheTTL, "expiration time of memory cache")
+	fs.BoolVar(&a.DB.AutoMigrate, "db.auto-migrate", a.DB.AutoMigrate, "apply pending migrations of database schema on start")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *file != "" {
+		b, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return Config{}, err
+		}
+		if err = yaml.UnmarshalStrict(b, &c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		c.AdminToken = v
+	}
+	repository.LoadEnv(&c.DB)
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "http.addr":
+			c.HTTPAddr = a.HTTPAddr
+		case "http.request-timeout":
+			c.RequestTimeout = a.RequestTimeout
+		case "rates.file":
+			c.RatesFile = a.RatesFile
+		case "fees.file":
+			c.FeesFile = a.FeesFile
+		case "admin.token":
+			c.AdminToken = a.AdminToken
+		case "holds.interval":
+			c.HoldsInterval = a.HoldsInterval
+		case "schedule.interval":
+			c.ScheduleInterval = a.ScheduleInterval
+		case "db.driver":
+			c.DB.Driver = a.DB.Driver
+		case "db.dsn":
+			c.DB.DSN = a.DB.DSN
+		case "db.max-conns":
+			c.DB.MaxConns = int32(*maxConns)
+		case "db.min-conns":
+			c.DB.MinConns = int32(*minConns)
+		case "db.cache-ttl":
+			c.DB.CacheTTL = a.DB.CacheTTL
+		case "db.auto-migrate":
+			c.DB.AutoMigrate = a.DB.AutoMigrate
+		}
+	})
+	if err := c.validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// validate - check values of configuration which can't be used by server
+// intervals of background processes must be positive, they are periods of tickers
+func (c Config) validate() error {
+	if c.HoldsInterval <= 0 {
+		return fmt.Errorf("holds interval must be positive, got %s", c.HoldsInterval)
+	}
+	if c.ScheduleInterval <= 0 {
+		return fmt.Errorf("schedule interval must be positive, got %s", c.ScheduleInterval)
+	}
+	return nil
+}
+
+// joinDrivers - list of registered storage drivers for help of argument
+func joinDrivers() string {
+	s := ""
+	for i, name := range repository.Drivers() {
+		if i > 0 {
+			s += ", "
+		}
+		s += name
+	}
+	return s
+}
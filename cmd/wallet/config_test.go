@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_loadConfig(t *testing.T) {
+	for _, k := range []string{"DB_DRIVER", "DB_DSN", "DB_MAX_CONNS", "DB_MIN_CONNS", "PGSQL_HOST", "CacheExpTime", "DB_AUTO_MIGRATE", "ADMIN_TOKEN", "CONFIG_FILE"} {
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+			os.Unsetenv(k)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "wallet.yaml")
+	if err := ioutil.WriteFile(file, []byte(`
+http_addr: ":9000"
+holds_interval: 30s
+schedule_interval: 5m
+db:
+  driver: sqlite
+  dsn: file.db
+  max_conns: 4
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// file < environment < arguments
+	os.Setenv("DB_DSN", "env.db")
+	defer os.Unsetenv("DB_DSN")
+	c, err := loadConfig(flag.NewFlagSet("wallet", flag.ContinueOnError),
+		[]string{"-config", file, "-db.max-conns", "8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.HTTPAddr != ":9000" || c.HoldsInterval != 30*time.Second || c.ScheduleInterval != 5*time.Minute || c.DB.Driver != "sqlite" {
+		t.Errorf("values of file are not loaded: %+v", c)
+	}
+	if c.DB.DSN != "env.db" {
+		t.Errorf("DB.DSN = %q, want value of environment", c.DB.DSN)
+	}
+	if c.DB.MaxConns != 8 {
+		t.Errorf("DB.MaxConns = %d, want value of argument", c.DB.MaxConns)
+	}
+	if c.DB.CacheTTL != 10*time.Minute {
+		t.Errorf("DB.CacheTTL = %s, want default", c.DB.CacheTTL)
+	}
+
+	// unknown key of file is an error
+	if err := ioutil.WriteFile(file, []byte(`db: {engine: sqlite}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(flag.NewFlagSet("wallet", flag.ContinueOnError), []string{"-config", file}); err == nil {
+		t.Error("unknown key of configuration file is accepted")
+	}
+
+	// intervals of background processes are periods of tickers
+	if err := ioutil.WriteFile(file, []byte(`holds_interval: 0s`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(flag.NewFlagSet("wallet", flag.ContinueOnError), []string{"-config", file}); err == nil {
+		t.Error("zero holds interval is accepted")
+	}
+	if _, err := loadConfig(flag.NewFlagSet("wallet", flag.ContinueOnError), []string{"-schedule.interval", "-1m"}); err == nil {
+		t.Error("negative schedule interval is accepted")
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+)
+
+// feesFile - format of JSON file with fee rules
+// {"fees": [{"currency": "usd", "flat": "0.30", "percent": "1.5", "min": "0.50", "max": "10"}]}
+type feesFile struct {
+	Fees []entity.FeeRule `json:"fees"`
+}
+
+// loadFees - load fee schedule of transfers from JSON file
+func loadFees(path string) (*entity.FeeSchedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f feesFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return entity.NewFeeSchedule(f.Fees)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_loadFees(t *testing.T) {
+	fees, err := loadFees("../../build/fees.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := fees.Fee("usd", money.MustParse("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.String() != "1.30" {
+		t.Errorf("Fee() = %s, want 1.30", fee)
+	}
+
+	if _, err = loadFees("../../build/rates.json"); err != nil {
+		t.Errorf("file without fees is not loaded: %v", err)
+	}
+}
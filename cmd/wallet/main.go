@@ -2,6 +2,7 @@
 // yuriyiovkov@gmail.com; telegram: @yuriyiovkov
 
 // starts http server for the REST API of wallet
+// command "wallet migrate up|down|status" manages versions of database schema
 
 package main
 
@@ -17,23 +18,31 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/internal/domain/wallet/exchange"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/memory"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/postgresql"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/sqlite"
 	"github.com/rurick/coinswallet/internal/services"
 	"github.com/rurick/coinswallet/internal/transport"
 )
 
 func main() {
-	var (
-		httpAddr = flag.String("http.addr", ":8081", "HTTP listen address")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:], os.Stdout))
+	}
 
-		s services.Service // services that implement business logic
-	)
+	var s services.Service // services that implement business logic
 
 	// global program context
 	ctx, cancel := context.WithCancel(context.Background())
-	// goroutines manager
-	goMgr := subprocmgr.New()
 
-	flag.Parse()
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(2)
+	}
 
 	var logger log.Logger
 	{
@@ -42,33 +51,112 @@ func main() {
 		logger = log.With(logger, "caller", log.DefaultCaller)
 	}
 
-	s = services.NewService(logger)
-	h := transport.MakeHTTPHandler(s, log.With(logger, "component", "HTTP"))
+	// exchange rates. Without rates transfers are allowed only between accounts with the same currency
+	var rates entity.ExchangeRateProvider
+	if cfg.RatesFile != "" {
+		r, err := exchange.NewFile(cfg.RatesFile)
+		if err != nil {
+			_ = logger.Log("rates", cfg.RatesFile, "error", err)
+			os.Exit(1)
+		}
+		rates = r
+	}
 
-	// channel of "exit" signal
-	errs := make(chan error)
+	// fees of transfers. Without fee rules transfers are free
+	var fees *entity.FeeSchedule
+	if cfg.FeesFile != "" {
+		if fees, err = loadFees(cfg.FeesFile); err != nil {
+			_ = logger.Log("fees", cfg.FeesFile, "error", err)
+			os.Exit(1)
+		}
+	}
 
-	// run server
-	goMgr.Add("httpServer")
-	go func() {
-		defer goMgr.Remove("httpServer")
-		runHttpServer(ctx, &h, httpAddr, logger, errs)
-	}()
+	// storage driver, repositories of all requests share its connection
+	// server doesn't start with outdated schema of database unless auto migration is enabled
+	db, err := repository.Open(cfg.DB)
+	if err != nil {
+		_ = logger.Log("db", cfg.DB.Driver, "error", err)
+		os.Exit(1)
+	}
+
+	s = services.NewService(logger, rates, fees, db)
+	h := transport.WithTimeout(
+		transport.MakeHTTPHandler(s, log.With(logger, "component", "HTTP"), cfg.AdminToken),
+		cfg.RequestTimeout,
+	)
 
 	// check program termination
-	go handleSignals(errs, func() {
+	stop := make(chan error, 1)
+	go handleSignals(stop, func() {
 		cancel()
 	})
 
-	// waiting for value in errs channel
-	// it becomes when program terminated or http server shutdown
-	_ = logger.Log("Exit", <-errs)
+	// run server and background workers until program terminated or http server failed
+	err = serve(ctx, h, cfg.HTTPAddr, logger, map[string]worker{
+		// release expired holds
+		"holdsExpiry": func(ctx context.Context) { runHoldsExpiry(ctx, s, cfg.HoldsInterval, logger) },
+		// execute scheduled transfers
+		"scheduler": func(ctx context.Context) { runScheduler(ctx, s, cfg.ScheduleInterval, logger) },
+	})
+	serverErr := err
+	if err == nil {
+		err = <-stop
+	}
+	_ = logger.Log("Exit", err)
+
+	_ = logger.Log("db", "close", "result", db.Close())
+	if serverErr != nil {
+		os.Exit(1)
+	}
+}
+
+// worker - background process of server, it must return when ctx is done
+type worker func(ctx context.Context)
+
+// serve - run http server and background workers until ctx is done or http server failed
+// in both cases workers are stopped and serve returns when all goroutines are completed
+// returning error of http server or nil if ctx is done
+func serve(ctx context.Context, h http.Handler, httpAddr string, logger log.Logger, workers map[string]worker) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// goroutines manager, all goroutines are added before start, so Done isn't closed by the first finished one
+	goMgr := subprocmgr.New()
+	goMgr.Add("httpServer")
+	for name := range workers {
+		goMgr.Add(name)
+	}
+
+	// channel of http server error
+	errs := make(chan error, 1)
+
+	// run server
+	go func() {
+		defer goMgr.Remove("httpServer")
+		runHttpServer(ctx, &h, &httpAddr, logger, errs)
+	}()
+	for name, w := range workers {
+		go func(name string, w worker) {
+			defer goMgr.Remove(name)
+			w(ctx)
+		}(name, w)
+	}
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+	// stop workers also when http server failed
+	cancel()
 
 	// waiting for completing all goroutines
 	<-goMgr.Done()
+	return err
 }
 
 // runHttpServer - run http server and shutdown one correctly
+// error of http server is sent to errs, which must have buffer for it
 func runHttpServer(ctx context.Context, h *http.Handler, httpAddr *string, logger log.Logger, errs chan error) {
 	httpServer := &http.Server{
 		Handler:      *h,
@@ -98,10 +186,52 @@ func runHttpServer(ctx context.Context, h *http.Handler, httpAddr *string, logge
 	}
 }
 
+// runHoldsExpiry - periodically release expired holds until the context is done
+func runHoldsExpiry(ctx context.Context, s services.Service, every time.Duration, logger log.Logger) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.ExpireHolds(ctx)
+			if err != nil {
+				_ = logger.Log("holdsExpiry", "run", "error", err)
+				continue
+			}
+			if n > 0 {
+				_ = logger.Log("holdsExpiry", "run", "expired", n)
+			}
+		}
+	}
+}
+
+// runScheduler - periodically execute scheduled transfers which time has come until the context is done
+func runScheduler(ctx context.Context, s services.Service, every time.Duration, logger log.Logger) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.ExecuteScheduledTransfers(ctx)
+			if err != nil {
+				_ = logger.Log("scheduler", "run", "error", err)
+				continue
+			}
+			if n > 0 {
+				_ = logger.Log("scheduler", "run", "executed", n)
+			}
+		}
+	}
+}
+
 // handleSignals - handle system interrupt signals and prepare program to finish
 // the value in channel "c" is set and the function onExit is called
 func handleSignals(c chan error, onExit func()) {
-	sigCh := make(chan os.Signal)
+	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	sig := <-sigCh
 	onExit()
@@ -4,10 +4,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"testing"
@@ -47,7 +49,7 @@ func Test_API(t *testing.T) {
 		for _, n := range wallets {
 			resp, err := client.R().
 				SetHeader("Content-Type", "application/json").
-				SetBody([]byte(fmt.Sprintf(`{"name":"%s","amount":%f}`, n, rand.Float64()*10+10))).
+				SetBody([]byte(fmt.Sprintf(`{"name":"%s","amount":%.2f}`, n, rand.Float64()*10+10))).
 				Patch("http://localhost:8081/account/deposit/")
 			if err != nil {
 				t.Fatal(err)
@@ -85,7 +87,7 @@ func Test_API(t *testing.T) {
 			send := func(name string, amount float64) (*resty.Response, error) {
 				return client.R().
 					SetHeader("Content-Type", "application/json").
-					SetBody([]byte(fmt.Sprintf(`{"name":"%s","amount":%f}`, name, amount))).
+					SetBody([]byte(fmt.Sprintf(`{"name":"%s","amount":%.2f}`, name, amount))).
 					Patch("http://localhost:8081/account/deposit/")
 			}
 			resp, err := send(n.n, n.a)
@@ -139,7 +141,7 @@ func Test_API(t *testing.T) {
 			send := func(name, nameTo string, amount float64) (*resty.Response, error) {
 				return client.R().
 					SetHeader("Content-Type", "application/json").
-					SetBody([]byte(fmt.Sprintf(`{"from":"%s","to":"%s", "amount":%f}`, name, nameTo, amount))).
+					SetBody([]byte(fmt.Sprintf(`{"from":"%s","to":"%s", "amount":%.2f}`, name, nameTo, amount))).
 					Patch("http://localhost:8081/account/transfer/")
 			}
 			resp, err := send(n.nFrom, n.nTo, n.a)
@@ -204,7 +206,7 @@ func Test_API(t *testing.T) {
 			send := func(name, nameTo string, amount float64) (*resty.Response, error) {
 				return client.R().
 					SetHeader("Content-Type", "application/json").
-					SetBody([]byte(fmt.Sprintf(`{"from":"%s","to":"%s", "amount":%f}`, name, nameTo, amount))).
+					SetBody([]byte(fmt.Sprintf(`{"from":"%s","to":"%s", "amount":%.2f}`, name, nameTo, amount))).
 					Patch("http://localhost:8081/account/transfer/")
 			}
 			resp, err := send(n.nFrom, n.nTo, n.a)
@@ -223,6 +225,57 @@ func Test_API(t *testing.T) {
 		}
 	})
 
+	t.Run("withdraw with errors", func(t *testing.T) {
+		w := []struct {
+			n            string
+			a            float64
+			counterparty string
+			code         int
+		}{
+			{
+				n:            "wrongAccountName",
+				a:            1,
+				counterparty: "card",
+				code:         404,
+			},
+			{
+				n:            wallets[0],
+				a:            -1,
+				counterparty: "card",
+				code:         400,
+			},
+			{
+				n:            wallets[0],
+				a:            2000,
+				counterparty: "card",
+				code:         400,
+			},
+			{
+				n:            wallets[0],
+				a:            1,
+				counterparty: "",
+				code:         400,
+			},
+		}
+		for _, n := range w {
+			resp, err := client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody([]byte(fmt.Sprintf(`{"name":"%s","amount":%.2f,"counterparty":"%s"}`, n.n, n.a, n.counterparty))).
+				Patch("http://localhost:8081/account/withdraw/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, n.code, resp.StatusCode())
+			var r map[string]interface{}
+			if err = json.Unmarshal(resp.Body(), &r); err != nil {
+				t.Fatal(err, resp)
+			}
+			if e, ok := r["error"]; !ok {
+				t.Error(e)
+			}
+		}
+	})
+
 	t.Run("accounts list", func(t *testing.T) {
 		resp, err := client.R().Get("http://localhost:8081/accounts/0/3/")
 		if err != nil {
@@ -269,15 +322,21 @@ func Test_API(t *testing.T) {
 	})
 
 	t.Run("delete 4 accounts", func(t *testing.T) {
+		// server's database is opened directly, so the test must use the same configuration
+		db, err := repository.Open(repository.ConfigFromEnv())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
 		for _, n := range wallets {
-			a, err := entity.NewAccount()
+			a, err := entity.NewAccount(db)
 			if err != nil {
 				t.Fatal(err)
 			}
-			if err = a.Find(entity.AccountName(n)); err != nil {
+			if err = a.Find(context.Background(), entity.AccountName(n)); err != nil {
 				t.Fatal(err)
 			}
-			if err = a.Delete(); err != nil {
+			if err = a.Delete(context.Background()); err != nil {
 				t.Error(err)
 			}
 		}
@@ -0,0 +1,98 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+)
+
+// migrateUsage - help of command migrate
+const migrateUsage = `usage: wallet migrate up|down|status [flags]
+  up      apply all pending migrations
+  down    revert the last applied migration
+  status  list migrations and time of applying
+flags are the same as flags of server`
+
+// runMigrate - run command "wallet migrate" with arguments following it, returning exit code
+func runMigrate(args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	cmd := args[0]
+	if cmd != "up" && cmd != "down" && cmd != "status" {
+		fmt.Fprintln(os.Stderr, "unknown command:", cmd)
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg, err := loadConfig(flag.NewFlagSet("wallet migrate "+cmd, flag.ExitOnError), args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		return 2
+	}
+	db, err := repository.OpenMigrator(cfg.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db:", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err = migrate(context.Background(), db, cmd, out); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	return 0
+}
+
+// migrate - execute migration command cmd and print result to out
+func migrate(ctx context.Context, db repository.Migrator, cmd string, out io.Writer) error {
+	switch cmd {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+
+	case "down":
+		m, err := db.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Fprintln(out, "no applied migrations")
+			return nil
+		}
+		fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+		return nil
+
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		// unknown migrations don't prevent listing of known ones
+		if err != nil && !errors.Is(err, repository.ErrSchemaUnknown) {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied() {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-32s %s\n", s.Version, s.Name, applied)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown command %s", cmd)
+}
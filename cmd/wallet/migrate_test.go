@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_runMigrate(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "wallet.db")
+	run := func(args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runMigrate(append(args, "-db.driver", "sqlite", "-db.dsn", dsn), &out)
+		return code, out.String()
+	}
+
+	if code, out := run("status"); code != 0 || !strings.Contains(out, "pending") {
+		t.Errorf("status of empty database = %d, %q", code, out)
+	}
+	if code, out := run("up"); code != 0 || !strings.Contains(out, "applied 0001_") {
+		t.Errorf("up = %d, %q", code, out)
+	}
+	if code, out := run("up"); code != 0 || !strings.Contains(out, "up to date") {
+		t.Errorf("repeated up = %d, %q", code, out)
+	}
+	if code, out := run("status"); code != 0 || strings.Contains(out, "pending") {
+		t.Errorf("status of migrated database = %d, %q", code, out)
+	}
+	if code, out := run("down"); code != 0 || !strings.Contains(out, "reverted") {
+		t.Errorf("down = %d, %q", code, out)
+	}
+	if code, _ := run("sideways"); code != 2 {
+		t.Errorf("unknown command exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/services"
+)
+
+func Test_serve(t *testing.T) {
+	t.Run("listen error", func(t *testing.T) {
+		// address is already bound, so http server fails at start
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		stopped := make(chan bool, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- serve(context.Background(), http.NotFoundHandler(), l.Addr().String(), log.NewNopLogger(), map[string]worker{
+				"worker": func(ctx context.Context) {
+					<-ctx.Done()
+					stopped <- true
+				},
+			})
+		}()
+
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("serve() returned nil error after failed listen")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve() doesn't return after failed listen")
+		}
+		select {
+		case <-stopped:
+		default:
+			t.Error("worker isn't stopped")
+		}
+	})
+
+	t.Run("scheduler is stopped when listen failed", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		db, err := repository.Open(repository.Config{Driver: "memory"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		logger := log.NewNopLogger()
+		s := services.NewService(logger, nil, nil, db)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- serve(context.Background(), http.NotFoundHandler(), l.Addr().String(), logger, map[string]worker{
+				"scheduler": func(ctx context.Context) { runScheduler(ctx, s, time.Millisecond, logger) },
+			})
+		}()
+
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("serve() returned nil error after failed listen")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("scheduler isn't stopped after failed listen")
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- serve(ctx, http.NotFoundHandler(), "127.0.0.1:0", log.NewNopLogger(), map[string]worker{
+				"worker": func(ctx context.Context) { <-ctx.Done() },
+			})
+		}()
+		cancel()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("serve() error = %v, want nil", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("serve() doesn't return after context is done")
+		}
+	})
+}
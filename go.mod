@@ -1,13 +1,17 @@
 module github.com/rurick/coinswallet
 
-go 1.14
+go 1.16
 
 require (
 	github.com/go-kit/kit v0.10.0
 	github.com/go-resty/resty/v2 v2.6.0
 	github.com/gorilla/mux v1.7.3
+	github.com/jackc/pgconn v1.8.1
+	github.com/jackc/pgtype v1.7.0
 	github.com/jackc/pgx/v4 v4.11.0
 	github.com/joho/godotenv v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/sirupsen/logrus v1.4.2
 	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.2.2
 )
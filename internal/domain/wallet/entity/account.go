@@ -8,10 +8,14 @@
 package entity
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 type (
@@ -20,34 +24,145 @@ type (
 	AccountID   int64
 )
 
+// Idempotency - idempotency key of request and fingerprint of request parameters
+type Idempotency = repository.Idempotency
+
+// Metadata - description, external reference and tags of deposit or transfer
+// external reference is unique per account which created payment
+type Metadata = repository.Metadata
+
+// Driver - storage of accounts and payments, defined by repository
+type Driver = repository.Driver
+
+// Storage - repositories of driver or unit of work, entities are stored by them
+type Storage = repository.Storage
+
+// ErrNoDriver is returned when entity is created without storage driver
+var ErrNoDriver = errors.New("storage driver is not set")
+
+// statuses of account
+const (
+	AccountStatusActive = repository.AccountStatusActive
+	AccountStatusFrozen = repository.AccountStatusFrozen
+	AccountStatusClosed = repository.AccountStatusClosed
+)
+
+var (
+	// ErrNoMoney is returned when account balance is not enough for payment
+	ErrNoMoney = repository.ErrNoMoney
+	// ErrRecipientNotFound is returned when recipient account of transfer not exists
+	ErrRecipientNotFound = repository.ErrRecipientNotFound
+
+	// ErrAccountFrozen is returned when account of payment is frozen
+	ErrAccountFrozen = repository.ErrAccountFrozen
+	// ErrAccountClosed is returned when account of payment or account which status is changed is closed
+	ErrAccountClosed = repository.ErrAccountClosed
+	// ErrRecipientFrozen is returned when recipient account of payment is frozen
+	ErrRecipientFrozen = repository.ErrRecipientFrozen
+	// ErrRecipientClosed is returned when recipient account of payment is closed
+	ErrRecipientClosed = repository.ErrRecipientClosed
+	// ErrAccountNotEmpty is returned when account with non zero balance or active holds is closed
+	ErrAccountNotEmpty = repository.ErrAccountNotEmpty
+	// ErrCreditLimitInvalid is returned when credit limit is negative or has more digits after decimal point
+	// than currency allows
+	ErrCreditLimitInvalid = errors.New("invalid credit limit value")
+
+	// ErrIdempotencyReplay is returned with id of original payment when request was already executed
+	ErrIdempotencyReplay = repository.ErrIdempotencyReplay
+	// ErrIdempotencyConflict is returned when idempotency key was used for request with other parameters
+	ErrIdempotencyConflict = repository.ErrIdempotencyConflict
+	// ErrExternalRefDuplicate is returned when account already has payment with the same external reference
+	ErrExternalRefDuplicate = repository.ErrExternalRefDuplicate
+)
+
 // Account - wallet account
 type Account struct {
-	ID       AccountID
-	Name     AccountName
-	Balance  float64
-	Currency string
+	ID      AccountID
+	Name    AccountName
+	Balance money.Amount
+	// AvailableBalance - balance without active holds
+	AvailableBalance money.Amount
+	// CreditLimit - overdraft limit, balance of account can go down to -CreditLimit
+	CreditLimit money.Amount
+	Currency    string
+	// System - true for system accounts of ledger. Balance of system account is not stored,
+	// use LedgerBalance for it
+	System bool
+	// Status - active, frozen or closed. Only active account can take part in payments
+	Status string
 
 	// pointer to implementation of model
 	rep repository.Account
 	// database driver
-	dbDriver string
+	db Storage
 }
 
-// Register - Create a new wallet account with zero balance
-func (a *Account) Register(name AccountName) (err error) {
-	err = a.rep.Create(string(name))
+// Register - Create a new wallet account with zero balance in currency
+// if currency is empty DefaultCurrency used
+func (a *Account) Register(ctx context.Context, name AccountName, currency string) (err error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	currency = NormalizeCurrencyCode(currency)
+	if err = ValidateCurrency(currency); err != nil {
+		return
+	}
+	err = a.rep.Create(ctx, string(name), currency)
 	if err == nil {
 		a.load()
 	}
 	return
 }
 
-// Delete - delete wallet account
-func (a *Account) Delete() (err error) {
-	err = a.rep.Delete()
+// Delete - delete wallet account physically
+// payments of account refer to missing account after it, so use Close for accounts in use
+func (a *Account) Delete(ctx context.Context) (err error) {
+	err = a.rep.Delete(ctx)
 	return
 }
 
+// Freeze - suspend account, frozen account can't take part in payments until it is unfrozen
+func (a *Account) Freeze(ctx context.Context) error {
+	return a.setStatus(ctx, AccountStatusFrozen)
+}
+
+// Unfreeze - make frozen account active again
+func (a *Account) Unfreeze(ctx context.Context) error {
+	return a.setStatus(ctx, AccountStatusActive)
+}
+
+// Close - close account forever. Account can be closed only with zero balance and without active holds
+// closed account and its payments are kept, but account can't take part in payments anymore
+func (a *Account) Close(ctx context.Context) error {
+	return a.setStatus(ctx, AccountStatusClosed)
+}
+
+func (a *Account) setStatus(ctx context.Context, status string) (err error) {
+	err = a.rep.SetStatus(ctx, status)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// SetCreditLimit - set overdraft limit of account, zero limit doesn't allow negative balance
+// returns ErrCreditLimitInvalid if limit is negative or its precision is greater than precision of account currency
+func (a *Account) SetCreditLimit(ctx context.Context, limit money.Amount) (err error) {
+	if limit.Sign() < 0 || limit.Cmp(money.MaxAmount) > 0 || limit.Precision() > a.Precision() {
+		return ErrCreditLimitInvalid
+	}
+	err = a.rep.SetCreditLimit(ctx, limit)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// Available - amount which account can spend: balance without active holds plus credit limit
+func (a *Account) Available() (money.Amount, error) {
+	return a.AvailableBalance.Add(a.CreditLimit)
+}
+
 // Validate - validate account for available symbols and length (4-32)
 func (a *Account) Validate(name AccountName) error {
 	re := regexp.MustCompile(`(?i)^[a-z\d]{4,32}$`)
@@ -57,9 +172,34 @@ func (a *Account) Validate(name AccountName) error {
 	return nil
 }
 
+// Precision return count of digits after decimal point allowed for account currency
+func (a *Account) Precision() uint8 {
+	c, err := LookupCurrency(a.Currency)
+	if err != nil {
+		// unknown currency of existing account, allow precision of database
+		return 4
+	}
+	return c.Precision
+}
+
+// ValidateAmount - checking that amount is positive, is not greater than money.MaxAmount
+// and has no more digits after decimal point than account currency allows
+func (a *Account) ValidateAmount(amount money.Amount) error {
+	if amount.Sign() <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if amount.Cmp(money.MaxAmount) > 0 {
+		return fmt.Errorf("amount %s is greater than %s", amount, money.MaxAmount)
+	}
+	if amount.Precision() > a.Precision() {
+		return fmt.Errorf("amount %s has more than %d digits after decimal point", amount, a.Precision())
+	}
+	return nil
+}
+
 // Find find account by name
-func (a *Account) Find(name AccountName) (err error) {
-	err = a.rep.Find(string(name))
+func (a *Account) Find(ctx context.Context, name AccountName) (err error) {
+	err = a.rep.Find(ctx, string(name))
 	if err == nil {
 		a.load()
 	}
@@ -67,27 +207,51 @@ func (a *Account) Find(name AccountName) (err error) {
 }
 
 // Get  account by id
-func (a *Account) Get(id AccountID) (err error) {
-	err = a.rep.Get(int64(id))
+func (a *Account) Get(ctx context.Context, id AccountID) (err error) {
+	err = a.rep.Get(ctx, int64(id))
 	if err == nil {
 		a.load()
 	}
 	return
 }
 
+// LedgerBalance - balance of account derived from ledger entries
+// for user account it is equal to Balance while ledger is consistent
+func (a *Account) LedgerBalance(ctx context.Context) (money.Amount, error) {
+	b, err := a.rep.LedgerBalance(ctx)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	return b.Trim(a.Precision()), nil
+}
+
 // Transfer creating a payment form account "a" to account with id "toID"
+// amount is in currency of account "a". If recipient has another currency amount is converted
+// using rates provider, when rates is nil transfer is allowed only between accounts with the same currency
+// fee of transfer defined by fees is debited from account "a" in addition to amount, nil fees charges no fee
+// meta is saved with payment, its external reference must be unique for account "a"
+// if idem is not nil and the same request was already executed, returning id of original payment and ErrIdempotencyReplay
 // returning id of payment
-func (a *Account) Transfer(toName AccountName, amount float64) (paymentID int64, err error) {
+func (a *Account) Transfer(ctx context.Context, toName AccountName, amount money.Amount, rates ExchangeRateProvider, fees *FeeSchedule, meta Metadata, idem *Idempotency) (paymentID int64, err error) {
 	var to *Account
 
-	if to, err = NewAccount(); err != nil {
+	if to, err = NewAccount(a.db); err != nil {
 		return
 	}
-	if err = to.Find(toName); err != nil {
+	if err = to.Find(ctx, toName); err != nil {
 		return
 	}
 
-	paymentID, err = a.rep.Transfer(int64(to.ID), amount)
+	conv, err := a.conversion(to, amount, rates)
+	if err != nil {
+		return 0, err
+	}
+	fee, err := fees.Fee(a.Currency, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	paymentID, err = a.rep.Transfer(ctx, int64(to.ID), amount, conv, fee, meta, idem)
 	if err == nil {
 		a.load()
 	}
@@ -95,22 +259,63 @@ func (a *Account) Transfer(toName AccountName, amount float64) (paymentID int64,
 	return
 }
 
+// conversion - convert amount to currency of recipient "to"
+// returning nil if currencies are the same
+func (a *Account) conversion(to *Account, amount money.Amount, rates ExchangeRateProvider) (*repository.Conversion, error) {
+	if to.Currency == a.Currency {
+		return nil, nil
+	}
+	toAmount, rate, err := Convert(amount, a.Currency, to.Currency, rates)
+	if err != nil {
+		return nil, err
+	}
+	if rate.Date.IsZero() {
+		rate.Date = time.Now()
+	}
+	return &repository.Conversion{
+		ToAmount: toAmount,
+		Rate:     rate.Rate,
+		RateDate: rate.Date,
+	}, nil
+}
+
 // Deposit - add amount to account balance.
+// meta is saved with payment, its external reference must be unique for account "a"
+// if idem is not nil and the same request was already executed, returning id of original payment and ErrIdempotencyReplay
+// returning id of payment
+func (a *Account) Deposit(ctx context.Context, amount money.Amount, meta Metadata, idem *Idempotency) (paymentID int64, err error) {
+	paymentID, err = a.rep.Deposit(ctx, amount, meta, idem)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// Withdraw - move amount out of wallet to external counterparty
+// if idem is not nil and the same request was already executed, returning id of original payment and ErrIdempotencyReplay
 // returning id of payment
-func (a *Account) Deposit(amount float64) (paymentID int64, err error) {
-	paymentID, err = a.rep.Deposit(amount)
+func (a *Account) Withdraw(ctx context.Context, amount money.Amount, counterparty string, idem *Idempotency) (paymentID int64, err error) {
+	paymentID, err = a.rep.Withdraw(ctx, amount, counterparty, idem)
 	if err == nil {
 		a.load()
 	}
 	return
 }
 
+// FindIdempotent - search payment created by account with idempotency key
+// returning 0 and nil error if key was not used
+// returning id of payment and ErrIdempotencyReplay or ErrIdempotencyConflict if it was
+func (a *Account) FindIdempotent(ctx context.Context, idem *Idempotency) (paymentID int64, err error) {
+	return a.rep.FindIdempotent(ctx, idem)
+}
+
 // List - return list of all wallets account names
 // Wallets listed ordering by id
+// if after > 0, list begins with the next account after account with this id
 // offset and limit are using for set slice bound of list
 // if limit = -1, then no limit
-func (a *Account) List(offset, limit int64) ([]Account, error) {
-	lst, err := a.rep.List(offset, limit)
+func (a *Account) List(ctx context.Context, after AccountID, offset, limit int64) ([]Account, error) {
+	lst, err := a.rep.List(ctx, int64(after), offset, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -118,11 +323,11 @@ func (a *Account) List(offset, limit int64) ([]Account, error) {
 	// Convert result type
 	var res []Account
 	for _, id := range lst {
-		a, err := NewAccount()
+		a, err := NewAccount(a.db)
 		if err != nil {
 			return nil, err
 		}
-		if err = a.Get(AccountID(id)); err != nil {
+		if err = a.Get(ctx, AccountID(id)); err != nil {
 			return nil, err
 		}
 		res = append(res, *a)
@@ -135,21 +340,23 @@ func (a *Account) List(offset, limit int64) ([]Account, error) {
 func (a *Account) load() {
 	a.ID = AccountID(a.rep.ID())
 	a.Name = AccountName(a.rep.Name())
-	a.Balance = a.rep.Balance()
 	a.Currency = a.rep.Currency()
+	a.System = a.rep.System()
+	a.Status = a.rep.Status()
+	a.Balance = a.rep.Balance().Trim(a.Precision())
+	a.AvailableBalance = a.rep.AvailableBalance().Trim(a.Precision())
+	a.CreditLimit = a.rep.CreditLimit().Trim(a.Precision())
 	return
 }
 
 //
-// NewAccount - create new instance of Account
-func NewAccount() (*Account, error) {
-	const dbDriver = "postgresql"
-
-	rep, err := repository.AccountFactory(dbDriver)
-	if err != nil {
-		return nil, err
+// NewAccount - create new instance of Account stored by driver db
+func NewAccount(db Storage) (*Account, error) {
+	if db == nil {
+		return nil, ErrNoDriver
 	}
 	return &Account{
-		rep: rep,
+		rep: db.Account(),
+		db:  db,
 	}, nil
 }
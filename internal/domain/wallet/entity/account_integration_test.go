@@ -8,7 +8,13 @@
 package entity
 
 import (
+	"os"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 func Test_Create(t *testing.T) {
@@ -52,11 +58,11 @@ func Test_Create(t *testing.T) {
 	// create accounts
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := NewAccount()
+			a, err := NewAccount(db)
 			if err != nil {
-				t.Fatal("NewAccount() error: ", err)
+				t.Fatal("NewAccount(db) error: ", err)
 			}
-			err = a.Register(tt.args.name)
+			err = a.Register(ctx, tt.args.name, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Register() error : %v, wantErr %v ", err, tt.wantErr)
 				return
@@ -67,15 +73,15 @@ func Test_Create(t *testing.T) {
 
 	// delete created accounts
 	t.Run("delete existing accounts", func(t *testing.T) {
-		a, err := NewAccount()
+		a, err := NewAccount(db)
 		if err != nil {
-			t.Fatalf("NewAccount() error = %v", err)
+			t.Fatalf("NewAccount(db) error = %v", err)
 		}
-		err = a.Get(tests[0].args.id)
+		err = a.Get(ctx, tests[0].args.id)
 		if err != nil {
 			t.Errorf("Get() error : %v ", err)
 		}
-		err = a.Delete()
+		err = a.Delete(ctx)
 		if err != nil {
 			t.Errorf("Delete() error : %v ", err)
 		}
@@ -84,14 +90,14 @@ func Test_Create(t *testing.T) {
 }
 
 func Test_Deposit(t *testing.T) {
-	a, err := NewAccount()
+	a, err := NewAccount(db)
 	if err != nil {
-		t.Fatalf("NewAccount() error : %v ", err)
+		t.Fatalf("NewAccount(db) error : %v ", err)
 	}
 	const accName = "testacc_76ck76wecoan0vl"
 
 	t.Run("register new account", func(t *testing.T) {
-		err = a.Register(accName)
+		err = a.Register(ctx, accName, "")
 		if err != nil {
 			t.Errorf("Register() error : %v ", err)
 		}
@@ -99,29 +105,29 @@ func Test_Deposit(t *testing.T) {
 
 	var tid int64
 	t.Run("deposit account", func(t *testing.T) {
-		if err = a.Find(accName); err != nil {
+		if err = a.Find(ctx, accName); err != nil {
 			t.Fatal(err)
 		}
 
-		tid, err = a.Deposit(1)
+		tid, err = a.Deposit(ctx, money.New(1, 0), Metadata{}, nil)
 		if err != nil {
 			t.Errorf("Deposit() error : %v ", err)
 		}
 	})
 
 	t.Run("check payment exists", func(t *testing.T) {
-		p, err := NewPayment()
+		p, err := NewPayment(db)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if err = p.Get(ID(tid)); err != nil {
+		if err = p.Get(ctx, ID(tid)); err != nil {
 			t.Errorf("Payment not found: %v", err)
 		}
 	})
 
 	// delete account
 	t.Run("delete account", func(t *testing.T) {
-		err = a.Delete()
+		err = a.Delete(ctx)
 		if err != nil {
 			t.Errorf("Delete() error : %v ", err)
 		}
@@ -130,30 +136,30 @@ func Test_Deposit(t *testing.T) {
 }
 
 func Test_Transfer(t *testing.T) {
-	a1, err := NewAccount()
+	a1, err := NewAccount(db)
 	if err != nil {
-		t.Fatalf("NewAccount() error : %v ", err)
+		t.Fatalf("NewAccount(db) error : %v ", err)
 	}
-	a2, err := NewAccount()
+	a2, err := NewAccount(db)
 	if err != nil {
-		t.Fatalf("NewAccount() error : %v ", err)
+		t.Fatalf("NewAccount(db) error : %v ", err)
 	}
 	const accName1 = "testacc1_76ck76wecoan0vl"
 	const accName2 = "testacc2_76ck76wecoan0vl"
 
 	t.Run("register new account", func(t *testing.T) {
-		err = a1.Register(accName1)
+		err = a1.Register(ctx, accName1, "")
 		if err != nil {
 			t.Fatalf("Register() error : %v ", err)
 		}
-		err = a2.Register(accName2)
+		err = a2.Register(ctx, accName2, "")
 		if err != nil {
 			t.Fatalf("Register() error : %v ", err)
 		}
 	})
 
 	t.Run("deposit account", func(t *testing.T) {
-		_, err = a1.Deposit(10)
+		_, err = a1.Deposit(ctx, money.New(10, 0), Metadata{}, nil)
 		if err != nil {
 			t.Errorf("Deposit() error : %v ", err)
 		}
@@ -162,40 +168,388 @@ func Test_Transfer(t *testing.T) {
 	// transfer
 	var tid int64
 	t.Run("transfer", func(t *testing.T) {
-		tid, err = a1.Transfer(accName2, 5)
+		tid, err = a1.Transfer(ctx, accName2, money.New(5, 0), nil, nil, Metadata{}, nil)
 		if err != nil {
 			t.Errorf("Transfer() error : %v ", err)
 		}
 	})
 
 	t.Run("check payment exists", func(t *testing.T) {
-		p, err := NewPayment()
+		p, err := NewPayment(db)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if err = p.Get(ID(tid)); err != nil {
+		if err = p.Get(ctx, ID(tid)); err != nil {
 			t.Errorf("Payment not found: %v", err)
 		}
 	})
 
 	// check balance
 	t.Run("check new balances", func(t *testing.T) {
-		_ = a2.Get(a2.ID) // reload from db with new values
-		if a1.Balance != 5 || a2.Balance != 5 {
+		_ = a2.Get(ctx, a2.ID) // reload from db with new values
+		if a1.Balance.Cmp(money.New(5, 0)) != 0 || a2.Balance.Cmp(money.New(5, 0)) != 0 {
 			t.Errorf("New balance error: %v, %v", a1.Balance, a2.Balance)
 		}
 	})
 
 	// delete accounts
 	t.Run("delete account", func(t *testing.T) {
-		err = a1.Delete()
+		err = a1.Delete(ctx)
 		if err != nil {
 			t.Errorf("Delete() error : %v ", err)
 		}
-		err = a2.Delete()
+		err = a2.Delete(ctx)
 		if err != nil {
 			t.Errorf("Delete() error : %v ", err)
 		}
 	})
 
 }
+
+// opposing transfers A->B and B->A running together must not deadlock and must not overdraw accounts
+func Test_TransferConcurrent(t *testing.T) {
+	testTransferConcurrent(t, db)
+}
+
+// the same test with PostgreSQL when tests are run with other driver, row locks and retries of transactions
+// are checked only by PostgreSQL. Database is set by PGSQL_TEST_DSN environment, test is skipped without it
+func Test_TransferConcurrentPostgreSQL(t *testing.T) {
+	dsn := os.Getenv("PGSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PGSQL_TEST_DSN is not set")
+	}
+	cfg := repository.DefaultConfig()
+	cfg.Driver, cfg.DSN, cfg.AutoMigrate = "postgresql", dsn, true
+	pg, err := repository.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pg.Close() }()
+	testTransferConcurrent(t, pg)
+}
+
+// testTransferConcurrent - run opposing transfers with storage db
+func testTransferConcurrent(t *testing.T, db Driver) {
+	const (
+		accName1  = "testacc1_concurrent76ck76w"
+		accName2  = "testacc2_concurrent76ck76w"
+		workers   = 8
+		transfers = 25
+	)
+	var (
+		names   = []AccountName{accName1, accName2}
+		initial = money.New(100, 0)
+		step    = money.New(7, 0)
+	)
+
+	t.Run("register and deposit accounts", func(t *testing.T) {
+		for _, n := range names {
+			a, err := NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Register(ctx, n, ""); err != nil {
+				t.Fatalf("Register() error : %v ", err)
+			}
+			if _, err = a.Deposit(ctx, initial, Metadata{}, nil); err != nil {
+				t.Fatalf("Deposit() error : %v ", err)
+			}
+		}
+	})
+
+	t.Run("opposing transfers", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, workers*transfers)
+		for w := 0; w < workers; w++ {
+			from, to := names[w%2], names[(w+1)%2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a, err := NewAccount(db)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if err = a.Find(ctx, from); err != nil {
+					errs <- err
+					return
+				}
+				for i := 0; i < transfers; i++ {
+					// insufficient funds is expected result of some transfers
+					if _, err := a.Transfer(ctx, to, step, nil, nil, Metadata{}, nil); err != nil && err != ErrNoMoney {
+						errs <- err
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Transfer() error : %v ", err)
+		}
+	})
+
+	t.Run("check balances", func(t *testing.T) {
+		total := money.Amount{}
+		for _, n := range names {
+			a, err := NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(ctx, n); err != nil {
+				t.Fatal(err)
+			}
+			if a.Balance.Sign() < 0 {
+				t.Errorf("negative balance of %s: %s", n, a.Balance)
+			}
+			if total, err = total.Add(a.Balance); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := money.New(initial.Units()*2, initial.Scale()); total.Cmp(want) != 0 {
+			t.Errorf("total balance must be %s, got %s", want, total)
+		}
+	})
+
+	t.Run("delete accounts", func(t *testing.T) {
+		for _, n := range names {
+			a, err := NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(ctx, n); err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Delete(ctx); err != nil {
+				t.Errorf("Delete() error : %v ", err)
+			}
+		}
+	})
+}
+
+func Test_Hold(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_hold76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_hold76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	if _, err := a1.Deposit(ctx, money.MustParse("10.00"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBalances := func(balance, available, to string) {
+		t.Helper()
+		_ = a1.Get(ctx, a1.ID)
+		_ = a2.Get(ctx, a2.ID)
+		if a1.Balance.String() != balance || a1.AvailableBalance.String() != available || a2.Balance.String() != to {
+			t.Errorf("balances = %s/%s, %s, want %s/%s, %s",
+				a1.Balance, a1.AvailableBalance, a2.Balance, balance, available, to)
+		}
+	}
+
+	var holdID int64
+	t.Run("hold reduces available balance", func(t *testing.T) {
+		var err error
+		if holdID, err = a1.Hold(ctx, a2.Name, money.MustParse("6.00"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		checkBalances("10.00", "4.00", "0.00")
+		if _, err = a1.Transfer(ctx, a2.Name, money.MustParse("5.00"), nil, nil, Metadata{}, nil); err != ErrNoMoney {
+			t.Errorf("Transfer() error = %v, want ErrNoMoney", err)
+		}
+	})
+	t.Run("capture exceeds hold", func(t *testing.T) {
+		if _, err := a1.Capture(ctx, holdID, money.MustParse("7.00"), nil, nil); err != ErrHoldExceedsAmount {
+			t.Errorf("Capture() error = %v, want ErrHoldExceedsAmount", err)
+		}
+	})
+	t.Run("partial capture", func(t *testing.T) {
+		if _, err := a1.Capture(ctx, holdID, money.MustParse("2.50"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		checkBalances("7.50", "7.50", "2.50")
+		h, err := a1.GetHold(ctx, holdID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Status != HoldStatusCaptured || h.PaymentID == 0 {
+			t.Errorf("hold = %+v", h)
+		}
+		if _, err = a1.Capture(ctx, holdID, money.Amount{}, nil, nil); err != ErrHoldNotActive {
+			t.Errorf("Capture() error = %v, want ErrHoldNotActive", err)
+		}
+	})
+	t.Run("release", func(t *testing.T) {
+		id, err := a1.Hold(ctx, a2.Name, money.MustParse("3.00"), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBalances("7.50", "4.50", "2.50")
+		if err = a1.Release(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		checkBalances("7.50", "7.50", "2.50")
+		if err = a1.Release(ctx, id); err != ErrHoldNotActive {
+			t.Errorf("Release() error = %v, want ErrHoldNotActive", err)
+		}
+		if err = a2.Release(ctx, id); err != ErrHoldNotFound {
+			t.Errorf("Release() error = %v, want ErrHoldNotFound", err)
+		}
+	})
+	t.Run("expired hold", func(t *testing.T) {
+		id, err := a1.Hold(ctx, a2.Name, money.MustParse("1.00"), time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		checkBalances("7.50", "7.50", "2.50")
+		if _, err = ExpireHolds(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+		h, err := a1.GetHold(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Status != HoldStatusExpired {
+			t.Errorf("status = %s, want %s", h.Status, HoldStatusExpired)
+		}
+	})
+}
+
+func Test_AccountStatus(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_status76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_status76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	if a1.Status != AccountStatusActive {
+		t.Errorf("status = %s, want %s", a1.Status, AccountStatusActive)
+	}
+	if _, err := a1.Deposit(ctx, money.MustParse("10.00"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("frozen account", func(t *testing.T) {
+		if err := a1.Freeze(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if a1.Status != AccountStatusFrozen {
+			t.Errorf("status = %s, want %s", a1.Status, AccountStatusFrozen)
+		}
+		if _, err := a1.Deposit(ctx, money.MustParse("1.00"), Metadata{}, nil); err != ErrAccountFrozen {
+			t.Errorf("Deposit() error = %v, want ErrAccountFrozen", err)
+		}
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, Metadata{}, nil); err != ErrAccountFrozen {
+			t.Errorf("Transfer() error = %v, want ErrAccountFrozen", err)
+		}
+		if err := a1.Unfreeze(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, Metadata{}, nil); err != nil {
+			t.Errorf("Transfer() error = %v", err)
+		}
+	})
+	t.Run("recipient frozen", func(t *testing.T) {
+		if err := a2.Freeze(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = a2.Unfreeze(ctx) }()
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, Metadata{}, nil); err != ErrRecipientFrozen {
+			t.Errorf("Transfer() error = %v, want ErrRecipientFrozen", err)
+		}
+	})
+	t.Run("close account with money", func(t *testing.T) {
+		if err := a1.Close(ctx); err != ErrAccountNotEmpty {
+			t.Errorf("Close() error = %v, want ErrAccountNotEmpty", err)
+		}
+	})
+	t.Run("close account", func(t *testing.T) {
+		if _, err := a2.Withdraw(ctx, a2.Balance, "card", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := a2.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if a2.Status != AccountStatusClosed {
+			t.Errorf("status = %s, want %s", a2.Status, AccountStatusClosed)
+		}
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, Metadata{}, nil); err != ErrRecipientClosed {
+			t.Errorf("Transfer() error = %v, want ErrRecipientClosed", err)
+		}
+		if err := a2.Unfreeze(ctx); err != ErrAccountClosed {
+			t.Errorf("Unfreeze() error = %v, want ErrAccountClosed", err)
+		}
+		// closed account is kept and can be found
+		if err := a2.Find(ctx, a2.Name); err != nil {
+			t.Errorf("Find() error = %v", err)
+		}
+	})
+}
+
+func Test_CreditLimit(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_credit3hq8d1v", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_credit3hq8d1v", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	// account without credit limit can't go negative
+	if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, Metadata{}, nil); err != ErrNoMoney {
+		t.Errorf("Transfer() without credit limit error = %v, want ErrNoMoney", err)
+	}
+
+	for _, v := range []string{"-1", "1.001"} {
+		if err := a1.SetCreditLimit(ctx, money.MustParse(v)); err != ErrCreditLimitInvalid {
+			t.Errorf("SetCreditLimit(%s) error = %v, want ErrCreditLimitInvalid", v, err)
+		}
+	}
+	if err := a1.SetCreditLimit(ctx, money.MustParse("50")); err != nil {
+		t.Fatal(err)
+	}
+	if available, err := a1.Available(); err != nil || a1.CreditLimit.Cmp(money.MustParse("50")) != 0 ||
+		available.Cmp(money.MustParse("50")) != 0 {
+		t.Errorf("credit limit = %s, available = %s, %v, want 50", a1.CreditLimit, available, err)
+	}
+
+	if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("30.00"), nil, nil, Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a1.Hold(ctx, a2.Name, money.MustParse("15.00"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := a1.Get(ctx, a1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if available, err := a1.Available(); err != nil || a1.Balance.Cmp(money.MustParse("-30")) != 0 ||
+		available.Cmp(money.MustParse("5")) != 0 {
+		t.Errorf("balance = %s, available = %s, %v, want -30 and 5", a1.Balance, available, err)
+	}
+	if _, err := a1.Withdraw(ctx, money.MustParse("5.01"), "card", nil); err != ErrNoMoney {
+		t.Errorf("Withdraw() over credit limit error = %v, want ErrNoMoney", err)
+	}
+	if _, err := a1.Withdraw(ctx, money.MustParse("5.00"), "card", nil); err != nil {
+		t.Errorf("Withdraw() within credit limit error = %v", err)
+	}
+
+	// account with debt can't be closed
+	if err := a1.Close(ctx); err != ErrAccountNotEmpty {
+		t.Errorf("Close() error = %v, want ErrAccountNotEmpty", err)
+	}
+}
@@ -4,9 +4,43 @@
 package entity
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"testing"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/memory"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/postgresql"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/sqlite"
+)
+
+var (
+	// db - storage used by tests
+	db Driver
+	// ctx - context of requests to storage used by tests
+	ctx = context.Background()
 )
 
+// tests are run with memory driver unless DB_DRIVER environment is set
+// run them with DB_DRIVER=postgresql for testing with database
+func TestMain(m *testing.M) {
+	cfg := repository.ConfigFromEnv()
+	if os.Getenv("DB_DRIVER") == "" {
+		cfg.Driver = "memory"
+	}
+	// test database is created from scratch by migrations
+	cfg.AutoMigrate = true
+	var err error
+	if db, err = repository.Open(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = db.Close()
+	os.Exit(code)
+}
+
 func Test_Validate(t *testing.T) {
 	type args struct {
 		name AccountName
@@ -0,0 +1,117 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultCurrency - currency of account when it was not set at registration
+const DefaultCurrency = "usd"
+
+var (
+	ErrCurrencyUnknown  = errors.New("unknown currency")
+	ErrCurrencyDisabled = errors.New("currency is disabled")
+	ErrCurrencyMismatch = errors.New("accounts have different currencies")
+)
+
+// Currency - currency which can be used for wallet accounts
+type Currency struct {
+	// Code - ISO-4217 code of currency in lower case
+	Code string
+	// Precision - count of digits after decimal point (minor unit)
+	Precision uint8
+	// Enabled - new accounts can be registered only in enabled currencies
+	Enabled bool
+}
+
+// registry of known currencies
+var currencies = struct {
+	sync.RWMutex
+	list map[string]Currency
+}{
+	list: map[string]Currency{
+		"usd": {Code: "usd", Precision: 2, Enabled: true},
+		"eur": {Code: "eur", Precision: 2, Enabled: true},
+		"gbp": {Code: "gbp", Precision: 2, Enabled: true},
+		"chf": {Code: "chf", Precision: 2, Enabled: true},
+		"rub": {Code: "rub", Precision: 2, Enabled: true},
+		"jpy": {Code: "jpy", Precision: 0, Enabled: true},
+		"kwd": {Code: "kwd", Precision: 3, Enabled: false},
+	},
+}
+
+// NormalizeCurrencyCode - bring currency code to the form used in registry and database
+func NormalizeCurrencyCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// LookupCurrency - find currency in registry by ISO-4217 code (case insensitive)
+// returns ErrCurrencyUnknown if currency is not registered
+func LookupCurrency(code string) (Currency, error) {
+	currencies.RLock()
+	defer currencies.RUnlock()
+
+	c, ok := currencies.list[NormalizeCurrencyCode(code)]
+	if !ok {
+		return Currency{}, ErrCurrencyUnknown
+	}
+	return c, nil
+}
+
+// ValidateCurrency - checking that currency is registered and enabled
+func ValidateCurrency(code string) error {
+	c, err := LookupCurrency(code)
+	if err != nil {
+		return err
+	}
+	if !c.Enabled {
+		return ErrCurrencyDisabled
+	}
+	return nil
+}
+
+// RegisterCurrency - add currency to registry or replace existing one
+func RegisterCurrency(c Currency) error {
+	c.Code = NormalizeCurrencyCode(c.Code)
+	if len(c.Code) != 3 {
+		return errors.New("currency code must contain 3 letters")
+	}
+
+	currencies.Lock()
+	defer currencies.Unlock()
+	currencies.list[c.Code] = c
+	return nil
+}
+
+// SetCurrencyEnabled - enable or disable registered currency
+func SetCurrencyEnabled(code string, enabled bool) error {
+	currencies.Lock()
+	defer currencies.Unlock()
+
+	code = NormalizeCurrencyCode(code)
+	c, ok := currencies.list[code]
+	if !ok {
+		return ErrCurrencyUnknown
+	}
+	c.Enabled = enabled
+	currencies.list[code] = c
+	return nil
+}
+
+// Currencies - return list of all registered currencies ordering by code
+func Currencies() []Currency {
+	currencies.RLock()
+	defer currencies.RUnlock()
+
+	res := make([]Currency, 0, len(currencies.list))
+	for _, c := range currencies.list {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Code < res[j].Code })
+	return res
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_LookupCurrency(t *testing.T) {
+	c, err := LookupCurrency("USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Code != "usd" || c.Precision != 2 {
+		t.Errorf("LookupCurrency() = %+v", c)
+	}
+	if _, err = LookupCurrency("xxx"); err != ErrCurrencyUnknown {
+		t.Errorf("LookupCurrency() error = %v, want %v", err, ErrCurrencyUnknown)
+	}
+	if err = ValidateCurrency("kwd"); err != ErrCurrencyDisabled {
+		t.Errorf("ValidateCurrency() error = %v, want %v", err, ErrCurrencyDisabled)
+	}
+}
+
+func Test_ValidateAmount(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+		wantErr  bool
+	}{
+		{"usd", "10.25", false},
+		{"usd", "10.2500", false},
+		{"usd", "10.255", true},
+		{"usd", "0", true},
+		{"usd", "-1", true},
+		{"jpy", "100", false},
+		{"jpy", "100.5", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			a := Account{Currency: tt.currency}
+			err := a.ValidateAmount(money.MustParse(tt.amount))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
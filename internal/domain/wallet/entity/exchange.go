@@ -0,0 +1,73 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// RateScale - maximal count of digits after decimal point of exchange rate
+// rates are saved with payments with this scale, so rate is rounded to it before conversion
+// and amount of payment is always converted by the saved rate
+const RateScale = 10
+
+var (
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+	ErrConvertedAmountZero  = errors.New("converted amount is zero")
+)
+
+// ExchangeRate - rate of conversion from currency From to currency To
+// amount in To = amount in From * Rate
+type ExchangeRate struct {
+	From string
+	To   string
+	Rate money.Amount
+	// Date - time when rate was fixed by source
+	Date time.Time
+}
+
+// ExchangeRateProvider - source of exchange rates used for cross-currency transfers
+// implementations are defined in package exchange
+type ExchangeRateProvider interface {
+	// Rate return rate for conversion "from" currency to "to" currency
+	// returns ErrExchangeRateNotFound if there is no rate for this pair
+	Rate(from, to string) (ExchangeRate, error)
+}
+
+// Convert - convert amount from currency "from" to currency "to" using rates provider
+// rate is rounded to RateScale, result rounded to precision of "to" currency
+func Convert(amount money.Amount, from, to string, rates ExchangeRateProvider) (money.Amount, ExchangeRate, error) {
+	from, to = NormalizeCurrencyCode(from), NormalizeCurrencyCode(to)
+	if from == to {
+		return amount, ExchangeRate{From: from, To: to, Rate: money.New(1, 0)}, nil
+	}
+	if rates == nil {
+		return money.Amount{}, ExchangeRate{}, ErrCurrencyMismatch
+	}
+
+	c, err := LookupCurrency(to)
+	if err != nil {
+		return money.Amount{}, ExchangeRate{}, err
+	}
+	rate, err := rates.Rate(from, to)
+	if err != nil {
+		return money.Amount{}, ExchangeRate{}, err
+	}
+	if rate.Rate.Scale() > RateScale {
+		if rate.Rate, err = rate.Rate.MulRound(money.New(1, 0), RateScale); err != nil {
+			return money.Amount{}, ExchangeRate{}, err
+		}
+	}
+	converted, err := amount.MulRound(rate.Rate, c.Precision)
+	if err != nil {
+		return money.Amount{}, ExchangeRate{}, err
+	}
+	if converted.Sign() <= 0 {
+		return money.Amount{}, ExchangeRate{}, ErrConvertedAmountZero
+	}
+	return converted, rate, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// rateStub - provider with one rate for any pair of currencies
+type rateStub struct {
+	rate ExchangeRate
+}
+
+func (r rateStub) Rate(from, to string) (ExchangeRate, error) {
+	return r.rate, nil
+}
+
+func Test_Convert(t *testing.T) {
+	tests := []struct {
+		amount, rate         string
+		wantAmount, wantRate string
+	}{
+		{"100", "0.8187", "81.87", "0.8187"},
+		// rate is rounded to scale of payments, amount is converted by rounded rate
+		{"10000000000", "1.00000000004", "10000000000.00", "1.0000000000"},
+		{"10000000000", "1.00000000005", "10000000001.00", "1.0000000001"},
+		{"10", "0.123456789012345678", "1.23", "0.1234567890"},
+	}
+	for _, tt := range tests {
+		amount, rate, err := Convert(money.MustParse(tt.amount), "usd", "eur",
+			rateStub{ExchangeRate{From: "usd", To: "eur", Rate: money.MustParse(tt.rate)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amount.String() != tt.wantAmount || rate.Rate.String() != tt.wantRate {
+			t.Errorf("Convert(%s, %s) = %s, %s, want %s, %s", tt.amount, tt.rate, amount, rate.Rate, tt.wantAmount, tt.wantRate)
+		}
+	}
+
+	if _, _, err := Convert(money.MustParse("1"), "usd", "eur", rateStub{ExchangeRate{Rate: money.MustParse("0.00000000001")}}); err != ErrConvertedAmountZero {
+		t.Errorf("Convert() with rate rounded to zero error = %v, want %v", err, ErrConvertedAmountZero)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+var ErrFeeRuleInvalid = errors.New("invalid fee rule")
+
+// FeeRule - rule of fee charged for transfer from account in currency Currency
+// fee = Flat + Percent% of amount, but not less than Min and not greater than Max
+// rule with empty Currency is used for currencies which have no own rule
+type FeeRule struct {
+	Currency string       `json:"currency"`
+	Flat     money.Amount `json:"flat"`
+	Percent  money.Amount `json:"percent"`
+	Min      money.Amount `json:"min"`
+	// Max - cap of fee, zero means fee is not limited
+	Max money.Amount `json:"max"`
+}
+
+// FeeSchedule - rules of transfer fees by currency of payer
+// nil schedule charges no fees
+type FeeSchedule struct {
+	rules map[string]FeeRule
+}
+
+// NewFeeSchedule - create schedule from rules
+// returns ErrFeeRuleInvalid if rule has negative values, Max less than Min, unknown currency
+// or currency has more than one rule
+func NewFeeSchedule(rules []FeeRule) (*FeeSchedule, error) {
+	f := &FeeSchedule{rules: make(map[string]FeeRule, len(rules))}
+	for _, r := range rules {
+		r.Currency = NormalizeCurrencyCode(r.Currency)
+		if r.Currency != "" {
+			if _, err := LookupCurrency(r.Currency); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrFeeRuleInvalid, r.Currency, err)
+			}
+		}
+		if r.Flat.Sign() < 0 || r.Percent.Sign() < 0 || r.Min.Sign() < 0 || r.Max.Sign() < 0 {
+			return nil, fmt.Errorf("%w: %q: negative value", ErrFeeRuleInvalid, r.Currency)
+		}
+		if !r.Max.IsZero() && r.Max.Cmp(r.Min) < 0 {
+			return nil, fmt.Errorf("%w: %q: max is less than min", ErrFeeRuleInvalid, r.Currency)
+		}
+		if _, ok := f.rules[r.Currency]; ok {
+			return nil, fmt.Errorf("%w: %q: duplicate currency", ErrFeeRuleInvalid, r.Currency)
+		}
+		f.rules[r.Currency] = r
+	}
+	return f, nil
+}
+
+// Fee - fee of transfer of amount from account in currency
+// result is rounded to precision of currency, zero if there is no rule for currency
+func (f *FeeSchedule) Fee(currency string, amount money.Amount) (money.Amount, error) {
+	if f == nil {
+		return money.Amount{}, nil
+	}
+	currency = NormalizeCurrencyCode(currency)
+	r, ok := f.rules[currency]
+	if !ok {
+		if r, ok = f.rules[""]; !ok {
+			return money.Amount{}, nil
+		}
+	}
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return money.Amount{}, err
+	}
+
+	// Percent/100 is the same digits with scale greater by 2
+	fee, err := amount.MulRound(money.New(r.Percent.Units(), r.Percent.Scale()+2), c.Precision)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	if fee, err = fee.Add(r.Flat); err != nil {
+		return money.Amount{}, err
+	}
+	if fee.Cmp(r.Min) < 0 {
+		fee = r.Min
+	}
+	if !r.Max.IsZero() && fee.Cmp(r.Max) > 0 {
+		fee = r.Max
+	}
+	return fee.MulRound(money.New(1, 0), c.Precision)
+}
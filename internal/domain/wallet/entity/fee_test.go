@@ -0,0 +1,71 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_FeeSchedule_Fee(t *testing.T) {
+	fees, err := NewFeeSchedule([]FeeRule{
+		{Flat: money.MustParse("0.30"), Percent: money.MustParse("1.5")},
+		{Currency: "EUR", Percent: money.MustParse("2"), Min: money.MustParse("1"), Max: money.MustParse("5")},
+		{Currency: "jpy", Percent: money.MustParse("0.5")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		currency string
+		amount   string
+		want     string
+	}{
+		{"usd", "10", "0.45"},
+		{"usd", "0.01", "0.30"},
+		{"usd", "10.33", "0.45"},
+		{"eur", "10", "1"},
+		{"eur", "100", "2"},
+		{"eur", "1000", "5"},
+		{"jpy", "1234", "6"},
+		{"jpy", "100", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			got, err := fees.Fee(tt.currency, money.MustParse(tt.amount))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Cmp(money.MustParse(tt.want)) != 0 {
+				t.Errorf("Fee() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	var none *FeeSchedule
+	if got, err := none.Fee("usd", money.MustParse("10")); err != nil || !got.IsZero() {
+		t.Errorf("Fee() of nil schedule = %s, %v", got, err)
+	}
+}
+
+func Test_NewFeeSchedule(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []FeeRule
+	}{
+		{"negative", []FeeRule{{Flat: money.MustParse("-1")}}},
+		{"max less than min", []FeeRule{{Min: money.MustParse("2"), Max: money.MustParse("1")}}},
+		{"unknown currency", []FeeRule{{Currency: "xxx"}}},
+		{"duplicate", []FeeRule{{Currency: "usd"}, {Currency: "USD"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFeeSchedule(tt.rules); !errors.Is(err, ErrFeeRuleInvalid) {
+				t.Errorf("NewFeeSchedule() error = %v, want %v", err, ErrFeeRuleInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// Hold - amount of account reserved for transfer to another account
+// active hold reduces available balance of account, money is moved only when hold is captured
+type Hold = repository.Hold
+
+// statuses of hold
+const (
+	HoldStatusActive   = repository.HoldStatusActive
+	HoldStatusCaptured = repository.HoldStatusCaptured
+	HoldStatusReleased = repository.HoldStatusReleased
+	HoldStatusExpired  = repository.HoldStatusExpired
+)
+
+var (
+	// ErrHoldNotFound is returned when hold of account not exists
+	ErrHoldNotFound = repository.ErrHoldNotFound
+	// ErrHoldNotActive is returned when hold was already captured, released or expired
+	ErrHoldNotActive = repository.ErrHoldNotActive
+	// ErrHoldExceedsAmount is returned when captured amount is greater than amount of hold
+	ErrHoldExceedsAmount = repository.ErrHoldExceedsAmount
+)
+
+// Hold - reserve amount of account "a" for transfer to account with name "toName" for ttl
+// after ttl hold is expired and reserved amount becomes available again
+// returning id of hold
+func (a *Account) Hold(ctx context.Context, toName AccountName, amount money.Amount, ttl time.Duration) (holdID int64, err error) {
+	var to *Account
+
+	if to, err = NewAccount(a.db); err != nil {
+		return
+	}
+	if err = to.Find(ctx, toName); err != nil {
+		return
+	}
+
+	holdID, err = a.rep.Hold(ctx, int64(to.ID), amount, ttl)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// GetHold - return hold of account "a" by id
+func (a *Account) GetHold(ctx context.Context, holdID int64) (Hold, error) {
+	return a.rep.GetHold(ctx, holdID)
+}
+
+// Capture - turn hold into transfer to recipient of hold
+// amount is in currency of account "a", if it is zero the whole amount of hold is transferred,
+// else the rest of hold is released. If recipient has another currency amount is converted using rates provider
+// fee of captured amount by fees schedule is charged the same as for transfer, it is not reserved by hold
+// returning id of payment
+func (a *Account) Capture(ctx context.Context, holdID int64, amount money.Amount, rates ExchangeRateProvider, fees *FeeSchedule) (paymentID int64, err error) {
+	h, err := a.rep.GetHold(ctx, holdID)
+	if err != nil {
+		return 0, err
+	}
+	if h.Status != HoldStatusActive {
+		return 0, ErrHoldNotActive
+	}
+	if amount.IsZero() {
+		amount = h.Amount
+	}
+	if amount.Cmp(h.Amount) > 0 {
+		return 0, ErrHoldExceedsAmount
+	}
+
+	to, err := NewAccount(a.db)
+	if err != nil {
+		return 0, err
+	}
+	if err = to.Get(ctx, AccountID(h.ToID)); err != nil {
+		return 0, ErrRecipientNotFound
+	}
+	conv, err := a.conversion(to, amount, rates)
+	if err != nil {
+		return 0, err
+	}
+	fee, err := fees.Fee(a.Currency, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	paymentID, err = a.rep.Capture(ctx, holdID, amount, conv, fee)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// Release - release active hold, reserved amount becomes available again
+func (a *Account) Release(ctx context.Context, holdID int64) (err error) {
+	err = a.rep.Release(ctx, holdID)
+	if err == nil {
+		a.load()
+	}
+	return
+}
+
+// ExpireHolds - set status expired for all holds with expired ttl
+// expired hold don't reduce available balance even before this function is called, it only fixes status of holds
+// returning count of expired holds
+func ExpireHolds(ctx context.Context, db Storage) (int64, error) {
+	a, err := NewAccount(db)
+	if err != nil {
+		return 0, err
+	}
+	return a.rep.ExpireHolds(ctx)
+}
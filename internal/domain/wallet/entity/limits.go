@@ -0,0 +1,82 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// Limits - spending limits of account, zero value of limit means the limit is not set
+type Limits = repository.Limits
+
+// Spending - total amount and count of outgoing transfers and withdrawals of account
+type Spending = repository.Spending
+
+var (
+	// ErrLimitsInvalid is returned when limit is negative or has more digits after decimal point than currency allows
+	ErrLimitsInvalid = errors.New("invalid limits value")
+	// ErrTransferLimitExceeded is returned when amount of transfer is greater than limit of one transfer
+	ErrTransferLimitExceeded = repository.ErrTransferLimitExceeded
+	// ErrDailyAmountExceeded is returned when transfer makes total amount of transfers of day greater than limit
+	ErrDailyAmountExceeded = repository.ErrDailyAmountExceeded
+	// ErrDailyCountExceeded is returned when count of transfers of day reached limit
+	ErrDailyCountExceeded = repository.ErrDailyCountExceeded
+)
+
+// LimitsDay - start of day of daily limits for time now, days of limits are days of UTC
+func LimitsDay(now time.Time) time.Time {
+	return repository.LimitsDay(now)
+}
+
+// Limits - return spending limits of account "a"
+func (a *Account) Limits(ctx context.Context) (Limits, error) {
+	return a.rep.Limits(ctx)
+}
+
+// SetLimits - set spending limits of account "a"
+// returns ErrLimitsInvalid if limit is negative or its precision is greater than precision of account currency
+func (a *Account) SetLimits(ctx context.Context, l Limits) error {
+	for _, v := range []money.Amount{l.MaxAmount, l.DailyAmount} {
+		if v.Sign() < 0 || v.Precision() > a.Precision() {
+			return ErrLimitsInvalid
+		}
+	}
+	if l.DailyCount < 0 {
+		return ErrLimitsInvalid
+	}
+	return a.rep.SetLimits(ctx, l)
+}
+
+// Spending - return total amount and count of outgoing transfers and withdrawals of account "a" since time
+func (a *Account) Spending(ctx context.Context, since time.Time) (Spending, error) {
+	return a.rep.Spending(ctx, since)
+}
+
+// CheckLimits - check that transfer of amount from account "a" at time now doesn't exceed its limits
+// pending is spending not saved yet, like previous transfers of batch
+// returns ErrTransferLimitExceeded, ErrDailyAmountExceeded or ErrDailyCountExceeded
+// payments are checked by repository again under lock of account, this check rejects them before any changes
+func (a *Account) CheckLimits(ctx context.Context, amount money.Amount, pending Spending, now time.Time) error {
+	l, err := a.Limits(ctx)
+	if err != nil {
+		return err
+	}
+	s := pending
+	if l.Daily() {
+		spent, err := a.Spending(ctx, LimitsDay(now))
+		if err != nil {
+			return err
+		}
+		if s.Amount, err = spent.Amount.Add(pending.Amount); err != nil {
+			return err
+		}
+		s.Count += spent.Count
+	}
+	return l.Check(s, amount)
+}
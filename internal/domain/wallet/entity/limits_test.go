@@ -0,0 +1,73 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_CheckLimits(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_limits9zk1q", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_limits9zk1q", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+	if _, err := a1.Deposit(ctx, money.MustParse("100"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	// account without limits
+	if err := a1.CheckLimits(ctx, money.MustParse("100"), Spending{}, now); err != nil {
+		t.Errorf("CheckLimits() without limits error = %v", err)
+	}
+
+	if err := a1.SetLimits(ctx, Limits{MaxAmount: money.MustParse("-1")}); err != ErrLimitsInvalid {
+		t.Errorf("SetLimits() with negative limit error = %v, want %v", err, ErrLimitsInvalid)
+	}
+	if err := a1.SetLimits(ctx, Limits{DailyAmount: money.MustParse("1.001")}); err != ErrLimitsInvalid {
+		t.Errorf("SetLimits() with wrong precision error = %v, want %v", err, ErrLimitsInvalid)
+	}
+	l := Limits{MaxAmount: money.MustParse("10"), DailyAmount: money.MustParse("25"), DailyCount: 3}
+	if err := a1.SetLimits(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("10"), nil, nil, Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s, err := a1.Spending(ctx, LimitsDay(now)); err != nil || s.Count != 2 || s.Amount.Cmp(money.MustParse("20")) != 0 {
+		t.Errorf("Spending() = %+v, %v, want 2 transfers of 20", s, err)
+	}
+
+	tests := []struct {
+		name    string
+		amount  string
+		pending Spending
+		now     time.Time
+		want    error
+	}{
+		{"within limits", "5", Spending{}, now, nil},
+		{"single transfer", "10.01", Spending{}, now, ErrTransferLimitExceeded},
+		{"daily amount", "5.01", Spending{}, now, ErrDailyAmountExceeded},
+		{"daily count", "1", Spending{Amount: money.MustParse("1"), Count: 1}, now, ErrDailyCountExceeded},
+		{"next day", "10", Spending{}, now.Add(24 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a1.CheckLimits(ctx, money.MustParse(tt.amount), tt.pending, tt.now); err != tt.want {
+				t.Errorf("CheckLimits() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
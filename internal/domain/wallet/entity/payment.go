@@ -1,74 +1,190 @@
 package entity
 
 import (
+	"context"
 	"time"
 
 	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 // ID identifier of payment. Integer value
 type ID int64
 
+// LedgerEntry - one leg of ledger transaction
+// positive amount is credit of account, negative amount is debit
+type LedgerEntry = repository.LedgerEntry
+
+// PaymentFilter - conditions of payments list of account
+// zero value of field means condition is not set, amounts are in account currency
+type PaymentFilter = repository.PaymentFilter
+
+// directions of payments relative to account, used by PaymentFilter
+const (
+	PaymentDirectionIncoming   = repository.PaymentDirectionIncoming
+	PaymentDirectionOutgoing   = repository.PaymentDirectionOutgoing
+	PaymentDirectionDeposit    = repository.PaymentDirectionDeposit
+	PaymentDirectionWithdrawal = repository.PaymentDirectionWithdrawal
+)
+
+// kinds of payments
+const (
+	PaymentKindDeposit    = repository.PaymentKindDeposit
+	PaymentKindTransfer   = repository.PaymentKindTransfer
+	PaymentKindWithdrawal = repository.PaymentKindWithdrawal
+	PaymentKindReversal   = repository.PaymentKindReversal
+)
+
+var (
+	// ErrLedgerUnbalanced is returned when entries of transaction don't sum to zero
+	ErrLedgerUnbalanced = repository.ErrLedgerUnbalanced
+	// ErrReversalNotAllowed is returned when payment can't be reversed
+	ErrReversalNotAllowed = repository.ErrReversalNotAllowed
+	// ErrReversalExceedsAmount is returned when total amount of reversals is greater than amount of payment
+	ErrReversalExceedsAmount = repository.ErrReversalExceedsAmount
+	// ErrAccountNotFound is returned when account of payment not exists
+	ErrAccountNotFound = repository.ErrAccountNotFound
+	// ErrPaymentNotFound is returned when payment with external reference not exists
+	ErrPaymentNotFound = repository.ErrPaymentNotFound
+)
+
 // Payment - contain information about payment (transaction)
+// payment is header of double-entry ledger transaction. FromID and ToID are accounts of
+// the first debit and the last credit entry, for deposit FromID and for withdrawal ToID is cash system account
 type Payment struct {
 	ID     ID
+	Kind   string
 	Date   time.Time
-	Amount float64
+	Amount money.Amount
 	FromID int64
 	ToID   int64
 
+	// ToAmount - amount credited to recipient in recipient currency
+	ToAmount money.Amount
+	// Rate - exchange rate applied to cross-currency transfer, 1 for other payments
+	Rate money.Amount
+	// RateDate - time of exchange rate, zero if no conversion was applied
+	RateDate time.Time
+	// ToBalance - recipient balance after payment
+	ToBalance money.Amount
+	// Counterparty - reference to external destination of withdrawal
+	Counterparty string
+	// ReversalOf - id of payment compensated by reversal, 0 for other payments
+	ReversalOf ID
+	// Fee - fee of transfer debited from payer in addition to Amount
+	Fee money.Amount
+	// Description, ExternalRef and Tags - client supplied metadata of deposit or transfer
+	Description string
+	ExternalRef string
+	Tags        map[string]string
+
 	// pointer to implementation of model
 	rep repository.Payment
+	// database driver
+	db Storage
 }
 
 func (a *Payment) load() {
 	a.ID = ID(a.rep.ID())
+	a.Kind = a.rep.Kind()
 	a.Amount = a.rep.Amount()
 	a.Date = a.rep.Date()
 	a.FromID = a.rep.From()
 	a.ToID = a.rep.To()
+	a.ToAmount = a.rep.ToAmount()
+	a.Rate = a.rep.Rate().Trim(0)
+	a.RateDate = a.rep.RateDate()
+	a.ToBalance = a.rep.ToBalance()
+	a.Counterparty = a.rep.Counterparty()
+	a.ReversalOf = ID(a.rep.ReversalOf())
+	a.Fee = a.rep.Fee()
+	meta := a.rep.Metadata()
+	a.Description, a.ExternalRef, a.Tags = meta.Description, meta.ExternalRef, meta.Tags
 }
 
 // Get  account by id
-func (a *Payment) Get(id ID) (err error) {
-	err = a.rep.Get(int64(id))
+func (a *Payment) Get(ctx context.Context, id ID) (err error) {
+	err = a.rep.Get(ctx, int64(id))
 	if err == nil {
 		a.load()
 	}
 	return
 }
 
-//
-// NewPayment - create new instance of Payment
-func NewPayment() (*Payment, error) {
-	const dbDriver = "postgresql"
+// FindByExternalRef - get payment created by account with external reference ref
+// payment is created by payer of transfer and by recipient of deposit
+// returns ErrPaymentNotFound if account has no payment with this reference
+func (a *Payment) FindByExternalRef(ctx context.Context, account *Account, ref string) (err error) {
+	err = a.rep.FindByExternalRef(ctx, int64(account.ID), ref)
+	if err == nil {
+		a.load()
+	}
+	return
+}
 
-	rep, err := repository.PaymentFactory(dbDriver)
-	if err != nil {
-		return nil, err
+// Entries - return ledger entries of payment
+func (a *Payment) Entries(ctx context.Context) ([]LedgerEntry, error) {
+	return a.rep.Entries(ctx)
+}
+
+// Reverse - create compensating payment which returns money from recipient back to payer of payment
+// amount is in payer currency, if it is zero the whole not reversed rest of payment is returned
+// for cross-currency transfer amount taken from recipient is converted by rate of original payment
+// recipient balance must be sufficient unless force is set
+// returning id of reversal payment
+func (a *Payment) Reverse(ctx context.Context, amount money.Amount, force bool) (paymentID int64, err error) {
+	if a.Kind == PaymentKindReversal {
+		return 0, ErrReversalNotAllowed
+	}
+	toAmount := amount
+	if !amount.IsZero() && !a.RateDate.IsZero() {
+		to, err := NewAccount(a.db)
+		if err != nil {
+			return 0, err
+		}
+		if err = to.Get(ctx, AccountID(a.ToID)); err != nil {
+			return 0, ErrAccountNotFound
+		}
+		if toAmount, err = amount.MulRound(a.Rate, to.Precision()); err != nil {
+			return 0, err
+		}
+		if toAmount.Sign() <= 0 {
+			return 0, ErrConvertedAmountZero
+		}
+	}
+	return a.rep.Reverse(ctx, amount, toAmount, force)
+}
+
+//
+// NewPayment - create new instance of Payment stored by driver db
+func NewPayment(db Storage) (*Payment, error) {
+	if db == nil {
+		return nil, ErrNoDriver
 	}
 	return &Payment{
-		rep: rep,
+		rep: db.Payment(),
+		db:  db,
 	}, nil
 }
 
 // PaymentsList - return list of all payments.
-// payments listed ordering by id
+// payments listed ordering by id descending
+// if after > 0, list begins with the next payment after payment with this id
 // offset and limit are using for set slice bound of list
 // if limit = -1, then no limit
-// if account is nil returning list of all accounts
-func PaymentsList(account *Account, offset, limit int64) ([]Payment, error) {
-	p, err := NewPayment()
+// if account is nil returning list of all accounts, else list of payments of account which meet conditions of filter
+func PaymentsList(ctx context.Context, db Storage, account *Account, filter PaymentFilter, after ID, offset, limit int64) ([]Payment, error) {
+	p, err := NewPayment(db)
 	if err != nil {
 		return nil, err
 	}
 	var lst []interface{}
 	if account == nil {
-		if lst, err = p.rep.ListAll(offset, limit); err != nil {
+		if lst, err = p.rep.ListAll(ctx, int64(after), offset, limit); err != nil {
 			return nil, err
 		}
 	} else {
-		if lst, err = p.rep.List(int64(account.ID), offset, limit); err != nil {
+		if lst, err = p.rep.List(ctx, int64(account.ID), filter, int64(after), offset, limit); err != nil {
 			return nil, err
 		}
 	}
@@ -76,9 +192,27 @@ func PaymentsList(account *Account, offset, limit int64) ([]Payment, error) {
 	var res []Payment
 	for _, n := range lst {
 		rp := n.(repository.Payment)
-		p := Payment{rep: rp} // it is able don't call NewPayment() because it was called early at begin of function so  driver was initialised
+		p := Payment{rep: rp, db: db}
 		p.load()
 		res = append(res, p)
 	}
 	return res, nil
 }
+
+// VerifyLedger - check invariant of double-entry ledger
+// returning ids of payments which entries don't sum to zero in some currency, empty list if ledger is consistent
+func VerifyLedger(ctx context.Context, db Storage) ([]ID, error) {
+	p, err := NewPayment(db)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := p.rep.Unbalanced(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []ID
+	for _, id := range ids {
+		res = append(res, ID(id))
+	}
+	return res, nil
+}
@@ -5,6 +5,9 @@ package entity
 
 import (
 	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 func Test_List(t *testing.T) {
@@ -14,25 +17,302 @@ func Test_List(t *testing.T) {
 	)
 
 	t.Run("get all payments", func(t *testing.T) {
-		lst, err = PaymentsList(nil, 0, -1)
+		lst, err = PaymentsList(ctx, db, nil, PaymentFilter{}, 0, 0, -1)
 		if err != nil {
 			t.Fatal(err)
 		}
 	})
 	t.Run("get account payments", func(t *testing.T) {
-		ac, err := NewAccount()
+		ac, err := NewAccount(db)
 		if err != nil {
 			t.Fatal(err)
 		}
-		_ = ac.Register("random_76ck76wecoan0vl12")
-		_, _ = ac.Deposit(1)
-		lst, err = PaymentsList(ac, 0, -1)
+		_ = ac.Register(ctx, "random_76ck76wecoan0vl12", "")
+		_, _ = ac.Deposit(ctx, money.New(1, 0), Metadata{}, nil)
+		lst, err = PaymentsList(ctx, db, ac, PaymentFilter{}, 0, 0, -1)
 		if len(lst) != 1 {
 			t.Errorf("wait 1 row got: %d", len(lst))
 		}
-		_ = ac.Delete()
+		_ = ac.Delete(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_Ledger(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_ledger76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_ledger76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	if _, err := a1.Deposit(ctx, money.MustParse("10.00"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	id, err := a1.Transfer(ctx, a2.Name, money.MustParse("3.50"), nil, nil, Metadata{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("transfer entries", func(t *testing.T) {
+		p, _ := NewPayment(db)
+		if err := p.Get(ctx, ID(id)); err != nil {
+			t.Fatal(err)
+		}
+		if p.Kind != PaymentKindTransfer {
+			t.Errorf("kind = %s, want %s", p.Kind, PaymentKindTransfer)
+		}
+		entries, err := p.Entries(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("wait 2 entries got: %d", len(entries))
+		}
+		if entries[0].AccountID != int64(a1.ID) || entries[0].Amount.Cmp(money.MustParse("-3.5")) != 0 {
+			t.Errorf("debit entry = %+v", entries[0])
+		}
+		if entries[1].AccountID != int64(a2.ID) || entries[1].Amount.Cmp(money.MustParse("3.5")) != 0 {
+			t.Errorf("credit entry = %+v", entries[1])
+		}
+	})
+
+	t.Run("balances derived from ledger", func(t *testing.T) {
+		for _, a := range []*Account{a1, a2} {
+			_ = a.Get(ctx, a.ID)
+			b, err := a.LedgerBalance(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Cmp(a.Balance) != 0 {
+				t.Errorf("%s ledger balance = %s, balance = %s", a.Name, b, a.Balance)
+			}
+		}
+	})
+
+	t.Run("ledger is balanced", func(t *testing.T) {
+		ids, err := VerifyLedger(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 0 {
+			t.Errorf("unbalanced payments: %v", ids)
+		}
+	})
+}
+
+func Test_Reverse(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_reverse76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_reverse76ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	if _, err := a1.Deposit(ctx, money.MustParse("10.00"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	id, err := a1.Transfer(ctx, a2.Name, money.MustParse("4.00"), nil, nil, Metadata{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := NewPayment(db)
+	if err := p.Get(ctx, ID(id)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBalances := func(want1, want2 string) {
+		t.Helper()
+		_ = a1.Get(ctx, a1.ID)
+		_ = a2.Get(ctx, a2.ID)
+		if a1.Balance.String() != want1 || a2.Balance.String() != want2 {
+			t.Errorf("balances = %s, %s, want %s, %s", a1.Balance, a2.Balance, want1, want2)
+		}
+	}
+
+	t.Run("partial reversal", func(t *testing.T) {
+		rid, err := p.Reverse(ctx, money.MustParse("1.50"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := NewPayment(db)
+		if err := r.Get(ctx, ID(rid)); err != nil {
+			t.Fatal(err)
+		}
+		if r.Kind != PaymentKindReversal || r.ReversalOf != p.ID || r.FromID != int64(a2.ID) || r.ToID != int64(a1.ID) {
+			t.Errorf("reversal = %+v", r)
+		}
+		checkBalances("7.50", "2.50")
+	})
+	t.Run("reversal exceeds rest", func(t *testing.T) {
+		if _, err := p.Reverse(ctx, money.MustParse("3.00"), false); err != ErrReversalExceedsAmount {
+			t.Errorf("Reverse() error = %v, want ErrReversalExceedsAmount", err)
+		}
+	})
+	t.Run("recipient has no money", func(t *testing.T) {
+		if _, err := a2.Withdraw(ctx, money.MustParse("2.00"), "card", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Reverse(ctx, money.Amount{}, false); err != ErrNoMoney {
+			t.Errorf("Reverse() error = %v, want ErrNoMoney", err)
+		}
+	})
+	t.Run("force reversal of rest", func(t *testing.T) {
+		if _, err := p.Reverse(ctx, money.Amount{}, true); err != nil {
+			t.Fatal(err)
+		}
+		checkBalances("10.00", "-2.00")
+		if _, err := p.Reverse(ctx, money.Amount{}, true); err != ErrReversalExceedsAmount {
+			t.Errorf("Reverse() error = %v, want ErrReversalExceedsAmount", err)
+		}
+	})
+	t.Run("ledger is balanced", func(t *testing.T) {
+		ids, err := VerifyLedger(ctx, db)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if len(ids) != 0 {
+			t.Errorf("unbalanced payments: %v", ids)
+		}
+	})
+}
+
+func Test_Metadata(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_meta9sk2hd7q", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_meta9sk2hd7q", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	deposit := Metadata{ExternalRef: "dep-9sk2hd7q", Tags: map[string]string{"source": "bank"}}
+	if _, err := a1.Deposit(ctx, money.MustParse("10.00"), deposit, nil); err != nil {
+		t.Fatal(err)
+	}
+	transfer := Metadata{Description: "invoice #42", ExternalRef: "inv-9sk2hd7q", Tags: map[string]string{"order": "42"}}
+	id, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, transfer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("saved with payment", func(t *testing.T) {
+		p, _ := NewPayment(db)
+		if err := p.Get(ctx, ID(id)); err != nil {
+			t.Fatal(err)
+		}
+		if p.Description != transfer.Description || p.ExternalRef != transfer.ExternalRef || p.Tags["order"] != "42" {
+			t.Errorf("metadata = %q, %q, %v", p.Description, p.ExternalRef, p.Tags)
+		}
+	})
+	t.Run("find by external reference", func(t *testing.T) {
+		p, _ := NewPayment(db)
+		if err := p.FindByExternalRef(ctx, a1, transfer.ExternalRef); err != nil || p.ID != ID(id) {
+			t.Errorf("FindByExternalRef() = %d, %v, want %d", p.ID, err, id)
+		}
+		if err := p.FindByExternalRef(ctx, a1, deposit.ExternalRef); err != nil || p.Kind != PaymentKindDeposit {
+			t.Errorf("FindByExternalRef() of deposit = %s, %v", p.Kind, err)
+		}
+		// reference of transfer belongs to payer
+		if err := p.FindByExternalRef(ctx, a2, transfer.ExternalRef); err != ErrPaymentNotFound {
+			t.Errorf("FindByExternalRef() of recipient error = %v, want %v", err, ErrPaymentNotFound)
+		}
+	})
+	t.Run("duplicate external reference", func(t *testing.T) {
+		if _, err := a1.Transfer(ctx, a2.Name, money.MustParse("1.00"), nil, nil, transfer, nil); err != ErrExternalRefDuplicate {
+			t.Errorf("Transfer() error = %v, want %v", err, ErrExternalRefDuplicate)
+		}
+		if _, err := a1.Deposit(ctx, money.MustParse("1.00"), Metadata{ExternalRef: transfer.ExternalRef}, nil); err != ErrExternalRefDuplicate {
+			t.Errorf("Deposit() error = %v, want %v", err, ErrExternalRefDuplicate)
+		}
+		// the same reference can be used by another account
+		if _, err := a2.Transfer(ctx, a1.Name, money.MustParse("1.00"), nil, nil, transfer, nil); err != nil {
+			t.Errorf("Transfer() of another account error = %v", err)
+		}
+	})
+}
+
+func Test_PaymentFilter(t *testing.T) {
+	accounts := make([]*Account, 3)
+	for i, name := range []AccountName{"testacc1_filter4jd8s1", "testacc2_filter4jd8s1", "testacc3_filter4jd8s1"} {
+		a, _ := NewAccount(db)
+		if err := a.Register(ctx, name, ""); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = a.Delete(ctx) }()
+		accounts[i] = a
+	}
+	a1, a2, a3 := accounts[0], accounts[1], accounts[2]
+
+	if _, err := a1.Deposit(ctx, money.MustParse("100"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a3.Deposit(ctx, money.MustParse("50"), Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range []struct {
+		from   *Account
+		to     AccountName
+		amount string
+	}{{a1, a2.Name, "30"}, {a2, a1.Name, "5"}, {a3, a1.Name, "50"}} {
+		if _, err := tr.from.Transfer(ctx, tr.to, money.MustParse(tr.amount), nil, nil, Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a1.Withdraw(ctx, money.MustParse("10"), "card", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		filter PaymentFilter
+		want   int
+	}{
+		{"no conditions", PaymentFilter{}, 5},
+		{"incoming", PaymentFilter{Direction: PaymentDirectionIncoming}, 2},
+		{"outgoing", PaymentFilter{Direction: PaymentDirectionOutgoing}, 1},
+		{"deposit", PaymentFilter{Direction: PaymentDirectionDeposit}, 1},
+		{"withdrawal", PaymentFilter{Direction: PaymentDirectionWithdrawal}, 1},
+		{"min amount", PaymentFilter{MinAmount: money.MustParse("30")}, 3},
+		{"amount range", PaymentFilter{MinAmount: money.MustParse("10"), MaxAmount: money.MustParse("30")}, 2},
+		{"incoming above amount", PaymentFilter{Direction: PaymentDirectionIncoming, MinAmount: money.MustParse("10")}, 1},
+		{"counterparty", PaymentFilter{CounterpartyID: int64(a2.ID)}, 2},
+		{"since future", PaymentFilter{Since: now.Add(time.Hour)}, 0},
+		{"date range", PaymentFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lst, err := PaymentsList(ctx, db, a1, tt.filter, 0, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lst) != tt.want {
+				t.Errorf("len(PaymentsList()) = %d, want %d", len(lst), tt.want)
+			}
+		})
+	}
+
+	t.Run("page of filtered list", func(t *testing.T) {
+		lst, err := PaymentsList(ctx, db, a1, PaymentFilter{CounterpartyID: int64(a2.ID)}, 0, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != 1 || lst[0].FromID != int64(a1.ID) {
+			t.Errorf("PaymentsList(1, 1) = %+v, want transfer to %s", lst, a2.Name)
+		}
 	})
 }
@@ -0,0 +1,189 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// ScheduledTransfer - transfer of account to another account executed at planned time once or repeatedly
+// all times of scheduled transfers are in UTC
+type ScheduledTransfer = repository.ScheduledTransfer
+
+// ScheduleExecution - result of execution of scheduled transfer
+type ScheduleExecution = repository.ScheduleExecution
+
+// recurrences of scheduled transfer
+// monthly transfer is executed on day of month Day, in shorter months on the last day of month
+const (
+	RecurrenceOnce    = repository.RecurrenceOnce
+	RecurrenceDaily   = repository.RecurrenceDaily
+	RecurrenceWeekly  = repository.RecurrenceWeekly
+	RecurrenceMonthly = repository.RecurrenceMonthly
+)
+
+// statuses of scheduled transfer
+const (
+	ScheduleStatusActive    = repository.ScheduleStatusActive
+	ScheduleStatusCompleted = repository.ScheduleStatusCompleted
+	ScheduleStatusCancelled = repository.ScheduleStatusCancelled
+)
+
+var (
+	// ErrScheduleNotFound is returned when scheduled transfer of account not exists
+	ErrScheduleNotFound = repository.ErrScheduleNotFound
+	// ErrScheduleNotActive is returned when scheduled transfer was completed or cancelled
+	ErrScheduleNotActive = repository.ErrScheduleNotActive
+	// ErrScheduleExecuted is returned when execution of scheduled transfer at planned time was already recorded
+	ErrScheduleExecuted = repository.ErrScheduleExecuted
+
+	// ErrRecurrenceInvalid is returned for unknown recurrence of scheduled transfer
+	ErrRecurrenceInvalid = errors.New("unknown recurrence of scheduled transfer")
+	// ErrScheduleDayInvalid is returned when day of month is out of 1..31 or is set for not monthly recurrence
+	ErrScheduleDayInvalid = errors.New("invalid day of month of scheduled transfer")
+)
+
+// NewSchedule - return scheduled transfer of amount with the first execution at runAt or later
+// recipient of transfer is set by Account.ScheduleTransfer. Recurrence is RecurrenceOnce if it is empty.
+// For monthly recurrence day is day of month of executions, if it is zero day of runAt is used.
+// Day must be zero for other recurrences
+func NewSchedule(amount money.Amount, runAt time.Time, recurrence string, day int) (ScheduledTransfer, error) {
+	if recurrence == "" {
+		recurrence = RecurrenceOnce
+	}
+	runAt = runAt.UTC().Truncate(time.Second)
+	switch recurrence {
+	case RecurrenceOnce, RecurrenceDaily, RecurrenceWeekly:
+		if day != 0 {
+			return ScheduledTransfer{}, ErrScheduleDayInvalid
+		}
+	case RecurrenceMonthly:
+		if day == 0 {
+			day = runAt.Day()
+		}
+		if day < 1 || day > 31 {
+			return ScheduledTransfer{}, ErrScheduleDayInvalid
+		}
+		// the first day N at time of runAt which is not before it
+		if first := monthDay(runAt.Year(), runAt.Month(), day, runAt); first.Before(runAt) {
+			runAt = monthDay(runAt.Year(), runAt.Month()+1, day, runAt)
+		} else {
+			runAt = first
+		}
+	default:
+		return ScheduledTransfer{}, ErrRecurrenceInvalid
+	}
+	return ScheduledTransfer{
+		Amount:     amount,
+		Recurrence: recurrence,
+		Day:        day,
+		NextRunAt:  runAt,
+	}, nil
+}
+
+// monthDay - return day of month of year at time of day of clock, day is limited by the last day of month
+// month is normalized, so month 13 is January of the next year
+func monthDay(year int, month time.Month, day int, clock time.Time) time.Time {
+	first := time.Date(year, month, 1, clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// NextRun - return time of execution of scheduled transfer t which follows planned time prev
+// returning zero time for transfer without recurrence
+func NextRun(t ScheduledTransfer, prev time.Time) time.Time {
+	switch t.Recurrence {
+	case RecurrenceDaily:
+		return prev.AddDate(0, 0, 1)
+	case RecurrenceWeekly:
+		return prev.AddDate(0, 0, 7)
+	case RecurrenceMonthly:
+		return monthDay(prev.Year(), prev.Month()+1, t.Day, prev)
+	default:
+		return time.Time{}
+	}
+}
+
+// ScheduleTransfer - schedule transfer t from account "a" to account with name "toName"
+// returning id of scheduled transfer
+func (a *Account) ScheduleTransfer(ctx context.Context, toName AccountName, t ScheduledTransfer) (id int64, err error) {
+	to, err := NewAccount(a.db)
+	if err != nil {
+		return 0, err
+	}
+	if err = to.Find(ctx, toName); err != nil {
+		return 0, ErrRecipientNotFound
+	}
+	t.AccountID = int64(a.ID)
+	t.ToID = int64(to.ID)
+	return a.db.Schedule().Create(ctx, t)
+}
+
+// GetScheduledTransfer - return scheduled transfer of account "a" by id
+func (a *Account) GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	return a.db.Schedule().Get(ctx, int64(a.ID), id)
+}
+
+// ScheduledTransfers - return scheduled transfers of account "a" ordering by id
+// if limit = -1, then no limit
+func (a *Account) ScheduledTransfers(ctx context.Context, offset, limit int64) ([]ScheduledTransfer, error) {
+	return a.db.Schedule().List(ctx, int64(a.ID), offset, limit)
+}
+
+// UpdateScheduledTransfer - change amount and planned time of active scheduled transfer of account "a"
+// id of t is id of changed transfer, recipient of transfer can't be changed
+func (a *Account) UpdateScheduledTransfer(ctx context.Context, t ScheduledTransfer) error {
+	t.AccountID = int64(a.ID)
+	return a.db.Schedule().Update(ctx, t)
+}
+
+// CancelScheduledTransfer - stop executions of scheduled transfer of account "a"
+func (a *Account) CancelScheduledTransfer(ctx context.Context, id int64) error {
+	return a.db.Schedule().Cancel(ctx, int64(a.ID), id)
+}
+
+// ScheduleExecutions - return results of executions of scheduled transfer of account "a" ordering by id
+// if limit = -1, then no limit
+func (a *Account) ScheduleExecutions(ctx context.Context, id int64, offset, limit int64) ([]ScheduleExecution, error) {
+	if _, err := a.GetScheduledTransfer(ctx, id); err != nil {
+		return nil, err
+	}
+	return a.db.Schedule().Executions(ctx, id, offset, limit)
+}
+
+// DueScheduledTransfers - return active scheduled transfers of all accounts which are planned not after now
+// transfers are ordered by planned time, no more than limit transfers are returned
+func DueScheduledTransfers(ctx context.Context, db Storage, now time.Time, limit int64) ([]ScheduledTransfer, error) {
+	if db == nil {
+		return nil, ErrNoDriver
+	}
+	return db.Schedule().Due(ctx, now, limit)
+}
+
+// CompleteScheduledTransfer - record result of execution of scheduled transfer t planned at t.NextRunAt
+// paymentID is id of transfer, when execution failed it is 0 and execErr is the reason.
+// Transfer is planned to the next time after now, so executions missed while wallet was stopped are skipped,
+// transfer without recurrence becomes completed.
+// ErrScheduleExecuted is returned if execution at this time was already recorded
+func CompleteScheduledTransfer(ctx context.Context, db Storage, t ScheduledTransfer, paymentID int64, execErr error, now time.Time) error {
+	if db == nil {
+		return ErrNoDriver
+	}
+	e := ScheduleExecution{ScheduleID: t.ID, RunAt: t.NextRunAt, PaymentID: paymentID}
+	if execErr != nil {
+		e.Error = execErr.Error()
+	}
+	next := NextRun(t, t.NextRunAt)
+	for !next.IsZero() && !next.After(now) {
+		next = NextRun(t, next)
+	}
+	return db.Schedule().Complete(ctx, e, next)
+}
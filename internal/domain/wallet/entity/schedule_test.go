@@ -0,0 +1,160 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_NewSchedule(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name       string
+		runAt      string
+		recurrence string
+		day        int
+		want       string
+		wantNext   string
+		wantErr    error
+	}{
+		{"once", "2021-05-21T10:00:00+03:00", "", 0, "2021-05-21T07:00:00Z", "", nil},
+		{"daily", "2021-05-21T10:00:00Z", RecurrenceDaily, 0, "2021-05-21T10:00:00Z", "2021-05-22T10:00:00Z", nil},
+		{"weekly", "2021-05-21T10:00:00Z", RecurrenceWeekly, 0, "2021-05-21T10:00:00Z", "2021-05-28T10:00:00Z", nil},
+		{"monthly on day of run", "2021-05-21T10:00:00Z", RecurrenceMonthly, 0, "2021-05-21T10:00:00Z", "2021-06-21T10:00:00Z", nil},
+		{"monthly later in month", "2021-05-21T10:00:00Z", RecurrenceMonthly, 25, "2021-05-25T10:00:00Z", "2021-06-25T10:00:00Z", nil},
+		{"monthly in next month", "2021-05-21T10:00:00Z", RecurrenceMonthly, 5, "2021-06-05T10:00:00Z", "2021-07-05T10:00:00Z", nil},
+		{"monthly on last day", "2021-01-31T10:00:00Z", RecurrenceMonthly, 31, "2021-01-31T10:00:00Z", "2021-02-28T10:00:00Z", nil},
+		{"day of not monthly", "2021-05-21T10:00:00Z", RecurrenceDaily, 5, "", "", ErrScheduleDayInvalid},
+		{"day out of month", "2021-05-21T10:00:00Z", RecurrenceMonthly, 32, "", "", ErrScheduleDayInvalid},
+		{"unknown recurrence", "2021-05-21T10:00:00Z", "yearly", 0, "", "", ErrRecurrenceInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSchedule(money.MustParse("1"), date(tt.runAt), tt.recurrence, tt.day)
+			if err != tt.wantErr {
+				t.Fatalf("NewSchedule() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !s.NextRunAt.Equal(date(tt.want)) {
+				t.Errorf("NextRunAt = %v, want %v", s.NextRunAt, tt.want)
+			}
+			next := NextRun(s, s.NextRunAt)
+			if tt.wantNext == "" {
+				if !next.IsZero() {
+					t.Errorf("NextRun() = %v, want zero time", next)
+				}
+			} else if !next.Equal(date(tt.wantNext)) {
+				t.Errorf("NextRun() = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+
+	// day of monthly transfer is kept in shorter months
+	s, _ := NewSchedule(money.MustParse("1"), date("2021-01-31T10:00:00Z"), RecurrenceMonthly, 0)
+	if next := NextRun(s, NextRun(s, s.NextRunAt)); !next.Equal(date("2021-03-31T10:00:00Z")) {
+		t.Errorf("NextRun() after February = %v, want 2021-03-31", next)
+	}
+}
+
+func Test_ScheduledTransfer(t *testing.T) {
+	a1, _ := NewAccount(db)
+	a2, _ := NewAccount(db)
+	if err := a1.Register(ctx, "testacc1_sched6ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, "testacc2_sched6ck76w", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s, err := NewSchedule(money.MustParse("1.50"), now.Add(-time.Hour), RecurrenceDaily, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a1.ScheduleTransfer(ctx, "testacc3_sched6ck76w", s); err != ErrRecipientNotFound {
+		t.Errorf("ScheduleTransfer() error = %v, want ErrRecipientNotFound", err)
+	}
+	id, err := a1.ScheduleTransfer(ctx, a2.Name, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a2.GetScheduledTransfer(ctx, id); err != ErrScheduleNotFound {
+		t.Errorf("GetScheduledTransfer() of another account error = %v, want ErrScheduleNotFound", err)
+	}
+
+	var due ScheduledTransfer
+	t.Run("due", func(t *testing.T) {
+		lst, err := DueScheduledTransfers(ctx, db, now, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range lst {
+			if d.ID == id {
+				due = d
+			}
+		}
+		if due.ID == 0 || due.ToID != int64(a2.ID) || due.Amount.Cmp(money.MustParse("1.50")) != 0 {
+			t.Fatalf("scheduled transfer %d is not due, due = %+v", id, lst)
+		}
+	})
+	t.Run("complete", func(t *testing.T) {
+		if err := CompleteScheduledTransfer(ctx, db, due, 1, nil, now); err != nil {
+			t.Fatal(err)
+		}
+		// the same planned time is executed only once
+		if err := CompleteScheduledTransfer(ctx, db, due, 2, nil, now); err != ErrScheduleExecuted {
+			t.Errorf("repeated CompleteScheduledTransfer() error = %v, want ErrScheduleExecuted", err)
+		}
+		st, err := a1.GetScheduledTransfer(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := due.NextRunAt.AddDate(0, 0, 1); st.Status != ScheduleStatusActive || !st.NextRunAt.Equal(want) {
+			t.Errorf("scheduled transfer = %s at %v, want active at %v", st.Status, st.NextRunAt, want)
+		}
+		ex, err := a1.ScheduleExecutions(ctx, id, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ex) != 1 || ex[0].PaymentID != 1 || !ex[0].RunAt.Equal(due.NextRunAt) {
+			t.Errorf("executions = %+v", ex)
+		}
+	})
+	t.Run("update and cancel", func(t *testing.T) {
+		st, _ := a1.GetScheduledTransfer(ctx, id)
+		st.Amount = money.MustParse("2")
+		if err := a1.UpdateScheduledTransfer(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+		if err := a1.CancelScheduledTransfer(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := a1.CancelScheduledTransfer(ctx, id); err != ErrScheduleNotActive {
+			t.Errorf("repeated CancelScheduledTransfer() error = %v, want ErrScheduleNotActive", err)
+		}
+		if err := a1.UpdateScheduledTransfer(ctx, st); err != ErrScheduleNotActive {
+			t.Errorf("UpdateScheduledTransfer() of cancelled error = %v, want ErrScheduleNotActive", err)
+		}
+		lst, err := a1.ScheduledTransfers(ctx, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != 1 || lst[0].Status != ScheduleStatusCancelled || lst[0].Amount.Cmp(money.MustParse("2")) != 0 {
+			t.Errorf("scheduled transfers = %+v", lst)
+		}
+	})
+}
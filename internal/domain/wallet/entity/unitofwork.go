@@ -0,0 +1,19 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package entity
+
+import (
+	"context"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+)
+
+// UnitOfWork - repositories which operations are executed atomically, defined by repository
+type UnitOfWork = repository.UnitOfWork
+
+// InUnitOfWork - execute fn in unit of work of driver db
+// entities created by fn with storage s are saved if fn returns nil, else all their changes are discarded
+func InUnitOfWork(ctx context.Context, db Driver, fn func(s Storage) error) error {
+	return repository.InUnitOfWork(ctx, db, fn)
+}
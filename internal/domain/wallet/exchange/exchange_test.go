@@ -0,0 +1,119 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package exchange
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_Static(t *testing.T) {
+	s, err := NewStatic(entity.ExchangeRate{From: "USD", To: "eur", Rate: money.MustParse("0.8187")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Rate("usd", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Rate.String() != "0.8187" {
+		t.Errorf("Rate() = %s", r.Rate)
+	}
+	if _, err = s.Rate("eur", "usd"); err != entity.ErrExchangeRateNotFound {
+		t.Errorf("Rate() error = %v, want %v", err, entity.ErrExchangeRateNotFound)
+	}
+	if err = s.Set(entity.ExchangeRate{From: "usd", To: "gbp", Rate: money.MustParse("-1")}); err == nil {
+		t.Error("negative rate must be rejected")
+	}
+}
+
+func Test_Convert(t *testing.T) {
+	s, _ := NewStatic(
+		entity.ExchangeRate{From: "usd", To: "eur", Rate: money.MustParse("0.8187")},
+		entity.ExchangeRate{From: "usd", To: "jpy", Rate: money.MustParse("109.1234")},
+	)
+	tests := []struct {
+		from, to string
+		amount   string
+		want     string
+		wantErr  error
+	}{
+		{"usd", "eur", "10.00", "8.19", nil},
+		{"usd", "jpy", "10.00", "1091", nil},
+		{"usd", "usd", "10.00", "10.00", nil},
+		{"usd", "gbp", "10.00", "", entity.ErrExchangeRateNotFound},
+		{"usd", "eur", "0.001", "", entity.ErrConvertedAmountZero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+tt.to+tt.amount, func(t *testing.T) {
+			got, _, err := entity.Convert(money.MustParse(tt.amount), tt.from, tt.to, s)
+			if err != tt.wantErr {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("Convert() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rates.json")
+
+	write := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write(`{"date":"2021-05-21T10:00:00Z","rates":[{"from":"usd","to":"eur","rate":"0.81"}]}`, now.Add(-time.Minute))
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := f.Rate("usd", "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Rate.String() != "0.81" || r.Date.Year() != 2021 {
+		t.Errorf("Rate() = %+v", r)
+	}
+
+	t.Run("reload on change", func(t *testing.T) {
+		write(`{"rates":[{"from":"usd","to":"eur","rate":"0.82"}]}`, now)
+		r, err := f.Rate("usd", "eur")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Rate.String() != "0.82" {
+			t.Errorf("Rate() = %s, want 0.82", r.Rate)
+		}
+	})
+
+	t.Run("broken file keeps previous rates", func(t *testing.T) {
+		write(`{"rates":[`, now.Add(time.Minute))
+		r, err := f.Rate("usd", "eur")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Rate.String() != "0.82" {
+			t.Errorf("Rate() = %s, want 0.82", r.Rate)
+		}
+	})
+}
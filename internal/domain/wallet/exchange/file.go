@@ -0,0 +1,113 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
+	logger "github.com/sirupsen/logrus"
+)
+
+// File - provider with table of rates loaded from JSON file
+// file is checked for modification on each request of rate and reloaded when it was changed.
+// If new version of file can't be loaded, the previous table of rates is used
+//
+// Format of file:
+// {
+//   "date": "2021-05-21T10:00:00Z",
+//   "rates": [
+//     {"from": "usd", "to": "eur", "rate": "0.8187"},
+//     {"from": "eur", "to": "usd", "rate": "1.2214"}
+//   ]
+// }
+// if date is omitted, modification time of file is used as date of rates
+type File struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	table   *Static
+}
+
+// fileContent - format of rates file
+type fileContent struct {
+	Date  *time.Time `json:"date"`
+	Rates []struct {
+		From string       `json:"from"`
+		To   string       `json:"to"`
+		Rate money.Amount `json:"rate"`
+	} `json:"rates"`
+}
+
+// NewFile create provider and load rates from file at path
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Rate implements entity.ExchangeRateProvider
+func (f *File) Rate(from, to string) (entity.ExchangeRate, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.reload(); err != nil {
+		logger.WithFields(logger.Fields{
+			"[Wallet][exchange.File]": err,
+			"path":                    f.path,
+		}).Warning("can't reload rates, previous rates are used")
+	}
+	return f.table.Rate(from, to)
+}
+
+// reload read file if it was modified after last loading
+func (f *File) reload() error {
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if f.table != nil && st.ModTime().Equal(f.modTime) && st.Size() == f.size {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	var c fileContent
+	if err = json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("can't parse rates file %s: %v", f.path, err)
+	}
+
+	date := st.ModTime()
+	if c.Date != nil {
+		date = *c.Date
+	}
+	table, err := NewStatic()
+	if err != nil {
+		return err
+	}
+	for _, r := range c.Rates {
+		if err = table.Set(entity.ExchangeRate{From: r.From, To: r.To, Rate: r.Rate, Date: date}); err != nil {
+			return fmt.Errorf("rates file %s: %v", f.path, err)
+		}
+	}
+
+	f.table = table
+	f.modTime = st.ModTime()
+	f.size = st.Size()
+	return nil
+}
+
+// compile time check of interface implementation
+var _ entity.ExchangeRateProvider = (*File)(nil)
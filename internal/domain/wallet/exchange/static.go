@@ -0,0 +1,84 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+// Package exchange contains implementations of entity.ExchangeRateProvider
+//
+// Static - fixed table of rates, set up in code or configuration
+// File - table of rates loaded from JSON file, reloaded when the file is changed
+package exchange
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+)
+
+// MaxRateScale - maximal count of digits after decimal point in rate. Depends on database column
+const MaxRateScale = 10
+
+// Static - provider with fixed table of rates
+type Static struct {
+	sync.RWMutex
+	rates map[string]entity.ExchangeRate
+}
+
+// NewStatic create provider with table of rates
+func NewStatic(rates ...entity.ExchangeRate) (*Static, error) {
+	s := &Static{
+		rates: make(map[string]entity.ExchangeRate),
+	}
+	for _, r := range rates {
+		if err := s.Set(r); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Set add rate to table or replace existing rate for the same currencies pair
+func (s *Static) Set(r entity.ExchangeRate) error {
+	r.From = entity.NormalizeCurrencyCode(r.From)
+	r.To = entity.NormalizeCurrencyCode(r.To)
+	if err := validateRate(r); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.rates[pairKey(r.From, r.To)] = r
+	return nil
+}
+
+// Rate implements entity.ExchangeRateProvider
+func (s *Static) Rate(from, to string) (entity.ExchangeRate, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, ok := s.rates[pairKey(entity.NormalizeCurrencyCode(from), entity.NormalizeCurrencyCode(to))]
+	if !ok {
+		return entity.ExchangeRate{}, entity.ErrExchangeRateNotFound
+	}
+	return r, nil
+}
+
+// validateRate checking rate before adding it to table
+func validateRate(r entity.ExchangeRate) error {
+	if r.From == "" || r.To == "" || r.From == r.To {
+		return fmt.Errorf("invalid currencies pair %q/%q", r.From, r.To)
+	}
+	if r.Rate.Sign() <= 0 {
+		return fmt.Errorf("rate %s/%s must be positive", r.From, r.To)
+	}
+	if r.Rate.Precision() > MaxRateScale {
+		return fmt.Errorf("rate %s/%s has more than %d digits after decimal point", r.From, r.To, MaxRateScale)
+	}
+	return nil
+}
+
+func pairKey(from, to string) string {
+	return from + "/" + to
+}
+
+// compile time check of interface implementation
+var _ entity.ExchangeRateProvider = (*Static)(nil)
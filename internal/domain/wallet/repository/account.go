@@ -1,13 +1,92 @@
 // repository implement interfaces for access to database layer
 
 // Package repository can used different driver of database
-// for each of a database need to define its implementation in driver
+// for each of a database need to define its implementation in driver and register it with Register
 package repository
 
 import (
-	"fmt"
+	"context"
+	"time"
 
 	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// Conversion - currency conversion applied to transfer, defined by driver
+type Conversion = driver.Conversion
+
+// Idempotency - idempotency key and fingerprint of request, defined by driver
+type Idempotency = driver.Idempotency
+
+// Metadata - description, external reference and tags of payment, defined by driver
+type Metadata = driver.Metadata
+
+// Hold - amount of account reserved for transfer, defined by driver
+type Hold = driver.Hold
+
+// Limits - spending limits of account, defined by driver
+type Limits = driver.Limits
+
+// Spending - total amount and count of outgoing transfers and withdrawals of account, defined by driver
+type Spending = driver.Spending
+
+// LimitsDay - start of day of daily limits for time now, defined by driver
+func LimitsDay(now time.Time) time.Time {
+	return driver.LimitsDay(now)
+}
+
+// statuses of account
+const (
+	AccountStatusActive = driver.AccountStatusActive
+	AccountStatusFrozen = driver.AccountStatusFrozen
+	AccountStatusClosed = driver.AccountStatusClosed
+)
+
+// statuses of hold
+const (
+	HoldStatusActive   = driver.HoldStatusActive
+	HoldStatusCaptured = driver.HoldStatusCaptured
+	HoldStatusReleased = driver.HoldStatusReleased
+	HoldStatusExpired  = driver.HoldStatusExpired
+)
+
+var (
+	// ErrNoMoney is returned when account balance is not enough for payment
+	ErrNoMoney = driver.ErrNoMoney
+	// ErrRecipientNotFound is returned when recipient account of transfer not exists
+	ErrRecipientNotFound = driver.ErrRecipientNotFound
+
+	// ErrAccountFrozen is returned when account of payment is frozen
+	ErrAccountFrozen = driver.ErrAccountFrozen
+	// ErrAccountClosed is returned when account of payment or account which status is changed is closed
+	ErrAccountClosed = driver.ErrAccountClosed
+	// ErrRecipientFrozen is returned when recipient account of payment is frozen
+	ErrRecipientFrozen = driver.ErrRecipientFrozen
+	// ErrRecipientClosed is returned when recipient account of payment is closed
+	ErrRecipientClosed = driver.ErrRecipientClosed
+	// ErrAccountNotEmpty is returned when account with non zero balance or active holds is closed
+	ErrAccountNotEmpty = driver.ErrAccountNotEmpty
+
+	// ErrHoldNotFound is returned when hold of account not exists
+	ErrHoldNotFound = driver.ErrHoldNotFound
+	// ErrHoldNotActive is returned when hold was already captured, released or expired
+	ErrHoldNotActive = driver.ErrHoldNotActive
+	// ErrHoldExceedsAmount is returned when captured amount is greater than amount of hold
+	ErrHoldExceedsAmount = driver.ErrHoldExceedsAmount
+
+	// ErrTransferLimitExceeded is returned when amount of payment is greater than limit of one payment
+	ErrTransferLimitExceeded = driver.ErrTransferLimitExceeded
+	// ErrDailyAmountExceeded is returned when payment makes total amount of payments of day greater than limit
+	ErrDailyAmountExceeded = driver.ErrDailyAmountExceeded
+	// ErrDailyCountExceeded is returned when count of payments of day reached limit
+	ErrDailyCountExceeded = driver.ErrDailyCountExceeded
+
+	// ErrIdempotencyReplay is returned with id of original payment when request was already executed
+	ErrIdempotencyReplay = driver.ErrIdempotencyReplay
+	// ErrIdempotencyConflict is returned when idempotency key was used for request with other parameters
+	ErrIdempotencyConflict = driver.ErrIdempotencyConflict
+	// ErrExternalRefDuplicate is returned when account already has payment with the same external reference
+	ErrExternalRefDuplicate = driver.ErrExternalRefDuplicate
 )
 
 // Account interface defined account repository for storage
@@ -17,39 +96,73 @@ type Account interface {
 	// Name return name of wallet account
 	Name() string
 	// Balance return balance of wallet
-	Balance() float64
+	Balance() money.Amount
+	// AvailableBalance return balance without active holds
+	AvailableBalance() money.Amount
+	// CreditLimit return overdraft limit of wallet, balance can go down to -CreditLimit
+	CreditLimit() money.Amount
 	// Currency return currency of wallet
 	Currency() string
+	// System return true for system accounts of ledger (cash, fx)
+	System() bool
+	// Status return status of wallet: active, frozen or closed
+	Status() string
+	// LedgerBalance return balance derived from ledger entries of account
+	LedgerBalance(ctx context.Context) (money.Amount, error)
 
-	// Find instance of wallet by account name
-	Find(name string) error
+	// Find instance of wallet by account name. System accounts are not found
+	Find(ctx context.Context, name string) error
 	// Get instance of wallet by account id
-	Get(id int64) error
+	Get(ctx context.Context, id int64) error
 
-	// Create new object in database
-	Create(name string) error
-	// Delete - delete wallet account
-	Delete() error
+	// Create new object in database with currency
+	Create(ctx context.Context, name, currency string) error
+	// SetStatus - change status of wallet account. Account can be closed only with zero balance
+	SetStatus(ctx context.Context, status string) error
+	// SetCreditLimit - set overdraft limit of wallet account
+	SetCreditLimit(ctx context.Context, limit money.Amount) error
+	// Delete - delete wallet account physically, used for clean up test data
+	Delete(ctx context.Context) error
 
 	// Transfer - creating a payment form account to account with id "toID"
-	Transfer(toID int64, amount float64) (int64, error)
+	// limits of account are checked by driver under lock of account, ErrTransferLimitExceeded, ErrDailyAmountExceeded
+	// or ErrDailyCountExceeded are returned if the transfer exceeds them
+	// conv is nil when both accounts have the same currency
+	// fee is charged in addition to amount, it is zero when transfer has no fee
+	// meta is zero when payment has no metadata, idem is nil when request has no idempotency key
+	Transfer(ctx context.Context, toID int64, amount money.Amount, conv *Conversion, fee money.Amount,
+		meta Metadata, idem *Idempotency) (int64, error)
 	// Deposit - add amount to account balance
-	Deposit(amount float64) (int64, error)
+	// meta is zero when payment has no metadata, idem is nil when request has no idempotency key
+	Deposit(ctx context.Context, amount money.Amount, meta Metadata, idem *Idempotency) (int64, error)
+	// Withdraw - move amount out of wallet to external counterparty
+	// limits of account are checked the same as by Transfer
+	// idem is nil when request has no idempotency key
+	Withdraw(ctx context.Context, amount money.Amount, counterparty string, idem *Idempotency) (int64, error)
+	// Hold - reserve amount for transfer to account with id "toID" for ttl
+	Hold(ctx context.Context, toID int64, amount money.Amount, ttl time.Duration) (int64, error)
+	// GetHold - return hold of account by id
+	GetHold(ctx context.Context, holdID int64) (Hold, error)
+	// Capture - turn hold into transfer of amount, the rest of hold is released
+	// conv is nil when both accounts have the same currency, fee is charged in addition to amount as by Transfer
+	// limits of account are checked the same as by Transfer
+	Capture(ctx context.Context, holdID int64, amount money.Amount, conv *Conversion, fee money.Amount) (int64, error)
+	// Release - release active hold
+	Release(ctx context.Context, holdID int64) error
+	// ExpireHolds - set status expired for all holds with expired ttl
+	ExpireHolds(ctx context.Context) (int64, error)
 
-	// List - return list of all wallets account names
-	List(offset, limit int64) ([]int64, error)
-}
+	// Limits - return spending limits of account, zero limits if they are not set
+	Limits(ctx context.Context) (Limits, error)
+	// SetLimits - set spending limits of account
+	SetLimits(ctx context.Context, l Limits) error
+	// Spending - return total amount and count of outgoing transfers and withdrawals of account since time
+	Spending(ctx context.Context, since time.Time) (Spending, error)
 
-//
-// AccountFactory create repository instance using dbDriver
-func AccountFactory(dbDriver string) (Account, error) {
-	switch dbDriver {
-	case "postgresql":
-		if err := driver.PgSQLInit(); err != nil {
-			return nil, err
-		}
-		return &driver.PgSqlAccount{}, nil
-	default:
-		return nil, fmt.Errorf("unknown database engine: %s", dbDriver)
-	}
+	// FindIdempotent - search payment created by account with idempotency key
+	FindIdempotent(ctx context.Context, idem *Idempotency) (int64, error)
+
+	// List - return list of all wallets account names
+	// if after > 0, list begins with the next account after account with this id, offset must be zero then
+	List(ctx context.Context, after, offset, limit int64) ([]int64, error)
 }
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+// Config - configuration of storage driver, defined by driver
+type Config = driver.Config
+
+// DefaultDriver - database driver used when driver is not set in configuration
+const DefaultDriver = "postgresql"
+
+// DefaultConfig - return configuration with default values
+func DefaultConfig() Config {
+	return Config{
+		Driver:   DefaultDriver,
+		CacheTTL: 10 * time.Minute,
+	}
+}
+
+// ConfigFromEnv - return default configuration overridden by environment
+func ConfigFromEnv() Config {
+	c := DefaultConfig()
+	LoadEnv(&c)
+	return c
+}
+
+// LoadEnv - override fields of configuration c by environment which are set
+// environment can be set in OS or in .env file:
+// # Storage driver: postgresql, sqlite or memory
+// DB_DRIVER=postgresql
+// # Connection string of database, for sqlite path to database file
+// DB_DSN=
+// # Size of pool of connections
+// DB_MAX_CONNS=10
+// DB_MIN_CONNS=0
+// # PostgreSQL connection, used when DB_DSN is not set
+// PGSQL_HOST=127.0.0.1
+// PGSQL_NAME=coins
+// PGSQL_USER=coins
+// PGSQL_PASS=coins
+// PGSQL_PORT=5432
+// # SQLite database file, used when DB_DSN is not set
+// SQLITE_PATH=wallet.db
+// # Apply pending migrations of database schema on start
+// DB_AUTO_MIGRATE=false
+// # Memory cache settings (in minutes)
+// CacheExpTime=10
+func LoadEnv(c *Config) {
+	_ = godotenv.Load()
+
+	if v := os.Getenv("DB_DRIVER"); v != "" {
+		c.Driver = v
+	}
+	switch {
+	case os.Getenv("DB_DSN") != "":
+		c.DSN = os.Getenv("DB_DSN")
+	case c.Driver == "postgresql" && os.Getenv("PGSQL_HOST") != "":
+		c.DSN = fmt.Sprintf(
+			"user=%s password=%s dbname=%s host=%s port=%s sslmode=disable",
+			os.Getenv("PGSQL_USER"),
+			os.Getenv("PGSQL_PASS"),
+			os.Getenv("PGSQL_NAME"),
+			os.Getenv("PGSQL_HOST"),
+			os.Getenv("PGSQL_PORT"),
+		)
+	case c.Driver == "sqlite" && os.Getenv("SQLITE_PATH") != "":
+		c.DSN = os.Getenv("SQLITE_PATH")
+	}
+	if v, err := strconv.ParseInt(os.Getenv("DB_MAX_CONNS"), 10, 32); err == nil {
+		c.MaxConns = int32(v)
+	}
+	if v, err := strconv.ParseInt(os.Getenv("DB_MIN_CONNS"), 10, 32); err == nil {
+		c.MinConns = int32(v)
+	}
+	if v, err := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE")); err == nil {
+		c.AutoMigrate = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("CacheExpTime"), 10, 64); err == nil {
+		c.CacheTTL = time.Duration(v) * time.Minute
+	}
+}
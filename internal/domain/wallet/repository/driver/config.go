@@ -0,0 +1,19 @@
+package driver
+
+import "time"
+
+// Config - configuration of storage driver
+type Config struct {
+	// Driver - name of registered driver: postgresql, sqlite or memory
+	Driver string `yaml:"driver"`
+	// DSN - connection string of PostgreSQL database or path to SQLite database file
+	DSN string `yaml:"dsn"`
+	// MaxConns, MinConns - size of pool of connections to database, 0 for default size
+	MaxConns int32 `yaml:"max_conns"`
+	MinConns int32 `yaml:"min_conns"`
+	// CacheTTL - expiration time of memory cache, 0 for default time
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// AutoMigrate - apply pending migrations when driver is opened
+	// when it is false opening of database with outdated schema fails
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
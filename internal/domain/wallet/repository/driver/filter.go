@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Filter of payments list of account
+// drivers with SQL support apply filter in query, others check every payment with match
+
+// directions of payments relative to account of list
+const (
+	// PaymentDirectionIncoming - transfers and reversals credited to account
+	PaymentDirectionIncoming = "incoming"
+	// PaymentDirectionOutgoing - transfers and reversals debited from account
+	PaymentDirectionOutgoing = "outgoing"
+	// PaymentDirectionDeposit - deposits to account
+	PaymentDirectionDeposit = "deposit"
+	// PaymentDirectionWithdrawal - withdrawals from account
+	PaymentDirectionWithdrawal = "withdrawal"
+)
+
+// PaymentFilter - conditions of payments list of account, zero value of field means condition is not set
+type PaymentFilter struct {
+	// Since and Until - bounds of date of payment, Since is included and Until is not
+	Since time.Time
+	Until time.Time
+	// MinAmount and MaxAmount - bounds of amount of payment in account currency, both are included
+	// amount of incoming payment is amount credited to account (to_amount)
+	MinAmount money.Amount
+	MaxAmount money.Amount
+	// Direction - one of PaymentDirection constants
+	Direction string
+	// CounterpartyID - id of the other account of payment
+	CounterpartyID int64
+}
+
+// IsZero checking that filter has no conditions
+func (f PaymentFilter) IsZero() bool {
+	return f.Since.IsZero() && f.Until.IsZero() && f.MinAmount.IsZero() && f.MaxAmount.IsZero() &&
+		f.Direction == "" && f.CounterpartyID == 0
+}
+
+// Conditions - conditions of payment direction for account with accountID
+// payment must have payer fromID, recipient toID and kind, and must not have kind notKind. Zero values are not checked
+func (f PaymentFilter) Conditions(accountID int64) (fromID, toID int64, kind, notKind string) {
+	switch f.Direction {
+	case PaymentDirectionIncoming:
+		return 0, accountID, "", PaymentKindDeposit
+	case PaymentDirectionOutgoing:
+		return accountID, 0, "", PaymentKindWithdrawal
+	case PaymentDirectionDeposit:
+		return 0, accountID, PaymentKindDeposit, ""
+	case PaymentDirectionWithdrawal:
+		return accountID, 0, PaymentKindWithdrawal, ""
+	}
+	return 0, 0, "", ""
+}
+
+// NullTime, NullAmount, NullID and NullString - value of query parameter, NULL for zero value
+// drivers with SQL support use them for conditions of filter which are not set
+func NullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+func NullAmount(a money.Amount) interface{} {
+	if a.IsZero() {
+		return nil
+	}
+	return a
+}
+func NullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+func NullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// FilteredPayment - fields of payment checked by filter
+type FilteredPayment interface {
+	Kind() string
+	Date() time.Time
+	Amount() money.Amount
+	ToAmount() money.Amount
+	From() int64
+	To() int64
+}
+
+// Match checking that payment p of account with accountID meets conditions of filter
+func (f PaymentFilter) Match(accountID int64, p FilteredPayment) bool {
+	if !f.Since.IsZero() && p.Date().Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !p.Date().Before(f.Until) {
+		return false
+	}
+	amount := p.Amount()
+	if p.To() == accountID {
+		amount = p.ToAmount()
+	}
+	if !f.MinAmount.IsZero() && amount.Cmp(f.MinAmount) < 0 {
+		return false
+	}
+	if !f.MaxAmount.IsZero() && amount.Cmp(f.MaxAmount) > 0 {
+		return false
+	}
+	fromID, toID, kind, notKind := f.Conditions(accountID)
+	switch {
+	case fromID != 0 && p.From() != fromID,
+		toID != 0 && p.To() != toID,
+		kind != "" && p.Kind() != kind,
+		notKind != "" && p.Kind() == notKind:
+		return false
+	}
+	if f.CounterpartyID != 0 && p.From() != f.CounterpartyID && p.To() != f.CounterpartyID {
+		return false
+	}
+	return true
+}
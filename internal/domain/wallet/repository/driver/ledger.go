@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Double-entry ledger
+// every payment is a ledger transaction which consist of two or more entries (legs)
+// entry with positive amount is credit of account (balance increased), with negative amount is debit
+// sum of entries amounts of one transaction must be zero in each currency
+// money which comes from outside or goes outside the wallet is posted to system accounts
+
+// kinds of payments
+const (
+	PaymentKindDeposit    = "deposit"
+	PaymentKindTransfer   = "transfer"
+	PaymentKindWithdrawal = "withdrawal"
+	PaymentKindReversal   = "reversal"
+)
+
+// kinds of system accounts
+const (
+	// SystemAccountCash - external cash, source of deposits and destination of withdrawals
+	SystemAccountCash = "cash"
+	// SystemAccountFx - currency exchange position, used as counterparty of cross-currency transfers
+	SystemAccountFx = "fx"
+	// SystemAccountFee - revenue of wallet, fees of transfers are credited to it
+	SystemAccountFee = "fee"
+)
+
+// ErrLedgerUnbalanced is returned when entries of transaction don't sum to zero
+var ErrLedgerUnbalanced = errors.New("ledger transaction is not balanced")
+
+// LedgerEntry - one leg of ledger transaction
+type LedgerEntry struct {
+	ID        int64
+	PaymentID int64
+	AccountID int64
+	// Amount - signed amount in account currency, positive for credit and negative for debit
+	Amount   money.Amount
+	Currency string
+	Date     time.Time
+}
+
+// SystemAccountName return name of system account of kind for currency
+// names of system accounts start with "@" so they never match name of user account
+func SystemAccountName(kind, currency string) string {
+	return "@" + kind + "." + strings.ToLower(currency)
+}
+
+// CheckBalanced - check that transaction has at least two entries and entries sum to zero in each currency
+func CheckBalanced(entries []LedgerEntry) error {
+	if len(entries) < 2 {
+		return fmt.Errorf("%w: transaction must have at least two entries", ErrLedgerUnbalanced)
+	}
+	sums := make(map[string]money.Amount)
+	for _, e := range entries {
+		sum, err := sums[e.Currency].Add(e.Amount)
+		if err != nil {
+			return err
+		}
+		sums[e.Currency] = sum
+	}
+	for cur, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: sum of %s entries is %s", ErrLedgerUnbalanced, cur, sum)
+		}
+	}
+	return nil
+}
+
+// ReversalEntries - build entries of payment which compensates payment with entries
+// entries in payer currency are reversed by amount, others by toAmount
+// for partial reversal of cross-currency transfer money goes back through the same fx accounts
+// fee is not returned: entry of fee account feeID is skipped, payer entry with fee is reversed by amount only
+func ReversalEntries(entries []LedgerEntry, payerCurrency string, amount, toAmount money.Amount, feeID int64) []LedgerEntry {
+	res := make([]LedgerEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if feeID != 0 && e.AccountID == feeID {
+			continue
+		}
+		v := toAmount
+		if e.Currency == payerCurrency {
+			v = amount
+		}
+		if e.Amount.Sign() > 0 {
+			v = v.Neg()
+		}
+		res = append(res, LedgerEntry{AccountID: e.AccountID, Amount: v, Currency: e.Currency})
+	}
+	return res
+}
+
+// TransferEntries - build entries of transfer amount from account fromID to account toID
+// for cross-currency transfer money goes through fx system accounts of both currencies:
+// payer -> fx(payer currency), fx(recipient currency) -> recipient
+func TransferEntries(fromID, toID int64, amount money.Amount, fromCurrency string,
+	toAmount money.Amount, toCurrency string, fxFromID, fxToID int64) []LedgerEntry {
+	if fromCurrency == toCurrency {
+		return []LedgerEntry{
+			{AccountID: fromID, Amount: amount.Neg(), Currency: fromCurrency},
+			{AccountID: toID, Amount: amount, Currency: toCurrency},
+		}
+	}
+	return []LedgerEntry{
+		{AccountID: fromID, Amount: amount.Neg(), Currency: fromCurrency},
+		{AccountID: fxFromID, Amount: amount, Currency: fromCurrency},
+		{AccountID: fxToID, Amount: toAmount.Neg(), Currency: toCurrency},
+		{AccountID: toID, Amount: toAmount, Currency: toCurrency},
+	}
+}
+
+// FeeEntries - add fee of transfer to its entries
+// payer, the account of the first entry, is debited by amount with fee, fee is credited to fee account feeID
+func FeeEntries(entries []LedgerEntry, fee money.Amount, feeID int64) ([]LedgerEntry, error) {
+	if fee.IsZero() {
+		return entries, nil
+	}
+	debit, err := entries[0].Amount.Sub(fee)
+	if err != nil {
+		return nil, err
+	}
+	entries[0].Amount = debit
+	return append(entries, LedgerEntry{AccountID: feeID, Amount: fee, Currency: entries[0].Currency}), nil
+}
@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_CheckBalanced(t *testing.T) {
+	m := money.MustParse
+	withFee, err := FeeEntries(TransferEntries(1, 2, m("10.00"), "usd", m("10.00"), "usd", 0, 0), m("0.30"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		entries []LedgerEntry
+		wantErr bool
+	}{
+		{"transfer", TransferEntries(1, 2, m("10.00"), "usd", m("10.00"), "usd", 0, 0), false},
+		{"cross-currency transfer", TransferEntries(1, 2, m("10.00"), "usd", m("8.19"), "eur", 3, 4), false},
+		{"transfer with fee", withFee, false},
+		{"single entry", []LedgerEntry{{AccountID: 1, Amount: m("0"), Currency: "usd"}}, true},
+		{"unbalanced", []LedgerEntry{
+			{AccountID: 1, Amount: m("-10.00"), Currency: "usd"},
+			{AccountID: 2, Amount: m("9.99"), Currency: "usd"},
+		}, true},
+		{"balanced in sum but not in currency", []LedgerEntry{
+			{AccountID: 1, Amount: m("-10.00"), Currency: "usd"},
+			{AccountID: 2, Amount: m("10.00"), Currency: "eur"},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBalanced(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckBalanced() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrLedgerUnbalanced) {
+				t.Errorf("CheckBalanced() error = %v, want ErrLedgerUnbalanced", err)
+			}
+		})
+	}
+}
+
+func Test_reversalEntries(t *testing.T) {
+	m := money.MustParse
+	orig := TransferEntries(1, 2, m("10.00"), "usd", m("8.19"), "eur", 3, 4)
+	rev := ReversalEntries(orig, "usd", m("5.00"), m("4.10"), 0)
+	if err := CheckBalanced(rev); err != nil {
+		t.Fatal(err)
+	}
+	want := []LedgerEntry{
+		{AccountID: 2, Amount: m("-4.10"), Currency: "eur"},
+		{AccountID: 4, Amount: m("4.10"), Currency: "eur"},
+		{AccountID: 3, Amount: m("-5.00"), Currency: "usd"},
+		{AccountID: 1, Amount: m("5.00"), Currency: "usd"},
+	}
+	for i, e := range rev {
+		if e.AccountID != want[i].AccountID || e.Amount.Cmp(want[i].Amount) != 0 || e.Currency != want[i].Currency {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func Test_reversalEntriesWithFee(t *testing.T) {
+	m := money.MustParse
+	orig, err := FeeEntries(TransferEntries(1, 2, m("10.00"), "usd", m("10.00"), "usd", 0, 0), m("0.30"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orig[0].Amount.Cmp(m("-10.30")) != 0 {
+		t.Errorf("payer entry = %s, want -10.30", orig[0].Amount)
+	}
+	// fee stays on fee account
+	rev := ReversalEntries(orig, "usd", m("10.00"), m("10.00"), 5)
+	if err := CheckBalanced(rev); err != nil {
+		t.Fatal(err)
+	}
+	if len(rev) != 2 || rev[1].AccountID != 1 || rev[1].Amount.Cmp(m("10.00")) != 0 {
+		t.Errorf("reversal entries = %+v", rev)
+	}
+}
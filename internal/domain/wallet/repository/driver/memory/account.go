@@ -0,0 +1,597 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for accounts for work with memory store
+
+type MemAccount struct {
+	// db - store of driver
+	db *Memory
+
+	id       int64
+	name     string
+	balance  money.Amount
+	currency string
+	system   bool
+	status   string
+	// available - balance without active holds
+	available money.Amount
+	// creditLimit - overdraft limit, balance can go down to -creditLimit
+	creditLimit money.Amount
+}
+
+func (mem *MemAccount) ID() int64 {
+	return mem.id
+}
+func (mem *MemAccount) Name() string {
+	return mem.name
+}
+func (mem *MemAccount) Currency() string {
+	return mem.currency
+}
+func (mem *MemAccount) Balance() money.Amount {
+	return mem.balance
+}
+func (mem *MemAccount) AvailableBalance() money.Amount {
+	return mem.available
+}
+func (mem *MemAccount) CreditLimit() money.Amount {
+	return mem.creditLimit
+}
+func (mem *MemAccount) System() bool {
+	return mem.system
+}
+func (mem *MemAccount) Status() string {
+	return mem.status
+}
+
+// LedgerBalance - balance of account derived from ledger entries
+func (mem *MemAccount) LedgerBalance(ctx context.Context) (money.Amount, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var (
+		sum money.Amount
+		err error
+	)
+	for _, e := range mem.db.entries {
+		if e.AccountID == mem.id {
+			if sum, err = sum.Add(e.Amount); err != nil {
+				return money.Amount{}, err
+			}
+		}
+	}
+	return sum, nil
+}
+
+// Find - find wallet with name and load in object
+// system accounts can't be found by name
+func (mem *MemAccount) Find(ctx context.Context, name string) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	id, ok := mem.db.names[name]
+	if !ok || mem.db.accounts[id].system {
+		return errMemNoRows
+	}
+	return mem.load(id)
+}
+
+// Get - get wallet by ID and load in object
+func (mem *MemAccount) Get(ctx context.Context, id int64) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	return mem.load(id)
+}
+
+// load - copy account row with id to object, store must be locked
+func (mem *MemAccount) load(id int64) error {
+	a, ok := mem.db.accounts[id]
+	if !ok {
+		return errMemNoRows
+	}
+	held, err := mem.db.heldAmount(a.id)
+	if err != nil {
+		return err
+	}
+	available, err := a.balance.Sub(held)
+	if err != nil {
+		return err
+	}
+	*mem = MemAccount{
+		db:          mem.db,
+		id:          a.id,
+		name:        a.name,
+		balance:     driver.RoundAmount(a.balance, driver.AmountScale),
+		currency:    a.currency,
+		system:      a.system,
+		status:      a.status,
+		available:   driver.RoundAmount(available, driver.AmountScale),
+		creditLimit: driver.RoundAmount(a.creditLimit, driver.AmountScale),
+	}
+	return nil
+}
+
+// Deposit - add amount to account balance
+// money is debited from cash system account in account currency
+// returns ErrAccountFrozen or ErrAccountClosed if account is not active
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (mem *MemAccount) Deposit(ctx context.Context, amount money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if id, err := mem.db.findIdempotent(mem.id, idem); id != 0 || err != nil {
+		return id, err
+	}
+	a, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return 0, errMemNoRows
+	}
+	if err := driver.AccountStatusError(a.status, false); err != nil {
+		return 0, err
+	}
+
+	balance, err := a.balance.Add(amount)
+	if err != nil {
+		return 0, err
+	}
+
+	cashID := mem.db.systemAccountID(driver.SystemAccountCash, a.currency)
+	entries := []driver.LedgerEntry{
+		{AccountID: cashID, Amount: amount.Neg(), Currency: a.currency},
+		{AccountID: a.id, Amount: amount, Currency: a.currency},
+	}
+	paymentID, err := mem.db.addPayment(MemPayment{
+		kind:      driver.PaymentKindDeposit,
+		fromID:    cashID,
+		toID:      a.id,
+		amount:    amount,
+		toAmount:  amount,
+		rate:      money.New(1, 0),
+		toBalance: balance,
+		meta:      meta,
+	}, entries, a.id, idem)
+	if err != nil {
+		return 0, err
+	}
+	a.balance = balance
+
+	return paymentID, mem.load(mem.id)
+}
+
+// Withdraw - move amount out of wallet to external counterparty
+// money is credited to cash system account in account currency, counterparty is reference to external destination
+// function check that the account is active and the account available balance is sufficient
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (mem *MemAccount) Withdraw(ctx context.Context, amount money.Amount, counterparty string, idem *driver.Idempotency) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if id, err := mem.db.findIdempotent(mem.id, idem); id != 0 || err != nil {
+		return id, err
+	}
+	a, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return 0, errMemNoRows
+	}
+	if err := driver.AccountStatusError(a.status, false); err != nil {
+		return 0, err
+	}
+	held, err := mem.db.heldAmount(a.id)
+	if err != nil {
+		return 0, err
+	}
+	if err = driver.CheckAvailable(a.balance, held, a.creditLimit, amount); err != nil {
+		return 0, err
+	}
+	if err = mem.db.checkLimits(a.id, amount); err != nil {
+		return 0, err
+	}
+	balance, err := a.balance.Sub(amount)
+	if err != nil {
+		return 0, err
+	}
+
+	cashID := mem.db.systemAccountID(driver.SystemAccountCash, a.currency)
+	entries := []driver.LedgerEntry{
+		{AccountID: a.id, Amount: amount.Neg(), Currency: a.currency},
+		{AccountID: cashID, Amount: amount, Currency: a.currency},
+	}
+	paymentID, err := mem.db.addPayment(MemPayment{
+		kind:         driver.PaymentKindWithdrawal,
+		fromID:       a.id,
+		toID:         cashID,
+		amount:       amount,
+		toAmount:     amount,
+		rate:         money.New(1, 0),
+		counterparty: counterparty,
+	}, entries, a.id, idem)
+	if err != nil {
+		return 0, err
+	}
+	a.balance = balance
+
+	return paymentID, mem.load(mem.id)
+}
+
+// FindIdempotent - search payment created by account with idempotency key
+// returns (0, nil) if key is not used, else id of payment and ErrIdempotencyReplay or ErrIdempotencyConflict
+func (mem *MemAccount) FindIdempotent(ctx context.Context, idem *driver.Idempotency) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	return mem.db.findIdempotent(mem.id, idem)
+}
+
+// Transfer - creating a payment form account to account with id "toID"
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// (balance without active holds) together with credit limit of the account is sufficient
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (mem *MemAccount) Transfer(ctx context.Context, toID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount,
+	meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	paymentID, err := mem.transfer(toID, amount, conv, fee, meta, idem)
+	if err != nil {
+		return paymentID, err
+	}
+	return paymentID, mem.load(mem.id)
+}
+
+// transfer - execute transfer, store must be locked
+// nothing is changed if function returns error
+func (mem *MemAccount) transfer(toID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount,
+	meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	if id, err := mem.db.findIdempotent(mem.id, idem); id != 0 || err != nil {
+		return id, err
+	}
+	from, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return 0, errMemNoRows
+	}
+	to, ok := mem.db.accounts[toID]
+	if !ok {
+		return 0, driver.ErrRecipientNotFound
+	}
+	if err := driver.AccountStatusError(from.status, false); err != nil {
+		return 0, err
+	}
+	if err := driver.AccountStatusError(to.status, true); err != nil {
+		return 0, err
+	}
+	debit, err := amount.Add(fee)
+	if err != nil {
+		return 0, err
+	}
+	held, err := mem.db.heldAmount(from.id)
+	if err != nil {
+		return 0, err
+	}
+	if err = driver.CheckAvailable(from.balance, held, from.creditLimit, debit); err != nil {
+		return 0, err
+	}
+	if err = mem.db.checkLimits(from.id, amount); err != nil {
+		return 0, err
+	}
+
+	// amount in recipient currency
+	toAmount, rate, rateDate := amount, money.New(1, 0), (*time.Time)(nil)
+	entries := driver.TransferEntries(from.id, to.id, amount, from.currency, amount, to.currency, 0, 0)
+	if conv != nil {
+		toAmount, rate, rateDate = conv.ToAmount, conv.Rate, &conv.RateDate
+		entries = driver.TransferEntries(from.id, to.id, amount, from.currency, toAmount, to.currency,
+			mem.db.systemAccountID(driver.SystemAccountFx, from.currency), mem.db.systemAccountID(driver.SystemAccountFx, to.currency))
+	}
+	if !fee.IsZero() {
+		if entries, err = driver.FeeEntries(entries, fee, mem.db.systemAccountID(driver.SystemAccountFee, from.currency)); err != nil {
+			return 0, err
+		}
+	}
+	fromBalance, err := from.balance.Sub(debit)
+	if err != nil {
+		return 0, err
+	}
+	toBalance, err := to.balance.Add(toAmount)
+	if err != nil {
+		return 0, err
+	}
+
+	paymentID, err := mem.db.addPayment(MemPayment{
+		kind:      driver.PaymentKindTransfer,
+		fromID:    from.id,
+		toID:      to.id,
+		amount:    amount,
+		toAmount:  toAmount,
+		rate:      rate,
+		rateDate:  rateDate,
+		toBalance: toBalance,
+		fee:       fee,
+		meta:      meta,
+	}, entries, from.id, idem)
+	if err != nil {
+		return 0, err
+	}
+	from.balance, to.balance = fromBalance, toBalance
+
+	return paymentID, nil
+}
+
+// Hold - reserve amount of account for transfer to account with id "toID" for ttl
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// is sufficient
+// returning id of hold
+func (mem *MemAccount) Hold(ctx context.Context, toID int64, amount money.Amount, ttl time.Duration) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	to, ok := mem.db.accounts[toID]
+	if !ok || to.system {
+		return 0, driver.ErrRecipientNotFound
+	}
+	if err := driver.AccountStatusError(to.status, true); err != nil {
+		return 0, err
+	}
+	a, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return 0, errMemNoRows
+	}
+	if err := driver.AccountStatusError(a.status, false); err != nil {
+		return 0, err
+	}
+	held, err := mem.db.heldAmount(a.id)
+	if err != nil {
+		return 0, err
+	}
+	if err = driver.CheckAvailable(a.balance, held, a.creditLimit, amount); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	h := &memHoldRow{Hold: driver.Hold{
+		ID:        int64(len(mem.db.holds)) + 1,
+		AccountID: a.id,
+		ToID:      toID,
+		Amount:    driver.RoundAmount(amount, driver.AmountScale),
+		Status:    driver.HoldStatusActive,
+		ExpiresAt: now.Add(ttl),
+		Date:      now,
+	}}
+	mem.db.holds = append(mem.db.holds, h)
+
+	return h.ID, mem.load(mem.id)
+}
+
+// GetHold - return hold of account by id
+// status of expired hold which was not processed by ExpireHolds yet is returned as expired
+func (mem *MemAccount) GetHold(ctx context.Context, holdID int64) (driver.Hold, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	h, err := mem.hold(holdID)
+	if err != nil {
+		return driver.Hold{}, err
+	}
+	res := h.Hold
+	if res.Status == driver.HoldStatusActive && !h.active(time.Now()) {
+		res.Status = driver.HoldStatusExpired
+	}
+	return res, nil
+}
+
+// hold - return hold row of account by id, store must be locked
+func (mem *MemAccount) hold(holdID int64) (*memHoldRow, error) {
+	if holdID <= 0 || holdID > int64(len(mem.db.holds)) || mem.db.holds[holdID-1].AccountID != mem.id {
+		return nil, driver.ErrHoldNotFound
+	}
+	return mem.db.holds[holdID-1], nil
+}
+
+// Capture - turn hold into transfer of amount to recipient of hold
+// amount can be less than amount of hold, the rest of hold is released
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// returning id of payment
+func (mem *MemAccount) Capture(ctx context.Context, holdID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	h, err := mem.hold(holdID)
+	if err != nil {
+		return 0, err
+	}
+	if !h.active(time.Now()) {
+		return 0, driver.ErrHoldNotActive
+	}
+	if amount.Cmp(h.Amount) > 0 {
+		return 0, driver.ErrHoldExceedsAmount
+	}
+
+	// hold stops to reduce available balance before transfer checks it
+	h.Status = driver.HoldStatusCaptured
+	paymentID, err := mem.transfer(h.ToID, amount, conv, fee, driver.Metadata{}, nil)
+	if err != nil {
+		h.Status = driver.HoldStatusActive
+		return 0, err
+	}
+	h.capturedAmount = amount
+	h.PaymentID = paymentID
+
+	return paymentID, mem.load(mem.id)
+}
+
+// Release - release active hold, reserved amount becomes available again
+func (mem *MemAccount) Release(ctx context.Context, holdID int64) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	h, err := mem.hold(holdID)
+	if err != nil {
+		return err
+	}
+	if !h.active(time.Now()) {
+		return driver.ErrHoldNotActive
+	}
+	h.Status = driver.HoldStatusReleased
+
+	return mem.load(mem.id)
+}
+
+// ExpireHolds - set status "expired" for all active holds with expired ttl
+// returning count of expired holds
+func (mem *MemAccount) ExpireHolds(ctx context.Context) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var n int64
+	now := time.Now()
+	for _, h := range mem.db.holds {
+		if h.Status == driver.HoldStatusActive && !h.active(now) {
+			h.Status = driver.HoldStatusExpired
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Limits - return spending limits of account, zero limits if they are not set
+func (mem *MemAccount) Limits(ctx context.Context) (driver.Limits, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	return mem.db.limits[mem.id], nil
+}
+
+// SetLimits - set spending limits of account
+func (mem *MemAccount) SetLimits(ctx context.Context, l driver.Limits) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	l.MaxAmount = driver.RoundAmount(l.MaxAmount, driver.AmountScale)
+	l.DailyAmount = driver.RoundAmount(l.DailyAmount, driver.AmountScale)
+	mem.db.limits[mem.id] = l
+	return nil
+}
+
+// Spending - return total amount and count of outgoing transfers and withdrawals of account since time
+func (mem *MemAccount) Spending(ctx context.Context, since time.Time) (driver.Spending, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	return mem.db.spending(mem.id, since)
+}
+
+// Create - create a new account with name and currency and load one in object
+// this function not validate name and currency
+func (mem *MemAccount) Create(ctx context.Context, name, currency string) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if len(name) > memMaxNameLength {
+		return errMemNameTooLong
+	}
+	if _, ok := mem.db.names[name]; ok {
+		return errMemDuplicate
+	}
+	mem.db.lastAccountID++
+	a := &memAccountRow{
+		id:       mem.db.lastAccountID,
+		name:     name,
+		currency: currency,
+		status:   driver.AccountStatusActive,
+	}
+	mem.db.accounts[a.id] = a
+	mem.db.names[name] = a.id
+
+	return mem.load(a.id)
+}
+
+// SetStatus - change status of account
+// closed account can't change status, returns ErrAccountClosed
+// account can be closed only if its balance is zero and it has no active holds, else returns ErrAccountNotEmpty
+func (mem *MemAccount) SetStatus(ctx context.Context, status string) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	a, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return errMemNoRows
+	}
+	if a.status == driver.AccountStatusClosed {
+		return driver.ErrAccountClosed
+	}
+	if status == driver.AccountStatusClosed {
+		held, err := mem.db.heldAmount(a.id)
+		if err != nil {
+			return err
+		}
+		if !a.balance.IsZero() || !held.IsZero() {
+			return driver.ErrAccountNotEmpty
+		}
+	}
+	a.status = status
+
+	return mem.load(mem.id)
+}
+
+// SetCreditLimit - set overdraft limit of account, balance of account can go down to -limit
+// closed account can't change limit, returns ErrAccountClosed
+func (mem *MemAccount) SetCreditLimit(ctx context.Context, limit money.Amount) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	a, ok := mem.db.accounts[mem.id]
+	if !ok {
+		return errMemNoRows
+	}
+	if a.status == driver.AccountStatusClosed {
+		return driver.ErrAccountClosed
+	}
+	a.creditLimit = driver.RoundAmount(limit, driver.AmountScale)
+
+	return mem.load(mem.id)
+}
+
+// Delete - delete wallet account physically
+// payments of account are kept and refer to missing account, so in the application accounts are closed
+// with SetStatus instead. Delete is used only to clean up test data
+func (mem *MemAccount) Delete(ctx context.Context) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if a, ok := mem.db.accounts[mem.id]; ok {
+		delete(mem.db.names, a.name)
+		delete(mem.db.accounts, a.id)
+	}
+	return nil
+}
+
+// List - return list of all wallets account names
+// Wallets listed ordering by id, system accounts are not listed
+// if after > 0, only accounts with id greater than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (mem *MemAccount) List(ctx context.Context, after, offset, limit int64) ([]int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var ids []int64
+	for _, id := range sortedIDs(mem.db.accounts) {
+		if !mem.db.accounts[id].system && id > after {
+			ids = append(ids, id)
+		}
+	}
+	return page(ids, offset, limit), nil
+}
@@ -0,0 +1,305 @@
+// in-memory driver for data manipulation for repository entities
+
+// all data is kept in process memory and lost on exit, driver is intended for tests and local development
+// every operation is executed under one lock of the store, so transfers, captures and reversals are atomic
+// and see consistent state the same way as transactions of PostgreSQL driver
+// operations are not blocked on I/O, so context of request is accepted by methods but not checked
+
+// Package memory registers storage driver in process memory with name "memory"
+// import it for side effect to make driver available for repository.Open
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+var (
+	// errMemNoRows is returned when record is not found, the same as pgx.ErrNoRows for PostgreSQL driver
+	errMemNoRows = errors.New("no rows in result set")
+	// errMemDuplicate is returned when account with the same name already exists
+	errMemDuplicate = errors.New("duplicate key value violates unique constraint \"accounts_name\"")
+	// errMemNameTooLong is returned when name of account is longer than memMaxNameLength
+	errMemNameTooLong = errors.New("value too long for type character varying(32)")
+)
+
+// memMaxNameLength - maximal length of account name, the same as in PostgreSQL database
+const memMaxNameLength = 32
+
+// Memory - tables of memory driver shared by account and payment repositories
+type Memory struct {
+	mu sync.Mutex
+
+	accounts map[int64]*memAccountRow
+	// names - index of accounts by name
+	names map[string]int64
+	// payments and holds are never deleted, id of row is index in slice + 1
+	payments []*memPaymentRow
+	holds    []*memHoldRow
+	entries  []driver.LedgerEntry
+	// scheduled transfers and their executions are never deleted, id of row is index in slice + 1
+	schedules  []*driver.ScheduledTransfer
+	executions []driver.ScheduleExecution
+	// limits - spending limits by id of account
+	limits map[int64]driver.Limits
+
+	lastAccountID int64
+}
+
+type memAccountRow struct {
+	id       int64
+	name     string
+	balance  money.Amount
+	currency string
+	system   bool
+	status   string
+	// creditLimit - overdraft limit, balance can go down to -creditLimit
+	creditLimit money.Amount
+}
+
+type memPaymentRow struct {
+	MemPayment
+	// idempotency key of request created payment, idemAccount is 0 if payment has no key
+	idemAccount int64
+	idemKey     string
+	idemHash    string
+	// refAccount - account owning external reference of payment, 0 if payment has no reference
+	refAccount int64
+}
+
+type memHoldRow struct {
+	driver.Hold
+	capturedAmount money.Amount
+}
+
+func init() {
+	repository.Register("memory", Open)
+}
+
+// Open - create store in process memory, every opened driver has its own store
+func Open(repository.Config) (repository.Driver, error) {
+	return NewMemory(), nil
+}
+
+// NewMemory - create empty store, every store has its own data
+func NewMemory() *Memory {
+	return &Memory{
+		accounts: make(map[int64]*memAccountRow),
+		names:    make(map[string]int64),
+		limits:   make(map[int64]driver.Limits),
+	}
+}
+
+// Close - nothing to close for memory store, data is kept while store is used
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Account - create account repository using store m
+func (m *Memory) Account() repository.Account {
+	return &MemAccount{db: m}
+}
+
+// Payment - create payment repository using store m
+func (m *Memory) Payment() repository.Payment {
+	return &MemPayment{db: m}
+}
+
+// Schedule - create repository of scheduled transfers using store m
+func (m *Memory) Schedule() repository.Schedule {
+	return &MemSchedule{db: m}
+}
+
+// active checking that hold reduces available balance at time now
+func (h *memHoldRow) active(now time.Time) bool {
+	return h.Status == driver.HoldStatusActive && h.ExpiresAt.After(now)
+}
+
+// heldAmount - sum of active holds of account
+func (m *Memory) heldAmount(accountID int64) (money.Amount, error) {
+	var (
+		sum money.Amount
+		err error
+	)
+	now := time.Now()
+	for _, h := range m.holds {
+		if h.AccountID == accountID && h.active(now) {
+			if sum, err = sum.Add(h.Amount); err != nil {
+				return money.Amount{}, err
+			}
+		}
+	}
+	return sum, nil
+}
+
+// spending - total amount and count of outgoing transfers and withdrawals of account since time
+func (m *Memory) spending(accountID int64, since time.Time) (driver.Spending, error) {
+	var (
+		s   driver.Spending
+		err error
+	)
+	for _, p := range m.payments {
+		outgoing := p.kind == driver.PaymentKindTransfer || p.kind == driver.PaymentKindWithdrawal
+		if p.fromID == accountID && outgoing && !p.date.Before(since) {
+			if s.Amount, err = s.Amount.Add(p.amount); err != nil {
+				return driver.Spending{}, err
+			}
+			s.Count++
+		}
+	}
+	return s, nil
+}
+
+// checkLimits - check that payment of amount from account doesn't exceed its limits, store must be locked
+func (m *Memory) checkLimits(accountID int64, amount money.Amount) error {
+	l := m.limits[accountID]
+	var (
+		s   driver.Spending
+		err error
+	)
+	if l.Daily() {
+		if s, err = m.spending(accountID, driver.LimitsDay(time.Now())); err != nil {
+			return err
+		}
+	}
+	return l.Check(s, amount)
+}
+
+// systemAccountID - return id of system account of kind for currency, account is created if it not exists
+func (m *Memory) systemAccountID(kind, currency string) int64 {
+	name := driver.SystemAccountName(kind, currency)
+	if id, ok := m.names[name]; ok {
+		return id
+	}
+	m.lastAccountID++
+	a := &memAccountRow{
+		id:       m.lastAccountID,
+		name:     name,
+		currency: currency,
+		system:   true,
+		status:   driver.AccountStatusActive,
+	}
+	m.accounts[a.id] = a
+	m.names[name] = a.id
+	return a.id
+}
+
+// findIdempotent - search payment created by account with idempotency key
+// returns the same results as pgFindIdempotentPayment
+func (m *Memory) findIdempotent(accountID int64, idem *driver.Idempotency) (int64, error) {
+	if idem == nil {
+		return 0, nil
+	}
+	for _, p := range m.payments {
+		if p.idemAccount == accountID && p.idemKey == idem.Key {
+			if p.idemHash != idem.Hash {
+				return p.id, driver.ErrIdempotencyConflict
+			}
+			return p.id, driver.ErrIdempotencyReplay
+		}
+	}
+	return 0, nil
+}
+
+// findByExternalRef - id of payment created by account with accountID with external reference ref
+// returns ErrPaymentNotFound if payment not exists
+func (m *Memory) findByExternalRef(accountID int64, ref string) (int64, error) {
+	for _, p := range m.payments {
+		if p.refAccount == accountID && p.meta.ExternalRef == ref {
+			return p.id, nil
+		}
+	}
+	return 0, driver.ErrPaymentNotFound
+}
+
+// addPayment - save payment with its ledger entries
+// entries are checked before anything is saved, so unbalanced transaction leaves store unchanged
+// external reference of payment metadata belongs to account with accountID,
+// returns ErrExternalRefDuplicate if the account already has payment with this reference
+func (m *Memory) addPayment(p MemPayment, entries []driver.LedgerEntry, accountID int64, idem *driver.Idempotency) (int64, error) {
+	if err := driver.CheckBalanced(entries); err != nil {
+		return 0, err
+	}
+	if p.meta.ExternalRef != "" {
+		if _, err := m.findByExternalRef(accountID, p.meta.ExternalRef); err == nil {
+			return 0, driver.ErrExternalRefDuplicate
+		}
+	}
+	p.db = m
+	p.id = int64(len(m.payments)) + 1
+	p.date = time.Now()
+	p.amount = driver.RoundAmount(p.amount, driver.AmountScale)
+	p.toAmount = driver.RoundAmount(p.toAmount, driver.AmountScale)
+	p.toBalance = driver.RoundAmount(p.toBalance, driver.AmountScale)
+	p.rate = driver.RoundAmount(p.rate, driver.RateScale)
+	p.fee = driver.RoundAmount(p.fee, driver.AmountScale)
+	p.meta.Tags = copyTags(p.meta.Tags)
+	row := &memPaymentRow{MemPayment: p}
+	if idem != nil {
+		row.idemAccount, row.idemKey, row.idemHash = accountID, idem.Key, idem.Hash
+	}
+	if p.meta.ExternalRef != "" {
+		row.refAccount = accountID
+	}
+	m.payments = append(m.payments, row)
+	for _, e := range entries {
+		e.ID = int64(len(m.entries)) + 1
+		e.PaymentID = p.id
+		e.Date = p.date
+		e.Amount = driver.RoundAmount(e.Amount, driver.AmountScale)
+		m.entries = append(m.entries, e)
+	}
+	return p.id, nil
+}
+
+// paymentEntries - ledger entries of payment ordered by id
+func (m *Memory) paymentEntries(paymentID int64) []driver.LedgerEntry {
+	var res []driver.LedgerEntry
+	for _, e := range m.entries {
+		if e.PaymentID == paymentID {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// page - return slice of ids bounded by offset and limit
+// if limit = -1, then no limit
+func page(ids []int64, offset, limit int64) []int64 {
+	if offset >= int64(len(ids)) {
+		return nil
+	}
+	ids = ids[offset:]
+	if limit >= 0 && limit < int64(len(ids)) {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+// sortedIDs - ids of map sorted ascending
+func sortedIDs(m map[int64]*memAccountRow) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// copyTags - copy of tags of payment, so stored payment can't be changed through returned value
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	res := make(map[string]string, len(tags))
+	for k, v := range tags {
+		res[k] = v
+	}
+	return res
+}
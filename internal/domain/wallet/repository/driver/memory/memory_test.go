@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_MemoryBalanceOverflow(t *testing.T) {
+	db := NewMemory()
+	ctx := context.Background()
+	a := db.Account()
+	if err := a.Create(ctx, "memwallet1", "usd"); err != nil {
+		t.Fatal(err)
+	}
+	// balance close to maximal value of int64 minor units
+	db.accounts[a.ID()].balance = money.New(1<<63-1-5000, driver.AmountScale)
+
+	if _, err := a.Deposit(ctx, money.MustParse("1"), driver.Metadata{}, nil); err != money.ErrRange {
+		t.Fatalf("Deposit() error = %v, want %v", err, money.ErrRange)
+	}
+	if err := a.Get(ctx, a.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance().Sign() <= 0 {
+		t.Errorf("balance = %s, deposit over range is saved", a.Balance())
+	}
+	if ids, err := db.Payment().Unbalanced(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("Unbalanced() = %v, %v", ids, err)
+	}
+}
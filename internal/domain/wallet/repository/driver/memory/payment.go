@@ -0,0 +1,286 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for payments for work with memory store
+
+type MemPayment struct {
+	// db - store of driver
+	db *Memory
+
+	id        int64
+	kind      string
+	date      time.Time
+	amount    money.Amount
+	toAmount  money.Amount
+	rate      money.Amount
+	rateDate  *time.Time
+	toBalance money.Amount
+	// counterparty - reference to external destination of withdrawal
+	counterparty string
+	// reversalOf - id of payment compensated by this reversal
+	reversalOf int64
+	// fee - fee of transfer charged to payer in addition to amount
+	fee    money.Amount
+	fromID int64
+	toID   int64
+	// meta - client supplied description, external reference and tags of payment
+	meta driver.Metadata
+}
+
+func (mem MemPayment) ID() int64 {
+	return mem.id
+}
+func (mem MemPayment) Kind() string {
+	return mem.kind
+}
+func (mem MemPayment) Date() time.Time {
+	return mem.date
+}
+func (mem MemPayment) Amount() money.Amount {
+	return mem.amount
+}
+func (mem MemPayment) From() int64 {
+	return mem.fromID
+}
+func (mem MemPayment) To() int64 {
+	return mem.toID
+}
+func (mem MemPayment) ToAmount() money.Amount {
+	return mem.toAmount
+}
+func (mem MemPayment) Rate() money.Amount {
+	return mem.rate
+}
+func (mem MemPayment) RateDate() time.Time {
+	if mem.rateDate == nil {
+		return time.Time{}
+	}
+	return *mem.rateDate
+}
+func (mem MemPayment) ToBalance() money.Amount {
+	return mem.toBalance
+}
+func (mem MemPayment) Counterparty() string {
+	return mem.counterparty
+}
+func (mem MemPayment) ReversalOf() int64 {
+	return mem.reversalOf
+}
+func (mem MemPayment) Fee() money.Amount {
+	return mem.fee
+}
+
+func (mem MemPayment) Metadata() driver.Metadata {
+	m := mem.meta
+	m.Tags = copyTags(m.Tags)
+	return m
+}
+
+// Entries return ledger entries of payment
+func (mem MemPayment) Entries(ctx context.Context) ([]driver.LedgerEntry, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	return mem.db.paymentEntries(mem.id), nil
+}
+
+// Unbalanced - return ids of payments which ledger entries don't sum to zero in some currency
+// or which have no entries at all. Empty result means that ledger is consistent
+func (mem MemPayment) Unbalanced(ctx context.Context) ([]int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var res []int64
+	for _, p := range mem.db.payments {
+		if driver.CheckBalanced(mem.db.paymentEntries(p.id)) != nil {
+			res = append(res, p.id)
+		}
+	}
+	return res, nil
+}
+
+// Get - get payment by ID and load in object
+func (mem *MemPayment) Get(ctx context.Context, id int64) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if id <= 0 || id > int64(len(mem.db.payments)) {
+		return errMemNoRows
+	}
+	*mem = mem.db.payments[id-1].MemPayment
+	return nil
+}
+
+// FindByExternalRef - find payment created by account with accountID with external reference and load in object
+// returns ErrPaymentNotFound if payment not exists
+func (mem *MemPayment) FindByExternalRef(ctx context.Context, accountID int64, ref string) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	id, err := mem.db.findByExternalRef(accountID, ref)
+	if err != nil {
+		return err
+	}
+	*mem = mem.db.payments[id-1].MemPayment
+	return nil
+}
+
+// List - return list of payments for account with accountID which meet conditions of filter
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (mem MemPayment) List(ctx context.Context, accountID int64, filter driver.PaymentFilter, after, offset, limit int64) ([]interface{}, error) {
+	return mem.list(func(p *memPaymentRow) bool {
+		return (p.fromID == accountID || p.toID == accountID) && filter.Match(accountID, p)
+	}, after, offset, limit), nil
+}
+
+// ListAll - return list of payments
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (mem MemPayment) ListAll(ctx context.Context, after, offset, limit int64) ([]interface{}, error) {
+	return mem.list(func(*memPaymentRow) bool { return true }, after, offset, limit), nil
+}
+
+// list - return payments matched by filter ordering by id descending, beginning after payment with id after
+func (mem MemPayment) list(filter func(p *memPaymentRow) bool, after, offset, limit int64) []interface{} {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var ids []int64
+	for i := len(mem.db.payments) - 1; i >= 0; i-- {
+		if p := mem.db.payments[i]; (after <= 0 || p.id < after) && filter(p) {
+			ids = append(ids, p.id)
+		}
+	}
+
+	var res []interface{}
+	for _, id := range page(ids, offset, limit) {
+		p := mem.db.payments[id-1].MemPayment
+		res = append(res, &p)
+	}
+	return res
+}
+
+// Reverse - create payment which compensates loaded payment, money goes back from recipient to payer
+// amount is in payer currency of original payment and toAmount is in its recipient currency
+// if amount is zero the whole not reversed rest of payment is returned
+// total amount of reversals can't exceed amount of payment. When reversal closes the rest of payment,
+// toAmount is set to the rest of recipient amount, so rounding of partial reversals never leaves a remainder
+// available balance of recipient is checked unless force is set, balances of system accounts are never checked
+// closed accounts can't take part in reversal, frozen accounts only if force is set
+func (mem *MemPayment) Reverse(ctx context.Context, amount, toAmount money.Amount, force bool) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if mem.id <= 0 || mem.id > int64(len(mem.db.payments)) {
+		return 0, errMemNoRows
+	}
+	orig := mem.db.payments[mem.id-1]
+	if orig.kind == driver.PaymentKindReversal {
+		return 0, driver.ErrReversalNotAllowed
+	}
+
+	// not reversed rest of payment
+	rest, restTo := orig.amount, orig.toAmount
+	for _, p := range mem.db.payments {
+		if p.reversalOf == orig.id {
+			var err error
+			if rest, err = rest.Sub(p.toAmount); err != nil {
+				return 0, err
+			}
+			if restTo, err = restTo.Sub(p.amount); err != nil {
+				return 0, err
+			}
+		}
+	}
+	switch {
+	case rest.Sign() <= 0 || amount.Cmp(rest) > 0:
+		return 0, driver.ErrReversalExceedsAmount
+	case amount.IsZero() || amount.Cmp(rest) == 0:
+		amount, toAmount = rest, restTo
+	case toAmount.Cmp(restTo) > 0:
+		toAmount = restTo
+	}
+
+	// money goes back from recipient of original payment
+	payer, okPayer := mem.db.accounts[orig.toID]
+	recipient, okRecipient := mem.db.accounts[orig.fromID]
+	if !okPayer || !okRecipient {
+		return 0, driver.ErrAccountNotFound
+	}
+	for _, a := range []struct {
+		status    string
+		recipient bool
+	}{{payer.status, false}, {recipient.status, true}} {
+		if a.status == driver.AccountStatusClosed || !force {
+			if err := driver.AccountStatusError(a.status, a.recipient); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if !payer.system && !force {
+		held, err := mem.db.heldAmount(payer.id)
+		if err != nil {
+			return 0, err
+		}
+		if err = driver.CheckAvailable(payer.balance, held, payer.creditLimit, toAmount); err != nil {
+			return 0, err
+		}
+	}
+	payerBalance, err := payer.balance.Sub(toAmount)
+	if err != nil {
+		return 0, err
+	}
+	recipientBalance, err := recipient.balance.Add(amount)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := mem.db.paymentEntries(orig.id)
+	if len(entries) == 0 {
+		return 0, driver.ErrReversalNotAllowed
+	}
+	// fee of original transfer is not returned
+	var feeID int64
+	if !orig.fee.IsZero() {
+		feeID = mem.db.systemAccountID(driver.SystemAccountFee, recipient.currency)
+	}
+
+	p := MemPayment{
+		kind:       driver.PaymentKindReversal,
+		fromID:     payer.id,
+		toID:       recipient.id,
+		amount:     toAmount,
+		toAmount:   amount,
+		rate:       money.New(1, 0),
+		reversalOf: orig.id,
+	}
+	if !recipient.system {
+		p.toBalance = recipientBalance
+	}
+	paymentID, err := mem.db.addPayment(p, driver.ReversalEntries(entries, recipient.currency, amount, toAmount, feeID), 0, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// balances of system accounts are not stored
+	if !payer.system {
+		payer.balance = payerBalance
+	}
+	if !recipient.system {
+		recipient.balance = recipientBalance
+	}
+	return paymentID, nil
+}
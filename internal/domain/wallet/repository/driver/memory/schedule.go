@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Driver for scheduled transfers for work with memory store
+
+type MemSchedule struct {
+	// db - store of driver
+	db *Memory
+}
+
+// schedule - return scheduled transfer row of account by id, store must be locked
+func (mem *MemSchedule) schedule(accountID, id int64) (*driver.ScheduledTransfer, error) {
+	if id <= 0 || id > int64(len(mem.db.schedules)) || mem.db.schedules[id-1].AccountID != accountID {
+		return nil, driver.ErrScheduleNotFound
+	}
+	return mem.db.schedules[id-1], nil
+}
+
+// Create - save new active scheduled transfer
+// returning id of scheduled transfer
+func (mem *MemSchedule) Create(ctx context.Context, t driver.ScheduledTransfer) (int64, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	t.ID = int64(len(mem.db.schedules)) + 1
+	t.Amount = driver.RoundAmount(t.Amount, driver.AmountScale)
+	t.Status = driver.ScheduleStatusActive
+	t.Date = time.Now()
+	mem.db.schedules = append(mem.db.schedules, &t)
+	return t.ID, nil
+}
+
+// Get - return scheduled transfer of account with accountID by id
+func (mem *MemSchedule) Get(ctx context.Context, accountID, id int64) (driver.ScheduledTransfer, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	t, err := mem.schedule(accountID, id)
+	if err != nil {
+		return driver.ScheduledTransfer{}, err
+	}
+	return *t, nil
+}
+
+// List - return scheduled transfers of account with accountID
+// scheduled transfers listed ordering by id
+// if limit = -1, then no limit
+func (mem *MemSchedule) List(ctx context.Context, accountID, offset, limit int64) ([]driver.ScheduledTransfer, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var ids []int64
+	for _, t := range mem.db.schedules {
+		if t.AccountID == accountID {
+			ids = append(ids, t.ID)
+		}
+	}
+	var res []driver.ScheduledTransfer
+	for _, id := range page(ids, offset, limit) {
+		res = append(res, *mem.db.schedules[id-1])
+	}
+	return res, nil
+}
+
+// Update - save amount, recurrence, day and next run time of active scheduled transfer
+func (mem *MemSchedule) Update(ctx context.Context, t driver.ScheduledTransfer) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	cur, err := mem.schedule(t.AccountID, t.ID)
+	if err != nil {
+		return err
+	}
+	if cur.Status != driver.ScheduleStatusActive {
+		return driver.ErrScheduleNotActive
+	}
+	cur.Amount = driver.RoundAmount(t.Amount, driver.AmountScale)
+	cur.Recurrence, cur.Day, cur.NextRunAt = t.Recurrence, t.Day, t.NextRunAt
+	return nil
+}
+
+// Cancel - set status "cancelled" for active scheduled transfer of account with accountID
+func (mem *MemSchedule) Cancel(ctx context.Context, accountID, id int64) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	t, err := mem.schedule(accountID, id)
+	if err != nil {
+		return err
+	}
+	if t.Status != driver.ScheduleStatusActive {
+		return driver.ErrScheduleNotActive
+	}
+	t.Status = driver.ScheduleStatusCancelled
+	return nil
+}
+
+// Due - return active scheduled transfers which next run time is not after now
+// scheduled transfers listed ordering by next run time
+func (mem *MemSchedule) Due(ctx context.Context, now time.Time, limit int64) ([]driver.ScheduledTransfer, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var res []driver.ScheduledTransfer
+	for _, t := range mem.db.schedules {
+		if t.Status == driver.ScheduleStatusActive && !t.NextRunAt.After(now) {
+			res = append(res, *t)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].NextRunAt.Before(res[j].NextRunAt) })
+	if limit >= 0 && int64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// Complete - record execution of scheduled transfer planned at e.RunAt and plan the next execution at next
+// if next is zero transfer becomes completed. Execution is recorded only once for every planned time,
+// ErrScheduleExecuted is returned if it was already recorded. When transfer was changed or cancelled
+// after it was taken for execution, execution is recorded but the next execution is not changed
+func (mem *MemSchedule) Complete(ctx context.Context, e driver.ScheduleExecution, next time.Time) error {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	if e.ScheduleID <= 0 || e.ScheduleID > int64(len(mem.db.schedules)) {
+		return driver.ErrScheduleNotFound
+	}
+	t := mem.db.schedules[e.ScheduleID-1]
+	for _, ex := range mem.db.executions {
+		if ex.ScheduleID == e.ScheduleID && ex.RunAt.Equal(e.RunAt) {
+			return driver.ErrScheduleExecuted
+		}
+	}
+
+	e.ID = int64(len(mem.db.executions)) + 1
+	e.Date = time.Now()
+	mem.db.executions = append(mem.db.executions, e)
+	if t.Status != driver.ScheduleStatusActive || !t.NextRunAt.Equal(e.RunAt) {
+		return nil
+	}
+	if next.IsZero() {
+		t.Status = driver.ScheduleStatusCompleted
+	} else {
+		t.NextRunAt = next
+	}
+	return nil
+}
+
+// Executions - return results of executions of scheduled transfer with scheduleID
+// executions listed ordering by id
+// if limit = -1, then no limit
+func (mem *MemSchedule) Executions(ctx context.Context, scheduleID, offset, limit int64) ([]driver.ScheduleExecution, error) {
+	mem.db.mu.Lock()
+	defer mem.db.mu.Unlock()
+
+	var ids []int64
+	for _, e := range mem.db.executions {
+		if e.ScheduleID == scheduleID {
+			ids = append(ids, e.ID)
+		}
+	}
+	var res []driver.ScheduleExecution
+	for _, id := range page(ids, offset, limit) {
+		res = append(res, mem.db.executions[id-1])
+	}
+	return res, nil
+}
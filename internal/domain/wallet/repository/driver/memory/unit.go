@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Unit of work of memory driver
+// unit works with copy of store, the copy replaces tables of store when unit is committed.
+// Store is locked while unit is active, so other operations wait until unit is finished
+
+// errMemUnitDone is returned when unit is committed twice
+var errMemUnitDone = errors.New("unit of work is already committed or rolled back")
+
+// MemoryUnit - unit of work of memory driver
+type MemoryUnit struct {
+	db     *Memory
+	parent *Memory
+	// done - unit is committed or rolled back
+	done bool
+}
+
+// Begin - start unit of work, changes of its repositories are visible only in unit until Commit
+// unit must be finished by Commit or Rollback
+func (m *Memory) Begin(context.Context) (repository.UnitOfWork, error) {
+	m.mu.Lock()
+	return &MemoryUnit{db: m.clone(), parent: m}, nil
+}
+
+// Account - create account repository of unit
+func (u *MemoryUnit) Account() repository.Account {
+	return u.db.Account()
+}
+
+// Payment - create payment repository of unit
+func (u *MemoryUnit) Payment() repository.Payment {
+	return u.db.Payment()
+}
+
+// Schedule - create repository of scheduled transfers of unit
+func (u *MemoryUnit) Schedule() repository.Schedule {
+	return u.db.Schedule()
+}
+
+// Commit - replace tables of store by tables of unit
+func (u *MemoryUnit) Commit(context.Context) error {
+	if u.done {
+		return errMemUnitDone
+	}
+	u.done = true
+
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	// payments created in unit refer to store of unit
+	for _, p := range u.db.payments {
+		if p.db == u.db {
+			p.db = u.parent
+		}
+	}
+	p := u.parent
+	p.accounts, p.names, p.payments, p.holds, p.entries = u.db.accounts, u.db.names, u.db.payments, u.db.holds, u.db.entries
+	p.schedules, p.executions, p.limits = u.db.schedules, u.db.executions, u.db.limits
+	p.lastAccountID = u.db.lastAccountID
+	p.mu.Unlock()
+	return nil
+}
+
+// Rollback - discard changes of unit, after Commit it does nothing
+func (u *MemoryUnit) Rollback(context.Context) error {
+	if u.done {
+		return nil
+	}
+	u.done = true
+	u.parent.mu.Unlock()
+	return nil
+}
+
+// clone - copy of tables of store, rows changed by operations are copied
+// payments, ledger entries and executions of scheduled transfers are never changed, so copy shares them
+func (m *Memory) clone() *Memory {
+	c := &Memory{
+		accounts:      make(map[int64]*memAccountRow, len(m.accounts)),
+		names:         make(map[string]int64, len(m.names)),
+		payments:      append([]*memPaymentRow(nil), m.payments...),
+		holds:         make([]*memHoldRow, len(m.holds)),
+		entries:       append([]driver.LedgerEntry(nil), m.entries...),
+		schedules:     make([]*driver.ScheduledTransfer, len(m.schedules)),
+		executions:    append([]driver.ScheduleExecution(nil), m.executions...),
+		limits:        make(map[int64]driver.Limits, len(m.limits)),
+		lastAccountID: m.lastAccountID,
+	}
+	for id, a := range m.accounts {
+		row := *a
+		c.accounts[id] = &row
+	}
+	for name, id := range m.names {
+		c.names[name] = id
+	}
+	for i, h := range m.holds {
+		row := *h
+		c.holds[i] = &row
+	}
+	for i, t := range m.schedules {
+		row := *t
+		c.schedules[i] = &row
+	}
+	for id, l := range m.limits {
+		c.limits[id] = l
+	}
+	return c
+}
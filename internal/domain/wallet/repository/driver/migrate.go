@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//
+// Versioned migrations of database schema
+// migrations of every database engine are embedded SQL files in directory migrations/<engine>
+// with names NNNN_name.up.sql and NNNN_name.down.sql, where NNNN is version of migration.
+// Applied migrations are stored in table schema_migrations, every migration is applied in own transaction
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+var (
+	// ErrSchemaOutdated is returned when database has migrations which are not applied
+	ErrSchemaOutdated = errors.New("database schema is outdated")
+	// ErrSchemaUnknown is returned when database has applied migrations which are unknown for this version of wallet
+	ErrSchemaUnknown = errors.New("database schema is newer than supported")
+)
+
+// migrationFileRe - name of migration file
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration - one version of database schema
+type Migration struct {
+	Version int64
+	Name    string
+	// Up, Down - SQL applying and reverting migration
+	Up   string
+	Down string
+}
+
+// MigrationStatus - migration and time of applying, AppliedAt is zero for pending migration
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Applied - is migration applied to database
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// Migrations - return migrations of database engine ("postgresql" or "sqlite") ordered by version
+func Migrations(engine string) ([]Migration, error) {
+	dir := path.Join("migrations", engine)
+	files, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %v", engine, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, f := range files {
+		m := migrationFileRe.FindStringSubmatch(f.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid name of migration file %s", f.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		data, err := fs.ReadFile(migrationsFS, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		} else if mg.Name != m[2] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(data)
+		} else {
+			mg.Down = string(data)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have up and down files", mg.Version, mg.Name)
+		}
+		res = append(res, *mg)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// MigrationStore - database which schema is changed by migrations
+type MigrationStore interface {
+	// AppliedMigrations return versions of applied migrations with time of applying
+	// table schema_migrations is created if it is not exists
+	AppliedMigrations(ctx context.Context) (map[int64]time.Time, error)
+	// ApplyMigration execute SQL of migration and save (up) or delete (down) its version in one transaction
+	ApplyMigration(ctx context.Context, m Migration, up bool) error
+}
+
+// MigrationStatuses - return all known migrations with time of applying
+// error ErrSchemaUnknown is returned when database has applied migrations unknown for this version of wallet
+func MigrationStatuses(ctx context.Context, store MigrationStore, engine string) ([]MigrationStatus, error) {
+	migrations, err := Migrations(engine)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := store.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		res[i] = MigrationStatus{Migration: m, AppliedAt: applied[m.Version]}
+		delete(applied, m.Version)
+	}
+	if len(applied) > 0 {
+		unknown := make([]int64, 0, len(applied))
+		for v := range applied {
+			unknown = append(unknown, v)
+		}
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+		return res, fmt.Errorf("%w: applied migrations %v are unknown", ErrSchemaUnknown, unknown)
+	}
+	return res, nil
+}
+
+// MigrateUp - apply all pending migrations in order of versions, returning applied migrations
+func MigrateUp(ctx context.Context, store MigrationStore, engine string) ([]Migration, error) {
+	status, err := MigrationStatuses(ctx, store, engine)
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for _, s := range status {
+		if s.Applied() {
+			continue
+		}
+		if err := store.ApplyMigration(ctx, s.Migration, true); err != nil {
+			return res, fmt.Errorf("migration %04d_%s: %v", s.Version, s.Name, err)
+		}
+		res = append(res, s.Migration)
+	}
+	return res, nil
+}
+
+// MigrateDown - revert the last applied migration, returning reverted migration
+// nil is returned when no migrations are applied
+func MigrateDown(ctx context.Context, store MigrationStore, engine string) (*Migration, error) {
+	status, err := MigrationStatuses(ctx, store, engine)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(status) - 1; i >= 0; i-- {
+		if !status[i].Applied() {
+			continue
+		}
+		m := status[i].Migration
+		if err := store.ApplyMigration(ctx, m, false); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+		return &m, nil
+	}
+	return nil, nil
+}
+
+// CheckSchema - return ErrSchemaOutdated if some migrations are not applied
+func CheckSchema(ctx context.Context, store MigrationStore, engine string) error {
+	status, err := MigrationStatuses(ctx, store, engine)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range status {
+		if !s.Applied() {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d of %d migrations are not applied, run \"wallet migrate up\"",
+			ErrSchemaOutdated, pending, len(status))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package driver
+
+import "testing"
+
+func Test_Migrations(t *testing.T) {
+	for _, engine := range []string{"postgresql", "sqlite"} {
+		migrations, err := Migrations(engine)
+		if err != nil {
+			t.Fatalf("Migrations(%s) error = %v", engine, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("Migrations(%s) is empty", engine)
+		}
+		// versions go one by one, so migrations of engines are not missed
+		for i, m := range migrations {
+			if m.Version != int64(i+1) {
+				t.Errorf("%s migration %s has version %d, want %d", engine, m.Name, m.Version, i+1)
+			}
+		}
+	}
+	if _, err := Migrations("oracle"); err == nil {
+		t.Error("Migrations(oracle) doesn't return error")
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.payments;
+DROP TABLE IF EXISTS public.accounts;
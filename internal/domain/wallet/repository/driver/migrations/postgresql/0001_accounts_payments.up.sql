@@ -0,0 +1,55 @@
+-- accounts and payments
+-- databases created before migrations already have these tables, missing columns are added to them
+
+CREATE TABLE IF NOT EXISTS public.accounts
+(
+	id bigserial NOT NULL,
+	name character varying(32) COLLATE pg_catalog."default" NOT NULL,
+	balance numeric(22,4) NOT NULL DEFAULT 0,
+	currency character varying COLLATE pg_catalog."default" NOT NULL,
+	system boolean NOT NULL DEFAULT false,
+	status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'active',
+	CONSTRAINT accounts_pk PRIMARY KEY (id),
+	CONSTRAINT accounts_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS public.payments
+(
+	id bigserial NOT NULL,
+	kind character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'transfer',
+	"from" bigint NOT NULL,
+	"to" bigint NOT NULL,
+	amount numeric(22,4) NOT NULL DEFAULT 0,
+	to_amount numeric(22,4),
+	rate numeric(22,10) DEFAULT 1,
+	rate_date timestamp with time zone,
+	to_balance numeric(22,4),
+	counterparty character varying(255) COLLATE pg_catalog."default",
+	reversal_of bigint,
+	idempotency_account bigint,
+	idempotency_key character varying(64) COLLATE pg_catalog."default",
+	idempotency_hash character varying(64) COLLATE pg_catalog."default",
+	date timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT payments_pk PRIMARY KEY (id)
+);
+
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS system boolean NOT NULL DEFAULT false;
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS status character varying(16) NOT NULL DEFAULT 'active';
+
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS kind character varying(16) NOT NULL DEFAULT 'transfer';
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS to_amount numeric(22,4);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS rate numeric(22,10) DEFAULT 1;
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS rate_date timestamp with time zone;
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS to_balance numeric(22,4);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS counterparty character varying(255);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS reversal_of bigint;
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS idempotency_account bigint;
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS idempotency_key character varying(64);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS idempotency_hash character varying(64);
+
+CREATE INDEX IF NOT EXISTS payments_from_to_idx
+	ON public.payments USING btree ("from" ASC NULLS LAST, "to" ASC NULLS LAST);
+CREATE UNIQUE INDEX IF NOT EXISTS payments_idempotency_idx
+	ON public.payments USING btree (idempotency_account, idempotency_key);
+CREATE INDEX IF NOT EXISTS payments_reversal_of_idx
+	ON public.payments USING btree (reversal_of);
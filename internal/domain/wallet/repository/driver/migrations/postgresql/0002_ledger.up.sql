@@ -0,0 +1,76 @@
+-- double-entry ledger
+-- constraint trigger checks at commit that entries of every payment sum to zero in each currency
+
+CREATE TABLE IF NOT EXISTS public.ledger_entries
+(
+	id bigserial NOT NULL,
+	payment_id bigint NOT NULL,
+	account_id bigint NOT NULL,
+	amount numeric(22,4) NOT NULL,
+	currency character varying COLLATE pg_catalog."default" NOT NULL,
+	date timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT ledger_entries_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_payment_idx
+	ON public.ledger_entries USING btree (payment_id);
+CREATE INDEX IF NOT EXISTS ledger_entries_account_idx
+	ON public.ledger_entries USING btree (account_id);
+
+CREATE OR REPLACE FUNCTION public.ledger_check_balanced() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM public.ledger_entries
+		WHERE payment_id = NEW.payment_id
+		GROUP BY currency
+		HAVING SUM(amount) <> 0
+	) THEN
+		RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.payment_id;
+	END IF;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_balanced ON public.ledger_entries;
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+	AFTER INSERT OR UPDATE ON public.ledger_entries
+	DEFERRABLE INITIALLY DEFERRED
+	FOR EACH ROW EXECUTE PROCEDURE public.ledger_check_balanced();
+
+-- deposits created before ledger have "from" = 0, move them to cash system account
+INSERT INTO public.accounts (name, balance, currency, system)
+	SELECT DISTINCT '@cash.' || a.currency, 0, a.currency, true
+	FROM public.payments p JOIN public.accounts a ON a.id = p."to"
+	WHERE p."from" = 0
+	ON CONFLICT (name) DO NOTHING;
+UPDATE public.payments p SET kind = 'deposit', "from" = c.id
+	FROM public.accounts a, public.accounts c
+	WHERE p."from" = 0 AND a.id = p."to" AND c.name = '@cash.' || a.currency;
+
+-- fx system accounts for cross-currency transfers created before ledger
+INSERT INTO public.accounts (name, balance, currency, system)
+	SELECT DISTINCT '@fx.' || c.currency, 0, c.currency, true
+	FROM public.payments p
+	JOIN public.accounts f ON f.id = p."from"
+	JOIN public.accounts t ON t.id = p."to"
+	CROSS JOIN LATERAL (VALUES (f.currency), (t.currency)) AS c(currency)
+	WHERE f.currency <> t.currency
+	ON CONFLICT (name) DO NOTHING;
+
+-- ledger entries for payments created before ledger
+INSERT INTO public.ledger_entries (payment_id, account_id, amount, currency, date)
+	SELECT p.id, leg.account_id, leg.amount, leg.currency, p.date
+	FROM public.payments p
+	JOIN public.accounts f ON f.id = p."from"
+	JOIN public.accounts t ON t.id = p."to"
+	LEFT JOIN public.accounts ff ON ff.name = '@fx.' || f.currency
+	LEFT JOIN public.accounts ft ON ft.name = '@fx.' || t.currency
+	CROSS JOIN LATERAL (VALUES
+		(1, p."from", -p.amount, f.currency),
+		(2, ff.id, p.amount, f.currency),
+		(3, ft.id, -COALESCE(p.to_amount, p.amount), t.currency),
+		(4, p."to", COALESCE(p.to_amount, p.amount), t.currency)
+	) AS leg(n, account_id, amount, currency)
+	WHERE (f.currency <> t.currency OR leg.n IN (1, 4))
+		AND NOT EXISTS (SELECT 1 FROM public.ledger_entries l WHERE l.payment_id = p.id)
+	ORDER BY p.id, leg.n;
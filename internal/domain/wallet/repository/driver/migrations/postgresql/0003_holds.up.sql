@@ -0,0 +1,22 @@
+-- holds of accounts, active hold reduces available balance of account
+
+CREATE TABLE IF NOT EXISTS public.holds
+(
+	id bigserial NOT NULL,
+	account_id bigint NOT NULL,
+	to_account_id bigint NOT NULL,
+	amount numeric(22,4) NOT NULL,
+	status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'active',
+	expires_at timestamp with time zone NOT NULL,
+	captured_amount numeric(22,4),
+	payment_id bigint,
+	date timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT holds_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS holds_account_active_idx
+	ON public.holds USING btree (account_id)
+	WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS holds_expires_active_idx
+	ON public.holds USING btree (expires_at)
+	WHERE status = 'active';
@@ -0,0 +1,34 @@
+-- scheduled and recurring transfers and results of their executions
+-- execution is recorded once for every planned time of transfer
+
+CREATE TABLE IF NOT EXISTS public.scheduled_transfers
+(
+	id bigserial NOT NULL,
+	account_id bigint NOT NULL,
+	to_account_id bigint NOT NULL,
+	amount numeric(22,4) NOT NULL,
+	recurrence character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'once',
+	day integer NOT NULL DEFAULT 0,
+	next_run_at timestamp with time zone NOT NULL,
+	status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'active',
+	date timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT scheduled_transfers_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_account_idx
+	ON public.scheduled_transfers USING btree (account_id);
+CREATE INDEX IF NOT EXISTS scheduled_transfers_next_run_active_idx
+	ON public.scheduled_transfers USING btree (next_run_at)
+	WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS public.scheduled_transfer_executions
+(
+	id bigserial NOT NULL,
+	schedule_id bigint NOT NULL,
+	run_at timestamp with time zone NOT NULL,
+	payment_id bigint,
+	error character varying(255) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+	date timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT scheduled_transfer_executions_pk PRIMARY KEY (id),
+	CONSTRAINT scheduled_transfer_executions_run UNIQUE (schedule_id, run_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE public.payments DROP COLUMN IF EXISTS fee;
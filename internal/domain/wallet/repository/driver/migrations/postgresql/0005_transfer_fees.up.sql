@@ -0,0 +1,3 @@
+-- fee of transfer charged to payer in addition to amount and credited to fee system account
+
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS fee numeric(22,4) NOT NULL DEFAULT 0;
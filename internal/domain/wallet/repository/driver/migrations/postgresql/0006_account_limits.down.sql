@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.payments_from_date_idx;
+DROP TABLE IF EXISTS public.account_limits;
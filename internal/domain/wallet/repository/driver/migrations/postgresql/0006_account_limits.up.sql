@@ -0,0 +1,14 @@
+-- spending limits of accounts, zero value of limit means the limit is not set
+-- usage of daily limits is aggregated from outgoing transfers of account
+
+CREATE TABLE IF NOT EXISTS public.account_limits
+(
+	account_id bigint NOT NULL,
+	max_amount numeric(22,4) NOT NULL DEFAULT 0,
+	daily_amount numeric(22,4) NOT NULL DEFAULT 0,
+	daily_count bigint NOT NULL DEFAULT 0,
+	CONSTRAINT account_limits_pk PRIMARY KEY (account_id)
+);
+
+CREATE INDEX IF NOT EXISTS payments_from_date_idx
+	ON public.payments USING btree ("from", date);
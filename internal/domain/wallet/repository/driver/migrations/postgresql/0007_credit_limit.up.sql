@@ -0,0 +1,3 @@
+-- overdraft limit of account, balance of account can go down to -credit_limit
+
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS credit_limit numeric(22,4) NOT NULL DEFAULT 0;
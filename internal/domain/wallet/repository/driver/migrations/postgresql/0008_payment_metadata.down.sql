@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS public.payments_external_ref_idx;
+
+ALTER TABLE public.payments DROP COLUMN IF EXISTS tags;
+ALTER TABLE public.payments DROP COLUMN IF EXISTS external_ref_account;
+ALTER TABLE public.payments DROP COLUMN IF EXISTS external_ref;
+ALTER TABLE public.payments DROP COLUMN IF EXISTS description;
@@ -0,0 +1,10 @@
+-- client supplied metadata of deposits and transfers
+-- external reference is unique per account which created payment (payer of transfer, recipient of deposit)
+
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS description character varying(255);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS external_ref character varying(64);
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS external_ref_account bigint;
+ALTER TABLE public.payments ADD COLUMN IF NOT EXISTS tags jsonb;
+
+CREATE UNIQUE INDEX IF NOT EXISTS payments_external_ref_idx
+	ON public.payments USING btree (external_ref_account, external_ref);
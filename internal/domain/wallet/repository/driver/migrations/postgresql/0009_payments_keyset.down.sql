@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.payments_to_id_idx;
+DROP INDEX IF EXISTS public.payments_from_id_idx;
@@ -0,0 +1,7 @@
+-- keyset pagination of account payments list: payments of account are selected by "from" or "to"
+-- and ordered by id, so the next page is read from index after id of cursor
+
+CREATE INDEX IF NOT EXISTS payments_from_id_idx
+	ON public.payments USING btree ("from", id);
+CREATE INDEX IF NOT EXISTS payments_to_id_idx
+	ON public.payments USING btree ("to", id);
@@ -0,0 +1,36 @@
+-- accounts and payments, the same as tables of PostgreSQL database
+-- amounts columns have type TEXT, so values are stored without conversion to float
+
+CREATE TABLE IF NOT EXISTS accounts
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL CHECK (length(name) <= 32),
+	balance TEXT NOT NULL DEFAULT '0.0000',
+	currency TEXT NOT NULL,
+	system INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL DEFAULT 'active'
+);
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_name ON accounts (name);
+
+CREATE TABLE IF NOT EXISTS payments
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL DEFAULT 'transfer',
+	"from" INTEGER NOT NULL,
+	"to" INTEGER NOT NULL,
+	amount TEXT NOT NULL,
+	to_amount TEXT NOT NULL,
+	rate TEXT NOT NULL,
+	rate_date TIMESTAMP,
+	to_balance TEXT,
+	counterparty TEXT,
+	reversal_of INTEGER,
+	idempotency_account INTEGER,
+	idempotency_key TEXT,
+	idempotency_hash TEXT,
+	date TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS payments_from ON payments ("from");
+CREATE INDEX IF NOT EXISTS payments_to ON payments ("to");
+CREATE INDEX IF NOT EXISTS payments_reversal_of ON payments (reversal_of);
+CREATE UNIQUE INDEX IF NOT EXISTS payments_idempotency ON payments (idempotency_account, idempotency_key);
@@ -0,0 +1,14 @@
+-- double-entry ledger
+-- SQLite has no deferred constraint triggers, balance of entries is checked by driver
+
+CREATE TABLE IF NOT EXISTS ledger_entries
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	payment_id INTEGER NOT NULL,
+	account_id INTEGER NOT NULL,
+	amount TEXT NOT NULL,
+	currency TEXT NOT NULL,
+	date TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS ledger_entries_payment_id ON ledger_entries (payment_id);
+CREATE INDEX IF NOT EXISTS ledger_entries_account_id ON ledger_entries (account_id);
@@ -0,0 +1,15 @@
+-- holds of accounts, active hold reduces available balance of account
+
+CREATE TABLE IF NOT EXISTS holds
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL,
+	to_account_id INTEGER NOT NULL,
+	amount TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'active',
+	expires_at TIMESTAMP NOT NULL,
+	captured_amount TEXT,
+	payment_id INTEGER,
+	date TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS holds_account_id_status ON holds (account_id, status);
@@ -0,0 +1,28 @@
+-- scheduled and recurring transfers and results of their executions
+-- execution is recorded once for every planned time of transfer
+
+CREATE TABLE IF NOT EXISTS scheduled_transfers
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL,
+	to_account_id INTEGER NOT NULL,
+	amount TEXT NOT NULL,
+	recurrence TEXT NOT NULL DEFAULT 'once',
+	day INTEGER NOT NULL DEFAULT 0,
+	next_run_at TIMESTAMP NOT NULL,
+	status TEXT NOT NULL DEFAULT 'active',
+	date TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_transfers_account_id ON scheduled_transfers (account_id);
+CREATE INDEX IF NOT EXISTS scheduled_transfers_status ON scheduled_transfers (status);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfer_executions
+(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	schedule_id INTEGER NOT NULL,
+	run_at TIMESTAMP NOT NULL,
+	payment_id INTEGER,
+	error TEXT NOT NULL DEFAULT '',
+	date TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_transfer_executions_schedule_id ON scheduled_transfer_executions (schedule_id);
@@ -0,0 +1,3 @@
+-- fee of transfer charged to payer in addition to amount and credited to fee system account
+
+ALTER TABLE payments ADD COLUMN fee TEXT NOT NULL DEFAULT '0';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS payments_from_date;
+DROP TABLE IF EXISTS account_limits;
@@ -0,0 +1,11 @@
+-- spending limits of accounts, zero value of limit means the limit is not set
+-- usage of daily limits is aggregated from outgoing transfers of account
+
+CREATE TABLE IF NOT EXISTS account_limits
+(
+	account_id INTEGER PRIMARY KEY,
+	max_amount TEXT NOT NULL DEFAULT '0',
+	daily_amount TEXT NOT NULL DEFAULT '0',
+	daily_count INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS payments_from_date ON payments ("from", date);
@@ -0,0 +1,3 @@
+-- overdraft limit of account, balance of account can go down to -credit_limit
+
+ALTER TABLE accounts ADD COLUMN credit_limit TEXT NOT NULL DEFAULT '0';
@@ -0,0 +1,6 @@
+DROP INDEX payments_external_ref;
+
+ALTER TABLE payments DROP COLUMN tags;
+ALTER TABLE payments DROP COLUMN external_ref_account;
+ALTER TABLE payments DROP COLUMN external_ref;
+ALTER TABLE payments DROP COLUMN description;
@@ -0,0 +1,9 @@
+-- client supplied metadata of deposits and transfers, tags are stored as JSON object
+-- external reference is unique per account which created payment (payer of transfer, recipient of deposit)
+
+ALTER TABLE payments ADD COLUMN description TEXT;
+ALTER TABLE payments ADD COLUMN external_ref TEXT;
+ALTER TABLE payments ADD COLUMN external_ref_account INTEGER;
+ALTER TABLE payments ADD COLUMN tags TEXT;
+
+CREATE UNIQUE INDEX payments_external_ref ON payments (external_ref_account, external_ref);
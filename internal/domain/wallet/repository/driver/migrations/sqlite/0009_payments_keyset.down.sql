@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS payments_to_id;
+DROP INDEX IF EXISTS payments_from_id;
@@ -0,0 +1,669 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for accounts for work with PostgreSQL database
+
+type PgSqlAccount struct {
+	// db - connection to database
+	db *PgSQL
+
+	id       int64
+	name     string
+	balance  money.Amount
+	currency string
+	system   bool
+	status   string
+	// available - balance without active holds
+	available money.Amount
+	// creditLimit - overdraft limit, balance can go down to -creditLimit
+	creditLimit money.Amount
+}
+
+func (pg *PgSqlAccount) ID() int64 {
+	return pg.id
+}
+func (pg *PgSqlAccount) Name() string {
+	return pg.name
+}
+func (pg *PgSqlAccount) Currency() string {
+	return pg.currency
+}
+func (pg *PgSqlAccount) Balance() money.Amount {
+	return pg.balance
+}
+func (pg *PgSqlAccount) AvailableBalance() money.Amount {
+	return pg.available
+}
+func (pg *PgSqlAccount) CreditLimit() money.Amount {
+	return pg.creditLimit
+}
+func (pg *PgSqlAccount) System() bool {
+	return pg.system
+}
+func (pg *PgSqlAccount) Status() string {
+	return pg.status
+}
+
+// LedgerBalance - balance of account derived from ledger entries
+func (pg *PgSqlAccount) LedgerBalance(ctx context.Context) (money.Amount, error) {
+	return pg.db.ledgerBalance(ctx, pg.id)
+}
+
+// Find - find wallet with name and load in object
+// system accounts can't be found by name
+func (pg *PgSqlAccount) Find(ctx context.Context, name string) error {
+	row := pg.db.conn.QueryRow(ctx, `SELECT id FROM accounts WHERE "name" = $1 AND NOT system LIMIT 1`, name)
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		return err
+	}
+	return pg.Get(ctx, id)
+}
+
+// Get - get wallet by ID and load in object
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (pg *PgSqlAccount) Get(ctx context.Context, id int64) error {
+	cacheKey := pg.cacheKey(id)
+	if v, ok := pg.db.cache.Get(cacheKey); ok {
+		*pg = v.(PgSqlAccount)
+		return nil
+	}
+	row := pg.db.conn.QueryRow(ctx, `
+		SELECT id, name, balance, currency, system, status,
+			balance - COALESCE((
+				SELECT SUM(amount) FROM holds WHERE account_id = accounts.id AND `+pgActiveHoldCondition+`
+			), 0),
+			credit_limit
+		FROM accounts
+		WHERE 
+			"id" = $1 
+		LIMIT 1`, id)
+	if err := row.Scan(
+		&pg.id, &pg.name, &pg.balance, &pg.currency, &pg.system, &pg.status, &pg.available, &pg.creditLimit); err != nil {
+		return err
+	}
+	pg.db.cache.Set(cacheKey, *pg, 0)
+	return nil
+}
+
+// Deposit - add amount to account balance
+// money is debited from cash system account in account currency
+// returns ErrAccountFrozen or ErrAccountClosed if account is not active
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (pg *PgSqlAccount) Deposit(ctx context.Context, amount money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	cashID, err := pg.db.systemAccountID(ctx, driver.SystemAccountCash, pg.currency)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// check for retry of request
+	if id, err := pg.db.findIdempotentPayment(ctx, tx, pg.id, idem); id != 0 || err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return id, err
+	}
+	if err := pg.db.checkExternalRef(ctx, tx, pg.id, meta); err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return 0, err
+	}
+	description, ref, refAccount, tags, err := meta.Values(pg.id)
+	if err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return 0, err
+	}
+
+	// update balances
+	var (
+		toBalance money.Amount
+		status    string
+	)
+	row := tx.QueryRow(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING balance, status`,
+		amount, pg.id)
+	if err = row.Scan(&toBalance, &status); err == nil {
+		err = driver.AccountStatusError(status, false)
+	}
+	if err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return 0, err
+	}
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+
+	// create payment
+	var paymentID int64
+	key, hash := idem.Values()
+	row = tx.QueryRow(ctx, `
+		INSERT INTO payments ("kind", "from", "to", "amount", "to_amount", "rate", "to_balance",
+			"idempotency_account", "idempotency_key", "idempotency_hash",
+			"description", "external_ref", "external_ref_account", "tags", "date")
+		VALUES($1, $2, $3, $4, $4, 1, $5, $6, $7, $8, $9, $10, $11, $12, NOW()) RETURNING id`,
+		driver.PaymentKindDeposit, cashID, pg.id, amount, toBalance, idem.Account(pg.id), key, hash,
+		description, ref, refAccount, tags)
+	if err = row.Scan(&paymentID); err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		// concurrent request with the same external reference was committed first
+		if isExternalRefViolation(err) {
+			return 0, driver.ErrExternalRefDuplicate
+		}
+		// concurrent request with the same idempotency key was committed first
+		if isUniqueViolation(err) && idem != nil {
+			return pg.db.findIdempotentPayment(ctx, pg.db.conn, pg.id, idem)
+		}
+		return 0, err
+	}
+	entries := []driver.LedgerEntry{
+		{AccountID: cashID, Amount: amount.Neg(), Currency: pg.currency},
+		{AccountID: pg.id, Amount: amount, Currency: pg.currency},
+	}
+	if err = pg.db.postEntries(ctx, tx, paymentID, entries); err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return 0, err
+	}
+	pg.clearPaymentsListCache()
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return paymentID, nil
+}
+
+// Withdraw - move amount out of wallet to external counterparty
+// money is credited to cash system account in account currency, counterparty is reference to external destination
+// function check that the account is active and the account available balance is sufficient
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (pg *PgSqlAccount) Withdraw(ctx context.Context, amount money.Amount, counterparty string, idem *driver.Idempotency) (int64, error) {
+	cashID, err := pg.db.systemAccountID(ctx, driver.SystemAccountCash, pg.currency)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// rollback transaction and return result
+	rollback := func(id int64, err error) (int64, error) {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return id, err
+	}
+
+	// check for retry of request
+	if id, err := pg.db.findIdempotentPayment(ctx, tx, pg.id, idem); id != 0 || err != nil {
+		return rollback(id, err)
+	}
+
+	// lock account and check status and available balance
+	var (
+		balance, creditLimit money.Amount
+		status               string
+	)
+	row := tx.QueryRow(ctx, `SELECT balance, credit_limit, status FROM accounts WHERE "id" = $1 FOR UPDATE`, pg.id)
+	if err = row.Scan(&balance, &creditLimit, &status); err != nil {
+		return rollback(0, err)
+	}
+	if err = driver.AccountStatusError(status, false); err != nil {
+		return rollback(0, err)
+	}
+	held, err := pg.db.heldAmount(ctx, tx, pg.id)
+	if err != nil {
+		return rollback(0, err)
+	}
+	if err = driver.CheckAvailable(balance, held, creditLimit, amount); err != nil {
+		return rollback(0, err)
+	}
+	if err = pg.db.checkLimits(ctx, tx, pg.id, amount); err != nil {
+		return rollback(0, err)
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE accounts SET balance = balance - $1 WHERE id = $2`,
+		amount, pg.id); err != nil {
+		return rollback(0, err)
+	}
+
+	// create payment
+	var paymentID int64
+	key, hash := idem.Values()
+	row = tx.QueryRow(ctx, `
+		INSERT INTO payments ("kind", "from", "to", "amount", "to_amount", "rate", "counterparty",
+			"idempotency_account", "idempotency_key", "idempotency_hash", "date")
+		VALUES($1, $2, $3, $4, $4, 1, $5, $6, $7, $8, NOW()) RETURNING id`,
+		driver.PaymentKindWithdrawal, pg.id, cashID, amount, counterparty, idem.Account(pg.id), key, hash)
+	if err = row.Scan(&paymentID); err != nil {
+		if _, err = rollback(0, err); isUniqueViolation(err) && idem != nil {
+			// concurrent request with the same idempotency key was committed first
+			return pg.db.findIdempotentPayment(ctx, pg.db.conn, pg.id, idem)
+		}
+		return 0, err
+	}
+	entries := []driver.LedgerEntry{
+		{AccountID: pg.id, Amount: amount.Neg(), Currency: pg.currency},
+		{AccountID: cashID, Amount: amount, Currency: pg.currency},
+	}
+	if err = pg.db.postEntries(ctx, tx, paymentID, entries); err != nil {
+		return rollback(0, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	pg.clearPaymentsListCache()
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return paymentID, nil
+}
+
+// FindIdempotent - search payment created by account with idempotency key
+// returns (0, nil) if key is not used, else id of payment and ErrIdempotencyReplay or ErrIdempotencyConflict
+func (pg *PgSqlAccount) FindIdempotent(ctx context.Context, idem *driver.Idempotency) (int64, error) {
+	return pg.db.findIdempotentPayment(ctx, pg.db.conn, pg.id, idem)
+}
+
+// Transfer - creating a payment form account to account with id "toID"
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// (balance without active holds) together with credit limit of the account is sufficient
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+// transaction is repeated when it fails because of deadlock or serialization failure
+func (pg *PgSqlAccount) Transfer(ctx context.Context, toID int64,
+	amount money.Amount, conv *driver.Conversion, fee money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	entries, err := pg.TransferEntries(ctx, toID, amount, conv, fee)
+	if err != nil {
+		return 0, err
+	}
+
+	var paymentID int64
+	err = pgRetry(ctx, func() (err error) {
+		paymentID, err = pg.transfer(ctx, toID, amount, conv, fee, meta, entries, idem)
+		return
+	})
+	if err != nil {
+		return paymentID, err
+	}
+
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return paymentID, nil
+}
+
+// TransferEntries - build ledger entries of transfer to account with id "toID" with fee
+// currency of account never changes, so it is read before locking
+func (pg *PgSqlAccount) TransferEntries(ctx context.Context, toID int64,
+	amount money.Amount, conv *driver.Conversion, fee money.Amount) ([]driver.LedgerEntry, error) {
+	to := &PgSqlAccount{db: pg.db}
+	if err := to.Get(ctx, toID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, driver.ErrRecipientNotFound
+		}
+		return nil, err
+	}
+	var feeID int64
+	if !fee.IsZero() {
+		id, err := pg.db.systemAccountID(ctx, driver.SystemAccountFee, pg.currency)
+		if err != nil {
+			return nil, err
+		}
+		feeID = id
+	}
+	if conv == nil {
+		entries := driver.TransferEntries(pg.id, to.id, amount, pg.currency, amount, to.currency, 0, 0)
+		return driver.FeeEntries(entries, fee, feeID)
+	}
+	fxFromID, err := pg.db.systemAccountID(ctx, driver.SystemAccountFx, pg.currency)
+	if err != nil {
+		return nil, err
+	}
+	fxToID, err := pg.db.systemAccountID(ctx, driver.SystemAccountFx, to.currency)
+	if err != nil {
+		return nil, err
+	}
+	entries := driver.TransferEntries(pg.id, to.id, amount, pg.currency, conv.ToAmount, to.currency, fxFromID, fxToID)
+	return driver.FeeEntries(entries, fee, feeID)
+}
+
+// transfer - one attempt of transfer in database transaction
+func (pg *PgSqlAccount) transfer(ctx context.Context, toID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount,
+	meta driver.Metadata, entries []driver.LedgerEntry, idem *driver.Idempotency) (int64, error) {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	paymentID, err := pg.transferTx(ctx, tx, toID, amount, conv, fee, meta, entries, idem)
+	if err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		if isExternalRefViolation(err) {
+			// concurrent request with the same external reference was committed first
+			return 0, driver.ErrExternalRefDuplicate
+		}
+		if isUniqueViolation(err) && idem != nil {
+			// concurrent request with the same idempotency key was committed first
+			return pg.db.findIdempotentPayment(ctx, pg.db.conn, pg.id, idem)
+		}
+		return paymentID, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	pg.clearTransferCache(toID)
+
+	return paymentID, nil
+}
+
+// transferTx - execute transfer in transaction tx, transaction is not committed or rolled back
+// both accounts rows are locked in ascending id order, so concurrent transfers A->B and B->A
+// wait for each other instead of deadlock, and balance and limits of payer are checked under the lock
+// entries are built by TransferEntries with the same fee
+func (pg *PgSqlAccount) transferTx(ctx context.Context, tx pgx.Tx, toID int64, amount money.Amount, conv *driver.Conversion,
+	fee money.Amount, meta driver.Metadata, entries []driver.LedgerEntry, idem *driver.Idempotency) (int64, error) {
+	// check for retry of request
+	if id, err := pg.db.findIdempotentPayment(ctx, tx, pg.id, idem); id != 0 || err != nil {
+		return id, err
+	}
+	if err := pg.db.checkExternalRef(ctx, tx, pg.id, meta); err != nil {
+		return 0, err
+	}
+	description, ref, refAccount, tags, err := meta.Values(pg.id)
+	if err != nil {
+		return 0, err
+	}
+
+	// lock accounts
+	ids := []int64{pg.id, toID}
+	if toID < pg.id {
+		ids[0], ids[1] = ids[1], ids[0]
+	}
+	var (
+		balances    = make(map[int64]money.Amount, 2)
+		creditLimit money.Amount
+	)
+	for _, id := range ids {
+		var (
+			balance, limit money.Amount
+			status         string
+		)
+		row := tx.QueryRow(ctx, `SELECT balance, credit_limit, status FROM accounts WHERE "id" = $1 FOR UPDATE`, id)
+		if err := row.Scan(&balance, &limit, &status); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) && id == toID {
+				err = driver.ErrRecipientNotFound
+			}
+			return 0, err
+		}
+		if err := driver.AccountStatusError(status, id == toID); err != nil {
+			return 0, err
+		}
+		balances[id] = balance
+		if id == pg.id {
+			creditLimit = limit
+		}
+	}
+
+	// check available balance
+	held, err := pg.db.heldAmount(ctx, tx, pg.id)
+	if err != nil {
+		return 0, err
+	}
+	// payer is debited by amount with fee
+	debit, err := amount.Add(fee)
+	if err != nil {
+		return 0, err
+	}
+	if err = driver.CheckAvailable(balances[pg.id], held, creditLimit, debit); err != nil {
+		return 0, err
+	}
+	if err = pg.db.checkLimits(ctx, tx, pg.id, amount); err != nil {
+		return 0, err
+	}
+
+	// amount in recipient currency
+	toAmount, rate, rateDate := amount, money.New(1, 0), (*time.Time)(nil)
+	if conv != nil {
+		toAmount, rate, rateDate = conv.ToAmount, conv.Rate, &conv.RateDate
+	}
+
+	// update balances
+	var toBalance money.Amount
+	row := tx.QueryRow(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING balance`,
+		toAmount, toID)
+	if err = row.Scan(&toBalance); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE accounts SET balance = balance - $1 WHERE id = $2`,
+		debit, pg.id); err != nil {
+		return 0, err
+	}
+
+	// create payment
+	var paymentID int64
+	key, hash := idem.Values()
+	row = tx.QueryRow(ctx, `
+		INSERT INTO payments ("kind", "from", "to", "amount", "to_amount", "rate", "rate_date", "to_balance", "fee",
+			"idempotency_account", "idempotency_key", "idempotency_hash",
+			"description", "external_ref", "external_ref_account", "tags", "date")
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW()) RETURNING id`,
+		driver.PaymentKindTransfer, pg.id, toID, amount, toAmount, rate, rateDate, toBalance, fee, idem.Account(pg.id), key, hash,
+		description, ref, refAccount, tags)
+	if err = row.Scan(&paymentID); err != nil {
+		return 0, err
+	}
+	if err = pg.db.postEntries(ctx, tx, paymentID, entries); err != nil {
+		return 0, err
+	}
+	return paymentID, nil
+}
+
+// clearTransferCache - clear cache of both accounts of transfer to account with id "toID"
+func (pg *PgSqlAccount) clearTransferCache(toID int64) {
+	_ = pg.db.cache.Delete(pg.cacheKey(toID))
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	pg.clearPaymentsListCache()
+	(&PgSqlAccount{db: pg.db, id: toID}).clearPaymentsListCache()
+}
+
+// Create - create a new account with name and currency and load one in object
+// this function not validate name and currency
+// Important! When any fields will be added into table, then need to add one in to INSERT query
+func (pg *PgSqlAccount) Create(ctx context.Context, name, currency string) error {
+	res := pg.db.conn.QueryRow(ctx, `
+		INSERT INTO accounts (name, balance, currency) VALUES(
+		$1, $2, $3
+		)
+		RETURNING id
+	`, name, money.Amount{}, currency)
+
+	var id int64
+	if err := res.Scan(&id); err != nil {
+		return err
+	}
+	pg.id = id
+	pg.balance = money.Amount{}
+	pg.available = money.Amount{}
+	pg.currency = currency
+	pg.name = name
+	pg.status = driver.AccountStatusActive
+	return nil
+}
+
+// SetStatus - change status of account
+// closed account can't change status, returns ErrAccountClosed
+// account can be closed only if its balance is zero and it has no active holds, else returns ErrAccountNotEmpty
+func (pg *PgSqlAccount) SetStatus(ctx context.Context, status string) error {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// rollback transaction and return error
+	rollback := func(err error) error {
+		if e := tx.Rollback(ctx); e != nil {
+			return e
+		}
+		return err
+	}
+
+	var (
+		balance money.Amount
+		current string
+	)
+	row := tx.QueryRow(ctx, `SELECT balance, status FROM accounts WHERE "id" = $1 FOR UPDATE`, pg.id)
+	if err = row.Scan(&balance, &current); err != nil {
+		return rollback(err)
+	}
+	if current == driver.AccountStatusClosed {
+		return rollback(driver.ErrAccountClosed)
+	}
+	if status == driver.AccountStatusClosed {
+		held, err := pg.db.heldAmount(ctx, tx, pg.id)
+		if err != nil {
+			return rollback(err)
+		}
+		if !balance.IsZero() || !held.IsZero() {
+			return rollback(driver.ErrAccountNotEmpty)
+		}
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE accounts SET status = $1 WHERE id = $2`, status, pg.id); err != nil {
+		return rollback(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return nil
+}
+
+// SetCreditLimit - set overdraft limit of account, balance of account can go down to -limit
+// closed account can't change limit, returns ErrAccountClosed
+func (pg *PgSqlAccount) SetCreditLimit(ctx context.Context, limit money.Amount) error {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// rollback transaction and return error
+	rollback := func(err error) error {
+		if e := tx.Rollback(ctx); e != nil {
+			return e
+		}
+		return err
+	}
+
+	var status string
+	row := tx.QueryRow(ctx, `SELECT status FROM accounts WHERE "id" = $1 FOR UPDATE`, pg.id)
+	if err = row.Scan(&status); err != nil {
+		return rollback(err)
+	}
+	if status == driver.AccountStatusClosed {
+		return rollback(driver.ErrAccountClosed)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE accounts SET credit_limit = $1 WHERE id = $2`, limit, pg.id); err != nil {
+		return rollback(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return nil
+}
+
+// Delete - delete wallet account physically
+// payments of account are kept and refer to missing account, so in the application accounts are closed
+// with SetStatus instead. Delete is used only to clean up test data
+func (pg *PgSqlAccount) Delete(ctx context.Context) error {
+	if _, err := pg.db.conn.Exec(ctx, `DELETE FROM accounts WHERE id = $1`, pg.id); err != nil {
+		return err
+	}
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	return nil
+}
+
+// List - return list of all wallets account names
+// Wallets listed ordering by id, system accounts are not listed
+// if after > 0, only accounts with id greater than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (pg *PgSqlAccount) List(ctx context.Context, after, offset, limit int64) ([]int64, error) {
+	rows, err := pg.db.conn.Query(ctx, `
+		SELECT id FROM accounts
+		WHERE NOT system AND id > $1
+		ORDER BY id
+		OFFSET $2
+		LIMIT $3`, after, offset, pgLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		res []int64
+		id  int64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+// clear list of account payments in cache
+func (pg *PgSqlAccount) clearPaymentsListCache() {
+	p := PgSqlPayment{}
+
+	// clear cache for accounts payment list
+	k := p._cacheListKey(pg.id)
+	_ = pg.db.cache.Delete(k)
+
+	// clear cache for all payments list
+	k = p._cacheListKey(-1)
+	_ = pg.db.cache.Delete(k)
+}
+
+func (pg *PgSqlAccount) cacheKey(id int64) string {
+	return fmt.Sprintf("account%d", id)
+}
@@ -0,0 +1,251 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Holds of accounts for PostgreSQL driver
+// hold is active while its status is "active" and expires_at is in the future, so expired hold stops to reduce
+// available balance immediately, even before its status is changed by ExpireHolds
+
+// pgActiveHoldCondition - condition of active hold used in queries
+const pgActiveHoldCondition = `status = 'active' AND expires_at > NOW()`
+
+// heldAmount - sum of active holds of account
+func (db *PgSQL) heldAmount(ctx context.Context, q pgQuerier, accountID int64) (money.Amount, error) {
+	var held money.Amount
+	row := q.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM holds WHERE account_id = $1 AND `+
+		pgActiveHoldCondition, accountID)
+	if err := row.Scan(&held); err != nil {
+		return money.Amount{}, err
+	}
+	return held, nil
+}
+
+// Hold - reserve amount of account for transfer to account with id "toID" for ttl
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// is sufficient
+// returning id of hold
+func (pg *PgSqlAccount) Hold(ctx context.Context, toID int64, amount money.Amount, ttl time.Duration) (int64, error) {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// rollback transaction and return result
+	rollback := func(id int64, err error) (int64, error) {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return id, err
+	}
+
+	var (
+		id     int64
+		status string
+	)
+	row := tx.QueryRow(ctx, `SELECT id, status FROM accounts WHERE "id" = $1 AND NOT system`, toID)
+	if err = row.Scan(&id, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = driver.ErrRecipientNotFound
+		}
+		return rollback(0, err)
+	}
+	if err = driver.AccountStatusError(status, true); err != nil {
+		return rollback(0, err)
+	}
+
+	// lock account and check status and available balance
+	var balance, creditLimit money.Amount
+	row = tx.QueryRow(ctx, `SELECT balance, credit_limit, status FROM accounts WHERE "id" = $1 FOR UPDATE`, pg.id)
+	if err = row.Scan(&balance, &creditLimit, &status); err != nil {
+		return rollback(0, err)
+	}
+	if err = driver.AccountStatusError(status, false); err != nil {
+		return rollback(0, err)
+	}
+	held, err := pg.db.heldAmount(ctx, tx, pg.id)
+	if err != nil {
+		return rollback(0, err)
+	}
+	if err = driver.CheckAvailable(balance, held, creditLimit, amount); err != nil {
+		return rollback(0, err)
+	}
+
+	row = tx.QueryRow(ctx, `
+		INSERT INTO holds (account_id, to_account_id, amount, status, expires_at, date)
+		VALUES($1, $2, $3, $4, NOW() + $5::interval, NOW()) RETURNING id`,
+		pg.id, toID, amount, driver.HoldStatusActive, fmt.Sprintf("%d milliseconds", ttl.Milliseconds()))
+	if err = row.Scan(&id); err != nil {
+		return rollback(0, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return id, nil
+}
+
+// GetHold - return hold of account by id
+// status of expired hold which was not processed by ExpireHolds yet is returned as expired
+func (pg *PgSqlAccount) GetHold(ctx context.Context, holdID int64) (driver.Hold, error) {
+	var h driver.Hold
+	row := pg.db.conn.QueryRow(ctx, `
+		SELECT id, account_id, to_account_id, amount,
+			CASE WHEN status = 'active' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+			expires_at, COALESCE(payment_id, 0), date
+		FROM holds
+		WHERE id = $1 AND account_id = $2`, holdID, pg.id)
+	err := row.Scan(&h.ID, &h.AccountID, &h.ToID, &h.Amount, &h.Status, &h.ExpiresAt, &h.PaymentID, &h.Date)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return driver.Hold{}, driver.ErrHoldNotFound
+	}
+	return h, err
+}
+
+// Capture - turn hold into transfer of amount to recipient of hold
+// amount can be less than amount of hold, the rest of hold is released
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// returning id of payment
+// transaction is repeated when it fails because of deadlock or serialization failure
+func (pg *PgSqlAccount) Capture(ctx context.Context, holdID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount) (int64, error) {
+	h, err := pg.GetHold(ctx, holdID)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := pg.TransferEntries(ctx, h.ToID, amount, conv, fee)
+	if err != nil {
+		return 0, err
+	}
+
+	var paymentID int64
+	err = pgRetry(ctx, func() (err error) {
+		paymentID, err = pg.capture(ctx, h, amount, conv, fee, entries)
+		return
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return paymentID, nil
+}
+
+// capture - one attempt of capture in database transaction
+// hold row is locked before accounts, so the same hold can't be captured twice
+func (pg *PgSqlAccount) capture(ctx context.Context, h driver.Hold, amount money.Amount, conv *driver.Conversion, fee money.Amount,
+	entries []driver.LedgerEntry) (int64, error) {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// rollback transaction and return result
+	rollback := func(id int64, err error) (int64, error) {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return id, err
+	}
+
+	var (
+		held   money.Amount
+		active bool
+	)
+	row := tx.QueryRow(ctx, `
+		SELECT amount, `+pgActiveHoldCondition+`
+		FROM holds
+		WHERE id = $1
+		FOR UPDATE`, h.ID)
+	if err = row.Scan(&held, &active); err != nil {
+		return rollback(0, err)
+	}
+	if !active {
+		return rollback(0, driver.ErrHoldNotActive)
+	}
+	if amount.Cmp(held) > 0 {
+		return rollback(0, driver.ErrHoldExceedsAmount)
+	}
+
+	// hold stops to reduce available balance before transfer checks it
+	if _, err = tx.Exec(ctx, `UPDATE holds SET status = $1, captured_amount = $2 WHERE id = $3`,
+		driver.HoldStatusCaptured, amount, h.ID); err != nil {
+		return rollback(0, err)
+	}
+	paymentID, err := pg.transferTx(ctx, tx, h.ToID, amount, conv, fee, driver.Metadata{}, entries, nil)
+	if err != nil {
+		return rollback(0, err)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE holds SET payment_id = $1 WHERE id = $2`, paymentID, h.ID); err != nil {
+		return rollback(0, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	pg.clearTransferCache(h.ToID)
+
+	return paymentID, nil
+}
+
+// Release - release active hold, reserved amount becomes available again
+func (pg *PgSqlAccount) Release(ctx context.Context, holdID int64) error {
+	res, err := pg.db.conn.Exec(ctx, `
+		UPDATE holds SET status = $1
+		WHERE id = $2 AND account_id = $3 AND `+pgActiveHoldCondition,
+		driver.HoldStatusReleased, holdID, pg.id)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		if _, err := pg.GetHold(ctx, holdID); err != nil {
+			return err
+		}
+		return driver.ErrHoldNotActive
+	}
+
+	_ = pg.db.cache.Delete(pg.cacheKey(pg.id))
+	_ = pg.Get(ctx, pg.id) // reread from db
+
+	return nil
+}
+
+// ExpireHolds - set status "expired" for all active holds with expired ttl
+// returning count of expired holds
+func (pg *PgSqlAccount) ExpireHolds(ctx context.Context) (int64, error) {
+	rows, err := pg.db.conn.Query(ctx, `
+		UPDATE holds SET status = $1
+		WHERE status = 'active' AND expires_at <= NOW()
+		RETURNING account_id`, driver.HoldStatusExpired)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var (
+		n  int64
+		id int64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return n, err
+		}
+		// available balance of account is changed
+		_ = pg.db.cache.Delete(pg.cacheKey(id))
+		n++
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,55 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Support of idempotency keys for PostgreSQL driver
+
+// pgQuerier - common interface of pool and transaction
+type pgQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// findIdempotentPayment - search payment created by account with idempotency key
+// returns (0, nil) if key is not used yet or idem is nil
+// returns (id, ErrIdempotencyReplay) if payment was created by the same request
+// returns (id, ErrIdempotencyConflict) if key was used for request with other parameters
+func (db *PgSQL) findIdempotentPayment(ctx context.Context, q pgQuerier, accountID int64, idem *driver.Idempotency) (int64, error) {
+	if idem == nil {
+		return 0, nil
+	}
+	var (
+		id   int64
+		hash string
+	)
+	row := q.QueryRow(ctx, `
+		SELECT id, idempotency_hash
+		FROM payments
+		WHERE
+			idempotency_account = $1 AND idempotency_key = $2
+		LIMIT 1`, accountID, idem.Key)
+	if err := row.Scan(&id, &hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if hash != idem.Hash {
+		return id, driver.ErrIdempotencyConflict
+	}
+	return id, driver.ErrIdempotencyReplay
+}
+
+// isUniqueViolation checking that error is violation of unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
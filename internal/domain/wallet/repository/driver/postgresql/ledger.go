@@ -0,0 +1,101 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Double-entry ledger for PostgreSQL driver
+
+// systemAccountID - return id of system account of kind for currency
+// account is created when it not exists yet. Ids of system accounts never change, so they are cached
+// system account is created outside of payment transaction, so rollback of payment never removes it
+func (db *PgSQL) systemAccountID(ctx context.Context, kind, currency string) (int64, error) {
+	name := driver.SystemAccountName(kind, currency)
+	cacheKey := "system" + name
+	if v, ok := db.cache.Get(cacheKey); ok {
+		return v.(int64), nil
+	}
+
+	var id int64
+	row := db.conn.QueryRow(ctx, `SELECT id FROM accounts WHERE "name" = $1 AND system`, name)
+	err := row.Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		row = db.conn.QueryRow(ctx, `
+			INSERT INTO accounts (name, balance, currency, system) VALUES($1, 0, $2, true)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id`, name, currency)
+		if err = row.Scan(&id); errors.Is(err, pgx.ErrNoRows) {
+			// account was created by concurrent request
+			row = db.conn.QueryRow(ctx, `SELECT id FROM accounts WHERE "name" = $1 AND system`, name)
+			err = row.Scan(&id)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	db.cache.Set(cacheKey, id, 0)
+	return id, nil
+}
+
+// postEntries - check that entries are balanced and save them as legs of payment
+// balances of user accounts are updated by caller. Balances of system accounts are not stored
+// and derived from ledger only, so deposits don't lock one hot row of cash account
+func (db *PgSQL) postEntries(ctx context.Context, tx pgx.Tx, paymentID int64, entries []driver.LedgerEntry) error {
+	if err := driver.CheckBalanced(entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO ledger_entries (payment_id, account_id, amount, currency, date)
+			VALUES($1, $2, $3, $4, NOW())`,
+			paymentID, e.AccountID, e.Amount, e.Currency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgRowsQuerier - common interface of pool and transaction for queries returning many rows
+type pgRowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// ledgerEntries - return entries of payment ordered by id
+func (db *PgSQL) ledgerEntries(ctx context.Context, q pgRowsQuerier, paymentID int64) ([]driver.LedgerEntry, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, payment_id, account_id, amount, currency, date
+		FROM ledger_entries
+		WHERE payment_id = $1
+		ORDER BY id`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.LedgerEntry
+	for rows.Next() {
+		var e driver.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.PaymentID, &e.AccountID, &e.Amount, &e.Currency, &e.Date); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+// ledgerBalance - balance of account derived from ledger as sum of all its entries
+func (db *PgSQL) ledgerBalance(ctx context.Context, accountID int64) (money.Amount, error) {
+	var balance money.Amount
+	row := db.conn.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account_id = $1`,
+		accountID)
+	if err := row.Scan(&balance); err != nil {
+		return money.Amount{}, err
+	}
+	return balance, nil
+}
@@ -0,0 +1,74 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Spending limits of accounts for PostgreSQL driver
+// limits are stored in table account_limits, usage of limits is aggregated from payments of account
+
+// Limits - return spending limits of account, zero limits if they are not set
+func (pg *PgSqlAccount) Limits(ctx context.Context) (driver.Limits, error) {
+	return pg.db.limits(ctx, pg.db.conn, pg.id)
+}
+
+// limits - return spending limits of account with id, zero limits if they are not set
+func (db *PgSQL) limits(ctx context.Context, q pgQuerier, accountID int64) (driver.Limits, error) {
+	var l driver.Limits
+	err := q.QueryRow(ctx, `
+		SELECT max_amount, daily_amount, daily_count FROM account_limits WHERE account_id = $1`, accountID,
+	).Scan(&l.MaxAmount, &l.DailyAmount, &l.DailyCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return driver.Limits{}, nil
+	}
+	return l, err
+}
+
+// SetLimits - set spending limits of account
+func (pg *PgSqlAccount) SetLimits(ctx context.Context, l driver.Limits) error {
+	_, err := pg.db.conn.Exec(ctx, `
+		INSERT INTO account_limits (account_id, max_amount, daily_amount, daily_count) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id) DO UPDATE
+			SET max_amount = EXCLUDED.max_amount, daily_amount = EXCLUDED.daily_amount, daily_count = EXCLUDED.daily_count`,
+		pg.id, l.MaxAmount, l.DailyAmount, l.DailyCount)
+	return err
+}
+
+// Spending - return total amount and count of outgoing transfers and withdrawals of account since time
+func (pg *PgSqlAccount) Spending(ctx context.Context, since time.Time) (driver.Spending, error) {
+	return pg.db.spending(ctx, pg.db.conn, pg.id, since)
+}
+
+// spending - return total amount and count of outgoing transfers and withdrawals of account with id since time
+func (db *PgSQL) spending(ctx context.Context, q pgQuerier, accountID int64, since time.Time) (driver.Spending, error) {
+	var s driver.Spending
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM payments WHERE "from" = $1 AND kind IN ($2, $3) AND date >= $4`,
+		accountID, driver.PaymentKindTransfer, driver.PaymentKindWithdrawal, since,
+	).Scan(&s.Amount, &s.Count)
+	return s, err
+}
+
+// checkLimits - check that payment of amount from account with id doesn't exceed its limits
+// it is called in transaction q after row of account is locked, so concurrent payments of account
+// are checked one after another and see spending of each other
+func (db *PgSQL) checkLimits(ctx context.Context, q pgQuerier, accountID int64, amount money.Amount) error {
+	l, err := db.limits(ctx, q, accountID)
+	if err != nil {
+		return err
+	}
+	var s driver.Spending
+	if l.Daily() {
+		if s, err = db.spending(ctx, q, accountID, driver.LimitsDay(time.Now())); err != nil {
+			return err
+		}
+	}
+	return l.Check(s, amount)
+}
@@ -0,0 +1,58 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Support of payment metadata for PostgreSQL driver
+
+// pgExternalRefIndex - unique index of external references of payments
+const pgExternalRefIndex = "payments_external_ref_idx"
+
+// checkExternalRef - check that account with accountID has no payment with external reference of meta
+// returns ErrExternalRefDuplicate if reference was already used
+func (db *PgSQL) checkExternalRef(ctx context.Context, q pgQuerier, accountID int64, meta driver.Metadata) error {
+	if meta.ExternalRef == "" {
+		return nil
+	}
+	var id int64
+	row := q.QueryRow(ctx, `
+		SELECT id FROM payments WHERE external_ref_account = $1 AND external_ref = $2 LIMIT 1`,
+		accountID, meta.ExternalRef)
+	switch err := row.Scan(&id); {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	}
+	return driver.ErrExternalRefDuplicate
+}
+
+// isExternalRefViolation checking that error is violation of unique index of external references
+// it happens when concurrent request with the same reference was committed first
+func isExternalRefViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == pgExternalRefIndex
+}
+
+// FindByExternalRef - find payment created by account with accountID with external reference and load in object
+// returns ErrPaymentNotFound if payment not exists
+func (pg *PgSqlPayment) FindByExternalRef(ctx context.Context, accountID int64, ref string) error {
+	var id int64
+	row := pg.db.conn.QueryRow(ctx, `
+		SELECT id FROM payments WHERE external_ref_account = $1 AND external_ref = $2 LIMIT 1`, accountID, ref)
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return driver.ErrPaymentNotFound
+		}
+		return err
+	}
+	return pg.Get(ctx, id)
+}
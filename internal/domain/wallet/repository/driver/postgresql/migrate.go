@@ -0,0 +1,90 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Versioned migrations of PostgreSQL database, see migrate.go
+
+// MigrateUp - apply all pending migrations to PostgreSQL database
+func (db *PgSQL) MigrateUp(ctx context.Context) ([]driver.Migration, error) {
+	return driver.MigrateUp(ctx, db, "postgresql")
+}
+
+// MigrateDown - revert the last applied migration of PostgreSQL database
+func (db *PgSQL) MigrateDown(ctx context.Context) (*driver.Migration, error) {
+	return driver.MigrateDown(ctx, db, "postgresql")
+}
+
+// MigrationStatus - return migrations of PostgreSQL database with time of applying
+func (db *PgSQL) MigrationStatus(ctx context.Context) ([]driver.MigrationStatus, error) {
+	return driver.MigrationStatuses(ctx, db, "postgresql")
+}
+
+// CheckSchema - return ErrSchemaOutdated if PostgreSQL database has not applied migrations
+func (db *PgSQL) CheckSchema(ctx context.Context) error {
+	return driver.CheckSchema(ctx, db, "postgresql")
+}
+
+// AppliedMigrations - return versions of migrations applied to PostgreSQL database with time of applying
+func (db *PgSQL) AppliedMigrations(ctx context.Context) (map[int64]time.Time, error) {
+	if _, err := db.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS public.schema_migrations
+		(
+			version bigint NOT NULL,
+			name character varying(255) NOT NULL,
+			applied_at timestamp with time zone NOT NULL DEFAULT now(),
+			CONSTRAINT schema_migrations_pk PRIMARY KEY (version)
+		)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			v int64
+			t time.Time
+		)
+		if err := rows.Scan(&v, &t); err != nil {
+			return nil, err
+		}
+		res[v] = t
+	}
+	return res, rows.Err()
+}
+
+// ApplyMigration - execute migration on PostgreSQL database and save or delete its version in one transaction
+func (db *PgSQL) ApplyMigration(ctx context.Context, m driver.Migration, up bool) error {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	// SQL of migration is executed without arguments, so it may contain several statements
+	if up {
+		if _, err = tx.Exec(ctx, m.Up); err == nil {
+			_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+		}
+	} else {
+		if _, err = tx.Exec(ctx, m.Down); err == nil {
+			_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+		}
+	}
+	if err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			return e
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
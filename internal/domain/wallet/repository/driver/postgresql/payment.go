@@ -0,0 +1,292 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for payments for work with PostgreSQL database
+
+type PgSqlPayment struct {
+	// db - connection to database
+	db *PgSQL
+
+	id        int64
+	kind      string
+	date      time.Time
+	amount    money.Amount
+	toAmount  money.Amount
+	rate      money.Amount
+	rateDate  *time.Time
+	toBalance money.Amount
+	// counterparty - reference to external destination of withdrawal
+	counterparty string
+	// reversalOf - id of payment compensated by this reversal
+	reversalOf int64
+	// fee - fee of transfer charged to payer in addition to amount
+	fee    money.Amount
+	fromID int64
+	toID   int64
+	// meta - client supplied description, external reference and tags of payment
+	meta driver.Metadata
+}
+
+// list of payments fields used in SELECT queries
+// to_amount and rate are NULL for payments created before cross-currency transfers were supported
+const pgPaymentFields = `id, kind, "from", "to", amount, COALESCE(to_amount, amount), COALESCE(rate, 1), rate_date, to_balance,
+	COALESCE(counterparty, ''), COALESCE(reversal_of, 0), fee, date,
+	COALESCE(description, ''), COALESCE(external_ref, ''), tags`
+
+func (pg PgSqlPayment) ID() int64 {
+	return pg.id
+}
+func (pg PgSqlPayment) Kind() string {
+	return pg.kind
+}
+func (pg PgSqlPayment) Date() time.Time {
+	return pg.date
+}
+func (pg PgSqlPayment) Amount() money.Amount {
+	return pg.amount
+}
+func (pg PgSqlPayment) From() int64 {
+	return pg.fromID
+}
+func (pg PgSqlPayment) To() int64 {
+	return pg.toID
+}
+func (pg PgSqlPayment) ToAmount() money.Amount {
+	return pg.toAmount
+}
+func (pg PgSqlPayment) Rate() money.Amount {
+	return pg.rate
+}
+func (pg PgSqlPayment) RateDate() time.Time {
+	if pg.rateDate == nil {
+		return time.Time{}
+	}
+	return *pg.rateDate
+}
+func (pg PgSqlPayment) ToBalance() money.Amount {
+	return pg.toBalance
+}
+func (pg PgSqlPayment) Counterparty() string {
+	return pg.counterparty
+}
+func (pg PgSqlPayment) ReversalOf() int64 {
+	return pg.reversalOf
+}
+func (pg PgSqlPayment) Fee() money.Amount {
+	return pg.fee
+}
+func (pg PgSqlPayment) Metadata() driver.Metadata {
+	return pg.meta
+}
+
+// Entries return ledger entries of payment
+func (pg PgSqlPayment) Entries(ctx context.Context) ([]driver.LedgerEntry, error) {
+	return pg.db.ledgerEntries(ctx, pg.db.conn, pg.id)
+}
+
+// Unbalanced - return ids of payments which ledger entries don't sum to zero in some currency
+// or which have no entries at all. Empty result means that ledger is consistent
+func (pg PgSqlPayment) Unbalanced(ctx context.Context) ([]int64, error) {
+	rows, err := pg.db.conn.Query(ctx, `
+		SELECT payment_id FROM ledger_entries
+		GROUP BY payment_id, currency
+		HAVING SUM(amount) <> 0
+		UNION
+		SELECT id FROM payments p
+		WHERE NOT EXISTS (SELECT 1 FROM ledger_entries l WHERE l.payment_id = p.id)
+		ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		res []int64
+		id  int64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+// Get - get payment by ID and load in object
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (pg *PgSqlPayment) Get(ctx context.Context, id int64) error {
+	// check in cache
+	cacheKey := pg._cacheKey(id)
+	if v, ok := pg.db.cache.Get(cacheKey); ok {
+		*pg = v.(PgSqlPayment)
+		return nil
+	}
+
+	row := pg.db.conn.QueryRow(ctx, `
+		SELECT `+pgPaymentFields+`
+		FROM payments
+		WHERE 
+			"id" = $1 
+		LIMIT 1`, id)
+
+	if err := pg.scan(row); err != nil {
+		return err
+	}
+	pg.db.cache.Set(cacheKey, *pg, 0)
+	return nil
+}
+
+// List - return list of payments for account with accountID which meet conditions of filter
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+// conditions of filter which are not set are passed as NULL, so the query is the same for every filter
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (pg PgSqlPayment) List(ctx context.Context, accountID int64, filter driver.PaymentFilter, after, offset, limit int64) ([]interface{}, error) {
+
+	// try to get list from pg.db.cache. Filtered lists and pages after cursor are not cached
+	cacheKey := pg._cacheListKey(accountID)
+	mayCache := filter.IsZero() && after <= 0 && maySaveToCache(accountID, offset, limit)
+	if mayCache {
+		if v, ok := pg.db.cache.Get(cacheKey); ok {
+			return v.([]interface{}), nil
+		}
+	}
+
+	fromID, toID, kind, notKind := filter.Conditions(accountID)
+	rows, err := pg.db.conn.Query(ctx, `
+		SELECT `+pgPaymentFields+`
+		FROM payments
+		WHERE
+			("from" = $1 OR "to" = $1)
+			AND ($2::timestamptz IS NULL OR date >= $2)
+			AND ($3::timestamptz IS NULL OR date < $3)
+			AND ($4::numeric IS NULL OR (CASE WHEN "to" = $1 THEN COALESCE(to_amount, amount) ELSE amount END) >= $4)
+			AND ($5::numeric IS NULL OR (CASE WHEN "to" = $1 THEN COALESCE(to_amount, amount) ELSE amount END) <= $5)
+			AND ($6::bigint IS NULL OR "from" = $6)
+			AND ($7::bigint IS NULL OR "to" = $7)
+			AND ($8::text IS NULL OR kind = $8)
+			AND ($9::text IS NULL OR kind <> $9)
+			AND ($10::bigint IS NULL OR "from" = $10 OR "to" = $10)
+			AND ($11::bigint IS NULL OR id < $11)
+		ORDER BY id DESC
+		OFFSET $12
+		LIMIT $13`,
+		accountID, driver.NullTime(filter.Since), driver.NullTime(filter.Until),
+		driver.NullAmount(filter.MinAmount), driver.NullAmount(filter.MaxAmount),
+		driver.NullID(fromID), driver.NullID(toID), driver.NullString(kind), driver.NullString(notKind), driver.NullID(filter.CounterpartyID),
+		driver.NullID(after), offset, pgLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []interface{}
+	for rows.Next() {
+		p := &PgSqlPayment{db: pg.db}
+		if err := p.scan(rows); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if mayCache {
+		pg.db.cache.Set(cacheKey, res, 0)
+	}
+	return res, nil
+}
+
+// ListAll - return list of payments
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (pg PgSqlPayment) ListAll(ctx context.Context, after, offset, limit int64) ([]interface{}, error) {
+
+	// try to get list from pg.db.cache. For list of all payments used cacheKey for accountID=-1
+	cacheKey := pg._cacheListKey(-1)
+	mayCache := after <= 0 && maySaveToCache(-1, offset, limit)
+	if mayCache {
+		if v, ok := pg.db.cache.Get(cacheKey); ok {
+			return v.([]interface{}), nil
+		}
+	}
+
+	rows, err := pg.db.conn.Query(ctx, `
+		SELECT `+pgPaymentFields+`
+		FROM payments
+		WHERE $1::bigint IS NULL OR id < $1
+		ORDER BY id DESC
+		OFFSET $2
+		LIMIT $3`, driver.NullID(after), offset, pgLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []interface{}
+	for rows.Next() {
+		p := &PgSqlPayment{db: pg.db}
+		if err := p.scan(rows); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if mayCache {
+		pg.db.cache.Set(cacheKey, res, 0)
+	}
+	return res, nil
+}
+
+// scan payment fields selected with pgPaymentFields
+func (pg *PgSqlPayment) scan(row pgx.Row) error {
+	var tags []byte
+	if err := row.Scan(&pg.id, &pg.kind, &pg.fromID, &pg.toID, &pg.amount, &pg.toAmount, &pg.rate, &pg.rateDate, &pg.toBalance,
+		&pg.counterparty, &pg.reversalOf, &pg.fee, &pg.date, &pg.meta.Description, &pg.meta.ExternalRef, &tags); err != nil {
+		return err
+	}
+	var err error
+	pg.meta.Tags, err = driver.DecodeTags(tags)
+	return err
+}
+
+// pgLimit - value of LIMIT parameter, NULL (no limit) for negative limit
+func pgLimit(limit int64) interface{} {
+	if limit < 0 {
+		return nil
+	}
+	return limit
+}
+
+// generate key for in memory cache
+func (pg PgSqlPayment) _cacheKey(id int64) string {
+	return fmt.Sprintf("PgSqlPayment%d", id)
+}
+
+// generate key for list in memory cache
+func (pg PgSqlPayment) _cacheListKey(accountID int64) string {
+	return fmt.Sprintf("List%d", accountID)
+}
+
+//
+func maySaveToCache(id, o, l int64) bool {
+	return o == 0 && l == -1
+}
@@ -0,0 +1,111 @@
+// postgresql driver for data manipulation for repository entities
+
+// for minimisation queries count to database this driver use memory cache from package coinswallet/pkg/memcache
+
+// configuration of connection to database is passed in Config: DSN is connection string of PostgreSQL,
+// for example "user=coins password=coins dbname=coins host=127.0.0.1 port=5432 sslmode=disable",
+// MaxConns and MinConns set size of pool of connections, CacheTTL - expiration time of memory cache
+
+// Package postgresql registers PostgreSQL storage driver with name "postgresql"
+// import it for side effect to make driver available for repository.Open
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	memorycache "github.com/rurick/coinswallet/pkg/memcache"
+	logger "github.com/sirupsen/logrus"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+// pgDefaultCacheTTL - expiration time of memory cache when it is not set in Config
+const pgDefaultCacheTTL = 10 * time.Minute
+
+// PgSQL - connection to PostgreSQL database shared by account and payment repositories
+type PgSQL struct {
+	// pool of database resources
+	pool *pgxpool.Pool
+	// conn - executor of all queries of driver, it is pool or transaction of unit of work
+	conn pgConn
+	// Context of all database operations, it is cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	// in memory cache
+	cache pgCache
+}
+
+func init() {
+	repository.Register("postgresql", Open)
+}
+
+// Open - open PostgreSQL database, repositories share pool of connections
+func Open(cfg repository.Config) (repository.Driver, error) {
+	db, err := NewPgSQL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewPgSQL - connect to PostgreSQL database
+// schema of database is created and upgraded by migrations, see MigrateUp
+func NewPgSQL(cfg driver.Config) (*PgSQL, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	cacheTTL := cfg.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = pgDefaultCacheTTL
+	}
+
+	logger.Info("Wallet pgsql driver. Connecting to database...")
+	db := &PgSQL{cache: memorycache.New(cacheTTL, cacheTTL)}
+	db.ctx, db.cancel = context.WithCancel(context.Background())
+	if db.pool, err = pgxpool.ConnectConfig(db.ctx, poolConfig); err != nil {
+		db.cancel()
+		logger.WithFields(logger.Fields{
+			"DBUser": poolConfig.ConnConfig.User,
+			"DBName": poolConfig.ConnConfig.Database,
+			"DBHost": poolConfig.ConnConfig.Host,
+			"DBPort": poolConfig.ConnConfig.Port,
+		}).Error("[Wallet][NewPgSQL]Unable to connect to database: ", err)
+		return nil, err
+	}
+	db.conn = db.pool
+
+	return db, nil
+}
+
+// Close - close connections to database
+func (db *PgSQL) Close() error {
+	db.cancel()
+	db.pool.Close()
+	logger.Info("Wallet pgsql driver. DB connection closed")
+	return nil
+}
+
+// Account - create account repository using connection db
+func (db *PgSQL) Account() repository.Account {
+	return &PgSqlAccount{db: db}
+}
+
+// Payment - create payment repository using connection db
+func (db *PgSQL) Payment() repository.Payment {
+	return &PgSqlPayment{db: db}
+}
+
+// Schedule - create repository of scheduled transfers using connection db
+func (db *PgSQL) Schedule() repository.Schedule {
+	return &PgSqlSchedule{db: db}
+}
@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+//
+// Retry of PostgreSQL transactions
+
+// pgMaxAttempts - count of attempts to execute transaction failed because of deadlock or serialization failure
+const pgMaxAttempts = 5
+
+// pgRetry - execute f and repeat it while it fails with retryable error
+// pause between attempts grows with each attempt, random jitter is added to separate competing transactions
+// retrying is stopped when ctx is done
+func pgRetry(ctx context.Context, f func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil || !isRetryable(err) || attempt == pgMaxAttempts {
+			return
+		}
+		pause := time.Duration(attempt*attempt) * 5 * time.Millisecond
+		t := time.NewTimer(pause + time.Duration(rand.Int63n(int64(pause))))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// isRetryable checking that transaction was failed because of serialization failure or deadlock
+// and may succeed when repeated
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func Test_pgRetry(t *testing.T) {
+	var (
+		serialization = &pgconn.PgError{Code: "40001"}
+		deadlock      = &pgconn.PgError{Code: "40P01"}
+		unique        = &pgconn.PgError{Code: "23505"}
+		other         = errors.New("other")
+	)
+	tests := []struct {
+		name string
+		// errs - results of attempts, the last one is repeated
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", []error{nil}, nil, 1},
+		{"serialization failure", []error{serialization, nil}, nil, 2},
+		{"deadlock", []error{deadlock, deadlock, nil}, nil, 3},
+		{"wrapped deadlock", []error{fmt.Errorf("transfer: %w", deadlock), nil}, nil, 2},
+		{"unique violation", []error{unique, nil}, unique, 1},
+		{"other error", []error{other, nil}, other, 1},
+		{"attempts exhausted", []error{serialization}, serialization, pgMaxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := pgRetry(context.Background(), func() error {
+				calls++
+				if calls > len(tt.errs) {
+					return tt.errs[len(tt.errs)-1]
+				}
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("pgRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := pgRetry(ctx, func() error {
+			calls++
+			cancel()
+			return deadlock
+		})
+		if err != context.Canceled || calls != 1 {
+			t.Errorf("pgRetry() = %v after %d calls, want %v after 1 call", err, calls, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Reversal of payments for PostgreSQL driver
+
+// Reverse - create payment which compensates loaded payment, money goes back from recipient to payer
+// amount is in payer currency of original payment and toAmount is in its recipient currency
+// if amount is zero the whole not reversed rest of payment is returned
+// total amount of reversals can't exceed amount of payment. When reversal closes the rest of payment,
+// toAmount is set to the rest of recipient amount, so rounding of partial reversals never leaves a remainder
+// available balance of recipient is checked unless force is set, balances of system accounts are never checked
+// closed accounts can't take part in reversal, frozen accounts only if force is set
+// transaction is repeated when it fails because of deadlock or serialization failure
+func (pg *PgSqlPayment) Reverse(ctx context.Context, amount, toAmount money.Amount, force bool) (int64, error) {
+	var paymentID int64
+	err := pgRetry(ctx, func() (err error) {
+		paymentID, err = pg.reverse(ctx, amount, toAmount, force)
+		return
+	})
+	return paymentID, err
+}
+
+// reverse - one attempt of reversal in database transaction
+// original payment row is locked, so concurrent reversals of the same payment are executed one by one
+func (pg *PgSqlPayment) reverse(ctx context.Context, amount, toAmount money.Amount, force bool) (int64, error) {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// rollback transaction and return result
+	rollback := func(id int64, err error) (int64, error) {
+		if e := tx.Rollback(ctx); e != nil {
+			return 0, e
+		}
+		return id, err
+	}
+
+	// lock original payment and get not reversed rest of it
+	var (
+		kind                 string
+		fromID, toID         int64
+		origAmount, origTo   money.Amount
+		fee                  money.Amount
+		reversed, reversedTo money.Amount
+	)
+	row := tx.QueryRow(ctx, `
+		SELECT kind, "from", "to", amount, COALESCE(to_amount, amount), fee
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE`, pg.id)
+	if err = row.Scan(&kind, &fromID, &toID, &origAmount, &origTo, &fee); err != nil {
+		return rollback(0, err)
+	}
+	if kind == driver.PaymentKindReversal {
+		return rollback(0, driver.ErrReversalNotAllowed)
+	}
+	row = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(to_amount), 0), COALESCE(SUM(amount), 0)
+		FROM payments
+		WHERE reversal_of = $1`, pg.id)
+	if err = row.Scan(&reversed, &reversedTo); err != nil {
+		return rollback(0, err)
+	}
+	rest, err := origAmount.Sub(reversed)
+	if err != nil {
+		return rollback(0, err)
+	}
+	restTo, err := origTo.Sub(reversedTo)
+	if err != nil {
+		return rollback(0, err)
+	}
+	switch {
+	case rest.Sign() <= 0 || amount.Cmp(rest) > 0:
+		return rollback(0, driver.ErrReversalExceedsAmount)
+	case amount.IsZero() || amount.Cmp(rest) == 0:
+		amount, toAmount = rest, restTo
+	case toAmount.Cmp(restTo) > 0:
+		toAmount = restTo
+	}
+
+	// lock accounts in ascending id order, the same as transfer does
+	type lockedAccount struct {
+		balance     money.Amount
+		creditLimit money.Amount
+		currency    string
+		system      bool
+		status      string
+	}
+	ids := []int64{fromID, toID}
+	if toID < fromID {
+		ids[0], ids[1] = ids[1], ids[0]
+	}
+	accounts := make(map[int64]lockedAccount, 2)
+	for _, id := range ids {
+		var a lockedAccount
+		row := tx.QueryRow(ctx,
+			`SELECT balance, credit_limit, currency, system, status FROM accounts WHERE "id" = $1 FOR UPDATE`, id)
+		if err = row.Scan(&a.balance, &a.creditLimit, &a.currency, &a.system, &a.status); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				err = driver.ErrAccountNotFound
+			}
+			return rollback(0, err)
+		}
+		accounts[id] = a
+	}
+
+	// money goes back from recipient of original payment
+	payer, recipient := accounts[toID], accounts[fromID]
+	for _, a := range []struct {
+		status    string
+		recipient bool
+	}{{payer.status, false}, {recipient.status, true}} {
+		if a.status == driver.AccountStatusClosed || !force {
+			if err = driver.AccountStatusError(a.status, a.recipient); err != nil {
+				return rollback(0, err)
+			}
+		}
+	}
+	if !payer.system && !force {
+		held, err := pg.db.heldAmount(ctx, tx, toID)
+		if err != nil {
+			return rollback(0, err)
+		}
+		if err = driver.CheckAvailable(payer.balance, held, payer.creditLimit, toAmount); err != nil {
+			return rollback(0, err)
+		}
+	}
+
+	// entries of original payment
+	entries, err := pg.db.ledgerEntries(ctx, tx, pg.id)
+	if err != nil {
+		return rollback(0, err)
+	}
+	if len(entries) == 0 {
+		return rollback(0, driver.ErrReversalNotAllowed)
+	}
+	// fee of transfer is not returned
+	var feeID int64
+	if !fee.IsZero() {
+		if feeID, err = pg.db.systemAccountID(ctx, driver.SystemAccountFee, recipient.currency); err != nil {
+			return rollback(0, err)
+		}
+	}
+
+	// update balances, balances of system accounts are not stored
+	var toBalance *money.Amount
+	if !payer.system {
+		if _, err = tx.Exec(ctx, `UPDATE accounts SET balance = balance - $1 WHERE id = $2`,
+			toAmount, toID); err != nil {
+			return rollback(0, err)
+		}
+	}
+	if !recipient.system {
+		toBalance = &money.Amount{}
+		row := tx.QueryRow(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING balance`,
+			amount, fromID)
+		if err = row.Scan(toBalance); err != nil {
+			return rollback(0, err)
+		}
+	}
+
+	// create payment
+	var paymentID int64
+	row = tx.QueryRow(ctx, `
+		INSERT INTO payments ("kind", "from", "to", "amount", "to_amount", "to_balance", "reversal_of", "date")
+		VALUES($1, $2, $3, $4, $5, $6, $7, NOW()) RETURNING id`,
+		driver.PaymentKindReversal, toID, fromID, toAmount, amount, toBalance, pg.id)
+	if err = row.Scan(&paymentID); err != nil {
+		return rollback(0, err)
+	}
+	if err = pg.db.postEntries(ctx, tx, paymentID, driver.ReversalEntries(entries, recipient.currency, amount, toAmount, feeID)); err != nil {
+		return rollback(0, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	a := PgSqlAccount{}
+	_ = pg.db.cache.Delete(a.cacheKey(fromID))
+	_ = pg.db.cache.Delete(a.cacheKey(toID))
+	(&PgSqlAccount{db: pg.db, id: fromID}).clearPaymentsListCache()
+	(&PgSqlAccount{db: pg.db, id: toID}).clearPaymentsListCache()
+
+	return paymentID, nil
+}
@@ -0,0 +1,224 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Driver for scheduled transfers for work with PostgreSQL database
+// scheduled transfers are not cached, they are read by scheduler worker which must see changes of all nodes
+
+type PgSqlSchedule struct {
+	// db - connection to database
+	db *PgSQL
+}
+
+// list of scheduled transfer fields used in SELECT queries
+const pgScheduleFields = `id, account_id, to_account_id, amount, recurrence, day, next_run_at, status, date`
+
+// pgScanSchedule - read scheduled transfer from row
+func pgScanSchedule(row pgx.Row) (driver.ScheduledTransfer, error) {
+	var t driver.ScheduledTransfer
+	err := row.Scan(&t.ID, &t.AccountID, &t.ToID, &t.Amount, &t.Recurrence, &t.Day, &t.NextRunAt, &t.Status, &t.Date)
+	return t, err
+}
+
+// querySchedules - read scheduled transfers selected by query
+func (pg *PgSqlSchedule) querySchedules(ctx context.Context, sql string, args ...interface{}) ([]driver.ScheduledTransfer, error) {
+	rows, err := pg.db.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.ScheduledTransfer
+	for rows.Next() {
+		t, err := pgScanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
+
+// Create - save new active scheduled transfer
+// returning id of scheduled transfer
+func (pg *PgSqlSchedule) Create(ctx context.Context, t driver.ScheduledTransfer) (int64, error) {
+	var id int64
+	row := pg.db.conn.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (account_id, to_account_id, amount, recurrence, day, next_run_at, status, date)
+		VALUES($1, $2, $3, $4, $5, $6, $7, NOW()) RETURNING id`,
+		t.AccountID, t.ToID, t.Amount, t.Recurrence, t.Day, t.NextRunAt, driver.ScheduleStatusActive)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get - return scheduled transfer of account with accountID by id
+func (pg *PgSqlSchedule) Get(ctx context.Context, accountID, id int64) (driver.ScheduledTransfer, error) {
+	row := pg.db.conn.QueryRow(ctx, `SELECT `+pgScheduleFields+`
+		FROM scheduled_transfers
+		WHERE id = $1 AND account_id = $2`, id, accountID)
+	t, err := pgScanSchedule(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return driver.ScheduledTransfer{}, driver.ErrScheduleNotFound
+	}
+	return t, err
+}
+
+// List - return scheduled transfers of account with accountID
+// scheduled transfers listed ordering by id
+// if limit = -1, then no limit
+func (pg *PgSqlSchedule) List(ctx context.Context, accountID, offset, limit int64) ([]driver.ScheduledTransfer, error) {
+	return pg.querySchedules(ctx, `SELECT `+pgScheduleFields+`
+		FROM scheduled_transfers
+		WHERE account_id = $1
+		ORDER BY id
+		OFFSET $2
+		LIMIT $3`, accountID, offset, pgLimit(limit))
+}
+
+// Update - save amount, recurrence, day and next run time of active scheduled transfer
+func (pg *PgSqlSchedule) Update(ctx context.Context, t driver.ScheduledTransfer) error {
+	res, err := pg.db.conn.Exec(ctx, `
+		UPDATE scheduled_transfers SET amount = $1, recurrence = $2, day = $3, next_run_at = $4
+		WHERE id = $5 AND account_id = $6 AND status = $7`,
+		t.Amount, t.Recurrence, t.Day, t.NextRunAt, t.ID, t.AccountID, driver.ScheduleStatusActive)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		if _, err := pg.Get(ctx, t.AccountID, t.ID); err != nil {
+			return err
+		}
+		return driver.ErrScheduleNotActive
+	}
+	return nil
+}
+
+// Cancel - set status "cancelled" for active scheduled transfer of account with accountID
+func (pg *PgSqlSchedule) Cancel(ctx context.Context, accountID, id int64) error {
+	res, err := pg.db.conn.Exec(ctx, `
+		UPDATE scheduled_transfers SET status = $1
+		WHERE id = $2 AND account_id = $3 AND status = $4`,
+		driver.ScheduleStatusCancelled, id, accountID, driver.ScheduleStatusActive)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		if _, err := pg.Get(ctx, accountID, id); err != nil {
+			return err
+		}
+		return driver.ErrScheduleNotActive
+	}
+	return nil
+}
+
+// Due - return active scheduled transfers which next run time is not after now
+// scheduled transfers listed ordering by next run time
+func (pg *PgSqlSchedule) Due(ctx context.Context, now time.Time, limit int64) ([]driver.ScheduledTransfer, error) {
+	return pg.querySchedules(ctx, `SELECT `+pgScheduleFields+`
+		FROM scheduled_transfers
+		WHERE status = $1 AND next_run_at <= $2
+		ORDER BY next_run_at, id
+		LIMIT $3`, driver.ScheduleStatusActive, now, limit)
+}
+
+// Complete - record execution of scheduled transfer planned at e.RunAt and plan the next execution at next
+// if next is zero transfer becomes completed. Execution is recorded only once for every planned time,
+// ErrScheduleExecuted is returned if it was already recorded. When transfer was changed or cancelled
+// after it was taken for execution, execution is recorded but the next execution is not changed
+func (pg *PgSqlSchedule) Complete(ctx context.Context, e driver.ScheduleExecution, next time.Time) error {
+	tx, err := pg.db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// rollback transaction and return error
+	rollback := func(err error) error {
+		if e := tx.Rollback(ctx); e != nil {
+			return e
+		}
+		return err
+	}
+
+	// lock of scheduled transfer serializes executions of it
+	var (
+		status    string
+		nextRunAt time.Time
+		executed  bool
+	)
+	row := tx.QueryRow(ctx, `SELECT status, next_run_at FROM scheduled_transfers WHERE id = $1 FOR UPDATE`, e.ScheduleID)
+	if err = row.Scan(&status, &nextRunAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = driver.ErrScheduleNotFound
+		}
+		return rollback(err)
+	}
+	row = tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM scheduled_transfer_executions WHERE schedule_id = $1 AND run_at = $2)`,
+		e.ScheduleID, e.RunAt)
+	if err = row.Scan(&executed); err != nil {
+		return rollback(err)
+	}
+	if executed {
+		return rollback(driver.ErrScheduleExecuted)
+	}
+
+	var paymentID interface{}
+	if e.PaymentID != 0 {
+		paymentID = e.PaymentID
+	}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO scheduled_transfer_executions (schedule_id, run_at, payment_id, error, date)
+		VALUES($1, $2, $3, $4, NOW())`, e.ScheduleID, e.RunAt, paymentID, e.Error); err != nil {
+		return rollback(err)
+	}
+	if status == driver.ScheduleStatusActive && nextRunAt.Equal(e.RunAt) {
+		if next.IsZero() {
+			_, err = tx.Exec(ctx, `UPDATE scheduled_transfers SET status = $1 WHERE id = $2`,
+				driver.ScheduleStatusCompleted, e.ScheduleID)
+		} else {
+			_, err = tx.Exec(ctx, `UPDATE scheduled_transfers SET next_run_at = $1 WHERE id = $2`, next, e.ScheduleID)
+		}
+		if err != nil {
+			return rollback(err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Executions - return results of executions of scheduled transfer with scheduleID
+// executions listed ordering by id
+// if limit = -1, then no limit
+func (pg *PgSqlSchedule) Executions(ctx context.Context, scheduleID, offset, limit int64) ([]driver.ScheduleExecution, error) {
+	rows, err := pg.db.conn.Query(ctx, `
+		SELECT id, schedule_id, run_at, COALESCE(payment_id, 0), error, date
+		FROM scheduled_transfer_executions
+		WHERE schedule_id = $1
+		ORDER BY id
+		OFFSET $2
+		LIMIT $3`, scheduleID, offset, pgLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.ScheduleExecution
+	for rows.Next() {
+		var e driver.ScheduleExecution
+		if err := rows.Scan(&e.ID, &e.ScheduleID, &e.RunAt, &e.PaymentID, &e.Error, &e.Date); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,124 @@
+package postgresql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	memorycache "github.com/rurick/coinswallet/pkg/memcache"
+)
+
+//
+// Unit of work of PostgreSQL driver
+// repositories of unit execute all queries in one transaction, transactions started by methods of repositories
+// become nested transactions (savepoints), so operations keep their own atomicity inside the unit
+
+// pgConn - common interface of pool and transaction, used for executing queries of driver
+type pgConn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// pgCache - memory cache of rows, implemented by memorycache.Cache and pgUnitCache
+type pgCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, duration time.Duration)
+	Delete(key string) error
+}
+
+// pgUnitCache - cache of unit of work
+// rows read in transaction are not visible outside of it and rows of shared cache are not used in transaction,
+// keys deleted in transaction are deleted from shared cache when unit is committed
+type pgUnitCache struct {
+	*memorycache.Cache
+	shared pgCache
+
+	mu      sync.Mutex
+	deleted map[string]bool
+}
+
+func (c *pgUnitCache) Delete(key string) error {
+	c.mu.Lock()
+	c.deleted[key] = true
+	c.mu.Unlock()
+	return c.Cache.Delete(key)
+}
+
+// commit - delete keys changed by committed transaction from shared cache
+func (c *pgUnitCache) commit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.deleted {
+		_ = c.shared.Delete(key)
+	}
+}
+
+// PgSQLUnit - unit of work of PostgreSQL driver
+type PgSQLUnit struct {
+	db    *PgSQL
+	tx    pgx.Tx
+	cache *pgUnitCache
+	// done - unit is committed or rolled back
+	done bool
+}
+
+// Begin - start unit of work, its repositories execute queries in one transaction
+// unit must be finished by Commit or Rollback
+func (db *PgSQL) Begin(ctx context.Context) (repository.UnitOfWork, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache := &pgUnitCache{
+		Cache:   memorycache.New(0, 0),
+		shared:  db.cache,
+		deleted: make(map[string]bool),
+	}
+	return &PgSQLUnit{
+		db:    &PgSQL{pool: db.pool, conn: tx, ctx: db.ctx, cancel: db.cancel, cache: cache},
+		tx:    tx,
+		cache: cache,
+	}, nil
+}
+
+// Account - create account repository of unit
+func (u *PgSQLUnit) Account() repository.Account {
+	return u.db.Account()
+}
+
+// Payment - create payment repository of unit
+func (u *PgSQLUnit) Payment() repository.Payment {
+	return u.db.Payment()
+}
+
+// Schedule - create repository of scheduled transfers of unit
+func (u *PgSQLUnit) Schedule() repository.Schedule {
+	return u.db.Schedule()
+}
+
+// Commit - commit transaction of unit
+func (u *PgSQLUnit) Commit(ctx context.Context) error {
+	if u.done {
+		return pgx.ErrTxClosed
+	}
+	u.done = true
+	if err := u.tx.Commit(ctx); err != nil {
+		return err
+	}
+	u.cache.commit()
+	return nil
+}
+
+// Rollback - roll back transaction of unit, after Commit it does nothing
+func (u *PgSQLUnit) Rollback(ctx context.Context) error {
+	if u.done {
+		return nil
+	}
+	u.done = true
+	return u.tx.Rollback(ctx)
+}
@@ -0,0 +1,640 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for accounts for work with SQLite database
+
+type SqliteAccount struct {
+	// db - connection to database
+	db *SQLite
+
+	id       int64
+	name     string
+	balance  money.Amount
+	currency string
+	system   bool
+	status   string
+	// available - balance without active holds
+	available money.Amount
+	// creditLimit - overdraft limit, balance can go down to -creditLimit
+	creditLimit money.Amount
+}
+
+func (sq *SqliteAccount) ID() int64 {
+	return sq.id
+}
+func (sq *SqliteAccount) Name() string {
+	return sq.name
+}
+func (sq *SqliteAccount) Currency() string {
+	return sq.currency
+}
+func (sq *SqliteAccount) Balance() money.Amount {
+	return sq.balance
+}
+func (sq *SqliteAccount) AvailableBalance() money.Amount {
+	return sq.available
+}
+func (sq *SqliteAccount) CreditLimit() money.Amount {
+	return sq.creditLimit
+}
+func (sq *SqliteAccount) System() bool {
+	return sq.system
+}
+func (sq *SqliteAccount) Status() string {
+	return sq.status
+}
+
+// LedgerBalance - balance of account derived from ledger entries
+func (sq *SqliteAccount) LedgerBalance(ctx context.Context) (money.Amount, error) {
+	rows, err := sq.db.conn.QueryContext(ctx, `SELECT amount FROM ledger_entries WHERE account_id = ?`, sq.id)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	defer rows.Close()
+
+	var sum money.Amount
+	for rows.Next() {
+		var a money.Amount
+		if err := rows.Scan(&a); err != nil {
+			return money.Amount{}, err
+		}
+		if sum, err = sum.Add(a); err != nil {
+			return money.Amount{}, err
+		}
+	}
+	return sum, rows.Err()
+}
+
+// Find - find wallet with name and load in object
+// system accounts can't be found by name
+func (sq *SqliteAccount) Find(ctx context.Context, name string) error {
+	var id int64
+	if err := sq.db.conn.QueryRowContext(ctx, `SELECT id FROM accounts WHERE name = ? AND system = 0`, name).Scan(&id); err != nil {
+		return err
+	}
+	return sq.load(ctx, sq.db.conn, id)
+}
+
+// Get - get wallet by ID and load in object
+func (sq *SqliteAccount) Get(ctx context.Context, id int64) error {
+	return sq.load(ctx, sq.db.conn, id)
+}
+
+// load - read account with id in object
+func (sq *SqliteAccount) load(ctx context.Context, q sqliteQuerier, id int64) error {
+	a, err := sqliteGetAccount(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	held, err := sqliteHeldAmount(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	available, err := a.balance.Sub(held)
+	if err != nil {
+		return err
+	}
+	*sq = SqliteAccount{
+		db:          sq.db,
+		id:          a.id,
+		name:        a.name,
+		balance:     a.balance,
+		currency:    a.currency,
+		system:      a.system,
+		status:      a.status,
+		available:   driver.RoundAmount(available, driver.AmountScale),
+		creditLimit: a.creditLimit,
+	}
+	return nil
+}
+
+// Deposit - add amount to account balance
+// money is debited from cash system account in account currency
+// returns ErrAccountFrozen or ErrAccountClosed if account is not active
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (sq *SqliteAccount) Deposit(ctx context.Context, amount money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	var paymentID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) (err error) {
+		if paymentID, err = sqliteFindIdempotent(ctx, tx, sq.id, idem); paymentID != 0 || err != nil {
+			return err
+		}
+		a, err := sqliteGetAccount(ctx, tx, sq.id)
+		if err != nil {
+			return err
+		}
+		if err := driver.AccountStatusError(a.status, false); err != nil {
+			return err
+		}
+		balance, err := a.balance.Add(amount)
+		if err != nil {
+			return err
+		}
+
+		cashID, err := sqliteSystemAccountID(ctx, tx, driver.SystemAccountCash, a.currency)
+		if err != nil {
+			return err
+		}
+		entries := []driver.LedgerEntry{
+			{AccountID: cashID, Amount: amount.Neg(), Currency: a.currency},
+			{AccountID: a.id, Amount: amount, Currency: a.currency},
+		}
+		if paymentID, err = sqliteAddPayment(ctx, tx, SqlitePayment{
+			kind:      driver.PaymentKindDeposit,
+			fromID:    cashID,
+			toID:      a.id,
+			amount:    amount,
+			toAmount:  amount,
+			rate:      money.New(1, 0),
+			toBalance: balance,
+			meta:      meta,
+		}, entries, a.id, idem); err != nil {
+			return err
+		}
+		return sqliteSetBalance(ctx, tx, a.id, balance)
+	})
+	if err != nil {
+		return paymentID, err
+	}
+	return paymentID, sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// Withdraw - move amount out of wallet to external counterparty
+// money is credited to cash system account in account currency, counterparty is reference to external destination
+// function check that the account is active and the account available balance is sufficient
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (sq *SqliteAccount) Withdraw(ctx context.Context, amount money.Amount, counterparty string, idem *driver.Idempotency) (int64, error) {
+	var paymentID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) (err error) {
+		if paymentID, err = sqliteFindIdempotent(ctx, tx, sq.id, idem); paymentID != 0 || err != nil {
+			return err
+		}
+		a, err := sqliteGetAccount(ctx, tx, sq.id)
+		if err != nil {
+			return err
+		}
+		if err := driver.AccountStatusError(a.status, false); err != nil {
+			return err
+		}
+		held, err := sqliteHeldAmount(ctx, tx, a.id)
+		if err != nil {
+			return err
+		}
+		if err = driver.CheckAvailable(a.balance, held, a.creditLimit, amount); err != nil {
+			return err
+		}
+		if err = sqliteCheckLimits(ctx, tx, a.id, amount); err != nil {
+			return err
+		}
+		balance, err := a.balance.Sub(amount)
+		if err != nil {
+			return err
+		}
+
+		cashID, err := sqliteSystemAccountID(ctx, tx, driver.SystemAccountCash, a.currency)
+		if err != nil {
+			return err
+		}
+		entries := []driver.LedgerEntry{
+			{AccountID: a.id, Amount: amount.Neg(), Currency: a.currency},
+			{AccountID: cashID, Amount: amount, Currency: a.currency},
+		}
+		if paymentID, err = sqliteAddPayment(ctx, tx, SqlitePayment{
+			kind:         driver.PaymentKindWithdrawal,
+			fromID:       a.id,
+			toID:         cashID,
+			amount:       amount,
+			toAmount:     amount,
+			rate:         money.New(1, 0),
+			counterparty: counterparty,
+		}, entries, a.id, idem); err != nil {
+			return err
+		}
+		return sqliteSetBalance(ctx, tx, a.id, balance)
+	})
+	if err != nil {
+		return paymentID, err
+	}
+	return paymentID, sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// FindIdempotent - search payment created by account with idempotency key
+// returns (0, nil) if key is not used, else id of payment and ErrIdempotencyReplay or ErrIdempotencyConflict
+func (sq *SqliteAccount) FindIdempotent(ctx context.Context, idem *driver.Idempotency) (int64, error) {
+	return sqliteFindIdempotent(ctx, sq.db.conn, sq.id, idem)
+}
+
+// Transfer - creating a payment form account to account with id "toID"
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// (balance without active holds) together with credit limit of the account is sufficient
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// meta is saved with payment, returns ErrExternalRefDuplicate if account already has payment with its external reference
+// if idem is not nil and payment with the same key exists, id of this payment returned with ErrIdempotencyReplay
+func (sq *SqliteAccount) Transfer(ctx context.Context, toID int64,
+	amount money.Amount, conv *driver.Conversion, fee money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	var paymentID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) (err error) {
+		paymentID, err = sq.transfer(ctx, tx, toID, amount, conv, fee, meta, idem)
+		return err
+	})
+	if err != nil {
+		return paymentID, err
+	}
+	return paymentID, sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// transfer - execute transfer in transaction tx
+func (sq *SqliteAccount) transfer(ctx context.Context, tx *sql.Tx, toID int64,
+	amount money.Amount, conv *driver.Conversion, fee money.Amount, meta driver.Metadata, idem *driver.Idempotency) (int64, error) {
+	if id, err := sqliteFindIdempotent(ctx, tx, sq.id, idem); id != 0 || err != nil {
+		return id, err
+	}
+	from, err := sqliteGetAccount(ctx, tx, sq.id)
+	if err != nil {
+		return 0, err
+	}
+	to, err := sqliteGetAccount(ctx, tx, toID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, driver.ErrRecipientNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	if err := driver.AccountStatusError(from.status, false); err != nil {
+		return 0, err
+	}
+	if err := driver.AccountStatusError(to.status, true); err != nil {
+		return 0, err
+	}
+	held, err := sqliteHeldAmount(ctx, tx, from.id)
+	if err != nil {
+		return 0, err
+	}
+	debit, err := amount.Add(fee)
+	if err != nil {
+		return 0, err
+	}
+	if err = driver.CheckAvailable(from.balance, held, from.creditLimit, debit); err != nil {
+		return 0, err
+	}
+	if err = sqliteCheckLimits(ctx, tx, from.id, amount); err != nil {
+		return 0, err
+	}
+
+	// amount in recipient currency
+	toAmount, rate, rateDate := amount, money.New(1, 0), (*time.Time)(nil)
+	entries := driver.TransferEntries(from.id, to.id, amount, from.currency, amount, to.currency, 0, 0)
+	if conv != nil {
+		fxFrom, err := sqliteSystemAccountID(ctx, tx, driver.SystemAccountFx, from.currency)
+		if err != nil {
+			return 0, err
+		}
+		fxTo, err := sqliteSystemAccountID(ctx, tx, driver.SystemAccountFx, to.currency)
+		if err != nil {
+			return 0, err
+		}
+		toAmount, rate, rateDate = conv.ToAmount, conv.Rate, &conv.RateDate
+		entries = driver.TransferEntries(from.id, to.id, amount, from.currency, toAmount, to.currency, fxFrom, fxTo)
+	}
+	if !fee.IsZero() {
+		feeID, err := sqliteSystemAccountID(ctx, tx, driver.SystemAccountFee, from.currency)
+		if err != nil {
+			return 0, err
+		}
+		if entries, err = driver.FeeEntries(entries, fee, feeID); err != nil {
+			return 0, err
+		}
+	}
+	fromBalance, err := from.balance.Sub(debit)
+	if err != nil {
+		return 0, err
+	}
+	toBalance, err := to.balance.Add(toAmount)
+	if err != nil {
+		return 0, err
+	}
+
+	paymentID, err := sqliteAddPayment(ctx, tx, SqlitePayment{
+		kind:      driver.PaymentKindTransfer,
+		fromID:    from.id,
+		toID:      to.id,
+		amount:    amount,
+		toAmount:  toAmount,
+		rate:      rate,
+		rateDate:  rateDate,
+		toBalance: toBalance,
+		fee:       fee,
+		meta:      meta,
+	}, entries, from.id, idem)
+	if err != nil {
+		return 0, err
+	}
+	if err := sqliteSetBalance(ctx, tx, from.id, fromBalance); err != nil {
+		return 0, err
+	}
+	if err := sqliteSetBalance(ctx, tx, to.id, toBalance); err != nil {
+		return 0, err
+	}
+	return paymentID, nil
+}
+
+// Hold - reserve amount of account for transfer to account with id "toID" for ttl
+// function check that recipient are exists, that both accounts are active and that the account available balance
+// is sufficient
+// returning id of hold
+func (sq *SqliteAccount) Hold(ctx context.Context, toID int64, amount money.Amount, ttl time.Duration) (int64, error) {
+	var holdID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) error {
+		to, err := sqliteGetAccount(ctx, tx, toID)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && to.system {
+			return driver.ErrRecipientNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := driver.AccountStatusError(to.status, true); err != nil {
+			return err
+		}
+		a, err := sqliteGetAccount(ctx, tx, sq.id)
+		if err != nil {
+			return err
+		}
+		if err := driver.AccountStatusError(a.status, false); err != nil {
+			return err
+		}
+		held, err := sqliteHeldAmount(ctx, tx, a.id)
+		if err != nil {
+			return err
+		}
+		if err = driver.CheckAvailable(a.balance, held, a.creditLimit, amount); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO holds (account_id, to_account_id, amount, status, expires_at, date)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			a.id, toID, driver.RoundAmount(amount, driver.AmountScale), driver.HoldStatusActive, now.Add(ttl), now)
+		if err != nil {
+			return err
+		}
+		holdID, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return holdID, sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// GetHold - return hold of account by id
+// status of expired hold which was not processed by ExpireHolds yet is returned as expired
+func (sq *SqliteAccount) GetHold(ctx context.Context, holdID int64) (driver.Hold, error) {
+	h, err := sq.hold(ctx, sq.db.conn, holdID)
+	if err != nil {
+		return driver.Hold{}, err
+	}
+	if h.Status == driver.HoldStatusActive && !h.ExpiresAt.After(time.Now()) {
+		h.Status = driver.HoldStatusExpired
+	}
+	return h, nil
+}
+
+// hold - read hold of account by id, returns ErrHoldNotFound if account has no hold with id
+func (sq *SqliteAccount) hold(ctx context.Context, q sqliteQuerier, holdID int64) (driver.Hold, error) {
+	var h driver.Hold
+	row := q.QueryRowContext(ctx, `
+		SELECT id, account_id, to_account_id, amount, status, expires_at, COALESCE(payment_id, 0), date
+		FROM holds
+		WHERE id = ? AND account_id = ?`, holdID, sq.id)
+	err := row.Scan(&h.ID, &h.AccountID, &h.ToID, &h.Amount, &h.Status, &h.ExpiresAt, &h.PaymentID, &h.Date)
+	if errors.Is(err, sql.ErrNoRows) {
+		return driver.Hold{}, driver.ErrHoldNotFound
+	}
+	return h, err
+}
+
+// Capture - turn hold into transfer of amount to recipient of hold
+// amount can be less than amount of hold, the rest of hold is released
+// if conv is not nil recipient balance increased by conv.ToAmount, else by amount
+// fee is charged to the account in addition to amount and credited to fee system account
+// returning id of payment
+func (sq *SqliteAccount) Capture(ctx context.Context, holdID int64, amount money.Amount, conv *driver.Conversion, fee money.Amount) (int64, error) {
+	var paymentID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) (err error) {
+		h, err := sq.hold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+		if h.Status != driver.HoldStatusActive || !h.ExpiresAt.After(time.Now()) {
+			return driver.ErrHoldNotActive
+		}
+		if amount.Cmp(h.Amount) > 0 {
+			return driver.ErrHoldExceedsAmount
+		}
+
+		// hold stops to reduce available balance before transfer checks it
+		if _, err := tx.ExecContext(ctx, `UPDATE holds SET status = ? WHERE id = ?`, driver.HoldStatusCaptured, h.ID); err != nil {
+			return err
+		}
+		if paymentID, err = sq.transfer(ctx, tx, h.ToID, amount, conv, fee, driver.Metadata{}, nil); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE holds SET captured_amount = ?, payment_id = ? WHERE id = ?`,
+			driver.RoundAmount(amount, driver.AmountScale), paymentID, h.ID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return paymentID, sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// Release - release active hold, reserved amount becomes available again
+func (sq *SqliteAccount) Release(ctx context.Context, holdID int64) error {
+	err := sq.db.tx(ctx, func(tx *sql.Tx) error {
+		h, err := sq.hold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+		if h.Status != driver.HoldStatusActive || !h.ExpiresAt.After(time.Now()) {
+			return driver.ErrHoldNotActive
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE holds SET status = ? WHERE id = ?`, driver.HoldStatusReleased, h.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// ExpireHolds - set status "expired" for all active holds with expired ttl
+// returning count of expired holds
+func (sq *SqliteAccount) ExpireHolds(ctx context.Context) (int64, error) {
+	var n int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, expires_at FROM holds WHERE status = ?`, driver.HoldStatusActive)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		now := time.Now()
+		for rows.Next() {
+			var (
+				id        int64
+				expiresAt time.Time
+			)
+			if err := rows.Scan(&id, &expiresAt); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			if !expiresAt.After(now) {
+				ids = append(ids, id)
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE holds SET status = ? WHERE id = ?`, driver.HoldStatusExpired, id); err != nil {
+				return err
+			}
+		}
+		n = int64(len(ids))
+		return nil
+	})
+	return n, err
+}
+
+// Limits - return spending limits of account, zero limits if they are not set
+func (sq *SqliteAccount) Limits(ctx context.Context) (driver.Limits, error) {
+	return sqliteLimits(ctx, sq.db.conn, sq.id)
+}
+
+// SetLimits - set spending limits of account
+func (sq *SqliteAccount) SetLimits(ctx context.Context, l driver.Limits) error {
+	_, err := sq.db.conn.ExecContext(ctx, `
+		INSERT INTO account_limits (account_id, max_amount, daily_amount, daily_count) VALUES (?, ?, ?, ?)
+		ON CONFLICT (account_id) DO UPDATE
+			SET max_amount = excluded.max_amount, daily_amount = excluded.daily_amount, daily_count = excluded.daily_count`,
+		sq.id, driver.RoundAmount(l.MaxAmount, driver.AmountScale), driver.RoundAmount(l.DailyAmount, driver.AmountScale), l.DailyCount)
+	return err
+}
+
+// Spending - return total amount and count of outgoing transfers and withdrawals of account since time
+func (sq *SqliteAccount) Spending(ctx context.Context, since time.Time) (driver.Spending, error) {
+	return sqliteSpending(ctx, sq.db.conn, sq.id, since)
+}
+
+// Create - create a new account with name and currency and load one in object
+// this function not validate name and currency
+func (sq *SqliteAccount) Create(ctx context.Context, name, currency string) error {
+	res, err := sq.db.conn.ExecContext(ctx, `INSERT INTO accounts (name, currency, status) VALUES (?, ?, ?)`,
+		name, currency, driver.AccountStatusActive)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return sq.load(ctx, sq.db.conn, id)
+}
+
+// SetStatus - change status of account
+// closed account can't change status, returns ErrAccountClosed
+// account can be closed only if its balance is zero and it has no active holds, else returns ErrAccountNotEmpty
+func (sq *SqliteAccount) SetStatus(ctx context.Context, status string) error {
+	err := sq.db.tx(ctx, func(tx *sql.Tx) error {
+		a, err := sqliteGetAccount(ctx, tx, sq.id)
+		if err != nil {
+			return err
+		}
+		if a.status == driver.AccountStatusClosed {
+			return driver.ErrAccountClosed
+		}
+		if status == driver.AccountStatusClosed {
+			held, err := sqliteHeldAmount(ctx, tx, a.id)
+			if err != nil {
+				return err
+			}
+			if !a.balance.IsZero() || !held.IsZero() {
+				return driver.ErrAccountNotEmpty
+			}
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE accounts SET status = ? WHERE id = ?`, status, a.id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// SetCreditLimit - set overdraft limit of account, balance of account can go down to -limit
+// closed account can't change limit, returns ErrAccountClosed
+func (sq *SqliteAccount) SetCreditLimit(ctx context.Context, limit money.Amount) error {
+	err := sq.db.tx(ctx, func(tx *sql.Tx) error {
+		a, err := sqliteGetAccount(ctx, tx, sq.id)
+		if err != nil {
+			return err
+		}
+		if a.status == driver.AccountStatusClosed {
+			return driver.ErrAccountClosed
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE accounts SET credit_limit = ? WHERE id = ?`,
+			driver.RoundAmount(limit, driver.AmountScale), a.id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return sq.load(ctx, sq.db.conn, sq.id)
+}
+
+// Delete - delete wallet account physically
+// payments of account are kept and refer to missing account, so in the application accounts are closed
+// with SetStatus instead. Delete is used only to clean up test data
+func (sq *SqliteAccount) Delete(ctx context.Context) error {
+	_, err := sq.db.conn.ExecContext(ctx, `DELETE FROM accounts WHERE id = ?`, sq.id)
+	return err
+}
+
+// List - return list of all wallets account names
+// Wallets listed ordering by id, system accounts are not listed
+// if after > 0, only accounts with id greater than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (sq *SqliteAccount) List(ctx context.Context, after, offset, limit int64) ([]int64, error) {
+	// negative LIMIT of SQLite means no limit
+	rows, err := sq.db.conn.QueryContext(ctx, `
+		SELECT id FROM accounts
+		WHERE system = 0 AND id > ?
+		ORDER BY id
+		LIMIT ? OFFSET ?`, after, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Versioned migrations of SQLite database, see migrate.go
+
+// MigrateUp - apply all pending migrations to SQLite database
+func (db *SQLite) MigrateUp(ctx context.Context) ([]driver.Migration, error) {
+	return driver.MigrateUp(ctx, db, "sqlite")
+}
+
+// MigrateDown - revert the last applied migration of SQLite database
+func (db *SQLite) MigrateDown(ctx context.Context) (*driver.Migration, error) {
+	return driver.MigrateDown(ctx, db, "sqlite")
+}
+
+// MigrationStatus - return migrations of SQLite database with time of applying
+func (db *SQLite) MigrationStatus(ctx context.Context) ([]driver.MigrationStatus, error) {
+	return driver.MigrationStatuses(ctx, db, "sqlite")
+}
+
+// CheckSchema - return ErrSchemaOutdated if SQLite database has not applied migrations
+func (db *SQLite) CheckSchema(ctx context.Context) error {
+	return driver.CheckSchema(ctx, db, "sqlite")
+}
+
+// AppliedMigrations - return versions of migrations applied to SQLite database with time of applying
+func (db *SQLite) AppliedMigrations(ctx context.Context) (map[int64]time.Time, error) {
+	if _, err := db.conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			v int64
+			t time.Time
+		)
+		if err := rows.Scan(&v, &t); err != nil {
+			return nil, err
+		}
+		res[v] = t
+	}
+	return res, rows.Err()
+}
+
+// ApplyMigration - execute migration on SQLite database and save or delete its version in one transaction
+func (db *SQLite) ApplyMigration(ctx context.Context, m driver.Migration, up bool) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		if up {
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, time.Now().UTC())
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+		return err
+	})
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+func Test_SqliteMigrate(t *testing.T) {
+	db, err := NewSQLite(driver.Config{DSN: filepath.Join(t.TempDir(), "wallet.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	migrations, _ := driver.Migrations("sqlite")
+
+	if err := db.CheckSchema(ctx); !errors.Is(err, driver.ErrSchemaOutdated) {
+		t.Errorf("CheckSchema() of empty database error = %v, want %v", err, driver.ErrSchemaOutdated)
+	}
+
+	applied, err := db.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("MigrateUp() applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	if err := db.CheckSchema(ctx); err != nil {
+		t.Errorf("CheckSchema() after MigrateUp() error = %v", err)
+	}
+	if applied, err = db.MigrateUp(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("repeated MigrateUp() = %v, %v, want nothing applied", applied, err)
+	}
+	if err := db.Account().Create(ctx, "migratewallet", "usd"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last migration is reverted, the others stay applied
+	last := migrations[len(migrations)-1]
+	m, err := db.MigrateDown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Version != last.Version {
+		t.Errorf("MigrateDown() reverted %v, want version %d", m, last.Version)
+	}
+	status, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.Applied() == (s.Version == last.Version) {
+			t.Errorf("migration %d applied = %v after MigrateDown()", s.Version, s.Applied())
+		}
+	}
+	if err := db.CheckSchema(ctx); !errors.Is(err, driver.ErrSchemaOutdated) {
+		t.Errorf("CheckSchema() after MigrateDown() error = %v, want %v", err, driver.ErrSchemaOutdated)
+	}
+
+	// data of not reverted migrations is kept
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Account().Find(ctx, "migratewallet"); err != nil {
+		t.Errorf("account is lost after MigrateDown() and MigrateUp(): %v", err)
+	}
+
+	// database migrated by newer version of wallet
+	if _, err := db.conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'future', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckSchema(ctx); !errors.Is(err, driver.ErrSchemaUnknown) {
+		t.Errorf("CheckSchema() error = %v, want %v", err, driver.ErrSchemaUnknown)
+	}
+}
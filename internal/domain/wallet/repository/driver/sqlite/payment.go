@@ -0,0 +1,373 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+//
+// Driver for payments for work with SQLite database
+
+type SqlitePayment struct {
+	// db - connection to database
+	db *SQLite
+
+	id        int64
+	kind      string
+	date      time.Time
+	amount    money.Amount
+	toAmount  money.Amount
+	rate      money.Amount
+	rateDate  *time.Time
+	toBalance money.Amount
+	// counterparty - reference to external destination of withdrawal
+	counterparty string
+	// reversalOf - id of payment compensated by this reversal
+	reversalOf int64
+	// fee - fee of transfer charged to payer in addition to amount
+	fee    money.Amount
+	fromID int64
+	toID   int64
+	// meta - client supplied description, external reference and tags of payment
+	meta driver.Metadata
+}
+
+// list of payments fields used in SELECT queries
+const sqlitePaymentFields = `id, kind, "from", "to", amount, to_amount, rate, rate_date, to_balance,
+	COALESCE(counterparty, ''), COALESCE(reversal_of, 0), fee, date,
+	COALESCE(description, ''), COALESCE(external_ref, ''), tags`
+
+func (sq SqlitePayment) ID() int64 {
+	return sq.id
+}
+func (sq SqlitePayment) Kind() string {
+	return sq.kind
+}
+func (sq SqlitePayment) Date() time.Time {
+	return sq.date
+}
+func (sq SqlitePayment) Amount() money.Amount {
+	return sq.amount
+}
+func (sq SqlitePayment) From() int64 {
+	return sq.fromID
+}
+func (sq SqlitePayment) To() int64 {
+	return sq.toID
+}
+func (sq SqlitePayment) ToAmount() money.Amount {
+	return sq.toAmount
+}
+func (sq SqlitePayment) Rate() money.Amount {
+	return sq.rate
+}
+func (sq SqlitePayment) RateDate() time.Time {
+	if sq.rateDate == nil {
+		return time.Time{}
+	}
+	return *sq.rateDate
+}
+func (sq SqlitePayment) ToBalance() money.Amount {
+	return sq.toBalance
+}
+func (sq SqlitePayment) Counterparty() string {
+	return sq.counterparty
+}
+func (sq SqlitePayment) ReversalOf() int64 {
+	return sq.reversalOf
+}
+func (sq SqlitePayment) Fee() money.Amount {
+	return sq.fee
+}
+
+func (sq SqlitePayment) Metadata() driver.Metadata {
+	return sq.meta
+}
+
+// Entries return ledger entries of payment
+func (sq SqlitePayment) Entries(ctx context.Context) ([]driver.LedgerEntry, error) {
+	return sqlitePaymentEntries(ctx, sq.db.conn, sq.id)
+}
+
+// Unbalanced - return ids of payments which ledger entries don't sum to zero in some currency
+// or which have no entries at all. Empty result means that ledger is consistent
+func (sq SqlitePayment) Unbalanced(ctx context.Context) ([]int64, error) {
+	rows, err := sq.db.conn.QueryContext(ctx, `SELECT payment_id, amount, currency FROM ledger_entries`)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[int64][]driver.LedgerEntry)
+	for rows.Next() {
+		var e driver.LedgerEntry
+		if err := rows.Scan(&e.PaymentID, &e.Amount, &e.Currency); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		entries[e.PaymentID] = append(entries[e.PaymentID], e)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	rows, err = sq.db.conn.QueryContext(ctx, `SELECT id FROM payments ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if driver.CheckBalanced(entries[id]) != nil {
+			res = append(res, id)
+		}
+	}
+	return res, rows.Err()
+}
+
+// Get - get payment by ID and load in object
+// Important! When any fields will be added into table, then need to add one in to SELECT query
+func (sq *SqlitePayment) Get(ctx context.Context, id int64) error {
+	return sq.scan(sq.db.conn.QueryRowContext(ctx, `SELECT `+sqlitePaymentFields+` FROM payments WHERE id = ?`, id))
+}
+
+// List - return list of payments for account with accountID which meet conditions of filter
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (sq SqlitePayment) List(ctx context.Context, accountID int64, filter driver.PaymentFilter, after, offset, limit int64) ([]interface{}, error) {
+	// dates are compared as julian days, because they are stored as text with time zone,
+	// amounts are compared as text padded to the same width
+	fromID, toID, kind, notKind := filter.Conditions(accountID)
+	return sq.list(ctx, `
+		SELECT `+sqlitePaymentFields+`
+		FROM payments
+		WHERE
+			("from" = ?1 OR "to" = ?1)
+			AND (?2 IS NULL OR julianday(date) >= julianday(?2))
+			AND (?3 IS NULL OR julianday(date) < julianday(?3))
+			AND (?4 IS NULL OR printf('`+sqliteAmountKeyFormat+`', CASE WHEN "to" = ?1 THEN to_amount ELSE amount END) >= ?4)
+			AND (?5 IS NULL OR printf('`+sqliteAmountKeyFormat+`', CASE WHEN "to" = ?1 THEN to_amount ELSE amount END) <= ?5)
+			AND (?6 IS NULL OR "from" = ?6)
+			AND (?7 IS NULL OR "to" = ?7)
+			AND (?8 IS NULL OR kind = ?8)
+			AND (?9 IS NULL OR kind <> ?9)
+			AND (?10 IS NULL OR "from" = ?10 OR "to" = ?10)
+			AND (?11 IS NULL OR id < ?11)
+		ORDER BY id DESC
+		LIMIT ?12 OFFSET ?13`,
+		accountID, driver.NullTime(filter.Since), driver.NullTime(filter.Until),
+		sqliteAmountKey(filter.MinAmount), sqliteAmountKey(filter.MaxAmount),
+		driver.NullID(fromID), driver.NullID(toID), driver.NullString(kind), driver.NullString(notKind), driver.NullID(filter.CounterpartyID),
+		driver.NullID(after), limit, offset)
+}
+
+// ListAll - return list of payments
+// payments listed ordering by id descending
+// if after > 0, only payments with id less than after are listed
+// offset and limit are using for set slice bound of list
+// if limit = -1, then no limit
+func (sq SqlitePayment) ListAll(ctx context.Context, after, offset, limit int64) ([]interface{}, error) {
+	return sq.list(ctx, `
+		SELECT `+sqlitePaymentFields+`
+		FROM payments
+		WHERE ? <= 0 OR id < ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, after, after, limit, offset)
+}
+
+// list - return payments selected by query, negative LIMIT of SQLite means no limit
+func (sq SqlitePayment) list(ctx context.Context, query string, args ...interface{}) ([]interface{}, error) {
+	rows, err := sq.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []interface{}
+	for rows.Next() {
+		p := &SqlitePayment{db: sq.db}
+		if err := p.scan(rows); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// scan payment fields selected with sqlitePaymentFields
+func (sq *SqlitePayment) scan(row sqliteScanner) error {
+	var tags []byte
+	if err := row.Scan(&sq.id, &sq.kind, &sq.fromID, &sq.toID, &sq.amount, &sq.toAmount, &sq.rate, &sq.rateDate,
+		&sq.toBalance, &sq.counterparty, &sq.reversalOf, &sq.fee, &sq.date,
+		&sq.meta.Description, &sq.meta.ExternalRef, &tags); err != nil {
+		return err
+	}
+	var err error
+	sq.meta.Tags, err = driver.DecodeTags(tags)
+	return err
+}
+
+// FindByExternalRef - find payment created by account with accountID with external reference and load in object
+// returns ErrPaymentNotFound if payment not exists
+func (sq *SqlitePayment) FindByExternalRef(ctx context.Context, accountID int64, ref string) error {
+	id, err := sqliteFindByExternalRef(ctx, sq.db.conn, accountID, ref)
+	if err != nil {
+		return err
+	}
+	return sq.Get(ctx, id)
+}
+
+// Reverse - create payment which compensates loaded payment, money goes back from recipient to payer
+// amount is in payer currency of original payment and toAmount is in its recipient currency
+// if amount is zero the whole not reversed rest of payment is returned
+// total amount of reversals can't exceed amount of payment. When reversal closes the rest of payment,
+// toAmount is set to the rest of recipient amount, so rounding of partial reversals never leaves a remainder
+// available balance of recipient is checked unless force is set, balances of system accounts are never checked
+// closed accounts can't take part in reversal, frozen accounts only if force is set
+func (sq *SqlitePayment) Reverse(ctx context.Context, amount, toAmount money.Amount, force bool) (int64, error) {
+	var paymentID int64
+	err := sq.db.tx(ctx, func(tx *sql.Tx) (err error) {
+		paymentID, err = sq.reverse(ctx, tx, amount, toAmount, force)
+		return err
+	})
+	return paymentID, err
+}
+
+// reverse - execute reversal in transaction tx
+func (sq *SqlitePayment) reverse(ctx context.Context, tx *sql.Tx, amount, toAmount money.Amount, force bool) (int64, error) {
+	var orig SqlitePayment
+	if err := orig.scan(tx.QueryRowContext(ctx, `SELECT `+sqlitePaymentFields+` FROM payments WHERE id = ?`, sq.id)); err != nil {
+		return 0, err
+	}
+	if orig.kind == driver.PaymentKindReversal {
+		return 0, driver.ErrReversalNotAllowed
+	}
+
+	// not reversed rest of payment
+	rest, restTo := orig.amount, orig.toAmount
+	rows, err := tx.QueryContext(ctx, `SELECT amount, to_amount FROM payments WHERE reversal_of = ?`, orig.id)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var a, to money.Amount
+		if err := rows.Scan(&a, &to); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		if rest, err = rest.Sub(to); err == nil {
+			restTo, err = restTo.Sub(a)
+		}
+		if err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	switch {
+	case rest.Sign() <= 0 || amount.Cmp(rest) > 0:
+		return 0, driver.ErrReversalExceedsAmount
+	case amount.IsZero() || amount.Cmp(rest) == 0:
+		amount, toAmount = rest, restTo
+	case toAmount.Cmp(restTo) > 0:
+		toAmount = restTo
+	}
+
+	// money goes back from recipient of original payment
+	payer, err := sqliteGetAccount(ctx, tx, orig.toID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, driver.ErrAccountNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	recipient, err := sqliteGetAccount(ctx, tx, orig.fromID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, driver.ErrAccountNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	for _, a := range []struct {
+		status    string
+		recipient bool
+	}{{payer.status, false}, {recipient.status, true}} {
+		if a.status == driver.AccountStatusClosed || !force {
+			if err := driver.AccountStatusError(a.status, a.recipient); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if !payer.system && !force {
+		held, err := sqliteHeldAmount(ctx, tx, payer.id)
+		if err != nil {
+			return 0, err
+		}
+		if err = driver.CheckAvailable(payer.balance, held, payer.creditLimit, toAmount); err != nil {
+			return 0, err
+		}
+	}
+	payerBalance, err := payer.balance.Sub(toAmount)
+	if err != nil {
+		return 0, err
+	}
+	recipientBalance, err := recipient.balance.Add(amount)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := sqlitePaymentEntries(ctx, tx, orig.id)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, driver.ErrReversalNotAllowed
+	}
+	// fee of original transfer is not returned
+	var feeID int64
+	if !orig.fee.IsZero() {
+		if feeID, err = sqliteSystemAccountID(ctx, tx, driver.SystemAccountFee, recipient.currency); err != nil {
+			return 0, err
+		}
+	}
+
+	p := SqlitePayment{
+		kind:       driver.PaymentKindReversal,
+		fromID:     payer.id,
+		toID:       recipient.id,
+		amount:     toAmount,
+		toAmount:   amount,
+		rate:       money.New(1, 0),
+		reversalOf: orig.id,
+	}
+	if !recipient.system {
+		p.toBalance = recipientBalance
+	}
+	paymentID, err := sqliteAddPayment(ctx, tx, p, driver.ReversalEntries(entries, recipient.currency, amount, toAmount, feeID), 0, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// balances of system accounts are not stored
+	if !payer.system {
+		if err := sqliteSetBalance(ctx, tx, payer.id, payerBalance); err != nil {
+			return 0, err
+		}
+	}
+	if !recipient.system {
+		if err := sqliteSetBalance(ctx, tx, recipient.id, recipientBalance); err != nil {
+			return 0, err
+		}
+	}
+	return paymentID, nil
+}
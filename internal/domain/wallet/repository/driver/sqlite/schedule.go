@@ -0,0 +1,222 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+//
+// Driver for scheduled transfers for work with SQLite database
+// times of scheduled transfers are stored in UTC, so planned time of execution can be compared with stored one
+
+type SqliteSchedule struct {
+	// db - connection to database
+	db *SQLite
+}
+
+// list of scheduled transfer fields used in SELECT queries
+const sqliteScheduleFields = `id, account_id, to_account_id, amount, recurrence, day, next_run_at, status, date`
+
+// sqliteScanSchedule - read scheduled transfer from row
+func sqliteScanSchedule(row sqliteScanner) (driver.ScheduledTransfer, error) {
+	var t driver.ScheduledTransfer
+	err := row.Scan(&t.ID, &t.AccountID, &t.ToID, &t.Amount, &t.Recurrence, &t.Day, &t.NextRunAt, &t.Status, &t.Date)
+	return t, err
+}
+
+// querySchedules - read scheduled transfers selected by query
+func (sq *SqliteSchedule) querySchedules(ctx context.Context, q sqliteQuerier, query string, args ...interface{}) ([]driver.ScheduledTransfer, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.ScheduledTransfer
+	for rows.Next() {
+		t, err := sqliteScanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
+
+// Create - save new active scheduled transfer
+// returning id of scheduled transfer
+func (sq *SqliteSchedule) Create(ctx context.Context, t driver.ScheduledTransfer) (int64, error) {
+	res, err := sq.db.conn.ExecContext(ctx, `
+		INSERT INTO scheduled_transfers (account_id, to_account_id, amount, recurrence, day, next_run_at, status, date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.AccountID, t.ToID, driver.RoundAmount(t.Amount, driver.AmountScale), t.Recurrence, t.Day, t.NextRunAt.UTC(),
+		driver.ScheduleStatusActive, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Get - return scheduled transfer of account with accountID by id
+func (sq *SqliteSchedule) Get(ctx context.Context, accountID, id int64) (driver.ScheduledTransfer, error) {
+	return sq.get(ctx, sq.db.conn, accountID, id)
+}
+
+func (sq *SqliteSchedule) get(ctx context.Context, q sqliteQuerier, accountID, id int64) (driver.ScheduledTransfer, error) {
+	row := q.QueryRowContext(ctx, `SELECT `+sqliteScheduleFields+`
+		FROM scheduled_transfers
+		WHERE id = ? AND account_id = ?`, id, accountID)
+	t, err := sqliteScanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return driver.ScheduledTransfer{}, driver.ErrScheduleNotFound
+	}
+	return t, err
+}
+
+// List - return scheduled transfers of account with accountID
+// scheduled transfers listed ordering by id
+// if limit = -1, then no limit
+func (sq *SqliteSchedule) List(ctx context.Context, accountID, offset, limit int64) ([]driver.ScheduledTransfer, error) {
+	// negative LIMIT of SQLite means no limit
+	return sq.querySchedules(ctx, sq.db.conn, `SELECT `+sqliteScheduleFields+`
+		FROM scheduled_transfers
+		WHERE account_id = ?
+		ORDER BY id LIMIT ? OFFSET ?`, accountID, limit, offset)
+}
+
+// Update - save amount, recurrence, day and next run time of active scheduled transfer
+func (sq *SqliteSchedule) Update(ctx context.Context, t driver.ScheduledTransfer) error {
+	return sq.db.tx(ctx, func(tx *sql.Tx) error {
+		cur, err := sq.get(ctx, tx, t.AccountID, t.ID)
+		if err != nil {
+			return err
+		}
+		if cur.Status != driver.ScheduleStatusActive {
+			return driver.ErrScheduleNotActive
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE scheduled_transfers SET amount = ?, recurrence = ?, day = ?, next_run_at = ? WHERE id = ?`,
+			driver.RoundAmount(t.Amount, driver.AmountScale), t.Recurrence, t.Day, t.NextRunAt.UTC(), t.ID)
+		return err
+	})
+}
+
+// Cancel - set status "cancelled" for active scheduled transfer of account with accountID
+func (sq *SqliteSchedule) Cancel(ctx context.Context, accountID, id int64) error {
+	return sq.db.tx(ctx, func(tx *sql.Tx) error {
+		cur, err := sq.get(ctx, tx, accountID, id)
+		if err != nil {
+			return err
+		}
+		if cur.Status != driver.ScheduleStatusActive {
+			return driver.ErrScheduleNotActive
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE scheduled_transfers SET status = ? WHERE id = ?`, driver.ScheduleStatusCancelled, id)
+		return err
+	})
+}
+
+// Due - return active scheduled transfers which next run time is not after now
+// scheduled transfers listed ordering by next run time
+func (sq *SqliteSchedule) Due(ctx context.Context, now time.Time, limit int64) ([]driver.ScheduledTransfer, error) {
+	active, err := sq.querySchedules(ctx, sq.db.conn, `SELECT `+sqliteScheduleFields+`
+		FROM scheduled_transfers
+		WHERE status = ?`, driver.ScheduleStatusActive)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []driver.ScheduledTransfer
+	for _, t := range active {
+		if !t.NextRunAt.After(now) {
+			res = append(res, t)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].NextRunAt.Before(res[j].NextRunAt) })
+	if limit >= 0 && int64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// Complete - record execution of scheduled transfer planned at e.RunAt and plan the next execution at next
+// if next is zero transfer becomes completed. Execution is recorded only once for every planned time,
+// ErrScheduleExecuted is returned if it was already recorded. When transfer was changed or cancelled
+// after it was taken for execution, execution is recorded but the next execution is not changed
+func (sq *SqliteSchedule) Complete(ctx context.Context, e driver.ScheduleExecution, next time.Time) error {
+	return sq.db.tx(ctx, func(tx *sql.Tx) error {
+		var (
+			status    string
+			nextRunAt time.Time
+			executed  int
+		)
+		row := tx.QueryRowContext(ctx, `SELECT status, next_run_at FROM scheduled_transfers WHERE id = ?`, e.ScheduleID)
+		if err := row.Scan(&status, &nextRunAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return driver.ErrScheduleNotFound
+			}
+			return err
+		}
+		row = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM scheduled_transfer_executions WHERE schedule_id = ? AND run_at = ?`,
+			e.ScheduleID, e.RunAt.UTC())
+		if err := row.Scan(&executed); err != nil {
+			return err
+		}
+		if executed > 0 {
+			return driver.ErrScheduleExecuted
+		}
+
+		var paymentID interface{}
+		if e.PaymentID != 0 {
+			paymentID = e.PaymentID
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO scheduled_transfer_executions (schedule_id, run_at, payment_id, error, date)
+			VALUES (?, ?, ?, ?, ?)`, e.ScheduleID, e.RunAt.UTC(), paymentID, e.Error, time.Now().UTC()); err != nil {
+			return err
+		}
+		if status != driver.ScheduleStatusActive || !nextRunAt.Equal(e.RunAt) {
+			return nil
+		}
+		var err error
+		if next.IsZero() {
+			_, err = tx.ExecContext(ctx, `UPDATE scheduled_transfers SET status = ? WHERE id = ?`,
+				driver.ScheduleStatusCompleted, e.ScheduleID)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE scheduled_transfers SET next_run_at = ? WHERE id = ?`,
+				next.UTC(), e.ScheduleID)
+		}
+		return err
+	})
+}
+
+// Executions - return results of executions of scheduled transfer with scheduleID
+// executions listed ordering by id
+// if limit = -1, then no limit
+func (sq *SqliteSchedule) Executions(ctx context.Context, scheduleID, offset, limit int64) ([]driver.ScheduleExecution, error) {
+	rows, err := sq.db.conn.QueryContext(ctx, `
+		SELECT id, schedule_id, run_at, COALESCE(payment_id, 0), error, date
+		FROM scheduled_transfer_executions
+		WHERE schedule_id = ?
+		ORDER BY id LIMIT ? OFFSET ?`, scheduleID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.ScheduleExecution
+	for rows.Next() {
+		var e driver.ScheduleExecution
+		if err := rows.Scan(&e.ID, &e.ScheduleID, &e.RunAt, &e.PaymentID, &e.Error, &e.Date); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
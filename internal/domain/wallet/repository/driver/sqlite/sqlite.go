@@ -0,0 +1,379 @@
+// sqlite driver for data manipulation for repository entities
+
+// database is one local file, driver is intended for single node installations and local development
+// DSN of Config is path to database file, ":memory:" for database in memory
+
+// SQLite has no numeric type with fixed scale, so amounts are stored as text rounded to scale of PostgreSQL columns
+// and sums of amounts are calculated by driver, not by database
+// transactions are started with write lock of database (BEGIN IMMEDIATE) and pool has one connection,
+// so transfers are serialized the same way as row locks serialize them in PostgreSQL driver
+
+// Package sqlite registers SQLite storage driver with name "sqlite"
+// import it for side effect to make driver available for repository.Open
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+	logger "github.com/sirupsen/logrus"
+)
+
+// sqliteDefaultPath - database file used when DSN is not set in Config
+const sqliteDefaultPath = "wallet.db"
+
+// SQLite - connection to SQLite database shared by account and payment repositories
+type SQLite struct {
+	pool *sql.DB
+	// conn - executor of queries of driver, it is pool or transaction of unit of work
+	conn sqliteQuerier
+	// unit - transaction of unit of work, nil outside of unit
+	unit *sql.Tx
+}
+
+// sqliteQuerier - queries common for connection and transaction
+type sqliteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// sqliteScanner - one row of query result, *sql.Row or *sql.Rows
+type sqliteScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func init() {
+	repository.Register("sqlite", Open)
+}
+
+// Open - open SQLite database file, repositories share one connection
+func Open(cfg repository.Config) (repository.Driver, error) {
+	db, err := NewSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLite - open database file
+// schema of database is created and upgraded by migrations, see MigrateUp
+// MaxConns of Config is ignored, driver always uses one connection
+func NewSQLite(cfg driver.Config) (*SQLite, error) {
+	path := cfg.DSN
+	if path == "" {
+		path = sqliteDefaultPath
+	}
+
+	logger.Info("Wallet sqlite driver. Opening database ", path)
+	conn, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		logger.Error("[Wallet][NewSQLite]Unable to open database: ", err)
+		return nil, err
+	}
+	// one connection keeps transactions serialized and database ":memory:" shared between queries
+	conn.SetMaxOpenConns(1)
+	return &SQLite{pool: conn, conn: conn}, nil
+}
+
+// Close - close database
+func (db *SQLite) Close() error {
+	return db.pool.Close()
+}
+
+// Account - create account repository using connection db
+func (db *SQLite) Account() repository.Account {
+	return &SqliteAccount{db: db}
+}
+
+// Payment - create payment repository using connection db
+func (db *SQLite) Payment() repository.Payment {
+	return &SqlitePayment{db: db}
+}
+
+// Schedule - create repository of scheduled transfers using connection db
+func (db *SQLite) Schedule() repository.Schedule {
+	return &SqliteSchedule{db: db}
+}
+
+// tx - execute fn in transaction
+// transaction is committed if fn returns nil, else it is rolled back and error of fn returned
+// in unit of work fn is executed in transaction of unit under savepoint
+func (db *SQLite) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if db.unit != nil {
+		return db.savepoint(ctx, fn)
+	}
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqliteAccountRow struct {
+	id       int64
+	name     string
+	balance  money.Amount
+	currency string
+	system   bool
+	status   string
+	// creditLimit - overdraft limit, balance can go down to -creditLimit
+	creditLimit money.Amount
+}
+
+// sqliteAmountKeyFormat - format of amount padded with spaces to width of numeric(22,4) column of PostgreSQL
+// amounts are stored as text with the same scale, so padded texts of non-negative amounts are ordered as amounts
+const sqliteAmountKeyFormat = "%23s"
+
+// sqliteAmountKey - value of query parameter compared with amount formatted by sqliteAmountKeyFormat,
+// NULL for zero amount
+func sqliteAmountKey(a money.Amount) interface{} {
+	if a.IsZero() {
+		return nil
+	}
+	return fmt.Sprintf(sqliteAmountKeyFormat, driver.RoundAmount(a, driver.AmountScale))
+}
+
+// sqliteGetAccount - read account row with id, returns sql.ErrNoRows if account not exists
+func sqliteGetAccount(ctx context.Context, q sqliteQuerier, id int64) (*sqliteAccountRow, error) {
+	a := &sqliteAccountRow{}
+	row := q.QueryRowContext(ctx,
+		`SELECT id, name, balance, currency, system, status, credit_limit FROM accounts WHERE id = ?`, id)
+	if err := row.Scan(&a.id, &a.name, &a.balance, &a.currency, &a.system, &a.status, &a.creditLimit); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// sqliteSetBalance - save balance of account
+func sqliteSetBalance(ctx context.Context, tx *sql.Tx, id int64, balance money.Amount) error {
+	_, err := tx.ExecContext(ctx, `UPDATE accounts SET balance = ? WHERE id = ?`, driver.RoundAmount(balance, driver.AmountScale), id)
+	return err
+}
+
+// sqliteHeldAmount - sum of active holds of account
+func sqliteHeldAmount(ctx context.Context, q sqliteQuerier, accountID int64) (money.Amount, error) {
+	rows, err := q.QueryContext(ctx, `SELECT amount, expires_at FROM holds WHERE account_id = ? AND status = ?`,
+		accountID, driver.HoldStatusActive)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	defer rows.Close()
+
+	var sum money.Amount
+	now := time.Now()
+	for rows.Next() {
+		var (
+			amount    money.Amount
+			expiresAt time.Time
+		)
+		if err := rows.Scan(&amount, &expiresAt); err != nil {
+			return money.Amount{}, err
+		}
+		if expiresAt.After(now) {
+			if sum, err = sum.Add(amount); err != nil {
+				return money.Amount{}, err
+			}
+		}
+	}
+	return sum, rows.Err()
+}
+
+// sqliteLimits - return spending limits of account with id, zero limits if they are not set
+func sqliteLimits(ctx context.Context, q sqliteQuerier, accountID int64) (driver.Limits, error) {
+	var l driver.Limits
+	err := q.QueryRowContext(ctx, `
+		SELECT max_amount, daily_amount, daily_count FROM account_limits WHERE account_id = ?`, accountID,
+	).Scan(&l.MaxAmount, &l.DailyAmount, &l.DailyCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return driver.Limits{}, nil
+	}
+	return l, err
+}
+
+// sqliteSpending - return total amount and count of outgoing transfers and withdrawals of account with id since time
+// amounts are stored as text, so they are summed by driver
+func sqliteSpending(ctx context.Context, q sqliteQuerier, accountID int64, since time.Time) (driver.Spending, error) {
+	rows, err := q.QueryContext(ctx, `SELECT amount, date FROM payments WHERE "from" = ? AND kind IN (?, ?)`,
+		accountID, driver.PaymentKindTransfer, driver.PaymentKindWithdrawal)
+	if err != nil {
+		return driver.Spending{}, err
+	}
+	defer rows.Close()
+
+	var s driver.Spending
+	for rows.Next() {
+		var (
+			amount money.Amount
+			date   time.Time
+		)
+		if err := rows.Scan(&amount, &date); err != nil {
+			return driver.Spending{}, err
+		}
+		if !date.Before(since) {
+			if s.Amount, err = s.Amount.Add(amount); err != nil {
+				return driver.Spending{}, err
+			}
+			s.Count++
+		}
+	}
+	return s, rows.Err()
+}
+
+// sqliteCheckLimits - check in transaction q that payment of amount from account with id doesn't exceed its limits
+// transactions are executed one after another, so concurrent payments of account see spending of each other
+func sqliteCheckLimits(ctx context.Context, q sqliteQuerier, accountID int64, amount money.Amount) error {
+	l, err := sqliteLimits(ctx, q, accountID)
+	if err != nil {
+		return err
+	}
+	var s driver.Spending
+	if l.Daily() {
+		if s, err = sqliteSpending(ctx, q, accountID, driver.LimitsDay(time.Now())); err != nil {
+			return err
+		}
+	}
+	return l.Check(s, amount)
+}
+
+// sqliteSystemAccountID - return id of system account of kind for currency, account is created if it not exists
+func sqliteSystemAccountID(ctx context.Context, tx *sql.Tx, kind, currency string) (int64, error) {
+	name := driver.SystemAccountName(kind, currency)
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM accounts WHERE name = ?`, name).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO accounts (name, currency, system) VALUES (?, ?, 1)`, name, currency)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// sqliteFindIdempotent - search payment created by account with idempotency key
+// returns the same results as pgFindIdempotentPayment
+func sqliteFindIdempotent(ctx context.Context, q sqliteQuerier, accountID int64, idem *driver.Idempotency) (int64, error) {
+	if idem == nil {
+		return 0, nil
+	}
+	var (
+		id   int64
+		hash string
+	)
+	row := q.QueryRowContext(ctx, `
+		SELECT id, COALESCE(idempotency_hash, '')
+		FROM payments
+		WHERE idempotency_account = ? AND idempotency_key = ?`, accountID, idem.Key)
+	if err := row.Scan(&id, &hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if hash != idem.Hash {
+		return id, driver.ErrIdempotencyConflict
+	}
+	return id, driver.ErrIdempotencyReplay
+}
+
+// sqliteAddPayment - save payment with its ledger entries
+// entries are checked before anything is saved, so unbalanced transaction is never written
+// external reference of payment metadata belongs to account with accountID,
+// returns ErrExternalRefDuplicate if the account already has payment with this reference
+func sqliteAddPayment(ctx context.Context, tx *sql.Tx,
+	p SqlitePayment, entries []driver.LedgerEntry, accountID int64, idem *driver.Idempotency) (int64, error) {
+	if err := driver.CheckBalanced(entries); err != nil {
+		return 0, err
+	}
+	if p.meta.ExternalRef != "" {
+		if _, err := sqliteFindByExternalRef(ctx, tx, accountID, p.meta.ExternalRef); err == nil {
+			return 0, driver.ErrExternalRefDuplicate
+		} else if err != driver.ErrPaymentNotFound {
+			return 0, err
+		}
+	}
+	description, ref, refAccount, tags, err := p.meta.Values(accountID)
+	if err != nil {
+		return 0, err
+	}
+	var idemAccount, idemKey, idemHash interface{}
+	if idem != nil {
+		idemAccount, idemKey, idemHash = accountID, idem.Key, idem.Hash
+	}
+	var reversalOf interface{}
+	if p.reversalOf != 0 {
+		reversalOf = p.reversalOf
+	}
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO payments (kind, "from", "to", amount, to_amount, rate, rate_date, to_balance, counterparty,
+			reversal_of, fee, idempotency_account, idempotency_key, idempotency_hash,
+			description, external_ref, external_ref_account, tags, date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.kind, p.fromID, p.toID, driver.RoundAmount(p.amount, driver.AmountScale), driver.RoundAmount(p.toAmount, driver.AmountScale),
+		driver.RoundAmount(p.rate, driver.RateScale), p.rateDate, driver.RoundAmount(p.toBalance, driver.AmountScale), p.counterparty,
+		reversalOf, driver.RoundAmount(p.fee, driver.AmountScale), idemAccount, idemKey, idemHash,
+		description, ref, refAccount, tags, now)
+	if err != nil {
+		return 0, err
+	}
+	paymentID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO ledger_entries (payment_id, account_id, amount, currency, date) VALUES (?, ?, ?, ?, ?)`,
+			paymentID, e.AccountID, driver.RoundAmount(e.Amount, driver.AmountScale), e.Currency, now); err != nil {
+			return 0, err
+		}
+	}
+	return paymentID, nil
+}
+
+// sqliteFindByExternalRef - id of payment created by account with accountID with external reference ref
+// returns ErrPaymentNotFound if payment not exists
+func sqliteFindByExternalRef(ctx context.Context, q sqliteQuerier, accountID int64, ref string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, `
+		SELECT id FROM payments WHERE external_ref_account = ? AND external_ref = ? LIMIT 1`, accountID, ref).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, driver.ErrPaymentNotFound
+	}
+	return id, err
+}
+
+// sqlitePaymentEntries - ledger entries of payment ordered by id
+func sqlitePaymentEntries(ctx context.Context, q sqliteQuerier, paymentID int64) ([]driver.LedgerEntry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, payment_id, account_id, amount, currency, date
+		FROM ledger_entries
+		WHERE payment_id = ?
+		ORDER BY id`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []driver.LedgerEntry
+	for rows.Next() {
+		var e driver.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.PaymentID, &e.AccountID, &e.Amount, &e.Currency, &e.Date); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
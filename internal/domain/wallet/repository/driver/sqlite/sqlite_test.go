@@ -0,0 +1,277 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_Sqlite(t *testing.T) {
+	db, err := NewSQLite(driver.Config{DSN: filepath.Join(t.TempDir(), "wallet.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := db.Account(), db.Account()
+	if err := from.Create(ctx, "sqlitewallet1", "usd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := to.Create(ctx, "sqlitewallet2", "usd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := from.Deposit(ctx, money.MustParse("10"), driver.Metadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("concurrent transfers", func(t *testing.T) {
+		// 20 transfers of 1 from balance 10, only 10 of them have money
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			noMoney int
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a := db.Account()
+				if err := a.Get(ctx, from.ID()); err != nil {
+					t.Error(err)
+					return
+				}
+				_, err := a.Transfer(ctx, to.ID(), money.MustParse("1"), nil, money.Amount{}, driver.Metadata{}, nil)
+				switch err {
+				case nil:
+				case driver.ErrNoMoney:
+					mu.Lock()
+					noMoney++
+					mu.Unlock()
+				default:
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if noMoney != 10 {
+			t.Errorf("transfers without money = %d, want 10", noMoney)
+		}
+		for _, tt := range []struct {
+			a    repository.Account
+			want string
+		}{{from, "0.0000"}, {to, "10.0000"}} {
+			if err := tt.a.Get(ctx, tt.a.ID()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.a.Balance().String() != tt.want {
+				t.Errorf("balance of %s = %s, want %s", tt.a.Name(), tt.a.Balance(), tt.want)
+			}
+			ledger, err := tt.a.LedgerBalance(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ledger.Cmp(tt.a.Balance()) != 0 {
+				t.Errorf("ledger balance of %s = %s, want %s", tt.a.Name(), ledger, tt.a.Balance())
+			}
+		}
+		if ids, err := db.Payment().Unbalanced(ctx); err != nil || len(ids) != 0 {
+			t.Errorf("Unbalanced() = %v, %v", ids, err)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		p := db.Payment()
+		all, err := p.List(ctx, to.ID(), driver.PaymentFilter{}, 0, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 10 {
+			t.Fatalf("len(List(0, -1)) = %d, want 10", len(all))
+		}
+		page, err := p.List(ctx, to.ID(), driver.PaymentFilter{}, 0, 8, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].(*SqlitePayment).ID() != all[8].(*SqlitePayment).ID() {
+			t.Errorf("List(8, 5) = %v", page)
+		}
+		if page, _ := p.ListAll(ctx, 0, 0, 3); len(page) != 3 || page[0].(*SqlitePayment).ID() <= page[1].(*SqlitePayment).ID() {
+			t.Errorf("ListAll(0, 3) is not ordered by id descending: %v", page)
+		}
+
+		// keyset page begins after the last payment of previous page
+		after := all[4].(*SqlitePayment).ID()
+		for _, f := range []driver.PaymentFilter{{}, {Direction: driver.PaymentDirectionIncoming}} {
+			page, err := p.List(ctx, to.ID(), f, after, 0, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) != 3 || page[0].(*SqlitePayment).ID() != all[5].(*SqlitePayment).ID() {
+				t.Errorf("List(%+v, after %d) = %v", f, after, page)
+			}
+		}
+		if page, _ := p.ListAll(ctx, after, 0, -1); len(page) == 0 || page[0].(*SqlitePayment).ID() >= after {
+			t.Errorf("ListAll(after %d) = %v", after, page)
+		}
+		if ids, err := from.List(ctx, from.ID(), 0, -1); err != nil || len(ids) == 0 || ids[0] <= from.ID() {
+			t.Errorf("accounts List(after %d) = %v, %v", from.ID(), ids, err)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		a, b := db.Account(), db.Account()
+		if err := a.Create(ctx, "sqlitewallet3", "usd"); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Create(ctx, "sqlitewallet4", "usd"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Deposit(ctx, money.MustParse("100"), driver.Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		// amounts 9 and 10 are ordered differently as numbers and as text
+		for _, amount := range []string{"9", "10", "0.5"} {
+			if _, err := a.Transfer(ctx, b.ID(), money.MustParse(amount), nil, money.Amount{}, driver.Metadata{}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		p := db.Payment()
+		now := time.Now()
+		tests := []struct {
+			account repository.Account
+			filter  driver.PaymentFilter
+			want    int
+		}{
+			{b, driver.PaymentFilter{MinAmount: money.MustParse("9")}, 2},
+			{b, driver.PaymentFilter{MaxAmount: money.MustParse("9")}, 2},
+			{b, driver.PaymentFilter{MinAmount: money.MustParse("0.5"), MaxAmount: money.MustParse("0.5")}, 1},
+			{b, driver.PaymentFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, 3},
+			{b, driver.PaymentFilter{Since: now.Add(time.Hour)}, 0},
+			{b, driver.PaymentFilter{Until: now.Add(-time.Hour)}, 0},
+			{a, driver.PaymentFilter{Direction: driver.PaymentDirectionOutgoing}, 3},
+			{a, driver.PaymentFilter{Direction: driver.PaymentDirectionDeposit}, 1},
+			{a, driver.PaymentFilter{Direction: driver.PaymentDirectionIncoming}, 0},
+			{a, driver.PaymentFilter{CounterpartyID: b.ID(), MinAmount: money.MustParse("1")}, 2},
+		}
+		for _, tt := range tests {
+			lst, err := p.List(ctx, tt.account.ID(), tt.filter, 0, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lst) != tt.want {
+				t.Errorf("List(%s, %+v) = %d payments, want %d", tt.account.Name(), tt.filter, len(lst), tt.want)
+			}
+			// query returns the same payments as filter checked in application
+			all, err := p.List(ctx, tt.account.ID(), driver.PaymentFilter{}, 0, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var matched []int64
+			for _, v := range all {
+				if tt.filter.Match(tt.account.ID(), v.(*SqlitePayment)) {
+					matched = append(matched, v.(*SqlitePayment).ID())
+				}
+			}
+			for i, v := range lst {
+				if i >= len(matched) || v.(*SqlitePayment).ID() != matched[i] {
+					t.Errorf("List(%s, %+v) = %v, want payments %v", tt.account.Name(), tt.filter, lst, matched)
+					break
+				}
+			}
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		if l, err := from.Limits(ctx); err != nil || l != (driver.Limits{}) {
+			t.Errorf("Limits() of account without limits = %+v, %v", l, err)
+		}
+		want := driver.Limits{MaxAmount: money.MustParse("5.0000"), DailyAmount: money.MustParse("20.0000"), DailyCount: 3}
+		for i := 0; i < 2; i++ {
+			// limits are replaced by the next call
+			if err := from.SetLimits(ctx, want); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if l, err := from.Limits(ctx); err != nil || l != want {
+			t.Errorf("Limits() = %+v, %v, want %+v", l, err, want)
+		}
+
+		s, err := from.Spending(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Count != 10 || s.Amount.Cmp(money.MustParse("10")) != 0 {
+			t.Errorf("Spending() = %+v, want 10 transfers of 10", s)
+		}
+		if s, _ := from.Spending(ctx, time.Now().Add(time.Hour)); s.Count != 0 {
+			t.Errorf("Spending() since future = %+v", s)
+		}
+
+		// payments are checked against limits in transaction
+		if _, err := from.Deposit(ctx, money.MustParse("7"), driver.Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := from.Transfer(ctx, to.ID(), money.MustParse("6"), nil, money.Amount{}, driver.Metadata{}, nil); err != driver.ErrTransferLimitExceeded {
+			t.Errorf("Transfer() over max amount error = %v, want %v", err, driver.ErrTransferLimitExceeded)
+		}
+		if _, err := from.Withdraw(ctx, money.MustParse("1"), "card", nil); err != driver.ErrDailyCountExceeded {
+			t.Errorf("Withdraw() over daily count error = %v, want %v", err, driver.ErrDailyCountExceeded)
+		}
+		if err := from.SetLimits(ctx, driver.Limits{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := from.Withdraw(ctx, money.MustParse("7"), "card", nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("credit limit", func(t *testing.T) {
+		// balance of account is zero after concurrent transfers
+		if err := from.SetCreditLimit(ctx, money.MustParse("2.5")); err != nil {
+			t.Fatal(err)
+		}
+		if from.CreditLimit().String() != "2.5000" {
+			t.Errorf("CreditLimit() = %s, want 2.5000", from.CreditLimit())
+		}
+		if _, err := from.Transfer(ctx, to.ID(), money.MustParse("2"), nil, money.Amount{}, driver.Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if from.Balance().String() != "-2.0000" {
+			t.Errorf("balance = %s, want -2.0000", from.Balance())
+		}
+		if _, err := from.Withdraw(ctx, money.MustParse("1"), "card", nil); err != driver.ErrNoMoney {
+			t.Errorf("Withdraw() over credit limit error = %v, want %v", err, driver.ErrNoMoney)
+		}
+		// pay off the debt
+		if _, err := to.Transfer(ctx, from.ID(), money.MustParse("2"), nil, money.Amount{}, driver.Metadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := from.Deposit(cctx, money.MustParse("1"), driver.Metadata{}, nil); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Deposit() with cancelled context error = %v, want %v", err, context.Canceled)
+		}
+		if err := from.Get(ctx, from.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if from.Balance().String() != "0.0000" {
+			t.Errorf("balance of %s = %s, deposit with cancelled context is saved", from.Name(), from.Balance())
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+)
+
+//
+// Unit of work of SQLite driver
+// repositories of unit execute all queries in one transaction, transactions started by methods of repositories
+// become savepoints of it. Driver has one connection, so other operations wait until unit is finished
+
+// SQLiteUnit - unit of work of SQLite driver
+type SQLiteUnit struct {
+	db *SQLite
+	tx *sql.Tx
+	// done - unit is committed or rolled back
+	done bool
+}
+
+// Begin - start unit of work, its repositories execute queries in one transaction
+// unit must be finished by Commit or Rollback
+func (db *SQLite) Begin(ctx context.Context) (repository.UnitOfWork, error) {
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteUnit{
+		db: &SQLite{pool: db.pool, conn: tx, unit: tx},
+		tx: tx,
+	}, nil
+}
+
+// Account - create account repository of unit
+func (u *SQLiteUnit) Account() repository.Account {
+	return u.db.Account()
+}
+
+// Payment - create payment repository of unit
+func (u *SQLiteUnit) Payment() repository.Payment {
+	return u.db.Payment()
+}
+
+// Schedule - create repository of scheduled transfers of unit
+func (u *SQLiteUnit) Schedule() repository.Schedule {
+	return u.db.Schedule()
+}
+
+// Commit - commit transaction of unit
+func (u *SQLiteUnit) Commit(context.Context) error {
+	if u.done {
+		return sql.ErrTxDone
+	}
+	u.done = true
+	return u.tx.Commit()
+}
+
+// Rollback - roll back transaction of unit, after Commit it does nothing
+func (u *SQLiteUnit) Rollback(context.Context) error {
+	if u.done {
+		return nil
+	}
+	u.done = true
+	return u.tx.Rollback()
+}
+
+// savepoint - execute fn in transaction of unit of work
+// changes of fn are rolled back to savepoint if it returns error, the rest of unit is kept
+func (db *SQLite) savepoint(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if _, err := db.unit.ExecContext(ctx, `SAVEPOINT op`); err != nil {
+		return err
+	}
+	if err := fn(db.unit); err != nil {
+		if _, e := db.unit.ExecContext(ctx, `ROLLBACK TO op`); e != nil {
+			return e
+		}
+		_, _ = db.unit.ExecContext(ctx, `RELEASE op`)
+		return err
+	}
+	_, err := db.unit.ExecContext(ctx, `RELEASE op`)
+	return err
+}
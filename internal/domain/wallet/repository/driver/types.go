@@ -0,0 +1,318 @@
+// Package driver - types and helpers shared by storage drivers of repository
+// drivers are implemented in packages memory, postgresql and sqlite, which register themselves in repository
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// scale of amounts and rates stored in database, the same as numeric(22,4) and numeric(22,10) columns of PostgreSQL
+// rates are rounded to RateScale by entity.Convert before conversion, so saved rate is the rate of payment
+const (
+	AmountScale = 4
+	RateScale   = 10
+)
+
+// RoundAmount - round amount to scale of database column
+// drivers which store amounts without database numeric type use it to return amounts the same as PostgreSQL
+func RoundAmount(a money.Amount, scale uint8) money.Amount {
+	r, err := a.MulRound(money.New(1, 0), scale)
+	if err != nil {
+		return a
+	}
+	return r
+}
+
+// CheckAvailable - check that balance without held amount together with credit limit is enough for amount
+// returns ErrNoMoney if it is not, or money.ErrRange if available amount overflows
+func CheckAvailable(balance, held, creditLimit, amount money.Amount) error {
+	available, err := balance.Sub(held)
+	if err == nil {
+		available, err = available.Add(creditLimit)
+	}
+	if err != nil {
+		return err
+	}
+	if available.Cmp(amount) < 0 {
+		return ErrNoMoney
+	}
+	return nil
+}
+
+// Conversion - currency conversion applied to transfer between accounts with different currencies
+type Conversion struct {
+	// ToAmount - amount credited to recipient in recipient currency
+	ToAmount money.Amount
+	// Rate - exchange rate from payer currency to recipient currency
+	Rate money.Amount
+	// RateDate - time when exchange rate was fixed
+	RateDate time.Time
+}
+
+// statuses of account
+// frozen account can't be used in payments until it is unfrozen, closed account can't be used anymore
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+)
+
+// AccountStatusError - return error for payment with account in status, nil for active account
+// recipient errors are returned if "recipient" is true
+func AccountStatusError(status string, recipient bool) error {
+	switch {
+	case status == AccountStatusFrozen && recipient:
+		return ErrRecipientFrozen
+	case status == AccountStatusFrozen:
+		return ErrAccountFrozen
+	case status == AccountStatusClosed && recipient:
+		return ErrRecipientClosed
+	case status == AccountStatusClosed:
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// statuses of hold
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+// Hold - amount of account reserved for transfer to account ToID
+// active hold reduces available balance of account but money is not moved until capture
+type Hold struct {
+	ID        int64
+	AccountID int64
+	ToID      int64
+	Amount    money.Amount
+	Status    string
+	ExpiresAt time.Time
+	// PaymentID - id of transfer created by capture, 0 if hold is not captured
+	PaymentID int64
+	Date      time.Time
+}
+
+// recurrences of scheduled transfer
+const (
+	RecurrenceOnce    = "once"
+	RecurrenceDaily   = "daily"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+// statuses of scheduled transfer
+// completed transfer has no more executions, cancelled transfer was stopped by owner of account
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// ScheduledTransfer - transfer from account AccountID to account ToID executed at NextRunAt
+// recurring transfer is executed repeatedly, Day is day of month of monthly recurrence
+type ScheduledTransfer struct {
+	ID         int64
+	AccountID  int64
+	ToID       int64
+	Amount     money.Amount
+	Recurrence string
+	Day        int
+	// NextRunAt - planned time of the next execution, for completed transfer time of the last execution
+	NextRunAt time.Time
+	Status    string
+	Date      time.Time
+}
+
+// ScheduleExecution - result of execution of scheduled transfer planned at RunAt
+// PaymentID is 0 and Error is set when transfer failed
+type ScheduleExecution struct {
+	ID         int64
+	ScheduleID int64
+	RunAt      time.Time
+	PaymentID  int64
+	Error      string
+	Date       time.Time
+}
+
+// Limits - spending limits of account, zero value of limit means the limit is not set
+type Limits struct {
+	// MaxAmount - maximal amount of one transfer
+	MaxAmount money.Amount
+	// DailyAmount - maximal total amount of outgoing transfers per day
+	DailyAmount money.Amount
+	// DailyCount - maximal count of outgoing transfers per day
+	DailyCount int64
+}
+
+// Spending - total amount and count of outgoing transfers and withdrawals of account for period
+type Spending struct {
+	Amount money.Amount
+	Count  int64
+}
+
+// LimitsDay - start of day of daily limits for time now, days of limits are days of UTC
+func LimitsDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Daily - return true if daily amount or daily count limit is set
+func (l Limits) Daily() bool {
+	return !l.DailyAmount.IsZero() || l.DailyCount != 0
+}
+
+// Check - check that payment of amount together with spending s of the day doesn't exceed limits
+// returns ErrTransferLimitExceeded, ErrDailyAmountExceeded or ErrDailyCountExceeded
+func (l Limits) Check(s Spending, amount money.Amount) error {
+	if !l.MaxAmount.IsZero() && amount.Cmp(l.MaxAmount) > 0 {
+		return ErrTransferLimitExceeded
+	}
+	if l.DailyCount != 0 && s.Count >= l.DailyCount {
+		return ErrDailyCountExceeded
+	}
+	if l.DailyAmount.IsZero() {
+		return nil
+	}
+	total, err := s.Amount.Add(amount)
+	if err != nil {
+		return err
+	}
+	if total.Cmp(l.DailyAmount) > 0 {
+		return ErrDailyAmountExceeded
+	}
+	return nil
+}
+
+// Metadata - client supplied information of deposit or transfer
+// zero value means payment has no metadata
+type Metadata struct {
+	// Description - free-text description of payment
+	Description string
+	// ExternalRef - client reference of payment, unique per account which created payment
+	// (payer of transfer, recipient of deposit)
+	ExternalRef string
+	// Tags - key/value tags of payment
+	Tags map[string]string
+}
+
+// Values return metadata for saving in database, NULL values are used for empty fields
+// accountID is account which created payment, it is saved only with external reference
+func (m Metadata) Values(accountID int64) (description, ref *string, refAccount *int64, tags *string, err error) {
+	if m.Description != "" {
+		description = &m.Description
+	}
+	if m.ExternalRef != "" {
+		ref, refAccount = &m.ExternalRef, &accountID
+	}
+	if len(m.Tags) != 0 {
+		b, err := json.Marshal(m.Tags)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		t := string(b)
+		tags = &t
+	}
+	return description, ref, refAccount, tags, nil
+}
+
+// DecodeTags - decode tags of payment saved as JSON object, NULL value is decoded as nil map
+func DecodeTags(b []byte) (map[string]string, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var tags map[string]string
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Idempotency - client supplied key of request which can be retried
+// payment is stored with key and hash of request, so retry of the same request returns
+// the original payment instead of creating new one
+type Idempotency struct {
+	// Key - value of Idempotency-Key header, unique per account
+	Key string
+	// Hash - fingerprint of request parameters
+	Hash string
+}
+
+// Values return key and hash for saving in database. NULL values are used when idem is nil
+func (idem *Idempotency) Values() (key, hash *string) {
+	if idem == nil {
+		return nil, nil
+	}
+	return &idem.Key, &idem.Hash
+}
+
+// Account return account id saved with idempotency key. NULL value is used when idem is nil
+func (idem *Idempotency) Account(accountID int64) *int64 {
+	if idem == nil {
+		return nil
+	}
+	return &accountID
+}
+
+var (
+	// ErrNoMoney is returned when account balance is not enough for payment
+	ErrNoMoney = errors.New("no enough money")
+	// ErrRecipientNotFound is returned when recipient account of transfer not exists
+	ErrRecipientNotFound = errors.New("recipient not found")
+
+	// ErrAccountFrozen is returned when account of payment is frozen
+	ErrAccountFrozen = errors.New("account is frozen")
+	// ErrAccountClosed is returned when account of payment or account which status is changed is closed
+	ErrAccountClosed = errors.New("account is closed")
+	// ErrRecipientFrozen is returned when recipient account of payment is frozen
+	ErrRecipientFrozen = errors.New("recipient account is frozen")
+	// ErrRecipientClosed is returned when recipient account of payment is closed
+	ErrRecipientClosed = errors.New("recipient account is closed")
+	// ErrAccountNotEmpty is returned when account with non zero balance or active holds is closed
+	ErrAccountNotEmpty = errors.New("account balance is not zero")
+
+	// ErrHoldNotFound is returned when hold of account not exists
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldNotActive is returned when hold was already captured, released or expired
+	ErrHoldNotActive = errors.New("hold is not active")
+	// ErrHoldExceedsAmount is returned when captured amount is greater than amount of hold
+	ErrHoldExceedsAmount = errors.New("capture amount exceeds hold amount")
+
+	// ErrTransferLimitExceeded is returned when amount of payment is greater than limit of one payment
+	ErrTransferLimitExceeded = errors.New("transfer amount exceeds limit")
+	// ErrDailyAmountExceeded is returned when payment makes total amount of payments of day greater than limit
+	ErrDailyAmountExceeded = errors.New("daily amount limit of transfers exceeded")
+	// ErrDailyCountExceeded is returned when count of payments of day reached limit
+	ErrDailyCountExceeded = errors.New("daily count limit of transfers exceeded")
+
+	// ErrScheduleNotFound is returned when scheduled transfer of account not exists
+	ErrScheduleNotFound = errors.New("scheduled transfer not found")
+	// ErrScheduleNotActive is returned when scheduled transfer was completed or cancelled
+	ErrScheduleNotActive = errors.New("scheduled transfer is not active")
+	// ErrScheduleExecuted is returned when execution of scheduled transfer at planned time was already recorded
+	ErrScheduleExecuted = errors.New("scheduled transfer was already executed")
+
+	// ErrReversalNotAllowed is returned when payment can't be reversed (it is reversal itself or has no ledger entries)
+	ErrReversalNotAllowed = errors.New("payment can't be reversed")
+	// ErrReversalExceedsAmount is returned when total amount of reversals is greater than amount of payment
+	ErrReversalExceedsAmount = errors.New("reversal amount exceeds payment amount")
+	// ErrAccountNotFound is returned when account of payment not exists
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrPaymentNotFound is returned when payment with external reference not exists
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrExternalRefDuplicate is returned when account already created payment with the same external reference
+	ErrExternalRefDuplicate = errors.New("external reference was already used")
+
+	// ErrIdempotencyReplay is returned with id of original payment when request with the same key and parameters
+	// was already executed
+	ErrIdempotencyReplay = errors.New("request with this idempotency key was already executed")
+	// ErrIdempotencyConflict is returned when idempotency key was already used for request with other parameters
+	ErrIdempotencyConflict = errors.New("idempotency key was already used for another request")
+)
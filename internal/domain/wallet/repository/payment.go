@@ -5,42 +5,95 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// LedgerEntry - one leg of ledger transaction, defined by driver
+type LedgerEntry = driver.LedgerEntry
+
+// PaymentFilter - conditions of payments list of account, defined by driver
+type PaymentFilter = driver.PaymentFilter
+
+// directions of payments relative to account, used by PaymentFilter
+const (
+	PaymentDirectionIncoming   = driver.PaymentDirectionIncoming
+	PaymentDirectionOutgoing   = driver.PaymentDirectionOutgoing
+	PaymentDirectionDeposit    = driver.PaymentDirectionDeposit
+	PaymentDirectionWithdrawal = driver.PaymentDirectionWithdrawal
+)
+
+// kinds of payments
+const (
+	PaymentKindDeposit    = driver.PaymentKindDeposit
+	PaymentKindTransfer   = driver.PaymentKindTransfer
+	PaymentKindWithdrawal = driver.PaymentKindWithdrawal
+	PaymentKindReversal   = driver.PaymentKindReversal
+)
+
+var (
+	// ErrLedgerUnbalanced is returned when entries of transaction don't sum to zero
+	ErrLedgerUnbalanced = driver.ErrLedgerUnbalanced
+	// ErrReversalNotAllowed is returned when payment can't be reversed
+	ErrReversalNotAllowed = driver.ErrReversalNotAllowed
+	// ErrReversalExceedsAmount is returned when total amount of reversals is greater than amount of payment
+	ErrReversalExceedsAmount = driver.ErrReversalExceedsAmount
+	// ErrAccountNotFound is returned when account of payment not exists
+	ErrAccountNotFound = driver.ErrAccountNotFound
+	// ErrPaymentNotFound is returned when payment with external reference not exists
+	ErrPaymentNotFound = driver.ErrPaymentNotFound
 )
 
 // interface defined payment repository for storage
+// payment is header of ledger transaction, money movement is stored as ledger entries
 type Payment interface {
 	// ID return id of payment
 	ID() int64
+	// Kind return kind of payment: deposit, transfer, withdrawal or reversal
+	Kind() string
 	// Date return date and time of payment
 	Date() time.Time
-	// Amount return payments amount
-	Amount() float64
+	// Amount return payments amount in payer currency
+	Amount() money.Amount
+	// ToAmount return amount credited to recipient in recipient currency
+	ToAmount() money.Amount
+	// Rate return exchange rate applied to payment (1 when currencies are the same)
+	Rate() money.Amount
+	// RateDate return time of exchange rate, zero time if no conversion was applied
+	RateDate() time.Time
+	// ToBalance return recipient balance after payment
+	ToBalance() money.Amount
+	// Counterparty return reference to external destination of withdrawal
+	Counterparty() string
+	// ReversalOf return id of payment compensated by reversal, 0 for other payments
+	ReversalOf() int64
+	// Fee return fee of transfer charged to payer in addition to amount, zero for other payments
+	Fee() money.Amount
+	// Metadata return description, external reference and tags of deposit or transfer
+	Metadata() Metadata
 	// From return payer account id
 	From() int64
 	// To return recipient account id
 	To() int64
+	// Entries return ledger entries of payment
+	Entries(ctx context.Context) ([]LedgerEntry, error)
 
-	// List - return list of payments for account with accountID
-	List(accountID, offset, limit int64) ([]interface{}, error)
+	// List - return list of payments for account with accountID which meet conditions of filter
+	// if after > 0, list begins with the next payment after payment with this id, offset must be zero then
+	List(ctx context.Context, accountID int64, filter PaymentFilter, after, offset, limit int64) ([]interface{}, error)
 	// ListAll - return list of all payments
-	ListAll(offset, limit int64) ([]interface{}, error)
-
-	Get(id int64) error
-}
+	// if after > 0, list begins with the next payment after payment with this id, offset must be zero then
+	ListAll(ctx context.Context, after, offset, limit int64) ([]interface{}, error)
+	// Reverse - create payment which compensates loaded payment
+	// amount is in payer currency and toAmount is in recipient currency of loaded payment
+	Reverse(ctx context.Context, amount, toAmount money.Amount, force bool) (int64, error)
+	// Unbalanced - return ids of payments which ledger entries don't sum to zero
+	Unbalanced(ctx context.Context) ([]int64, error)
 
-// create repository instance using current DBEngine
-func PaymentFactory(dbDriver string) (Payment, error) {
-	switch dbDriver {
-	case "postgresql":
-		if err := driver.PgSQLInit(); err != nil {
-			return nil, err
-		}
-		return &driver.PgSqlPayment{}, nil
-	default:
-		return nil, fmt.Errorf("unknown database engine: %s", dbDriver)
-	}
+	Get(ctx context.Context, id int64) error
+	// FindByExternalRef - find payment created by account with accountID with external reference ref
+	FindByExternalRef(ctx context.Context, accountID int64, ref string) error
 }
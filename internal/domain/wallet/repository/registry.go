@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+// Storage - provider of account, payment and scheduled transfer repositories, implemented by Driver and UnitOfWork
+type Storage interface {
+	// Account return new account repository
+	Account() Account
+	// Payment return new payment repository
+	Payment() Payment
+	// Schedule return new repository of scheduled transfers
+	Schedule() Schedule
+}
+
+// Driver - storage engine which provides account and payment repositories
+// repositories of one driver share its connection to database
+type Driver interface {
+	Storage
+	// Begin start unit of work, operations of its repositories are committed or rolled back together
+	Begin(ctx context.Context) (UnitOfWork, error)
+	// Close connection to database
+	Close() error
+}
+
+// Migrator - driver with versioned migrations of database schema
+type Migrator interface {
+	Driver
+	// MigrateUp apply all pending migrations, returning applied migrations
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	// MigrateDown revert the last applied migration, returning nil if no migrations are applied
+	MigrateDown(ctx context.Context) (*Migration, error)
+	// MigrationStatus return all migrations with time of applying
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+	// CheckSchema return ErrSchemaOutdated if some migrations are not applied
+	CheckSchema(ctx context.Context) error
+}
+
+// Migration - one version of database schema
+type Migration = driver.Migration
+
+// MigrationStatus - migration and time of applying
+type MigrationStatus = driver.MigrationStatus
+
+var (
+	// ErrSchemaOutdated is returned by Open when database has migrations which are not applied
+	ErrSchemaOutdated = driver.ErrSchemaOutdated
+	// ErrSchemaUnknown is returned when database has applied migrations unknown for this version of wallet
+	ErrSchemaUnknown = driver.ErrSchemaUnknown
+)
+
+// DriverFactory - open driver with configuration, every call establishes new connection to database
+type DriverFactory func(cfg Config) (Driver, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]DriverFactory)
+)
+
+// Register - make driver available by name for Open
+// it is intended to be called from init function of driver package, which is imported by program for side effect
+// Register panics if factory is nil or driver with the same name is already registered
+func Register(name string, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("repository: Register factory of driver " + name + " is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("repository: Register called twice for driver " + name)
+	}
+	drivers[name] = factory
+}
+
+// Drivers - return sorted list of names of registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open - open registered driver with name cfg.Driver
+// for unknown name returned error lists available drivers
+// if driver has migrations Open refuses database with outdated schema,
+// unless cfg.AutoMigrate is set and pending migrations are applied
+func Open(cfg Config) (Driver, error) {
+	d, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := d.(Migrator)
+	if !ok {
+		return d, nil
+	}
+
+	ctx := context.Background()
+	if cfg.AutoMigrate {
+		_, err = m.MigrateUp(ctx)
+	} else {
+		err = m.CheckSchema(ctx)
+	}
+	if err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// OpenMigrator - open registered driver for applying migrations, schema of database is not checked
+// error is returned if driver has no migrations
+func OpenMigrator(cfg Config) (Migrator, error) {
+	d, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := d.(Migrator)
+	if !ok {
+		_ = d.Close()
+		return nil, fmt.Errorf("database engine %s has no migrations", cfg.Driver)
+	}
+	return m, nil
+}
+
+// open - open registered driver without check of schema
+func open(cfg Config) (Driver, error) {
+	driversMu.RLock()
+	factory, ok := drivers[cfg.Driver]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine: %s (available: %s)", cfg.Driver,
+			strings.Join(Drivers(), ", "))
+	}
+	return factory(cfg)
+}
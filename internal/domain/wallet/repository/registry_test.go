@@ -0,0 +1,91 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/memory"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/postgresql"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/sqlite"
+)
+
+func Test_Open(t *testing.T) {
+	for _, name := range []string{"postgresql", "sqlite", "memory"} {
+		found := false
+		for _, d := range repository.Drivers() {
+			found = found || d == name
+		}
+		if !found {
+			t.Errorf("driver %s is not registered, Drivers() = %v", name, repository.Drivers())
+		}
+	}
+
+	_, err := repository.Open(repository.Config{Driver: "oracle"})
+	if err == nil || !strings.Contains(err.Error(), strings.Join(repository.Drivers(), ", ")) {
+		t.Errorf("Open(oracle) error = %v, want list of available drivers", err)
+	}
+}
+
+func Test_Register(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register of duplicate driver doesn't panic")
+		}
+	}()
+	repository.Register("memory", memory.Open)
+}
+
+// drivers opened with the same configuration don't share data
+func Test_OpenIsolated(t *testing.T) {
+	d1, err := repository.Open(repository.Config{Driver: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d1.Close()
+	d2, err := repository.Open(repository.Config{Driver: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Close()
+	ctx := context.Background()
+
+	if err := d1.Account().Create(ctx, "wallet1", "usd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d2.Account().Find(ctx, "wallet1"); err == nil {
+		t.Error("account created by first driver is found by second driver")
+	}
+	if err := d2.Account().Create(ctx, "wallet1", "usd"); err != nil {
+		t.Errorf("account with the same name can't be created by second driver: %v", err)
+	}
+}
+
+// database with outdated schema is opened only with AutoMigrate
+func Test_OpenMigrations(t *testing.T) {
+	cfg := repository.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "wallet.db")}
+	if _, err := repository.Open(cfg); !errors.Is(err, repository.ErrSchemaOutdated) {
+		t.Errorf("Open() of empty database error = %v, want %v", err, repository.ErrSchemaOutdated)
+	}
+
+	cfg.AutoMigrate = true
+	d, err := repository.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	cfg.AutoMigrate = false
+	d, err = repository.Open(cfg)
+	if err != nil {
+		t.Fatalf("Open() of migrated database error = %v", err)
+	}
+	_ = d.Close()
+
+	if _, err = repository.OpenMigrator(repository.Config{Driver: "memory"}); err == nil {
+		t.Error("OpenMigrator(memory) doesn't return error")
+	}
+}
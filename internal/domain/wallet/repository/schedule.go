@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository/driver"
+)
+
+// ScheduledTransfer - transfer executed at planned time, defined by driver
+type ScheduledTransfer = driver.ScheduledTransfer
+
+// ScheduleExecution - result of execution of scheduled transfer, defined by driver
+type ScheduleExecution = driver.ScheduleExecution
+
+// recurrences of scheduled transfer
+const (
+	RecurrenceOnce    = driver.RecurrenceOnce
+	RecurrenceDaily   = driver.RecurrenceDaily
+	RecurrenceWeekly  = driver.RecurrenceWeekly
+	RecurrenceMonthly = driver.RecurrenceMonthly
+)
+
+// statuses of scheduled transfer
+const (
+	ScheduleStatusActive    = driver.ScheduleStatusActive
+	ScheduleStatusCompleted = driver.ScheduleStatusCompleted
+	ScheduleStatusCancelled = driver.ScheduleStatusCancelled
+)
+
+var (
+	// ErrScheduleNotFound is returned when scheduled transfer of account not exists
+	ErrScheduleNotFound = driver.ErrScheduleNotFound
+	// ErrScheduleNotActive is returned when scheduled transfer was completed or cancelled
+	ErrScheduleNotActive = driver.ErrScheduleNotActive
+	// ErrScheduleExecuted is returned when execution of scheduled transfer at planned time was already recorded
+	ErrScheduleExecuted = driver.ErrScheduleExecuted
+)
+
+// Schedule interface defined repository of scheduled transfers for storage
+type Schedule interface {
+	// Create - save new active scheduled transfer, returning its id
+	Create(ctx context.Context, t ScheduledTransfer) (int64, error)
+	// Get - return scheduled transfer of account with accountID by id
+	Get(ctx context.Context, accountID, id int64) (ScheduledTransfer, error)
+	// List - return scheduled transfers of account with accountID
+	List(ctx context.Context, accountID, offset, limit int64) ([]ScheduledTransfer, error)
+	// Update - save amount, recurrence, day and next run time of active scheduled transfer
+	Update(ctx context.Context, t ScheduledTransfer) error
+	// Cancel - set status cancelled for active scheduled transfer of account with accountID
+	Cancel(ctx context.Context, accountID, id int64) error
+
+	// Due - return active scheduled transfers which next run time is not after now, ordered by it
+	Due(ctx context.Context, now time.Time, limit int64) ([]ScheduledTransfer, error)
+	// Complete - record execution of scheduled transfer planned at e.RunAt and plan the next one at next
+	// transfer becomes completed if next is zero
+	Complete(ctx context.Context, e ScheduleExecution, next time.Time) error
+	// Executions - return results of executions of scheduled transfer
+	Executions(ctx context.Context, scheduleID, offset, limit int64) ([]ScheduleExecution, error)
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+)
+
+// UnitOfWork - account and payment repositories which operations are executed in one transaction
+// changes of operations are visible outside of unit only after Commit, Rollback discards all of them.
+// Operation failed in unit doesn't change data, changes of previous operations are kept until unit is finished.
+// Unit must be finished by Commit or Rollback, Rollback after Commit does nothing, so it can be deferred.
+// Unit is not safe for concurrent use. Memory and sqlite drivers block other operations while unit is active
+type UnitOfWork interface {
+	Storage
+	// Commit save changes of all operations of unit
+	Commit(ctx context.Context) error
+	// Rollback discard changes of all operations of unit
+	Rollback(ctx context.Context) error
+}
+
+// InUnitOfWork - execute fn with repositories of unit of work of driver d
+// unit is committed if fn returns nil, else it is rolled back and error of fn returned
+func InUnitOfWork(ctx context.Context, d Driver, fn func(s Storage) error) error {
+	u, err := d.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = u.Rollback(ctx) }()
+
+	if err = fn(u); err != nil {
+		return err
+	}
+	return u.Commit(ctx)
+}
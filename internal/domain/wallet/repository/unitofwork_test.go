@@ -0,0 +1,100 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+func Test_UnitOfWork(t *testing.T) {
+	for _, cfg := range []repository.Config{
+		{Driver: "memory"},
+		{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "wallet.db"), AutoMigrate: true},
+	} {
+		t.Run(cfg.Driver, func(t *testing.T) {
+			d, err := repository.Open(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+			testUnitOfWork(t, d)
+		})
+	}
+}
+
+func testUnitOfWork(t *testing.T, d repository.Driver) {
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	t.Run("rollback", func(t *testing.T) {
+		err := repository.InUnitOfWork(ctx, d, func(s repository.Storage) error {
+			a := s.Account()
+			if err := a.Create(ctx, "unitwallet1", "usd"); err != nil {
+				return err
+			}
+			if _, err := a.Deposit(ctx, money.MustParse("5"), repository.Metadata{}, nil); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if err != errFailed {
+			t.Errorf("InUnitOfWork() error = %v, want %v", err, errFailed)
+		}
+		if err := d.Account().Find(ctx, "unitwallet1"); err == nil {
+			t.Error("account created in rolled back unit exists")
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		err := repository.InUnitOfWork(ctx, d, func(s repository.Storage) error {
+			from, to := s.Account(), s.Account()
+			if err := from.Create(ctx, "unitwallet1", "usd"); err != nil {
+				return err
+			}
+			if err := to.Create(ctx, "unitwallet2", "usd"); err != nil {
+				return err
+			}
+			if _, err := from.Deposit(ctx, money.MustParse("5"), repository.Metadata{}, nil); err != nil {
+				return err
+			}
+			// failed operation doesn't change data and doesn't break unit
+			if _, err := from.Transfer(ctx, to.ID(), money.MustParse("6"), nil, money.Amount{}, repository.Metadata{}, nil); err != repository.ErrNoMoney {
+				t.Errorf("Transfer() error = %v, want %v", err, repository.ErrNoMoney)
+			}
+			_, err := from.Transfer(ctx, to.ID(), money.MustParse("2"), nil, money.Amount{}, repository.Metadata{}, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]string{"unitwallet1": "3", "unitwallet2": "2"} {
+			a := d.Account()
+			if err := a.Find(ctx, name); err != nil {
+				t.Fatal(err)
+			}
+			if a.Balance().Cmp(money.MustParse(want)) != 0 {
+				t.Errorf("balance of %s = %s, want %s", name, a.Balance(), want)
+			}
+		}
+	})
+
+	t.Run("rollback after commit", func(t *testing.T) {
+		u, err := d.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = u.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err = u.Rollback(ctx); err != nil {
+			t.Errorf("Rollback() after Commit() error = %v", err)
+		}
+		if err = u.Commit(ctx); err == nil {
+			t.Error("second Commit() doesn't return error")
+		}
+	})
+}
@@ -2,29 +2,67 @@ package endpoints
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
 	"github.com/rurick/coinswallet/internal/services"
 )
 
 type Endpoints struct {
-	CreateAccount   endpoint.Endpoint
-	Deposit         endpoint.Endpoint
-	Transfer        endpoint.Endpoint
-	PaymentsList    endpoint.Endpoint
-	AllPaymentsList endpoint.Endpoint
-	AccountsList    endpoint.Endpoint
+	CreateAccount           endpoint.Endpoint
+	Deposit                 endpoint.Endpoint
+	Transfer                endpoint.Endpoint
+	PaymentByExternalRef    endpoint.Endpoint
+	BatchTransfer           endpoint.Endpoint
+	Withdraw                endpoint.Endpoint
+	Reverse                 endpoint.Endpoint
+	Hold                    endpoint.Endpoint
+	Capture                 endpoint.Endpoint
+	Release                 endpoint.Endpoint
+	ScheduleTransfer        endpoint.Endpoint
+	GetScheduledTransfer    endpoint.Endpoint
+	ScheduledTransfersList  endpoint.Endpoint
+	UpdateScheduledTransfer endpoint.Endpoint
+	CancelScheduledTransfer endpoint.Endpoint
+	FreezeAccount           endpoint.Endpoint
+	UnfreezeAccount         endpoint.Endpoint
+	CloseAccount            endpoint.Endpoint
+	SetCreditLimit          endpoint.Endpoint
+	GetLimits               endpoint.Endpoint
+	SetLimits               endpoint.Endpoint
+	PaymentsList            endpoint.Endpoint
+	AllPaymentsList         endpoint.Endpoint
+	AccountsList            endpoint.Endpoint
 }
 
 // Endpoints holds all Go kit endpoints for the wallet service.
 func MakeEndpoints(s services.Service) Endpoints {
 	return Endpoints{
-		CreateAccount:   makeCreateAccountEndpoint(s),
-		Deposit:         makeDepositEndpoint(s),
-		Transfer:        makeTransferEndpoint(s),
-		PaymentsList:    makePaymentsListEndpoint(s),
-		AllPaymentsList: makeAllPaymentsListEndpoint(s),
-		AccountsList:    makeAccountsListEndpoint(s),
+		CreateAccount:           makeCreateAccountEndpoint(s),
+		Deposit:                 makeDepositEndpoint(s),
+		Transfer:                makeTransferEndpoint(s),
+		PaymentByExternalRef:    makePaymentByExternalRefEndpoint(s),
+		BatchTransfer:           makeBatchTransferEndpoint(s),
+		Withdraw:                makeWithdrawEndpoint(s),
+		Reverse:                 makeReverseEndpoint(s),
+		Hold:                    makeHoldEndpoint(s),
+		Capture:                 makeCaptureEndpoint(s),
+		Release:                 makeReleaseEndpoint(s),
+		ScheduleTransfer:        makeScheduleTransferEndpoint(s),
+		GetScheduledTransfer:    makeGetScheduledTransferEndpoint(s),
+		ScheduledTransfersList:  makeScheduledTransfersListEndpoint(s),
+		UpdateScheduledTransfer: makeUpdateScheduledTransferEndpoint(s),
+		CancelScheduledTransfer: makeCancelScheduledTransferEndpoint(s),
+		FreezeAccount:           makeAccountStatusEndpoint(s.FreezeAccount),
+		UnfreezeAccount:         makeAccountStatusEndpoint(s.UnfreezeAccount),
+		CloseAccount:            makeAccountStatusEndpoint(s.CloseAccount),
+		SetCreditLimit:          makeSetCreditLimitEndpoint(s),
+		GetLimits:               makeGetLimitsEndpoint(s),
+		SetLimits:               makeSetLimitsEndpoint(s),
+		PaymentsList:            makePaymentsListEndpoint(s),
+		AllPaymentsList:         makeAllPaymentsListEndpoint(s),
+		AccountsList:            makeAccountsListEndpoint(s),
 	}
 }
 
@@ -35,7 +73,7 @@ func MakeEndpoints(s services.Service) Endpoints {
 func makeCreateAccountEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateAccountRequest)
-		id, err := s.CreateAccount(ctx, req.Name)
+		id, err := s.CreateAccount(ctx, req.Name, req.Currency, req.InitialDeposit)
 		return CreateAccountResponse{ID: id, Err: err}, nil
 	}
 }
@@ -43,7 +81,7 @@ func makeCreateAccountEndpoint(s services.Service) endpoint.Endpoint {
 func makeDepositEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(DepositRequest)
-		a, err := s.Deposit(ctx, req.Name, req.Amount)
+		a, err := s.Deposit(ctx, req.Name, req.Amount, req.metadata(), req.IdempotencyKey)
 		return DepositResponse{Balance: a, Err: err}, nil
 	}
 }
@@ -51,32 +89,166 @@ func makeDepositEndpoint(s services.Service) endpoint.Endpoint {
 func makeTransferEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(TransferRequest)
-		p, err := s.Transfer(ctx, req.From, req.To, req.Amount)
+		p, err := s.Transfer(ctx, req.From, req.To, req.Amount, req.metadata(), req.IdempotencyKey)
 		return TransferResponse{Payment: p, Err: err}, nil
 	}
 }
 
+func makePaymentByExternalRefEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PaymentByExternalRefRequest)
+		p, err := s.PaymentByExternalRef(ctx, req.Name, req.ExternalRef)
+		return PaymentResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeBatchTransferEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(BatchTransferRequest)
+		ids, err := s.BatchTransfer(ctx, req.Transfers)
+		return BatchTransferResponse{PaymentIDs: ids, Err: err}, nil
+	}
+}
+
+func makeWithdrawEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WithdrawRequest)
+		p, err := s.Withdraw(ctx, req.Name, req.Amount, req.Counterparty, req.IdempotencyKey)
+		return WithdrawResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeReverseEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ReverseRequest)
+		p, err := s.Reverse(ctx, req.ID, req.Amount, req.Force)
+		return ReverseResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeHoldEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(HoldRequest)
+		h, err := s.Hold(ctx, req.Name, req.To, req.Amount, time.Duration(req.TTL)*time.Second)
+		return HoldResponse{Hold: h, Err: err}, nil
+	}
+}
+
+func makeCaptureEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CaptureRequest)
+		p, err := s.Capture(ctx, req.Name, req.HoldID, req.Amount)
+		return CaptureResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeReleaseEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ReleaseRequest)
+		h, err := s.Release(ctx, req.Name, req.HoldID)
+		return HoldResponse{Hold: h, Err: err}, nil
+	}
+}
+
+func makeScheduleTransferEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ScheduleTransferRequest)
+		t, err := s.ScheduleTransfer(ctx, req.Name, req.To, req.Amount, req.RunAt, req.Recurrence, req.Day)
+		return ScheduledTransferResponse{ScheduledTransfer: t, Err: err}, nil
+	}
+}
+
+func makeGetScheduledTransferEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ScheduledTransferRequest)
+		t, err := s.GetScheduledTransfer(ctx, req.Name, req.ID)
+		return ScheduledTransferResponse{ScheduledTransfer: t, Err: err}, nil
+	}
+}
+
+func makeScheduledTransfersListEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ScheduledTransfersListRequest)
+		lst, err := s.ScheduledTransfersList(ctx, req.Name, req.Offset, req.Limit)
+		return ScheduledTransfersListResponse{List: lst, Err: err}, nil
+	}
+}
+
+func makeUpdateScheduledTransferEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UpdateScheduledTransferRequest)
+		t, err := s.UpdateScheduledTransfer(ctx, req.Name, req.ID, req.Amount, req.RunAt, req.Recurrence, req.Day)
+		return ScheduledTransferResponse{ScheduledTransfer: t, Err: err}, nil
+	}
+}
+
+func makeCancelScheduledTransferEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ScheduledTransferRequest)
+		t, err := s.CancelScheduledTransfer(ctx, req.Name, req.ID)
+		return ScheduledTransferResponse{ScheduledTransfer: t, Err: err}, nil
+	}
+}
+
+// makeAccountStatusEndpoint - create endpoint for service method which changes status of account
+func makeAccountStatusEndpoint(
+	set func(ctx context.Context, name entity.AccountName) (*services.AccountEntity, error)) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(AccountStatusRequest)
+		a, err := set(ctx, req.Name)
+		return AccountStatusResponse{Account: a, Err: err}, nil
+	}
+}
+
+func makeSetCreditLimitEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreditLimitRequest)
+		a, err := s.SetCreditLimit(ctx, req.Name, req.CreditLimit)
+		return AccountStatusResponse{Account: a, Err: err}, nil
+	}
+}
+
+func makeGetLimitsEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LimitsRequest)
+		l, err := s.GetLimits(ctx, req.Name)
+		return LimitsResponse{Limits: l, Err: err}, nil
+	}
+}
+
+func makeSetLimitsEndpoint(s services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetLimitsRequest)
+		l, err := s.SetLimits(ctx, req.Name, entity.Limits{
+			MaxAmount:   req.MaxAmount,
+			DailyAmount: req.DailyAmount,
+			DailyCount:  req.DailyCount,
+		})
+		return LimitsResponse{Limits: l, Err: err}, nil
+	}
+}
+
 func makePaymentsListEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(PaymentsListRequest)
-		lst, err := s.PaymentsList(ctx, req.Name, req.Offset, req.Limit)
-		return PaymentsListResponse{List: lst, Err: err}, nil
+		lst, next, err := s.PaymentsList(ctx, req.Name, req.Filter, req.Cursor, req.Offset, req.Limit)
+		return PaymentsListResponse{List: lst, NextCursor: next, Err: err}, nil
 	}
 }
 
 func makeAllPaymentsListEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AllPaymentsListRequest)
-		lst, err := s.AllPaymentsList(ctx, req.Offset, req.Limit)
+		lst, next, err := s.AllPaymentsList(ctx, req.Cursor, req.Offset, req.Limit)
 
-		return AllPaymentsListResponse{List: lst, Err: err}, nil
+		return AllPaymentsListResponse{List: lst, NextCursor: next, Err: err}, nil
 	}
 }
 
 func makeAccountsListEndpoint(s services.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AccountsListRequest)
-		lst, err := s.AccountsList(ctx, req.Offset, req.Limit)
-		return AccountsListResponse{List: lst, Err: err}, nil
+		lst, next, err := s.AccountsList(ctx, req.Cursor, req.Offset, req.Limit)
+		return AccountsListResponse{List: lst, NextCursor: next, Err: err}, nil
 	}
 }
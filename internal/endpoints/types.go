@@ -1,13 +1,20 @@
 package endpoints
 
 import (
+	"time"
+
 	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/internal/services"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 //
 // CreateAccountRequest - holds the request params for the CreateAccount method
 type CreateAccountRequest struct {
-	Name entity.AccountName
+	Name     entity.AccountName
+	Currency string
+	// InitialDeposit - amount deposited to account when it is created, zero for empty account
+	InitialDeposit money.Amount `json:"initial_deposit"`
 }
 
 // CreateAccountResponse - holds the response values for the CreateAccount method
@@ -22,13 +29,16 @@ func (r CreateAccountResponse) Error() error { return r.Err }
 // DepositRequest - holds the request params for the Deposit method
 type DepositRequest struct {
 	Name   entity.AccountName
-	Amount float64
+	Amount money.Amount
+	PaymentMetadata
+	// IdempotencyKey - value of Idempotency-Key header
+	IdempotencyKey string `json:"-"`
 }
 
 // DepositResponse - holds the response values for the Deposit method
 type DepositResponse struct {
-	Balance float64 `json:"balance,omitempty"`
-	Err     error   `json:"error,omitempty"`
+	Balance money.Amount `json:"balance"`
+	Err     error        `json:"error,omitempty"`
 }
 
 func (r DepositResponse) Error() error { return r.Err }
@@ -38,7 +48,10 @@ func (r DepositResponse) Error() error { return r.Err }
 type TransferRequest struct {
 	From   entity.AccountName
 	To     entity.AccountName
-	Amount float64
+	Amount money.Amount
+	PaymentMetadata
+	// IdempotencyKey - value of Idempotency-Key header
+	IdempotencyKey string `json:"-"`
 }
 
 // TransferResponse - holds the response values for the Transfer method
@@ -49,18 +62,253 @@ type TransferResponse struct {
 
 func (r TransferResponse) Error() error { return r.Err }
 
+//
+// PaymentMetadata - optional metadata of deposit and transfer requests
+// ExternalRef is client reference of payment, it must be unique for account which creates payment
+type PaymentMetadata struct {
+	Description string            `json:"description"`
+	ExternalRef string            `json:"external_ref"`
+	Tags        map[string]string `json:"tags"`
+}
+
+// metadata - convert request params to metadata of payment
+func (m PaymentMetadata) metadata() entity.Metadata {
+	return entity.Metadata{
+		Description: m.Description,
+		ExternalRef: m.ExternalRef,
+		Tags:        m.Tags,
+	}
+}
+
+// PaymentByExternalRefRequest - holds the request params for the PaymentByExternalRef method
+// params are taken from URI
+type PaymentByExternalRefRequest struct {
+	Name        entity.AccountName
+	ExternalRef string
+}
+
+// PaymentResponse - holds the response values for the PaymentByExternalRef method
+type PaymentResponse struct {
+	Payment interface{} `json:"payment,omitempty"`
+	Err     error       `json:"error,omitempty"`
+}
+
+func (r PaymentResponse) Error() error { return r.Err }
+
+//
+// BatchTransferRequest - holds the request params for the BatchTransfer method
+type BatchTransferRequest struct {
+	Transfers []services.TransferLeg `json:"transfers"`
+}
+
+// BatchTransferResponse - holds the response values for the BatchTransfer method
+// PaymentIDs are in order of transfers of request
+type BatchTransferResponse struct {
+	PaymentIDs []entity.ID `json:"payment_ids,omitempty"`
+	Err        error       `json:"error,omitempty"`
+}
+
+func (r BatchTransferResponse) Error() error { return r.Err }
+
+//
+// WithdrawRequest - holds the request params for the Withdraw method
+type WithdrawRequest struct {
+	Name   entity.AccountName
+	Amount money.Amount
+	// Counterparty - reference to external destination of money (bank account, card etc.)
+	Counterparty string
+	// IdempotencyKey - value of Idempotency-Key header
+	IdempotencyKey string `json:"-"`
+}
+
+// WithdrawResponse - holds the response values for the Withdraw method
+type WithdrawResponse struct {
+	Payment interface{} `json:"payment,omitempty"`
+	Err     error       `json:"error,omitempty"`
+}
+
+func (r WithdrawResponse) Error() error { return r.Err }
+
+//
+// HoldRequest - holds the request params for the Hold method
+type HoldRequest struct {
+	Name   entity.AccountName
+	To     entity.AccountName
+	Amount money.Amount
+	// TTL - time of life of hold in seconds, default is used if it is zero
+	TTL int64
+}
+
+// HoldResponse - holds the response values for the Hold and Release methods
+type HoldResponse struct {
+	Hold interface{} `json:"hold,omitempty"`
+	Err  error       `json:"error,omitempty"`
+}
+
+func (r HoldResponse) Error() error { return r.Err }
+
+//
+// CaptureRequest - holds the request params for the Capture method
+type CaptureRequest struct {
+	Name   entity.AccountName
+	HoldID int64 `json:"hold_id"`
+	// Amount - captured amount, zero for the whole hold
+	Amount money.Amount
+}
+
+// CaptureResponse - holds the response values for the Capture method
+type CaptureResponse struct {
+	Payment interface{} `json:"payment,omitempty"`
+	Err     error       `json:"error,omitempty"`
+}
+
+func (r CaptureResponse) Error() error { return r.Err }
+
+//
+// ReleaseRequest - holds the request params for the Release method
+type ReleaseRequest struct {
+	Name   entity.AccountName
+	HoldID int64 `json:"hold_id"`
+}
+
+//
+// ScheduleTransferRequest - holds the request params for the ScheduleTransfer method
+type ScheduleTransferRequest struct {
+	Name   entity.AccountName
+	To     entity.AccountName
+	Amount money.Amount
+	// RunAt - time of the first execution, transfer is executed by the next run of scheduler if it is not set
+	RunAt time.Time `json:"run_at"`
+	// Recurrence - once, daily, weekly or monthly, once if it is not set
+	Recurrence string
+	// Day - day of month of monthly transfer, day of RunAt if it is not set
+	Day int
+}
+
+// ScheduledTransferRequest - holds the request params for the GetScheduledTransfer and CancelScheduledTransfer methods
+// params are taken from URI
+type ScheduledTransferRequest struct {
+	Name entity.AccountName
+	ID   int64
+}
+
+// UpdateScheduledTransferRequest - holds the request params for the UpdateScheduledTransfer method
+// values which are not set are not changed, Name and ID are taken from URI
+type UpdateScheduledTransferRequest struct {
+	Name       entity.AccountName `json:"-"`
+	ID         int64              `json:"-"`
+	Amount     money.Amount
+	RunAt      time.Time `json:"run_at"`
+	Recurrence string
+	Day        int
+}
+
+// ScheduledTransferResponse - holds the response values for the methods of single scheduled transfer
+type ScheduledTransferResponse struct {
+	ScheduledTransfer interface{} `json:"scheduled_transfer,omitempty"`
+	Err               error       `json:"error,omitempty"`
+}
+
+func (r ScheduledTransferResponse) Error() error { return r.Err }
+
+//
+// ScheduledTransfersListRequest - holds the request params for the ScheduledTransfersList method
+type ScheduledTransfersListRequest struct {
+	Name   entity.AccountName
+	Offset int64
+	Limit  int64
+}
+
+// ScheduledTransfersListResponse - holds the response values for the ScheduledTransfersList method
+type ScheduledTransfersListResponse struct {
+	List interface{} `json:"list"`
+	Err  error       `json:"error,omitempty"`
+}
+
+func (r ScheduledTransfersListResponse) Error() error { return r.Err }
+
+//
+// AccountStatusRequest - holds the request params for the FreezeAccount, UnfreezeAccount and CloseAccount methods
+type AccountStatusRequest struct {
+	Name entity.AccountName
+}
+
+// AccountStatusResponse - holds the response values for the FreezeAccount, UnfreezeAccount, CloseAccount
+// and SetCreditLimit methods
+type AccountStatusResponse struct {
+	Account interface{} `json:"account,omitempty"`
+	Err     error       `json:"error,omitempty"`
+}
+
+func (r AccountStatusResponse) Error() error { return r.Err }
+
+//
+// CreditLimitRequest - holds the request params for the SetCreditLimit method
+// zero credit limit doesn't allow negative balance
+type CreditLimitRequest struct {
+	Name        entity.AccountName
+	CreditLimit money.Amount `json:"credit_limit"`
+}
+
+//
+// LimitsRequest - holds the request params for the GetLimits method, name is taken from URI
+type LimitsRequest struct {
+	Name entity.AccountName
+}
+
+// SetLimitsRequest - holds the request params for the SetLimits method, name is taken from URI
+// zero value of limit means the limit is not set
+type SetLimitsRequest struct {
+	Name        entity.AccountName `json:"-"`
+	MaxAmount   money.Amount       `json:"max_amount"`
+	DailyAmount money.Amount       `json:"daily_amount"`
+	DailyCount  int64              `json:"daily_count"`
+}
+
+// LimitsResponse - holds the response values for the GetLimits and SetLimits methods
+type LimitsResponse struct {
+	Limits interface{} `json:"limits,omitempty"`
+	Err    error       `json:"error,omitempty"`
+}
+
+func (r LimitsResponse) Error() error { return r.Err }
+
+//
+// ReverseRequest - holds the request params for the Reverse method
+type ReverseRequest struct {
+	// ID - id of reversed payment, it is taken from URI
+	ID entity.ID `json:"-"`
+	// Amount - amount to return in payer currency, zero for the whole rest of payment
+	Amount money.Amount
+	// Force - reverse payment even if recipient has no enough money. Allowed only for administrator
+	Force bool
+}
+
+// ReverseResponse - holds the response values for the Reverse method
+type ReverseResponse struct {
+	Payment interface{} `json:"payment,omitempty"`
+	Err     error       `json:"error,omitempty"`
+}
+
+func (r ReverseResponse) Error() error { return r.Err }
+
 //
 // PaymentsListRequest - holds the request params for the PaymentsList method
+// Filter and Cursor are taken from query params of URI
 type PaymentsListRequest struct {
 	Name   entity.AccountName
 	Offset int64
 	Limit  int64
+	Filter services.PaymentsFilter
+	Cursor string
 }
 
 // PaymentsListResponse - holds the response values for the PaymentsList method
+// NextCursor is set when the next page may exist
 type PaymentsListResponse struct {
-	List interface{} `json:"list"`
-	Err  error       `json:"error,omitempty"`
+	List       interface{} `json:"list"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Err        error       `json:"error,omitempty"`
 }
 
 func (r PaymentsListResponse) Error() error { return r.Err }
@@ -70,12 +318,14 @@ func (r PaymentsListResponse) Error() error { return r.Err }
 type AllPaymentsListRequest struct {
 	Offset int64
 	Limit  int64
+	Cursor string
 }
 
 // AllPaymentsListResponse PaymentsListResponse - holds the response values for the AllPaymentsList method
 type AllPaymentsListResponse struct {
-	List interface{} `json:"list"`
-	Err  error       `json:"error,omitempty"`
+	List       interface{} `json:"list"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Err        error       `json:"error,omitempty"`
 }
 
 func (r AllPaymentsListResponse) Error() error { return r.Err }
@@ -85,12 +335,14 @@ func (r AllPaymentsListResponse) Error() error { return r.Err }
 type AccountsListRequest struct {
 	Offset int64
 	Limit  int64
+	Cursor string
 }
 
 // AccountsListResponse - holds the response values for the AccountsList method
 type AccountsListResponse struct {
-	List interface{} `json:"list"`
-	Err  error       `json:"error,omitempty"`
+	List       interface{} `json:"list"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Err        error       `json:"error,omitempty"`
 }
 
 func (r AccountsListResponse) Error() error { return r.Err }
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+)
+
+func (s Service) BatchTransfer(ctx context.Context, legs []TransferLeg) ([]entity.ID, error) {
+	if len(legs) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(legs) > MaxBatchLegs {
+		return nil, ErrBatchTooLarge
+	}
+	if err := s.validateBatch(ctx, legs); err != nil {
+		return nil, err
+	}
+
+	// all legs are executed in one unit of work, failed leg rolls back the previous ones
+	ids := make([]entity.ID, len(legs))
+	err := entity.InUnitOfWork(ctx, s.db, func(db entity.Storage) error {
+		for i, l := range legs {
+			a, err := entity.NewAccount(db)
+			if err == nil {
+				err = a.Find(ctx, l.From)
+			}
+			var paymentID int64
+			if err == nil {
+				paymentID, err = a.Transfer(ctx, l.To, l.Amount, s.rates, s.fees, entity.Metadata{}, nil)
+			}
+			if err != nil {
+				return &BatchError{Leg: i, Err: err}
+			}
+			ids[i] = entity.ID(paymentID)
+		}
+		return nil
+	})
+	if err == nil {
+		return ids, nil
+	}
+
+	if be, ok := err.(*BatchError); ok {
+		if e, ok := transferErrors[be.Err]; ok {
+			return nil, &BatchError{Leg: be.Leg, Err: e}
+		}
+	}
+	_ = s.logger.Log("service", "BatchTransfer", "func", "Transfer()", "error", err)
+	return nil, ErrInService
+}
+
+// validateBatch - check accounts and amounts of all legs before any transfer is done
+// returning *BatchError for the first invalid leg
+func (s Service) validateBatch(ctx context.Context, legs []TransferLeg) error {
+	// accounts are found once for all legs
+	accounts := make(map[entity.AccountName]*entity.Account)
+	find := func(name entity.AccountName) (*entity.Account, error) {
+		if a, ok := accounts[name]; ok {
+			return a, nil
+		}
+		a, err := entity.NewAccount(s.db)
+		if err == nil {
+			err = a.Find(ctx, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		accounts[name] = a
+		return a, nil
+	}
+	// legs of the same account are checked against limits together
+	pending := make(map[entity.AccountName]entity.Spending)
+
+	for i, l := range legs {
+		if l.From == l.To {
+			return &BatchError{Leg: i, Err: ErrTransferSelfToSelfError}
+		}
+		from, err := find(l.From)
+		if err != nil {
+			_ = s.logger.Log("service", "BatchTransfer", "func", "Find()", "error", err)
+			return &BatchError{Leg: i, Err: ErrTransferFromNotFound}
+		}
+		to, err := find(l.To)
+		if err != nil {
+			_ = s.logger.Log("service", "BatchTransfer", "func", "Find()", "error", err)
+			return &BatchError{Leg: i, Err: ErrTransferToNotFound}
+		}
+		if from.Currency != to.Currency && s.rates == nil {
+			return &BatchError{Leg: i, Err: ErrTransferCurrencyError}
+		}
+		if err = from.ValidateAmount(l.Amount); err != nil {
+			return &BatchError{Leg: i, Err: ErrTransferAmountError}
+		}
+		p := pending[l.From]
+		if err = s.checkLimits(ctx, "BatchTransfer", from, l.Amount, p); err != nil {
+			return &BatchError{Leg: i, Err: err}
+		}
+		sum, err := p.Amount.Add(l.Amount)
+		if err != nil {
+			return &BatchError{Leg: i, Err: ErrTransferAmountError}
+		}
+		pending[l.From] = entity.Spending{Amount: sum, Count: p.Count + 1}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package services
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// lists of cursors, cursor of one list can't be used for another
+const (
+	cursorPayments = "payments"
+	cursorAccounts = "accounts"
+)
+
+// encodeCursor - opaque cursor of the next page of list, it keeps id of the last item of current page
+// next page is possible only if current page is full, otherwise cursor is empty
+func encodeCursor(list string, lastID int64, pageLen int, limit int64) string {
+	if limit <= 0 || int64(pageLen) < limit {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(list + ":" + strconv.FormatInt(lastID, 10)))
+}
+
+// decodeCursor - id of the last item of previous page of list, 0 for empty cursor
+// page of cursor begins right after the last item, so offset must be zero when cursor is set
+func decodeCursor(list, cursor string, offset int64) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	if offset != 0 {
+		return 0, ErrListCursorOffsetError
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrListCursorError
+	}
+	s := strings.TrimPrefix(string(b), list+":")
+	if len(s) == len(b) {
+		return 0, ErrListCursorError
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrListCursorError
+	}
+	return id, nil
+}
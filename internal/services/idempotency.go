@@ -0,0 +1,37 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+)
+
+// MaxIdempotencyKeyLength - maximal length of Idempotency-Key value
+const MaxIdempotencyKeyLength = 64
+
+// newIdempotency - create idempotency key of request with fingerprint of request parameters
+// returns nil if key is empty (request is not idempotent)
+func newIdempotency(key string, params ...string) (*entity.Idempotency, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		return nil, ErrIdempotencyKeyInvalid
+	}
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e { // printable ASCII without spaces
+			return nil, ErrIdempotencyKeyInvalid
+		}
+	}
+
+	h := sha256.Sum256([]byte(strings.Join(params, "\x00")))
+	return &entity.Idempotency{
+		Key:  key,
+		Hash: hex.EncodeToString(h[:]),
+	}, nil
+}
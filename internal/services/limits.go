@@ -0,0 +1,96 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// limitsErrors - service errors for exceeded limits returned by entity
+var limitsErrors = map[error]error{
+	entity.ErrTransferLimitExceeded: ErrTransferLimitExceeded,
+	entity.ErrDailyAmountExceeded:   ErrDailyAmountLimitExceeded,
+	entity.ErrDailyCountExceeded:    ErrDailyCountLimitExceeded,
+}
+
+func (s Service) GetLimits(ctx context.Context, name entity.AccountName) (*LimitsEntity, error) {
+	a, err := s.limitsAccount(ctx, "GetLimits", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.limitsResult(ctx, "GetLimits", a)
+}
+
+func (s Service) SetLimits(ctx context.Context, name entity.AccountName, limits entity.Limits) (*LimitsEntity, error) {
+	a, err := s.limitsAccount(ctx, "SetLimits", name)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.SetLimits(ctx, limits); err == entity.ErrLimitsInvalid {
+		return nil, ErrLimitsValueError
+	} else if err != nil {
+		_ = s.logger.Log("service", "SetLimits", "func", "SetLimits()", "error", err)
+		return nil, ErrInService
+	}
+	return s.limitsResult(ctx, "SetLimits", a)
+}
+
+// checkLimits - check that transfer of amount from account "a" doesn't exceed limits of account
+// pending is amount and count of transfers of account which are not done yet
+// method is name of service method for logging
+func (s Service) checkLimits(ctx context.Context, method string, a *entity.Account, amount money.Amount, pending entity.Spending) error {
+	err := a.CheckLimits(ctx, amount, pending, time.Now())
+	if err == nil {
+		return nil
+	}
+	if e, ok := limitsErrors[err]; ok {
+		return e
+	}
+	_ = s.logger.Log("service", method, "func", "CheckLimits()", "error", err)
+	return ErrInService
+}
+
+// limitsAccount - find account of limits by name
+// method is name of service method for logging
+func (s Service) limitsAccount(ctx context.Context, method string, name entity.AccountName) (*entity.Account, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", method, "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", method, "func", "Find()", "error", err)
+		return nil, ErrLimitsNotFound
+	}
+	return a, nil
+}
+
+// limitsResult - load limits of account "a" with their usage today and convert them to service response
+func (s Service) limitsResult(ctx context.Context, method string, a *entity.Account) (*LimitsEntity, error) {
+	l, err := a.Limits(ctx)
+	if err != nil {
+		_ = s.logger.Log("service", method, "func", "Limits()", "error", err)
+		return nil, ErrInService
+	}
+	since := entity.LimitsDay(time.Now())
+	used, err := a.Spending(ctx, since)
+	if err != nil {
+		_ = s.logger.Log("service", method, "func", "Spending()", "error", err)
+		return nil, ErrInService
+	}
+	return &LimitsEntity{
+		Account:     a.Name,
+		Currency:    a.Currency,
+		MaxAmount:   l.MaxAmount.Trim(a.Precision()),
+		DailyAmount: l.DailyAmount.Trim(a.Precision()),
+		DailyCount:  l.DailyCount,
+		UsedAmount:  used.Amount.Trim(a.Precision()),
+		UsedCount:   used.Count,
+		Since:       since,
+	}, nil
+}
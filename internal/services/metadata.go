@@ -0,0 +1,97 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+)
+
+// MaxDescriptionLength - maximal length of description of payment in characters
+const MaxDescriptionLength = 255
+
+// MaxExternalRefLength - maximal length of external reference of payment
+const MaxExternalRefLength = 64
+
+// MaxTags - maximal count of tags of payment
+const MaxTags = 16
+
+// MaxTagLength - maximal length of key and value of tag in characters
+const MaxTagLength = 64
+
+// validateMetadata - check description, external reference and tags of payment
+// external reference is printable ASCII without spaces like idempotency key, tag keys can't be empty
+func validateMetadata(meta entity.Metadata) error {
+	if !utf8.ValidString(meta.Description) || utf8.RuneCountInString(meta.Description) > MaxDescriptionLength {
+		return ErrDescriptionInvalid
+	}
+	if len(meta.ExternalRef) > MaxExternalRefLength {
+		return ErrExternalRefInvalid
+	}
+	for _, c := range meta.ExternalRef {
+		if c < 0x21 || c > 0x7e {
+			return ErrExternalRefInvalid
+		}
+	}
+	if len(meta.Tags) > MaxTags {
+		return ErrTagsInvalid
+	}
+	for k, v := range meta.Tags {
+		if k == "" || !utf8.ValidString(k) || !utf8.ValidString(v) ||
+			utf8.RuneCountInString(k) > MaxTagLength || utf8.RuneCountInString(v) > MaxTagLength {
+			return ErrTagsInvalid
+		}
+	}
+	return nil
+}
+
+// metadataParams - metadata as parameters of request fingerprint of idempotency key
+// tags are encoded to JSON with sorted keys, so the same tags always give the same fingerprint
+func metadataParams(meta entity.Metadata) []string {
+	tags := ""
+	if len(meta.Tags) != 0 {
+		b, _ := json.Marshal(meta.Tags)
+		tags = string(b)
+	}
+	return []string{meta.Description, meta.ExternalRef, tags}
+}
+
+func (s Service) PaymentByExternalRef(ctx context.Context, name entity.AccountName, ref string) (*PaymentEntity, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "PaymentByExternalRef", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "PaymentByExternalRef", "func", "Find()", "error", err)
+		return nil, ErrPaymentRefAccountNotFound
+	}
+	if ref == "" || validateMetadata(entity.Metadata{ExternalRef: ref}) != nil {
+		return nil, ErrExternalRefInvalid
+	}
+
+	p, err := entity.NewPayment(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "PaymentByExternalRef", "func", "NewPayment()", "error", err)
+		return nil, ErrInService
+	}
+	switch err = p.FindByExternalRef(ctx, a, ref); err {
+	case nil:
+	case entity.ErrPaymentNotFound:
+		return nil, ErrPaymentRefNotFound
+	default:
+		_ = s.logger.Log("service", "PaymentByExternalRef", "func", "FindByExternalRef()", "error", err)
+		return nil, ErrInService
+	}
+
+	lst, err := convertPaymentDomainEntityToServiceEntity(ctx, s.db, []entity.Payment{*p}, a)
+	if err != nil {
+		_ = s.logger.Log("service", "PaymentByExternalRef", "func", "convert()", "error", err)
+		return nil, ErrInService
+	}
+	return &lst[0], nil
+}
@@ -0,0 +1,292 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
+)
+
+// scheduleErrors - service errors for errors of scheduled transfers returned by entity
+var scheduleErrors = map[error]error{
+	entity.ErrScheduleNotFound:   ErrScheduleNotFound,
+	entity.ErrScheduleNotActive:  ErrScheduleNotActive,
+	entity.ErrRecurrenceInvalid:  ErrScheduleRecurrenceError,
+	entity.ErrScheduleDayInvalid: ErrScheduleDayError,
+	entity.ErrRecipientNotFound:  ErrScheduleToNotFound,
+}
+
+func (s Service) ScheduleTransfer(ctx context.Context, name entity.AccountName, to entity.AccountName, amount money.Amount, runAt time.Time, recurrence string, day int) (*ScheduledTransferEntity, error) {
+	aFrom, err := entity.NewAccount(s.db)
+	aTo, _ := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "ScheduleTransfer", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if name == to {
+		return nil, ErrScheduleSelfToSelfError
+	}
+	if err = aFrom.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "ScheduleTransfer", "func", "Find()", "error", err)
+		return nil, ErrScheduleFromNotFound
+	}
+	if err = aTo.Find(ctx, to); err != nil {
+		_ = s.logger.Log("service", "ScheduleTransfer", "func", "Find()", "error", err)
+		return nil, ErrScheduleToNotFound
+	}
+	if aFrom.Currency != aTo.Currency && s.rates == nil {
+		return nil, ErrTransferCurrencyError
+	}
+	if err = aFrom.ValidateAmount(amount); err != nil {
+		return nil, ErrScheduleAmountError
+	}
+	// transfer without time is executed by the next run of scheduler
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	t, err := entity.NewSchedule(amount, runAt, recurrence, day)
+	if err != nil {
+		return nil, scheduleErrors[err]
+	}
+
+	id, err := aFrom.ScheduleTransfer(ctx, to, t)
+	if err != nil {
+		if e, ok := scheduleErrors[err]; ok {
+			return nil, e
+		}
+		_ = s.logger.Log("service", "ScheduleTransfer", "func", "ScheduleTransfer()", "error", err)
+		return nil, ErrInService
+	}
+	return s.scheduleResult(ctx, aFrom, id, false)
+}
+
+func (s Service) GetScheduledTransfer(ctx context.Context, name entity.AccountName, id int64) (*ScheduledTransferEntity, error) {
+	a, err := s.scheduleAccount(ctx, "GetScheduledTransfer", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduleResult(ctx, a, id, true)
+}
+
+func (s Service) ScheduledTransfersList(ctx context.Context, name entity.AccountName, offset, limit int64) ([]ScheduledTransferEntity, error) {
+	a, err := s.scheduleAccount(ctx, "ScheduledTransfersList", name)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, ErrScheduleOffsetLimitError
+	}
+
+	lst, err := a.ScheduledTransfers(ctx, offset, limit)
+	if err != nil {
+		_ = s.logger.Log("service", "ScheduledTransfersList", "func", "ScheduledTransfers()", "error", err)
+		return nil, ErrInService
+	}
+	res := make([]ScheduledTransferEntity, 0, len(lst))
+	for _, t := range lst {
+		te, err := s.newScheduledTransferEntity(ctx, a, t)
+		if err != nil {
+			_ = s.logger.Log("service", "ScheduledTransfersList", "func", "Get()", "error", err)
+			return nil, ErrInService
+		}
+		res = append(res, *te)
+	}
+	return res, nil
+}
+
+func (s Service) UpdateScheduledTransfer(ctx context.Context, name entity.AccountName, id int64, amount money.Amount, runAt time.Time, recurrence string, day int) (*ScheduledTransferEntity, error) {
+	a, err := s.scheduleAccount(ctx, "UpdateScheduledTransfer", name)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := a.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		if e, ok := scheduleErrors[err]; ok {
+			return nil, e
+		}
+		_ = s.logger.Log("service", "UpdateScheduledTransfer", "func", "GetScheduledTransfer()", "error", err)
+		return nil, ErrInService
+	}
+
+	// values which are not set are kept
+	if amount.IsZero() {
+		amount = cur.Amount.Trim(a.Precision())
+	} else if err = a.ValidateAmount(amount); err != nil {
+		return nil, ErrScheduleAmountError
+	}
+	if runAt.IsZero() {
+		runAt = cur.NextRunAt
+	}
+	if recurrence == "" {
+		recurrence = cur.Recurrence
+		if day == 0 {
+			day = cur.Day
+		}
+	}
+	t, err := entity.NewSchedule(amount, runAt, recurrence, day)
+	if err != nil {
+		return nil, scheduleErrors[err]
+	}
+	t.ID = id
+
+	if err = a.UpdateScheduledTransfer(ctx, t); err != nil {
+		if e, ok := scheduleErrors[err]; ok {
+			return nil, e
+		}
+		_ = s.logger.Log("service", "UpdateScheduledTransfer", "func", "UpdateScheduledTransfer()", "error", err)
+		return nil, ErrInService
+	}
+	return s.scheduleResult(ctx, a, id, false)
+}
+
+func (s Service) CancelScheduledTransfer(ctx context.Context, name entity.AccountName, id int64) (*ScheduledTransferEntity, error) {
+	a, err := s.scheduleAccount(ctx, "CancelScheduledTransfer", name)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.CancelScheduledTransfer(ctx, id); err != nil {
+		if e, ok := scheduleErrors[err]; ok {
+			return nil, e
+		}
+		_ = s.logger.Log("service", "CancelScheduledTransfer", "func", "CancelScheduledTransfer()", "error", err)
+		return nil, ErrInService
+	}
+	return s.scheduleResult(ctx, a, id, false)
+}
+
+// ExecuteScheduledTransfers - execute every scheduled transfer planned not later than now
+// transfer is executed by Transfer with idempotency key of scheduled transfer and its planned time,
+// so transfer executed before restart of wallet but not recorded yet is not repeated: the original payment is recorded.
+// Failed transfer is recorded with its error and is not repeated. Transfers failed because of internal error are
+// left for the next run. Returning count of recorded executions
+func (s Service) ExecuteScheduledTransfers(ctx context.Context) (int64, error) {
+	now := time.Now()
+	due, err := entity.DueScheduledTransfers(ctx, s.db, now, MaxScheduledTransfersPerRun)
+	if err != nil {
+		_ = s.logger.Log("service", "ExecuteScheduledTransfers", "func", "DueScheduledTransfers()", "error", err)
+		return 0, ErrInService
+	}
+
+	var n int64
+	for _, t := range due {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+		paymentID, err := s.executeScheduledTransfer(ctx, t)
+		if err == ErrInService {
+			continue
+		}
+		switch e := entity.CompleteScheduledTransfer(ctx, s.db, t, int64(paymentID), err, now); e {
+		case nil:
+			n++
+		case entity.ErrScheduleExecuted:
+			// recorded by another instance of wallet
+		default:
+			_ = s.logger.Log("service", "ExecuteScheduledTransfers", "func", "CompleteScheduledTransfer()", "error", e)
+		}
+	}
+	return n, nil
+}
+
+// executeScheduledTransfer - execute transfer of scheduled transfer t planned at t.NextRunAt
+// returning id of payment or error of service
+func (s Service) executeScheduledTransfer(ctx context.Context, t entity.ScheduledTransfer) (entity.ID, error) {
+	from, err := entity.NewAccount(s.db)
+	to, _ := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "ExecuteScheduledTransfers", "func", "NewAccount()", "error", err)
+		return 0, ErrInService
+	}
+	if err = from.Get(ctx, entity.AccountID(t.AccountID)); err != nil {
+		return 0, ErrTransferFromNotFound
+	}
+	if err = to.Get(ctx, entity.AccountID(t.ToID)); err != nil {
+		return 0, ErrTransferToNotFound
+	}
+
+	key := fmt.Sprintf("scheduled-%d-%d", t.ID, t.NextRunAt.Unix())
+	p, err := s.Transfer(ctx, from.Name, to.Name, t.Amount.Trim(from.Precision()), entity.Metadata{}, key)
+	if err != nil {
+		return 0, err
+	}
+	return p.ID, nil
+}
+
+// scheduleAccount - find account of scheduled transfers by name
+// method is name of service method for logging
+func (s Service) scheduleAccount(ctx context.Context, method string, name entity.AccountName) (*entity.Account, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", method, "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", method, "func", "Find()", "error", err)
+		return nil, ErrScheduleFromNotFound
+	}
+	return a, nil
+}
+
+// scheduleResult - load scheduled transfer of account "a" and convert it to service response
+// if executions is set results of all executions of transfer are added to response
+func (s Service) scheduleResult(ctx context.Context, a *entity.Account, id int64, executions bool) (*ScheduledTransferEntity, error) {
+	t, err := a.GetScheduledTransfer(ctx, id)
+	if err == entity.ErrScheduleNotFound {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		_ = s.logger.Log("service", "ScheduledTransfer", "func", "GetScheduledTransfer()", "error", err)
+		return nil, ErrInService
+	}
+	te, err := s.newScheduledTransferEntity(ctx, a, t)
+	if err != nil {
+		_ = s.logger.Log("service", "ScheduledTransfer", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	if !executions {
+		return te, nil
+	}
+
+	lst, err := a.ScheduleExecutions(ctx, id, 0, -1)
+	if err != nil {
+		_ = s.logger.Log("service", "ScheduledTransfer", "func", "ScheduleExecutions()", "error", err)
+		return nil, ErrInService
+	}
+	te.Executions = make([]ScheduleExecutionEntity, 0, len(lst))
+	for _, e := range lst {
+		te.Executions = append(te.Executions, ScheduleExecutionEntity{
+			RunAt:     e.RunAt.UTC(),
+			PaymentID: entity.ID(e.PaymentID),
+			Error:     e.Error,
+			Date:      e.Date,
+		})
+	}
+	return te, nil
+}
+
+// newScheduledTransferEntity - convert scheduled transfer of account "a" to service response
+func (s Service) newScheduledTransferEntity(ctx context.Context, a *entity.Account, t entity.ScheduledTransfer) (*ScheduledTransferEntity, error) {
+	to, err := entity.NewAccount(s.db)
+	if err == nil {
+		err = to.Get(ctx, entity.AccountID(t.ToID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduledTransferEntity{
+		ID:         t.ID,
+		Account:    a.Name,
+		ToAccount:  to.Name,
+		Amount:     t.Amount.Trim(a.Precision()),
+		Currency:   a.Currency,
+		Recurrence: t.Recurrence,
+		Day:        t.Day,
+		NextRunAt:  t.NextRunAt.UTC(),
+		Status:     t.Status,
+	}, nil
+}
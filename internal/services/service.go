@@ -6,45 +6,136 @@ package services
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
 type Services interface {
 
-	// CreateAccount -  create new wallet account with name
-	CreateAccount(ctx context.Context, name entity.AccountName) error
+	// CreateAccount -  create new wallet account with name in currency (ISO-4217 code)
+	// if currency is empty entity.DefaultCurrency is used
+	// if initialDeposit is not zero it is deposited to new account, account is not created if deposit fails
+	CreateAccount(ctx context.Context, name entity.AccountName, currency string, initialDeposit money.Amount) (entity.AccountName, error)
 
 	// Deposit - deposit amount of currency to the wallet account.
-	Deposit(ctx context.Context, name entity.AccountName, amount float64) error
+	// meta is description, external reference and tags of payment, external reference is unique for the account
+	// if idempotencyKey is not empty, retry of request with the same key returns the original result
+	Deposit(ctx context.Context, name entity.AccountName, amount money.Amount, meta entity.Metadata, idempotencyKey string) (money.Amount, error)
 
 	// Transfer - send amount of currency between two wallet accounts.
-	Transfer(ctx context.Context, from entity.AccountName, to entity.AccountName, amount float64) error
+	// meta is description, external reference and tags of payment, external reference is unique for account "from"
+	// if idempotencyKey is not empty, retry of request with the same key returns the original result
+	Transfer(ctx context.Context, from entity.AccountName, to entity.AccountName, amount money.Amount, meta entity.Metadata, idempotencyKey string) (*PaymentEntity, error)
 
-	// PaymentsList - list of payments of the account.
+	// PaymentByExternalRef - payment created by the wallet account with external reference ref
+	// (deposit to the account or transfer from it)
+	PaymentByExternalRef(ctx context.Context, name entity.AccountName, ref string) (*PaymentEntity, error)
+
+	// BatchTransfer - execute transfers of legs atomically: either all of them are done or none
+	// returning ids of payments in order of legs. If batch is rejected error is *BatchError with index of failed leg
+	BatchTransfer(ctx context.Context, legs []TransferLeg) ([]entity.ID, error)
+
+	// Withdraw - move amount of currency out of the wallet account to external counterparty.
+	// if idempotencyKey is not empty, retry of request with the same key returns the original result
+	Withdraw(ctx context.Context, name entity.AccountName, amount money.Amount, counterparty string, idempotencyKey string) (*PaymentEntity, error)
+
+	// Reverse - create payment which returns amount of payment with id back to payer.
+	// if amount is zero the whole not reversed rest of payment is returned
+	// if force is set payment is reversed even if recipient has no enough money
+	Reverse(ctx context.Context, id entity.ID, amount money.Amount, force bool) (*PaymentEntity, error)
+
+	// Hold - reserve amount of currency of the wallet account for transfer to account "to" for ttl.
+	// if ttl is zero DefaultHoldTTL is used
+	Hold(ctx context.Context, name entity.AccountName, to entity.AccountName, amount money.Amount, ttl time.Duration) (*HoldEntity, error)
+
+	// Capture - turn hold into transfer. If amount is zero the whole hold is transferred, else the rest is released
+	Capture(ctx context.Context, name entity.AccountName, holdID int64, amount money.Amount) (*PaymentEntity, error)
+
+	// Release - release hold, reserved amount becomes available
+	Release(ctx context.Context, name entity.AccountName, holdID int64) (*HoldEntity, error)
+
+	// ExpireHolds - release all holds with expired ttl. Called periodically by background worker
+	ExpireHolds(ctx context.Context) (int64, error)
+
+	// ScheduleTransfer - plan transfer of amount to account "to" at runAt once or repeatedly.
+	// recurrence is once, daily, weekly or monthly, day is day of month of monthly transfer.
+	// if runAt is zero transfer is executed by the next run of scheduler
+	ScheduleTransfer(ctx context.Context, name entity.AccountName, to entity.AccountName, amount money.Amount, runAt time.Time, recurrence string, day int) (*ScheduledTransferEntity, error)
+
+	// GetScheduledTransfer - scheduled transfer of the account with results of its executions
+	GetScheduledTransfer(ctx context.Context, name entity.AccountName, id int64) (*ScheduledTransferEntity, error)
+
+	// ScheduledTransfersList - list of scheduled transfers of the account.
+	// if limit =-1 returns all scheduled transfers
+	ScheduledTransfersList(ctx context.Context, name entity.AccountName, offset, limit int64) ([]ScheduledTransferEntity, error)
+
+	// UpdateScheduledTransfer - change active scheduled transfer. Zero values are not changed
+	UpdateScheduledTransfer(ctx context.Context, name entity.AccountName, id int64, amount money.Amount, runAt time.Time, recurrence string, day int) (*ScheduledTransferEntity, error)
+
+	// CancelScheduledTransfer - stop executions of scheduled transfer
+	CancelScheduledTransfer(ctx context.Context, name entity.AccountName, id int64) (*ScheduledTransferEntity, error)
+
+	// ExecuteScheduledTransfers - execute scheduled transfers which time has come. Called periodically by background worker
+	ExecuteScheduledTransfers(ctx context.Context) (int64, error)
+
+	// PaymentsList - list of payments of the account which meet conditions of filter.
 	// if set offset and limit > 0 returns slice
 	// if limit =-1 returns all payments
-	PaymentsList(ctx context.Context, name entity.AccountName, offset, limit int64) ([]entity.Payment, error)
+	// if cursor is set, list begins after the last payment of page which returned it. Cursor of the next page is returned
+	PaymentsList(ctx context.Context, name entity.AccountName, filter PaymentsFilter, cursor string, offset, limit int64) ([]entity.Payment, string, error)
 
 	// AllPaymentsList - list of all payments
 	// if set offset and limit > 0 returns slice
 	// if limit =-1 returns all payments
-	AllPaymentsList(ctx context.Context, offset, limit int64) ([]entity.Payment, error)
+	// if cursor is set, list begins after the last payment of page which returned it. Cursor of the next page is returned
+	AllPaymentsList(ctx context.Context, cursor string, offset, limit int64) ([]entity.Payment, string, error)
+
+	// FreezeAccount - suspend the wallet account, frozen account can't take part in payments
+	FreezeAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error)
+
+	// UnfreezeAccount - make frozen wallet account active again
+	UnfreezeAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error)
+
+	// CloseAccount - close the wallet account with zero balance. Account and its payments are kept
+	CloseAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error)
+
+	// SetCreditLimit - set overdraft limit of the wallet account, balance of account can go down to -limit.
+	// zero limit doesn't allow negative balance
+	SetCreditLimit(ctx context.Context, name entity.AccountName, limit money.Amount) (*AccountEntity, error)
+
+	// GetLimits - spending limits of the wallet account and their usage today
+	GetLimits(ctx context.Context, name entity.AccountName) (*LimitsEntity, error)
+
+	// SetLimits - set spending limits of the wallet account. Zero value of limit means the limit is not set
+	SetLimits(ctx context.Context, name entity.AccountName, limits entity.Limits) (*LimitsEntity, error)
 
 	// AccountsList - List of all registered accounts
 	// if set offset and limit > 0 returns slice
 	// if limit =-1 returns all accounts
-	AccountsList(ctx context.Context, offset, limit int64) ([]entity.Account, error)
+	// if cursor is set, list begins after the last account of page which returned it. Cursor of the next page is returned
+	AccountsList(ctx context.Context, cursor string, offset, limit int64) ([]entity.Account, string, error)
 }
 
 type Service struct {
 	logger log.Logger
+	// source of exchange rates for cross-currency transfers
+	// if nil, transfers are allowed only between accounts with the same currency
+	rates entity.ExchangeRateProvider
+	// fees of transfers, if nil transfers are free
+	fees *entity.FeeSchedule
+	// storage of accounts and payments
+	db entity.Driver
 }
 
-func NewService(logger log.Logger) Service {
+func NewService(logger log.Logger, rates entity.ExchangeRateProvider, fees *entity.FeeSchedule, db entity.Driver) Service {
 	s := Service{
 		logger,
+		rates,
+		fees,
+		db,
 	}
 	return s
 }
@@ -55,6 +146,7 @@ var (
 	ErrCreateAccountInvalidName = errors.New("invalid name format")
 	ErrCreateAccount            = errors.New("create account error")
 	ErrCreateAccountDuplicate   = errors.New("create account error: duplicate name")
+	ErrCreateAccountCurrency    = errors.New("create account error: unsupported currency")
 
 	ErrDepositNotFound    = errors.New("account not found")
 	ErrDepositAmountError = errors.New("error in amount value")
@@ -64,15 +156,113 @@ var (
 	ErrTransferAmountError     = errors.New("error in amount value")
 	ErrTransferNoMoneyError    = errors.New("no enough money")
 	ErrTransferSelfToSelfError = errors.New("disable transfer to self account")
+	ErrTransferCurrencyError   = errors.New("accounts have different currencies")
+	ErrTransferNoExchangeRate  = errors.New("exchange rate not available")
+
+	ErrTransferLimitExceeded    = errors.New("transfer amount exceeds limit")
+	ErrDailyAmountLimitExceeded = errors.New("daily amount limit of transfers exceeded")
+	ErrDailyCountLimitExceeded  = errors.New("daily count limit of transfers exceeded")
+
+	ErrBatchEmpty    = errors.New("batch has no transfers")
+	ErrBatchTooLarge = errors.New("batch has too many transfers")
+
+	ErrWithdrawNotFound          = errors.New("account not found")
+	ErrWithdrawAmountError       = errors.New("error in amount value")
+	ErrWithdrawNoMoneyError      = errors.New("no enough money")
+	ErrWithdrawCounterpartyError = errors.New("error in counterparty value")
+
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldFromNotFound    = errors.New("account not found")
+	ErrHoldToNotFound      = errors.New("to account not found")
+	ErrHoldAmountError     = errors.New("error in amount value")
+	ErrHoldTTLError        = errors.New("error in ttl value")
+	ErrHoldNoMoneyError    = errors.New("no enough money")
+	ErrHoldSelfToSelfError = errors.New("disable hold for self account")
+	ErrHoldNotActive       = errors.New("hold is not active")
+	ErrHoldExceedsAmount   = errors.New("capture amount exceeds hold amount")
+
+	ErrScheduleNotFound         = errors.New("scheduled transfer not found")
+	ErrScheduleFromNotFound     = errors.New("account not found")
+	ErrScheduleToNotFound       = errors.New("to account not found")
+	ErrScheduleAmountError      = errors.New("error in amount value")
+	ErrScheduleSelfToSelfError  = errors.New("disable transfer to self account")
+	ErrScheduleRecurrenceError  = errors.New("error in recurrence value")
+	ErrScheduleDayError         = errors.New("error in day value")
+	ErrScheduleNotActive        = errors.New("scheduled transfer is not active")
+	ErrScheduleOffsetLimitError = errors.New("error in offset, limit params")
+
+	ErrReverseNotFound        = errors.New("payment not found")
+	ErrReverseAccountNotFound = errors.New("account of payment not found")
+	ErrReverseAmountError     = errors.New("error in amount value")
+	ErrReverseExceedsAmount   = errors.New("reversal amount exceeds payment amount")
+	ErrReverseNotAllowed      = errors.New("payment can't be reversed")
+	ErrReverseNoMoney         = errors.New("recipient has no enough money")
+	ErrReverseForbidden       = errors.New("force reversal is allowed only for administrator")
+
+	ErrAccountFrozen          = errors.New("account is frozen")
+	ErrAccountClosed          = errors.New("account is closed")
+	ErrToAccountFrozen        = errors.New("to account is frozen")
+	ErrToAccountClosed        = errors.New("to account is closed")
+	ErrAccountStatusNotFound  = errors.New("account not found")
+	ErrAccountNotEmpty        = errors.New("account balance is not zero")
+	ErrAccountStatusForbidden = errors.New("changing of account status is allowed only for administrator")
+
+	ErrCreditLimitNotFound   = errors.New("account not found")
+	ErrCreditLimitValueError = errors.New("error in credit limit value")
+	ErrCreditLimitForbidden  = errors.New("changing of credit limit is allowed only for administrator")
 
-	ErrPaymentsListNotFound         = errors.New("account not found")
-	ErrPaymentsListOffsetLimitError = errors.New("error in offset, limit params")
+	ErrLimitsNotFound   = errors.New("account not found")
+	ErrLimitsValueError = errors.New("error in limits value")
+	ErrLimitsForbidden  = errors.New("limits are available only for administrator")
+
+	ErrIdempotencyKeyInvalid = errors.New("invalid idempotency key")
+	ErrIdempotencyConflict   = errors.New("idempotency key was already used for another request")
+
+	ErrDescriptionInvalid        = errors.New("invalid description")
+	ErrExternalRefInvalid        = errors.New("invalid external reference")
+	ErrTagsInvalid               = errors.New("invalid tags")
+	ErrExternalRefDuplicate      = errors.New("external reference was already used")
+	ErrPaymentRefAccountNotFound = errors.New("account not found")
+	ErrPaymentRefNotFound        = errors.New("payment not found")
+
+	ErrPaymentsListNotFound             = errors.New("account not found")
+	ErrPaymentsListOffsetLimitError     = errors.New("error in offset, limit params")
+	ErrPaymentsListFilterError          = errors.New("error in filter params")
+	ErrPaymentsListCounterpartyNotFound = errors.New("counterparty account not found")
 
 	ErrAccountsListOffsetLimitError = errors.New("error in offset, limit params")
+
+	ErrListCursorError       = errors.New("invalid cursor")
+	ErrListCursorOffsetError = errors.New("offset can't be used with cursor")
 )
 
-func (s Service) CreateAccount(ctx context.Context, name entity.AccountName) (entity.AccountName, error) {
-	a, err := entity.NewAccount()
+// accountStatusErrors - service errors for payments with frozen or closed accounts
+var accountStatusErrors = map[error]error{
+	entity.ErrAccountFrozen:   ErrAccountFrozen,
+	entity.ErrAccountClosed:   ErrAccountClosed,
+	entity.ErrRecipientFrozen: ErrToAccountFrozen,
+	entity.ErrRecipientClosed: ErrToAccountClosed,
+}
+
+// transferErrors - service errors for errors of transfer returned by entity
+var transferErrors = map[error]error{
+	entity.ErrNoMoney:              ErrTransferNoMoneyError,
+	entity.ErrRecipientNotFound:    ErrTransferToNotFound,
+	entity.ErrAccountFrozen:        ErrAccountFrozen,
+	entity.ErrAccountClosed:        ErrAccountClosed,
+	entity.ErrRecipientFrozen:      ErrToAccountFrozen,
+	entity.ErrRecipientClosed:      ErrToAccountClosed,
+	entity.ErrExchangeRateNotFound: ErrTransferNoExchangeRate,
+	entity.ErrConvertedAmountZero:  ErrTransferAmountError,
+	entity.ErrExternalRefDuplicate: ErrExternalRefDuplicate,
+
+	entity.ErrTransferLimitExceeded: ErrTransferLimitExceeded,
+	entity.ErrDailyAmountExceeded:   ErrDailyAmountLimitExceeded,
+	entity.ErrDailyCountExceeded:    ErrDailyCountLimitExceeded,
+}
+
+func (s Service) CreateAccount(ctx context.Context, name entity.AccountName, currency string, initialDeposit money.Amount) (entity.AccountName, error) {
+	a, err := entity.NewAccount(s.db)
 	if err != nil {
 		_ = s.logger.Log("service", "CreateAccount", "func", "NewAccount()", "error", err)
 		return "", ErrInService
@@ -81,40 +271,124 @@ func (s Service) CreateAccount(ctx context.Context, name entity.AccountName) (en
 		_ = s.logger.Log("service", "CreateAccount", "func", "Validate()", "error", err)
 		return "", ErrCreateAccountInvalidName
 	}
-	if err = a.Register(name); err != nil {
-		_ = s.logger.Log("service", "CreateAccount", "func", "Register()", "error", err)
-		if a.Find(name) != err { // duplicate
+	if currency != "" {
+		if err = entity.ValidateCurrency(currency); err != nil {
+			_ = s.logger.Log("service", "CreateAccount", "func", "ValidateCurrency()", "error", err)
+			return "", ErrCreateAccountCurrency
+		}
+	}
+
+	// account and its initial deposit are created in one unit of work, failed deposit doesn't leave empty account
+	var registerErr error
+	err = entity.InUnitOfWork(ctx, s.db, func(db entity.Storage) error {
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			return err
+		}
+		if registerErr = a.Register(ctx, name, currency); registerErr != nil {
+			return registerErr
+		}
+		if initialDeposit.IsZero() {
+			return nil
+		}
+		if err = a.ValidateAmount(initialDeposit); err != nil {
+			return ErrDepositAmountError
+		}
+		_, err = a.Deposit(ctx, initialDeposit, entity.Metadata{}, nil)
+		return err
+	})
+	switch {
+	case err == nil:
+	case registerErr != nil:
+		_ = s.logger.Log("service", "CreateAccount", "func", "Register()", "error", registerErr)
+		if a.Find(ctx, name) == nil {
 			return "", ErrCreateAccountDuplicate
 		}
 		return "", ErrCreateAccount
+	case err == ErrDepositAmountError:
+		return "", err
+	default:
+		_ = s.logger.Log("service", "CreateAccount", "func", "Deposit()", "error", err)
+		return "", ErrInService
 	}
-	return a.Name, nil
+	return name, nil
 }
 
-func (s Service) Deposit(ctx context.Context, name entity.AccountName, amount float64) (float64, error) {
-	a, err := entity.NewAccount()
+func (s Service) Deposit(ctx context.Context, name entity.AccountName, amount money.Amount, meta entity.Metadata, idempotencyKey string) (money.Amount, error) {
+	idem, err := newIdempotency(idempotencyKey, append([]string{"deposit", string(name), amount.Trim(0).String()},
+		metadataParams(meta)...)...)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	a, err := entity.NewAccount(s.db)
 	if err != nil {
 		_ = s.logger.Log("service", "Deposit", "func", "NewAccount()", "error", err)
-		return 0, ErrInService
+		return money.Amount{}, ErrInService
 	}
-	if err = a.Find(name); err != nil {
+	if err = a.Find(ctx, name); err != nil {
 		_ = s.logger.Log("service", "Deposit", "func", "Find()", "error", err)
-		return 0, ErrDepositNotFound
+		return money.Amount{}, ErrDepositNotFound
 	}
-	if amount <= 0 {
-		return 0, ErrDepositAmountError
+
+	// retry of already executed request
+	paymentID, err := a.FindIdempotent(ctx, idem)
+	if err != nil {
+		return s.depositReplay(ctx, a, paymentID, err)
 	}
-	if _, err = a.Deposit(amount); err != nil {
+
+	if err = a.ValidateAmount(amount); err != nil {
+		return money.Amount{}, ErrDepositAmountError
+	}
+	if err = validateMetadata(meta); err != nil {
+		return money.Amount{}, err
+	}
+	paymentID, err = a.Deposit(ctx, amount, meta, idem)
+	switch err {
+	case nil:
+	case entity.ErrIdempotencyReplay, entity.ErrIdempotencyConflict:
+		return s.depositReplay(ctx, a, paymentID, err)
+	case entity.ErrExternalRefDuplicate:
+		return money.Amount{}, ErrExternalRefDuplicate
+	case entity.ErrAccountFrozen, entity.ErrAccountClosed:
+		return money.Amount{}, accountStatusErrors[err]
+	default:
 		_ = s.logger.Log("service", "Deposit", "func", "Deposit()", "error", err)
-		return 0, ErrInService
+		return money.Amount{}, ErrInService
 	}
 	return a.Balance, nil
 
 }
 
-func (s Service) Transfer(ctx context.Context, from entity.AccountName, to entity.AccountName, amount float64) (*PaymentEntity, error) {
-	aFrom, err := entity.NewAccount()
-	aTo, _ := entity.NewAccount()
+// depositReplay - return result of deposit executed early with the same idempotency key
+// err is the error returned by idempotency check
+func (s Service) depositReplay(ctx context.Context, a *entity.Account, paymentID int64, err error) (money.Amount, error) {
+	switch err {
+	case entity.ErrIdempotencyReplay:
+		p, err := entity.NewPayment(s.db)
+		if err == nil {
+			err = p.Get(ctx, entity.ID(paymentID))
+		}
+		if err != nil {
+			_ = s.logger.Log("service", "Deposit", "func", "Get()", "error", err)
+			return money.Amount{}, ErrInService
+		}
+		return p.ToBalance.Trim(a.Precision()), nil
+	case entity.ErrIdempotencyConflict:
+		return money.Amount{}, ErrIdempotencyConflict
+	default:
+		_ = s.logger.Log("service", "Deposit", "func", "FindIdempotent()", "error", err)
+		return money.Amount{}, ErrInService
+	}
+}
+
+func (s Service) Transfer(ctx context.Context, from entity.AccountName, to entity.AccountName, amount money.Amount, meta entity.Metadata, idempotencyKey string) (*PaymentEntity, error) {
+	idem, err := newIdempotency(idempotencyKey, append([]string{"transfer", string(from), string(to), amount.Trim(0).String()},
+		metadataParams(meta)...)...)
+	if err != nil {
+		return nil, err
+	}
+	aFrom, err := entity.NewAccount(s.db)
+	aTo, _ := entity.NewAccount(s.db)
 	if err != nil {
 		_ = s.logger.Log("service", "Transfer", "func", "NewAccount()", "error", err)
 		return nil, ErrInService
@@ -122,159 +396,749 @@ func (s Service) Transfer(ctx context.Context, from entity.AccountName, to entit
 	if from == to {
 		return nil, ErrTransferSelfToSelfError
 	}
-	if err = aFrom.Find(from); err != nil {
+	if err = aFrom.Find(ctx, from); err != nil {
 		_ = s.logger.Log("service", "Transfer", "func", "Find()", "error", err)
 		return nil, ErrTransferFromNotFound
 	}
-	if err = aTo.Find(to); err != nil {
+	if err = aTo.Find(ctx, to); err != nil {
 		_ = s.logger.Log("service", "Transfer", "func", "Find()", "error", err)
 		return nil, ErrTransferToNotFound
 	}
-	if amount <= 0 {
+
+	// retry of already executed request
+	paymentID, err := aFrom.FindIdempotent(ctx, idem)
+	if err != nil {
+		return s.transferReplay(ctx, aFrom, aTo, paymentID, err)
+	}
+
+	if aFrom.Currency != aTo.Currency && s.rates == nil {
+		return nil, ErrTransferCurrencyError
+	}
+	if err = aFrom.ValidateAmount(amount); err != nil {
 		return nil, ErrTransferAmountError
 	}
+	if err = validateMetadata(meta); err != nil {
+		return nil, err
+	}
 
-	if aFrom.Balance < amount {
+	available, err := aFrom.Available()
+	if err != nil {
+		_ = s.logger.Log("service", "Transfer", "func", "Available()", "error", err)
+		return nil, ErrInService
+	}
+	if available.Cmp(amount) < 0 {
 		return nil, ErrTransferNoMoneyError
 	}
+	if err = s.checkLimits(ctx, "Transfer", aFrom, amount, entity.Spending{}); err != nil {
+		return nil, err
+	}
 
-	if _, err = aFrom.Transfer(to, amount); err != nil {
+	paymentID, err = aFrom.Transfer(ctx, to, amount, s.rates, s.fees, meta, idem)
+	switch err {
+	case nil:
+	case entity.ErrIdempotencyReplay, entity.ErrIdempotencyConflict:
+		return s.transferReplay(ctx, aFrom, aTo, paymentID, err)
+	default:
+		if e, ok := transferErrors[err]; ok {
+			return nil, e
+		}
 		_ = s.logger.Log("service", "Transfer", "func", "Transfer()", "error", err)
 		return nil, ErrInService
 	}
-	return &PaymentEntity{
-		Account:   aFrom.Name,
-		ToAccount: aTo.Name,
-		Amount:    amount,
-		Direction: PaymentDirectionOutgoing,
+
+	return s.transferResult(ctx, aFrom, aTo, paymentID)
+}
+
+// transferReplay - return result of transfer executed early with the same idempotency key
+// err is the error returned by idempotency check
+func (s Service) transferReplay(ctx context.Context, from, to *entity.Account, paymentID int64, err error) (*PaymentEntity, error) {
+	switch err {
+	case entity.ErrIdempotencyReplay:
+		return s.transferResult(ctx, from, to, paymentID)
+	case entity.ErrIdempotencyConflict:
+		return nil, ErrIdempotencyConflict
+	default:
+		_ = s.logger.Log("service", "Transfer", "func", "FindIdempotent()", "error", err)
+		return nil, ErrInService
+	}
+}
+
+// transferResult - load payment and convert it to service response
+func (s Service) transferResult(ctx context.Context, from, to *entity.Account, paymentID int64) (*PaymentEntity, error) {
+	p, err := entity.NewPayment(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Transfer", "func", "NewPayment()", "error", err)
+		return nil, ErrInService
+	}
+	if err = p.Get(ctx, entity.ID(paymentID)); err != nil {
+		_ = s.logger.Log("service", "Transfer", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	pe, err := newPaymentEntity(*p, from, to, PaymentDirectionOutgoing)
+	if err != nil {
+		_ = s.logger.Log("service", "Transfer", "func", "newPaymentEntity()", "error", err)
+		return nil, ErrInService
+	}
+	return &pe, nil
+}
+
+func (s Service) Withdraw(ctx context.Context, name entity.AccountName, amount money.Amount, counterparty string, idempotencyKey string) (*PaymentEntity, error) {
+	idem, err := newIdempotency(idempotencyKey, "withdraw", string(name), amount.Trim(0).String(), counterparty)
+	if err != nil {
+		return nil, err
+	}
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "Find()", "error", err)
+		return nil, ErrWithdrawNotFound
+	}
+
+	// retry of already executed request
+	paymentID, err := a.FindIdempotent(ctx, idem)
+	if err != nil {
+		return s.withdrawReplay(ctx, a, paymentID, err)
+	}
+
+	if counterparty == "" || len(counterparty) > MaxCounterpartyLength {
+		return nil, ErrWithdrawCounterpartyError
+	}
+	if err = a.ValidateAmount(amount); err != nil {
+		return nil, ErrWithdrawAmountError
+	}
+	available, err := a.Available()
+	if err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "Available()", "error", err)
+		return nil, ErrInService
+	}
+	if available.Cmp(amount) < 0 {
+		return nil, ErrWithdrawNoMoneyError
+	}
+	if err = s.checkLimits(ctx, "Withdraw", a, amount, entity.Spending{}); err != nil {
+		return nil, err
+	}
+
+	paymentID, err = a.Withdraw(ctx, amount, counterparty, idem)
+	switch err {
+	case nil:
+	case entity.ErrIdempotencyReplay, entity.ErrIdempotencyConflict:
+		return s.withdrawReplay(ctx, a, paymentID, err)
+	case entity.ErrNoMoney:
+		return nil, ErrWithdrawNoMoneyError
+	case entity.ErrAccountFrozen, entity.ErrAccountClosed, entity.ErrRecipientFrozen, entity.ErrRecipientClosed:
+		return nil, accountStatusErrors[err]
+	case entity.ErrTransferLimitExceeded, entity.ErrDailyAmountExceeded, entity.ErrDailyCountExceeded:
+		return nil, limitsErrors[err]
+	default:
+		_ = s.logger.Log("service", "Withdraw", "func", "Withdraw()", "error", err)
+		return nil, ErrInService
+	}
+
+	return s.withdrawResult(ctx, a, paymentID)
+}
+
+// withdrawReplay - return result of withdrawal executed early with the same idempotency key
+// err is the error returned by idempotency check
+func (s Service) withdrawReplay(ctx context.Context, a *entity.Account, paymentID int64, err error) (*PaymentEntity, error) {
+	switch err {
+	case entity.ErrIdempotencyReplay:
+		return s.withdrawResult(ctx, a, paymentID)
+	case entity.ErrIdempotencyConflict:
+		return nil, ErrIdempotencyConflict
+	default:
+		_ = s.logger.Log("service", "Withdraw", "func", "FindIdempotent()", "error", err)
+		return nil, ErrInService
+	}
+}
+
+// withdrawResult - load payment and convert it to service response
+func (s Service) withdrawResult(ctx context.Context, a *entity.Account, paymentID int64) (*PaymentEntity, error) {
+	p, err := entity.NewPayment(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "NewPayment()", "error", err)
+		return nil, ErrInService
+	}
+	if err = p.Get(ctx, entity.ID(paymentID)); err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	pe, err := newPaymentEntity(*p, a, nil, PaymentDirectionWithdrawal)
+	if err != nil {
+		_ = s.logger.Log("service", "Withdraw", "func", "newPaymentEntity()", "error", err)
+		return nil, ErrInService
+	}
+	return &pe, nil
+}
+
+func (s Service) Hold(ctx context.Context, name entity.AccountName, to entity.AccountName, amount money.Amount, ttl time.Duration) (*HoldEntity, error) {
+	aFrom, err := entity.NewAccount(s.db)
+	aTo, _ := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if name == to {
+		return nil, ErrHoldSelfToSelfError
+	}
+	if ttl == 0 {
+		ttl = DefaultHoldTTL
+	}
+	if ttl < 0 || ttl > MaxHoldTTL {
+		return nil, ErrHoldTTLError
+	}
+	if err = aFrom.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "Find()", "error", err)
+		return nil, ErrHoldFromNotFound
+	}
+	if err = aTo.Find(ctx, to); err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "Find()", "error", err)
+		return nil, ErrHoldToNotFound
+	}
+	if aFrom.Currency != aTo.Currency && s.rates == nil {
+		return nil, ErrTransferCurrencyError
+	}
+	if err = aFrom.ValidateAmount(amount); err != nil {
+		return nil, ErrHoldAmountError
+	}
+	available, err := aFrom.Available()
+	if err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "Available()", "error", err)
+		return nil, ErrInService
+	}
+	if available.Cmp(amount) < 0 {
+		return nil, ErrHoldNoMoneyError
+	}
+	if err = s.checkLimits(ctx, "Hold", aFrom, amount, entity.Spending{}); err != nil {
+		return nil, err
+	}
+
+	holdID, err := aFrom.Hold(ctx, to, amount, ttl)
+	switch err {
+	case nil:
+	case entity.ErrNoMoney:
+		return nil, ErrHoldNoMoneyError
+	case entity.ErrRecipientNotFound:
+		return nil, ErrHoldToNotFound
+	case entity.ErrAccountFrozen, entity.ErrAccountClosed, entity.ErrRecipientFrozen, entity.ErrRecipientClosed:
+		return nil, accountStatusErrors[err]
+	default:
+		_ = s.logger.Log("service", "Hold", "func", "Hold()", "error", err)
+		return nil, ErrInService
+	}
+	return s.holdResult(ctx, aFrom, holdID)
+}
+
+func (s Service) Capture(ctx context.Context, name entity.AccountName, holdID int64, amount money.Amount) (*PaymentEntity, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Capture", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "Capture", "func", "Find()", "error", err)
+		return nil, ErrHoldFromNotFound
+	}
+	if !amount.IsZero() {
+		if err = a.ValidateAmount(amount); err != nil {
+			return nil, ErrHoldAmountError
+		}
+	}
+	// captured amount becomes transfer, so limits are checked again with transfers done since hold was created
+	// errors of hold are returned by Capture
+	if h, err := a.GetHold(ctx, holdID); err == nil && h.Status == entity.HoldStatusActive {
+		captured := amount
+		if captured.IsZero() {
+			captured = h.Amount
+		}
+		if err = s.checkLimits(ctx, "Capture", a, captured, entity.Spending{}); err != nil {
+			return nil, err
+		}
+	}
+
+	paymentID, err := a.Capture(ctx, holdID, amount, s.rates, s.fees)
+	switch err {
+	case nil:
+	case entity.ErrHoldNotFound:
+		return nil, ErrHoldNotFound
+	case entity.ErrHoldNotActive:
+		return nil, ErrHoldNotActive
+	case entity.ErrHoldExceedsAmount:
+		return nil, ErrHoldExceedsAmount
+	case entity.ErrNoMoney:
+		return nil, ErrHoldNoMoneyError
+	case entity.ErrRecipientNotFound:
+		return nil, ErrHoldToNotFound
+	case entity.ErrAccountFrozen, entity.ErrAccountClosed, entity.ErrRecipientFrozen, entity.ErrRecipientClosed:
+		return nil, accountStatusErrors[err]
+	case entity.ErrTransferLimitExceeded, entity.ErrDailyAmountExceeded, entity.ErrDailyCountExceeded:
+		return nil, limitsErrors[err]
+	case entity.ErrCurrencyMismatch:
+		return nil, ErrTransferCurrencyError
+	case entity.ErrExchangeRateNotFound:
+		return nil, ErrTransferNoExchangeRate
+	case entity.ErrConvertedAmountZero:
+		return nil, ErrHoldAmountError
+	default:
+		_ = s.logger.Log("service", "Capture", "func", "Capture()", "error", err)
+		return nil, ErrInService
+	}
+
+	// recipient of payment
+	h, err := a.GetHold(ctx, holdID)
+	to, _ := entity.NewAccount(s.db)
+	if err == nil {
+		err = to.Get(ctx, entity.AccountID(h.ToID))
+	}
+	if err != nil {
+		_ = s.logger.Log("service", "Capture", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	return s.transferResult(ctx, a, to, paymentID)
+}
+
+func (s Service) Release(ctx context.Context, name entity.AccountName, holdID int64) (*HoldEntity, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Release", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "Release", "func", "Find()", "error", err)
+		return nil, ErrHoldFromNotFound
+	}
+
+	switch err = a.Release(ctx, holdID); err {
+	case nil:
+	case entity.ErrHoldNotFound:
+		return nil, ErrHoldNotFound
+	case entity.ErrHoldNotActive:
+		return nil, ErrHoldNotActive
+	default:
+		_ = s.logger.Log("service", "Release", "func", "Release()", "error", err)
+		return nil, ErrInService
+	}
+	return s.holdResult(ctx, a, holdID)
+}
+
+func (s Service) ExpireHolds(ctx context.Context) (int64, error) {
+	n, err := entity.ExpireHolds(ctx, s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "ExpireHolds", "func", "ExpireHolds()", "error", err)
+		return 0, ErrInService
+	}
+	return n, nil
+}
+
+// holdResult - load hold and convert it to service response
+func (s Service) holdResult(ctx context.Context, a *entity.Account, holdID int64) (*HoldEntity, error) {
+	h, err := a.GetHold(ctx, holdID)
+	if err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "GetHold()", "error", err)
+		return nil, ErrInService
+	}
+	to, err := entity.NewAccount(s.db)
+	if err == nil {
+		err = to.Get(ctx, entity.AccountID(h.ToID))
+	}
+	if err != nil {
+		_ = s.logger.Log("service", "Hold", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	return &HoldEntity{
+		ID:        h.ID,
+		Account:   a.Name,
+		ToAccount: to.Name,
+		Amount:    h.Amount.Trim(a.Precision()),
+		Currency:  a.Currency,
+		Status:    h.Status,
+		ExpiresAt: h.ExpiresAt,
+		PaymentID: h.PaymentID,
 	}, nil
 }
 
-func (s Service) PaymentsList(ctx context.Context, name entity.AccountName, offset, limit int64) ([]PaymentEntity, error) {
-	a, err := entity.NewAccount()
+func (s Service) Reverse(ctx context.Context, id entity.ID, amount money.Amount, force bool) (*PaymentEntity, error) {
+	p, err := entity.NewPayment(s.db)
 	if err != nil {
-		_ = s.logger.Log("service", "PaymentsList", "func", "NewAccount()", "error", err)
+		_ = s.logger.Log("service", "Reverse", "func", "NewPayment()", "error", err)
+		return nil, ErrInService
+	}
+	if err = p.Get(ctx, id); err != nil {
+		_ = s.logger.Log("service", "Reverse", "func", "Get()", "error", err)
+		return nil, ErrReverseNotFound
+	}
+
+	// amount is in payer currency of payment
+	payer, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "Reverse", "func", "NewAccount()", "error", err)
+		return nil, ErrInService
+	}
+	if err = payer.Get(ctx, entity.AccountID(p.FromID)); err != nil {
+		_ = s.logger.Log("service", "Reverse", "func", "Get()", "error", err)
+		return nil, ErrReverseAccountNotFound
+	}
+	if !amount.IsZero() {
+		if err = payer.ValidateAmount(amount); err != nil {
+			return nil, ErrReverseAmountError
+		}
+	}
+
+	reversalID, err := p.Reverse(ctx, amount, force)
+	switch err {
+	case nil:
+	case entity.ErrReversalNotAllowed:
+		return nil, ErrReverseNotAllowed
+	case entity.ErrReversalExceedsAmount:
+		return nil, ErrReverseExceedsAmount
+	case entity.ErrNoMoney:
+		return nil, ErrReverseNoMoney
+	case entity.ErrAccountNotFound:
+		return nil, ErrReverseAccountNotFound
+	case entity.ErrAccountFrozen, entity.ErrAccountClosed, entity.ErrRecipientFrozen, entity.ErrRecipientClosed:
+		return nil, accountStatusErrors[err]
+	case entity.ErrConvertedAmountZero:
+		return nil, ErrReverseAmountError
+	default:
+		_ = s.logger.Log("service", "Reverse", "func", "Reverse()", "error", err)
+		return nil, ErrInService
+	}
+
+	r, err := entity.NewPayment(s.db)
+	if err == nil {
+		err = r.Get(ctx, entity.ID(reversalID))
+	}
+	if err != nil {
+		_ = s.logger.Log("service", "Reverse", "func", "Get()", "error", err)
+		return nil, ErrInService
+	}
+	lst, err := convertPaymentDomainEntityToServiceEntity(ctx, s.db, []entity.Payment{*r}, nil)
+	if err != nil {
+		_ = s.logger.Log("service", "Reverse", "func", "convertPaymentDomainEntityToServiceEntity()", "error", err)
 		return nil, ErrInService
 	}
-	if err = a.Find(name); err != nil {
+	return &lst[0], nil
+}
+
+func (s Service) PaymentsList(ctx context.Context, name entity.AccountName, filter PaymentsFilter, cursor string, offset, limit int64) ([]PaymentEntity, string, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "PaymentsList", "func", "NewAccount()", "error", err)
+		return nil, "", ErrInService
+	}
+	if err = a.Find(ctx, name); err != nil {
 		_ = s.logger.Log("service", "PaymentsList", "func", "Find()", "error", err)
-		return nil, ErrPaymentsListNotFound
+		return nil, "", ErrPaymentsListNotFound
 	}
 	if offset < 0 {
-		return nil, ErrPaymentsListOffsetLimitError
+		return nil, "", ErrPaymentsListOffsetLimitError
+	}
+	after, err := decodeCursor(cursorPayments, cursor, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := s.paymentFilter(ctx, filter)
+	if err != nil {
+		return nil, "", err
 	}
 
-	lst, err := entity.PaymentsList(a, offset, limit)
+	lst, err := entity.PaymentsList(ctx, s.db, a, f, entity.ID(after), offset, limit)
 	if err != nil {
 		_ = s.logger.Log("service", "PaymentsList", "func", "List()", "error", err)
-		return nil, ErrInService
+		return nil, "", ErrInService
 	}
 
-	return convertPaymentDomainEntityToServiceEntity(lst, a)
+	res, err := convertPaymentDomainEntityToServiceEntity(ctx, s.db, lst, a)
+	if err != nil {
+		return nil, "", err
+	}
+	return res, nextPaymentsCursor(lst, limit), nil
 }
 
-func (s Service) AllPaymentsList(ctx context.Context, offset, limit int64) ([]PaymentEntity, error) {
+func (s Service) AllPaymentsList(ctx context.Context, cursor string, offset, limit int64) ([]PaymentEntity, string, error) {
 	if offset < 0 {
-		return nil, ErrPaymentsListOffsetLimitError
+		return nil, "", ErrPaymentsListOffsetLimitError
+	}
+	after, err := decodeCursor(cursorPayments, cursor, offset)
+	if err != nil {
+		return nil, "", err
 	}
 
-	lst, err := entity.PaymentsList(nil, offset, limit)
+	lst, err := entity.PaymentsList(ctx, s.db, nil, entity.PaymentFilter{}, entity.ID(after), offset, limit)
 	if err != nil {
 		_ = s.logger.Log("service", "AllPaymentsList", "func", "List()", "error", err)
+		return nil, "", ErrInService
+	}
+
+	res, err := convertPaymentDomainEntityToServiceEntity(ctx, s.db, lst, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return res, nextPaymentsCursor(lst, limit), nil
+}
+
+// nextPaymentsCursor - cursor of the page of payments list following lst
+func nextPaymentsCursor(lst []entity.Payment, limit int64) string {
+	if len(lst) == 0 {
+		return ""
+	}
+	return encodeCursor(cursorPayments, int64(lst[len(lst)-1].ID), len(lst), limit)
+}
+
+// paymentFilter - check conditions of payments list and convert them to filter of entity
+// counterparty is found by name
+func (s Service) paymentFilter(ctx context.Context, filter PaymentsFilter) (entity.PaymentFilter, error) {
+	switch filter.Direction {
+	case "", entity.PaymentDirectionIncoming, entity.PaymentDirectionOutgoing,
+		entity.PaymentDirectionDeposit, entity.PaymentDirectionWithdrawal:
+	default:
+		return entity.PaymentFilter{}, ErrPaymentsListFilterError
+	}
+	switch {
+	case filter.MinAmount.Sign() < 0 || filter.MaxAmount.Sign() < 0,
+		!filter.MaxAmount.IsZero() && filter.MinAmount.Cmp(filter.MaxAmount) > 0,
+		!filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until):
+		return entity.PaymentFilter{}, ErrPaymentsListFilterError
+	}
+
+	f := entity.PaymentFilter{
+		Since:     filter.Since,
+		Until:     filter.Until,
+		MinAmount: filter.MinAmount,
+		MaxAmount: filter.MaxAmount,
+		Direction: filter.Direction,
+	}
+	if filter.Counterparty != "" {
+		c, err := entity.NewAccount(s.db)
+		if err != nil {
+			_ = s.logger.Log("service", "PaymentsList", "func", "NewAccount()", "error", err)
+			return entity.PaymentFilter{}, ErrInService
+		}
+		if err = c.Find(ctx, filter.Counterparty); err != nil {
+			_ = s.logger.Log("service", "PaymentsList", "func", "Find()", "error", err)
+			return entity.PaymentFilter{}, ErrPaymentsListCounterpartyNotFound
+		}
+		f.CounterpartyID = int64(c.ID)
+	}
+	return f, nil
+}
+
+func (s Service) FreezeAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error) {
+	return s.setAccountStatus(ctx, "FreezeAccount", name, (*entity.Account).Freeze)
+}
+
+func (s Service) UnfreezeAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error) {
+	return s.setAccountStatus(ctx, "UnfreezeAccount", name, (*entity.Account).Unfreeze)
+}
+
+func (s Service) CloseAccount(ctx context.Context, name entity.AccountName) (*AccountEntity, error) {
+	return s.setAccountStatus(ctx, "CloseAccount", name, (*entity.Account).Close)
+}
+
+// setAccountStatus - find account and change its status with function "set"
+// method is name of service method for logging
+func (s Service) setAccountStatus(ctx context.Context, method string, name entity.AccountName, set func(*entity.Account, context.Context) error) (*AccountEntity, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", method, "func", "NewAccount()", "error", err)
 		return nil, ErrInService
 	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", method, "func", "Find()", "error", err)
+		return nil, ErrAccountStatusNotFound
+	}
 
-	return convertPaymentDomainEntityToServiceEntity(lst, nil)
+	switch err = set(a, ctx); err {
+	case nil:
+	case entity.ErrAccountClosed:
+		return nil, ErrAccountClosed
+	case entity.ErrAccountNotEmpty:
+		return nil, ErrAccountNotEmpty
+	default:
+		_ = s.logger.Log("service", method, "func", "SetStatus()", "error", err)
+		return nil, ErrInService
+	}
+
+	lst, _ := convertAccountDomainEntityToServiceEntity([]entity.Account{*a})
+	return &lst[0], nil
 }
 
-func (s Service) AccountsList(ctx context.Context, offset, limit int64) ([]AccountEntity, error) {
-	a, err := entity.NewAccount()
+func (s Service) SetCreditLimit(ctx context.Context, name entity.AccountName, limit money.Amount) (*AccountEntity, error) {
+	a, err := entity.NewAccount(s.db)
 	if err != nil {
-		_ = s.logger.Log("service", "AccountsList", "func", "NewAccount()", "error", err)
+		_ = s.logger.Log("service", "SetCreditLimit", "func", "NewAccount()", "error", err)
 		return nil, ErrInService
 	}
+	if err = a.Find(ctx, name); err != nil {
+		_ = s.logger.Log("service", "SetCreditLimit", "func", "Find()", "error", err)
+		return nil, ErrCreditLimitNotFound
+	}
+
+	switch err = a.SetCreditLimit(ctx, limit); err {
+	case nil:
+	case entity.ErrCreditLimitInvalid:
+		return nil, ErrCreditLimitValueError
+	case entity.ErrAccountClosed:
+		return nil, ErrAccountClosed
+	default:
+		_ = s.logger.Log("service", "SetCreditLimit", "func", "SetCreditLimit()", "error", err)
+		return nil, ErrInService
+	}
+
+	lst, _ := convertAccountDomainEntityToServiceEntity([]entity.Account{*a})
+	return &lst[0], nil
+}
+
+func (s Service) AccountsList(ctx context.Context, cursor string, offset, limit int64) ([]AccountEntity, string, error) {
+	a, err := entity.NewAccount(s.db)
+	if err != nil {
+		_ = s.logger.Log("service", "AccountsList", "func", "NewAccount()", "error", err)
+		return nil, "", ErrInService
+	}
 
 	if offset < 0 {
-		return nil, ErrAccountsListOffsetLimitError
+		return nil, "", ErrAccountsListOffsetLimitError
+	}
+	after, err := decodeCursor(cursorAccounts, cursor, offset)
+	if err != nil {
+		return nil, "", err
 	}
 
-	lst, err := a.List(offset, limit)
+	lst, err := a.List(ctx, entity.AccountID(after), offset, limit)
 	if err != nil {
 		_ = s.logger.Log("service", "AccountsList", "func", "List()", "error", err)
-		return nil, ErrInService
+		return nil, "", ErrInService
 	}
 
-	return convertAccountDomainEntityToServiceEntity(lst)
+	res, err := convertAccountDomainEntityToServiceEntity(lst)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(lst) > 0 {
+		next = encodeCursor(cursorAccounts, int64(lst[len(lst)-1].ID), len(lst), limit)
+	}
+	return res, next, nil
 }
 
 // convert response
 func convertAccountDomainEntityToServiceEntity(lst []entity.Account) ([]AccountEntity, error) {
 	var res []AccountEntity
 	for _, a := range lst {
+		available, err := a.Available()
+		if err != nil {
+			return nil, err
+		}
 		res = append(res, AccountEntity{
-			Id:       a.Name,
-			Balance:  a.Balance,
-			Currency: a.Currency,
+			Id:               a.Name,
+			Balance:          a.Balance,
+			AvailableBalance: a.AvailableBalance,
+			CreditLimit:      a.CreditLimit,
+			Available:        available,
+			Currency:         a.Currency,
+			Status:           a.Status,
 		})
 	}
 	return res, nil
 }
 
 // convert response
-func convertPaymentDomainEntityToServiceEntity(lst []entity.Payment, a *entity.Account) ([]PaymentEntity, error) {
+func convertPaymentDomainEntityToServiceEntity(ctx context.Context, db entity.Storage, lst []entity.Payment, a *entity.Account) ([]PaymentEntity, error) {
 	var res []PaymentEntity
 	for _, p := range lst {
 		// for each payment
-		toAccount, err := entity.NewAccount()
+		toAccount, err := entity.NewAccount(db)
 		if err != nil {
 			return nil, err
 		}
-		if err = toAccount.Get(entity.AccountID(p.ToID)); err != nil {
+		if err = toAccount.Get(ctx, entity.AccountID(p.ToID)); err != nil {
 			toAccount = nil
 		}
 
-		fromAccount, err := entity.NewAccount()
+		fromAccount, err := entity.NewAccount(db)
 		if err != nil {
 			return nil, err
 		}
-		if err = fromAccount.Get(entity.AccountID(p.FromID)); err != nil {
+		if err = fromAccount.Get(ctx, entity.AccountID(p.FromID)); err != nil {
 			fromAccount = nil
 		}
 
-		to := entity.AccountName("")
-		if toAccount != nil {
-			to = toAccount.Name
+		// if defined account for witch getting payments, else all payments will be "outgoing"
+		direction := PaymentDirectionOutgoing
+		if a != nil && entity.AccountID(p.FromID) != a.ID {
+			direction = PaymentDirectionIncoming
 		}
-		from := entity.AccountName("")
-		if fromAccount != nil {
-			from = fromAccount.Name
+		pe, err := newPaymentEntity(p, fromAccount, toAccount, direction)
+		if err != nil {
+			return nil, err
 		}
-		if a != nil {
-			// if defined account for witch getting payments, else all payments will be "outgoing"
-
-			// define direction
-			direction := PaymentDirectionOutgoing
-			if entity.AccountID(p.FromID) != a.ID {
-				from, to = to, from
-				direction = PaymentDirectionIncoming
-			}
+		res = append(res, pe)
+	}
+	return res, nil
+}
 
-			res = append(res, PaymentEntity{
-				Account:   from,
-				ToAccount: to,
-				Amount:    p.Amount,
-				Direction: direction,
-			})
-		} else {
-
-			// all payments will be "outgoing"
-			res = append(res, PaymentEntity{
-				Account:   from,
-				ToAccount: to,
-				Amount:    p.Amount,
-				Direction: PaymentDirectionOutgoing,
-			})
+// newPaymentEntity - convert payment to service response
+// from and to can be nil if account not exists (for deposits from is always nil, for withdrawals to is always nil)
+// for incoming payments "account" is recipient and "to_account" is payer
+func newPaymentEntity(p entity.Payment, from, to *entity.Account, direction string) (PaymentEntity, error) {
+	// payer of deposit and recipient of withdrawal is cash system account, it is not shown to clients
+	if from != nil && from.System {
+		from = nil
+	}
+	if to != nil && to.System {
+		to = nil
+	}
+	if p.Kind == entity.PaymentKindWithdrawal {
+		direction = PaymentDirectionWithdrawal
+	}
+	pe := PaymentEntity{
+		ID:           p.ID,
+		ReversalOf:   p.ReversalOf,
+		Kind:         p.Kind,
+		Amount:       p.Amount,
+		ToAmount:     p.ToAmount,
+		Counterparty: p.Counterparty,
+		Description:  p.Description,
+		Direction:    direction,
+	}
+	// external reference and tags belong to account which created payment: payer of transfer, recipient of deposit
+	if direction != PaymentDirectionIncoming || p.Kind == entity.PaymentKindDeposit {
+		pe.ExternalRef, pe.Tags = p.ExternalRef, p.Tags
+	}
+	if to != nil {
+		pe.ToAccount = to.Name
+		pe.ToCurrency = to.Currency
+		pe.ToAmount = p.ToAmount.Trim(to.Precision())
+		// deposits have no payer, amount is in recipient currency
+		pe.Currency = to.Currency
+		pe.Amount = p.Amount.Trim(to.Precision())
+	}
+	if from != nil {
+		pe.Account = from.Name
+		pe.Currency = from.Currency
+		pe.Amount = p.Amount.Trim(from.Precision())
+		if to == nil {
+			// withdrawal, amount goes out in payer currency
+			pe.ToAmount = p.ToAmount.Trim(from.Precision())
+		}
+		// fee is paid by payer, it is not shown to recipient
+		if !p.Fee.IsZero() && direction != PaymentDirectionIncoming {
+			total, err := p.Amount.Add(p.Fee)
+			if err != nil {
+				return PaymentEntity{}, err
+			}
+			fee, total := p.Fee.Trim(from.Precision()), total.Trim(from.Precision())
+			pe.Fee, pe.Total = &fee, &total
 		}
 	}
-	return res, nil
+	if !p.RateDate.IsZero() {
+		rate, rateDate := p.Rate, p.RateDate
+		pe.Rate, pe.RateDate = &rate, &rateDate
+	}
+	if direction == PaymentDirectionIncoming {
+		pe.Account, pe.ToAccount = pe.ToAccount, pe.Account
+	}
+	return pe, nil
 }
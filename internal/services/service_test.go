@@ -2,21 +2,55 @@
 // yuriyiovkov@gmail.com; telegram: @yuriyiovkov
 
 // Important!
-// for successfully test passed run it from directory where file .env is
+// for testing with PostgreSQL set DB_DRIVER=postgresql and run it from directory where file .env is
 // or set up the ENV in your OS environment
 
 package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/internal/domain/wallet/exchange"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/memory"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/postgresql"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/sqlite"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
-var logger log.Logger
+var (
+	logger log.Logger
+	// db - storage used by tests
+	db entity.Driver
+)
+
+// tests are run with memory driver unless DB_DRIVER environment is set
+// run them with DB_DRIVER=postgresql for testing with database
+func TestMain(m *testing.M) {
+	cfg := repository.ConfigFromEnv()
+	if os.Getenv("DB_DRIVER") == "" {
+		cfg.Driver = "memory"
+	}
+	// test database is created from scratch by migrations
+	cfg.AutoMigrate = true
+	var err error
+	if db, err = repository.Open(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = db.Close()
+	os.Exit(code)
+}
 
 func initLogger() {
 	logger = log.NewLogfmtLogger(os.Stderr)
@@ -29,133 +63,1103 @@ func Test_CreateAccount(t *testing.T) {
 	const invalidAccName = "Testing987ha9 871hgaf98782"
 	initLogger()
 
-	srv := NewService(logger)
+	srv := NewService(logger, nil, nil, db)
 	t.Run("with valid account name", func(t *testing.T) {
-		if _, err := srv.CreateAccount(context.Background(), validAccName); err != nil {
+		if _, err := srv.CreateAccount(context.Background(), validAccName, "", money.Amount{}); err != nil {
 			t.Error(err)
 		}
 		// delete account
-		a, err := entity.NewAccount()
+		a, err := entity.NewAccount(db)
 		if err != nil {
 			t.Fatal(err)
 		}
-		_ = a.Find(validAccName)
-		_ = a.Delete()
+		_ = a.Find(context.Background(), validAccName)
+		_ = a.Delete(context.Background())
 	})
 	t.Run("with invalid account name", func(t *testing.T) {
-		if _, err := srv.CreateAccount(context.Background(), invalidAccName); err == nil {
+		if _, err := srv.CreateAccount(context.Background(), invalidAccName, "", money.Amount{}); err == nil {
 			t.Error("wait error but not")
 		}
 	})
+	t.Run("with initial deposit", func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := srv.CreateAccount(ctx, validAccName, "", money.MustParse("12.5")); err != nil {
+			t.Fatal(err)
+		}
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = a.Find(ctx, validAccName); err != nil {
+			t.Fatal(err)
+		}
+		if a.Balance.Cmp(money.MustParse("12.5")) != 0 {
+			t.Errorf("balance = %s, want 12.5", a.Balance)
+		}
+		if _, err := srv.CreateAccount(ctx, validAccName, "", money.Amount{}); err != ErrCreateAccountDuplicate {
+			t.Errorf("CreateAccount() of existing account error = %v, want %v", err, ErrCreateAccountDuplicate)
+		}
+		_ = a.Delete(ctx)
+	})
+	t.Run("with invalid initial deposit", func(t *testing.T) {
+		ctx := context.Background()
+		// usd has 2 digits after decimal point, deposit fails after account is registered
+		if _, err := srv.CreateAccount(ctx, validAccName, "usd", money.MustParse("1.005")); err != ErrDepositAmountError {
+			t.Errorf("CreateAccount() error = %v, want %v", err, ErrDepositAmountError)
+		}
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = a.Find(ctx, validAccName); err == nil {
+			t.Error("account is created although initial deposit failed")
+			_ = a.Delete(ctx)
+		}
+	})
 }
 
 func Test_Deposit(t *testing.T) {
 	const validAccName = "Testing987ha9871hgaf98782"
 	initLogger()
 
-	a, err := entity.NewAccount()
+	a, err := entity.NewAccount(db)
 	if err != nil {
 		t.Fatal(err)
 	}
-	srv := NewService(logger)
+	srv := NewService(logger, nil, nil, db)
 
 	t.Run("create temp account", func(t *testing.T) {
-		if err := a.Register(validAccName); err != nil {
+		if err := a.Register(context.Background(), validAccName, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("run service ", func(t *testing.T) {
-		if _, err := srv.Deposit(context.Background(), validAccName, 3); err != nil {
+		if _, err := srv.Deposit(context.Background(), validAccName, money.New(3, 0), entity.Metadata{}, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("delete temp account", func(t *testing.T) {
-		if err := a.Delete(); err != nil {
+		if err := a.Delete(context.Background()); err != nil {
 			t.Error(err)
 		}
 	})
 }
 
+func Test_DepositIdempotency(t *testing.T) {
+	const validAccName = "Testing987ha9871hgaf98idem"
+	const key = "b1c2d3e4-deposit"
+	initLogger()
+
+	a, err := entity.NewAccount(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+
+	t.Run("create temp account", func(t *testing.T) {
+		if err := a.Register(context.Background(), validAccName, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("deposit and retry", func(t *testing.T) {
+		b1, err := srv.Deposit(ctx, validAccName, money.New(3, 0), entity.Metadata{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b2, err := srv.Deposit(ctx, validAccName, money.MustParse("3.00"), entity.Metadata{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b1.Cmp(b2) != 0 {
+			t.Errorf("replay must return original balance %s, got %s", b1, b2)
+		}
+		lst, _, err := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, "", 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != 1 {
+			t.Errorf("list length must be 1, got: %d", len(lst))
+		}
+	})
+	t.Run("retry with other amount", func(t *testing.T) {
+		if _, err := srv.Deposit(ctx, validAccName, money.New(4, 0), entity.Metadata{}, key); err != ErrIdempotencyConflict {
+			t.Errorf("wait %v, got %v", ErrIdempotencyConflict, err)
+		}
+	})
+	t.Run("delete temp account", func(t *testing.T) {
+		if err := a.Delete(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func Test_IdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantNil bool
+		wantErr bool
+	}{
+		{"empty key", "", true, false},
+		{"uuid key", "0b5d2a8e-5b0c-4c1a-9a57-4a1c2c9b7e11", false, false},
+		{"key with space", "my key", true, true},
+		{"too long key", "0123456789012345678901234567890123456789012345678901234567890123456789", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idem, err := newIdempotency(tt.key, "deposit", "wallet", "1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newIdempotency() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (idem == nil) != tt.wantNil {
+				t.Errorf("newIdempotency() = %v, wantNil %v", idem, tt.wantNil)
+			}
+		})
+	}
+
+	i1, _ := newIdempotency("key", "deposit", "wallet", "1")
+	i2, _ := newIdempotency("key", "deposit", "wallet", "2")
+	if i1.Hash == i2.Hash {
+		t.Error("requests with different parameters must have different hashes")
+	}
+}
+
 func Test_Transfer(t *testing.T) {
 	const validAccName1 = "Testing987ha9871hgaf987821"
 	const validAccName2 = "Testing987ha9871hgaf987822"
 	initLogger()
 
-	a1, err := entity.NewAccount()
+	a1, err := entity.NewAccount(db)
 	if err != nil {
 		t.Fatal(err)
 	}
-	a2, err := entity.NewAccount()
-	srv := NewService(logger)
+	a2, err := entity.NewAccount(db)
+	srv := NewService(logger, nil, nil, db)
 
 	t.Run("create temp account 1", func(t *testing.T) {
-		if err := a1.Register(validAccName1); err != nil {
+		if err := a1.Register(context.Background(), validAccName1, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("create temp account 2", func(t *testing.T) {
-		if err := a2.Register(validAccName2); err != nil {
+		if err := a2.Register(context.Background(), validAccName2, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("deposit ", func(t *testing.T) {
-		if _, err := srv.Deposit(context.Background(), validAccName1, 2); err != nil {
+		if _, err := srv.Deposit(context.Background(), validAccName1, money.New(2, 0), entity.Metadata{}, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("run service ", func(t *testing.T) {
-		if _, err := srv.Transfer(context.Background(), validAccName1, validAccName2, 1); err != nil {
+		if _, err := srv.Transfer(context.Background(), validAccName1, validAccName2, money.New(1, 0), entity.Metadata{}, ""); err != nil {
 			t.Error(err)
 		}
-		_ = a1.Find(validAccName1)
-		_ = a2.Find(validAccName2)
-		if a1.Balance != a2.Balance && a2.Balance != 1 {
-			t.Errorf("balanse must be 1 and 1, have: %f, %f", a1.Balance, a2.Balance)
+		_ = a1.Find(context.Background(), validAccName1)
+		_ = a2.Find(context.Background(), validAccName2)
+		if a1.Balance.Cmp(a2.Balance) != 0 && a2.Balance.Cmp(money.New(1, 0)) != 0 {
+			t.Errorf("balanse must be 1 and 1, have: %s, %s", a1.Balance, a2.Balance)
+		}
+	})
+	t.Run("amount out of range", func(t *testing.T) {
+		ctx := context.Background()
+		for _, amount := range []money.Amount{money.New(1<<63-1, 0), money.New(100000000000001, 2)} {
+			if _, err := srv.Transfer(ctx, validAccName1, validAccName2, amount, entity.Metadata{}, ""); err != ErrTransferAmountError {
+				t.Errorf("Transfer(%s) error = %v, want ErrTransferAmountError", amount, err)
+			}
+			if _, err := srv.Deposit(ctx, validAccName1, amount, entity.Metadata{}, ""); err != ErrDepositAmountError {
+				t.Errorf("Deposit(%s) error = %v, want ErrDepositAmountError", amount, err)
+			}
 		}
 	})
 
 	t.Run("delete temp accounts", func(t *testing.T) {
-		if err := a1.Delete(); err != nil {
+		if err := a1.Delete(context.Background()); err != nil {
 			t.Error(err)
 		}
-		if err := a2.Delete(); err != nil {
+		if err := a2.Delete(context.Background()); err != nil {
 			t.Error(err)
 		}
 	})
 }
 
+func Test_TransferCurrencyMismatch(t *testing.T) {
+	const usdAccName = "Testing987ha9871hgaf98usd"
+	const eurAccName = "Testing987ha9871hgaf98eur"
+	initLogger()
+
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+
+	t.Run("create accounts", func(t *testing.T) {
+		if _, err := srv.CreateAccount(ctx, usdAccName, "USD", money.Amount{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.CreateAccount(ctx, eurAccName, "eur", money.Amount{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Deposit(ctx, usdAccName, money.New(2, 0), entity.Metadata{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("unsupported currency", func(t *testing.T) {
+		if _, err := srv.CreateAccount(ctx, "Testing987ha9871hgaf98xxx", "xxx", money.Amount{}); err != ErrCreateAccountCurrency {
+			t.Errorf("wait %v, got %v", ErrCreateAccountCurrency, err)
+		}
+	})
+	t.Run("transfer between currencies", func(t *testing.T) {
+		if _, err := srv.Transfer(ctx, usdAccName, eurAccName, money.New(1, 0), entity.Metadata{}, ""); err != ErrTransferCurrencyError {
+			t.Errorf("wait %v, got %v", ErrTransferCurrencyError, err)
+		}
+	})
+
+	t.Run("delete temp accounts", func(t *testing.T) {
+		for _, n := range []entity.AccountName{usdAccName, eurAccName} {
+			a, err := entity.NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(context.Background(), n); err != nil {
+				t.Error(err)
+				continue
+			}
+			if err = a.Delete(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func Test_TransferCrossCurrency(t *testing.T) {
+	const usdAccName = "Testing987ha9871hgaf9xusd"
+	const eurAccName = "Testing987ha9871hgaf9xeur"
+	initLogger()
+
+	rates, err := exchange.NewStatic(entity.ExchangeRate{
+		From: "usd", To: "eur", Rate: money.MustParse("0.8187"), Date: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewService(logger, rates, nil, db)
+	ctx := context.Background()
+
+	t.Run("create accounts", func(t *testing.T) {
+		if _, err := srv.CreateAccount(ctx, usdAccName, "usd", money.Amount{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.CreateAccount(ctx, eurAccName, "eur", money.Amount{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Deposit(ctx, usdAccName, money.New(20, 0), entity.Metadata{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("transfer with conversion", func(t *testing.T) {
+		p, err := srv.Transfer(ctx, usdAccName, eurAccName, money.MustParse("10.00"), entity.Metadata{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ToAmount.String() != "8.19" || p.Rate == nil || p.Rate.String() != "0.8187" {
+			t.Errorf("wrong conversion: %+v", p)
+		}
+	})
+	t.Run("no exchange rate", func(t *testing.T) {
+		if _, err := srv.Transfer(ctx, eurAccName, usdAccName, money.New(1, 0), entity.Metadata{}, ""); err != ErrTransferNoExchangeRate {
+			t.Errorf("wait %v, got %v", ErrTransferNoExchangeRate, err)
+		}
+	})
+
+	t.Run("delete temp accounts", func(t *testing.T) {
+		for _, n := range []entity.AccountName{usdAccName, eurAccName} {
+			a, err := entity.NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(context.Background(), n); err != nil {
+				t.Error(err)
+				continue
+			}
+			if err = a.Delete(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func Test_TransferFee(t *testing.T) {
+	const fromAccName = "Testing987ha9871hgaf9feefrom"
+	const toAccName = "Testing987ha9871hgaf9feeto"
+	initLogger()
+
+	fees, err := entity.NewFeeSchedule([]entity.FeeRule{
+		{Currency: "usd", Flat: money.MustParse("0.30"), Percent: money.MustParse("1"), Max: money.MustParse("1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewService(logger, nil, fees, db)
+	ctx := context.Background()
+	var paymentID entity.ID
+
+	t.Run("create accounts", func(t *testing.T) {
+		if _, err := srv.CreateAccount(ctx, fromAccName, "usd", money.New(20, 0)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.CreateAccount(ctx, toAccName, "usd", money.Amount{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("transfer with fee", func(t *testing.T) {
+		p, err := srv.Transfer(ctx, fromAccName, toAccName, money.MustParse("10.00"), entity.Metadata{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paymentID = p.ID
+		if p.Fee == nil || p.Fee.String() != "0.40" || p.Total == nil || p.Total.String() != "10.40" {
+			t.Errorf("wrong fee: %+v", p)
+		}
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = a.Find(ctx, fromAccName)
+		if a.Balance.String() != "9.60" {
+			t.Errorf("payer balance = %s, want 9.60", a.Balance)
+		}
+		_ = a.Find(ctx, toAccName)
+		if a.Balance.String() != "10.00" {
+			t.Errorf("recipient balance = %s, want 10.00", a.Balance)
+		}
+
+		// fee is credited to revenue account in the same ledger transaction
+		pay, err := entity.NewPayment(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = pay.Get(ctx, p.ID); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := pay.Entries(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 3 || entries[0].Amount.Cmp(money.MustParse("-10.40")) != 0 ||
+			entries[2].Amount.Cmp(money.MustParse("0.40")) != 0 || entries[2].AccountID == pay.ToID {
+			t.Errorf("wrong ledger entries: %+v", entries)
+		}
+
+		lst, _, err := srv.PaymentsList(ctx, toAccName, PaymentsFilter{}, "", 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != 1 || lst[0].Fee != nil {
+			t.Errorf("fee is shown to recipient: %+v", lst)
+		}
+	})
+	t.Run("fee is not covered by balance", func(t *testing.T) {
+		if _, err := srv.Transfer(ctx, fromAccName, toAccName, money.MustParse("9.60"), entity.Metadata{}, ""); err != ErrTransferNoMoneyError {
+			t.Errorf("wait %v, got %v", ErrTransferNoMoneyError, err)
+		}
+	})
+	t.Run("reversal doesn't return fee", func(t *testing.T) {
+		if _, err := srv.Reverse(ctx, paymentID, money.Amount{}, false); err != nil {
+			t.Fatal(err)
+		}
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = a.Find(ctx, fromAccName)
+		if a.Balance.String() != "19.60" {
+			t.Errorf("payer balance = %s, want 19.60", a.Balance)
+		}
+	})
+	t.Run("capture charges the same fee as transfer", func(t *testing.T) {
+		h, err := srv.Hold(ctx, fromAccName, toAccName, money.MustParse("10.00"), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := srv.Capture(ctx, fromAccName, h.ID, money.Amount{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Fee == nil || p.Fee.String() != "0.40" || p.Total == nil || p.Total.String() != "10.40" {
+			t.Errorf("wrong fee of capture: %+v", p)
+		}
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = a.Find(ctx, fromAccName)
+		if a.Balance.String() != "9.20" {
+			t.Errorf("payer balance = %s, want 9.20", a.Balance)
+		}
+	})
+
+	t.Run("delete temp accounts", func(t *testing.T) {
+		for _, n := range []entity.AccountName{fromAccName, toAccName} {
+			a, err := entity.NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(context.Background(), n); err != nil {
+				t.Error(err)
+				continue
+			}
+			if err = a.Delete(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func Test_TransferMetadata(t *testing.T) {
+	const fromAccName = "Testing987ha9871hgafmeta1"
+	const toAccName = "Testing987ha9871hgafmeta2"
+	initLogger()
+
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+	for _, name := range []entity.AccountName{fromAccName, toAccName} {
+		a, err := entity.NewAccount(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = a.Register(ctx, name, ""); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = a.Delete(ctx) }()
+	}
+	if _, err := srv.Deposit(ctx, fromAccName, money.New(10, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	meta := entity.Metadata{Description: "invoice #7", ExternalRef: "inv-7", Tags: map[string]string{"order": "7"}}
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		for _, tt := range []struct {
+			meta entity.Metadata
+			want error
+		}{
+			{entity.Metadata{Description: strings.Repeat("d", MaxDescriptionLength+1)}, ErrDescriptionInvalid},
+			{entity.Metadata{ExternalRef: "inv 7"}, ErrExternalRefInvalid},
+			{entity.Metadata{ExternalRef: strings.Repeat("r", MaxExternalRefLength+1)}, ErrExternalRefInvalid},
+			{entity.Metadata{Tags: map[string]string{"": "7"}}, ErrTagsInvalid},
+			{entity.Metadata{Tags: map[string]string{"order": strings.Repeat("7", MaxTagLength+1)}}, ErrTagsInvalid},
+		} {
+			if _, err := srv.Transfer(ctx, fromAccName, toAccName, money.New(1, 0), tt.meta, ""); err != tt.want {
+				t.Errorf("Transfer() error = %v, want %v", err, tt.want)
+			}
+		}
+	})
+	t.Run("transfer with metadata", func(t *testing.T) {
+		p, err := srv.Transfer(ctx, fromAccName, toAccName, money.New(1, 0), meta, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Description != meta.Description || p.ExternalRef != meta.ExternalRef || p.Tags["order"] != "7" {
+			t.Errorf("payment metadata = %q, %q, %v", p.Description, p.ExternalRef, p.Tags)
+		}
+		if _, err = srv.Transfer(ctx, fromAccName, toAccName, money.New(1, 0), meta, ""); err != ErrExternalRefDuplicate {
+			t.Errorf("Transfer() with used reference error = %v, want %v", err, ErrExternalRefDuplicate)
+		}
+	})
+	t.Run("payment by external reference", func(t *testing.T) {
+		p, err := srv.PaymentByExternalRef(ctx, fromAccName, meta.ExternalRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Direction != PaymentDirectionOutgoing || p.ExternalRef != meta.ExternalRef {
+			t.Errorf("PaymentByExternalRef() = %+v", p)
+		}
+		if _, err = srv.PaymentByExternalRef(ctx, toAccName, meta.ExternalRef); err != ErrPaymentRefNotFound {
+			t.Errorf("PaymentByExternalRef() of recipient error = %v, want %v", err, ErrPaymentRefNotFound)
+		}
+	})
+	t.Run("recipient doesn't see reference and tags", func(t *testing.T) {
+		lst, _, err := srv.PaymentsList(ctx, toAccName, PaymentsFilter{}, "", 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != 1 || lst[0].Description != meta.Description || lst[0].ExternalRef != "" || lst[0].Tags != nil {
+			t.Errorf("payments of recipient = %+v", lst)
+		}
+	})
+}
+
+func Test_Withdraw(t *testing.T) {
+	const validAccName = "Testing987ha9871hgaf98783"
+	initLogger()
+
+	a, err := entity.NewAccount(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewService(logger, nil, nil, db)
+
+	if err := a.Register(context.Background(), validAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Delete(context.Background()) }()
+	if _, err := srv.Deposit(context.Background(), validAccName, money.New(10, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("withdraw", func(t *testing.T) {
+		p, err := srv.Withdraw(context.Background(), validAccName, money.MustParse("2.50"), "IBAN DE00 0000 0000", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Direction != PaymentDirectionWithdrawal || p.Counterparty != "IBAN DE00 0000 0000" {
+			t.Errorf("payment = %+v", p)
+		}
+		if p.Amount.String() != "2.50" || p.Account != validAccName || p.ToAccount != "" {
+			t.Errorf("payment = %+v", p)
+		}
+		_ = a.Find(context.Background(), validAccName)
+		if a.Balance.String() != "7.50" {
+			t.Errorf("balance = %s, want 7.50", a.Balance)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name         entity.AccountName
+			amount       money.Amount
+			counterparty string
+			want         error
+		}{
+			{validAccName, money.New(100, 0), "card", ErrWithdrawNoMoneyError},
+			{validAccName, money.New(-1, 0), "card", ErrWithdrawAmountError},
+			{validAccName, money.New(1, 0), "", ErrWithdrawCounterpartyError},
+			{"wrongAccountName", money.New(1, 0), "card", ErrWithdrawNotFound},
+		}
+		for _, tt := range tests {
+			if _, err := srv.Withdraw(context.Background(), tt.name, tt.amount, tt.counterparty, ""); err != tt.want {
+				t.Errorf("Withdraw(%s, %s, %q) error = %v, want %v", tt.name, tt.amount, tt.counterparty, err, tt.want)
+			}
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := srv.SetLimits(ctx, validAccName, entity.Limits{MaxAmount: money.New(2, 0)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Withdraw(ctx, validAccName, money.New(3, 0), "card", ""); err != ErrTransferLimitExceeded {
+			t.Errorf("Withdraw() over MaxAmount error = %v, want ErrTransferLimitExceeded", err)
+		}
+
+		// withdrawal done before is counted in spending of account
+		l, err := srv.SetLimits(ctx, validAccName, entity.Limits{DailyAmount: money.New(3, 0), DailyCount: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.UsedAmount.Cmp(money.MustParse("2.50")) != 0 || l.UsedCount != 1 {
+			t.Errorf("used = %s, %d, want 2.50, 1", l.UsedAmount, l.UsedCount)
+		}
+		if _, err = srv.Withdraw(ctx, validAccName, money.New(1, 0), "card", ""); err != ErrDailyAmountLimitExceeded {
+			t.Errorf("Withdraw() over DailyAmount error = %v, want ErrDailyAmountLimitExceeded", err)
+		}
+		if _, err = srv.Withdraw(ctx, validAccName, money.MustParse("0.50"), "card", ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.Withdraw(ctx, validAccName, money.MustParse("0.01"), "card", ""); err != ErrDailyCountLimitExceeded {
+			t.Errorf("Withdraw() over DailyCount error = %v, want ErrDailyCountLimitExceeded", err)
+		}
+	})
+}
+
+func Test_Hold(t *testing.T) {
+	const (
+		fromAccName = "Testing987ha9871hgaf98784"
+		toAccName   = "Testing987ha9871hgaf98785"
+	)
+	initLogger()
+
+	a1, _ := entity.NewAccount(db)
+	a2, _ := entity.NewAccount(db)
+	srv := NewService(logger, nil, nil, db)
+
+	if err := a1.Register(context.Background(), fromAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(context.Background()) }()
+	if err := a2.Register(context.Background(), toAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(context.Background()) }()
+	if _, err := srv.Deposit(context.Background(), fromAccName, money.New(10, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("hold and capture", func(t *testing.T) {
+		h, err := srv.Hold(context.Background(), fromAccName, toAccName, money.MustParse("4.00"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Status != entity.HoldStatusActive || h.Account != fromAccName || h.ToAccount != toAccName {
+			t.Errorf("hold = %+v", h)
+		}
+		_ = a1.Find(context.Background(), fromAccName)
+		if a1.Balance.String() != "10.00" || a1.AvailableBalance.String() != "6.00" {
+			t.Errorf("balance = %s, available = %s", a1.Balance, a1.AvailableBalance)
+		}
+		p, err := srv.Capture(context.Background(), fromAccName, h.ID, money.Amount{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Amount.String() != "4.00" || p.ToAccount != toAccName {
+			t.Errorf("payment = %+v", p)
+		}
+		if _, err = srv.Release(context.Background(), fromAccName, h.ID); err != ErrHoldNotActive {
+			t.Errorf("Release() error = %v, want ErrHoldNotActive", err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name, to entity.AccountName
+			amount   money.Amount
+			ttl      time.Duration
+			want     error
+		}{
+			{fromAccName, toAccName, money.New(100, 0), 0, ErrHoldNoMoneyError},
+			{fromAccName, toAccName, money.New(-1, 0), 0, ErrHoldAmountError},
+			{fromAccName, toAccName, money.New(1, 0), -time.Second, ErrHoldTTLError},
+			{fromAccName, toAccName, money.New(1, 0), MaxHoldTTL + time.Second, ErrHoldTTLError},
+			{fromAccName, fromAccName, money.New(1, 0), 0, ErrHoldSelfToSelfError},
+			{"wrongAccountName", toAccName, money.New(1, 0), 0, ErrHoldFromNotFound},
+			{fromAccName, "wrongAccountName", money.New(1, 0), 0, ErrHoldToNotFound},
+		}
+		for _, tt := range tests {
+			if _, err := srv.Hold(context.Background(), tt.name, tt.to, tt.amount, tt.ttl); err != tt.want {
+				t.Errorf("Hold(%s, %s, %s, %s) error = %v, want %v", tt.name, tt.to, tt.amount, tt.ttl, err, tt.want)
+			}
+		}
+		if _, err := srv.Capture(context.Background(), fromAccName, 0, money.Amount{}); err != ErrHoldNotFound {
+			t.Errorf("Capture() error = %v, want ErrHoldNotFound", err)
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := srv.SetLimits(ctx, fromAccName, entity.Limits{MaxAmount: money.New(2, 0)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Hold(ctx, fromAccName, toAccName, money.New(3, 0), 0); err != ErrTransferLimitExceeded {
+			t.Errorf("Hold() over MaxAmount error = %v, want ErrTransferLimitExceeded", err)
+		}
+
+		// daily count is reached by transfer done after hold
+		h, err := srv.Hold(ctx, fromAccName, toAccName, money.New(2, 0), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.SetLimits(ctx, fromAccName, entity.Limits{DailyCount: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.Transfer(ctx, fromAccName, toAccName, money.New(1, 0), entity.Metadata{}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.Capture(ctx, fromAccName, h.ID, money.Amount{}); err != ErrDailyCountLimitExceeded {
+			t.Errorf("Capture() over DailyCount error = %v, want ErrDailyCountLimitExceeded", err)
+		}
+
+		if _, err = srv.SetLimits(ctx, fromAccName, entity.Limits{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.Capture(ctx, fromAccName, h.ID, money.Amount{}); err != nil {
+			t.Errorf("Capture() without limits error = %v", err)
+		}
+	})
+}
+
+func Test_TransferConcurrentLimits(t *testing.T) {
+	const (
+		fromAccName = "Testing987ha9871hgaf98788"
+		toAccName   = "Testing987ha9871hgaf98789"
+		workers     = 50
+		dailyCount  = 3
+	)
+	initLogger()
+
+	a1, _ := entity.NewAccount(db)
+	a2, _ := entity.NewAccount(db)
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+
+	if err := a1.Register(ctx, fromAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, toAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+	if _, err := srv.Deposit(ctx, fromAccName, money.New(100, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.SetLimits(ctx, fromAccName, entity.Limits{DailyCount: dailyCount}); err != nil {
+		t.Fatal(err)
+	}
+
+	// all transfers pass check of service before any of them is done, limits are checked again under lock of account
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make(chan error, workers)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := srv.Transfer(ctx, fromAccName, toAccName, money.New(1, 0), entity.Metadata{}, "")
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	done := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			done++
+		case ErrDailyCountLimitExceeded:
+		default:
+			t.Errorf("Transfer() error = %v", err)
+		}
+	}
+	if done != dailyCount {
+		t.Errorf("done transfers = %d, want %d", done, dailyCount)
+	}
+	l, err := srv.GetLimits(ctx, fromAccName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.UsedCount != dailyCount {
+		t.Errorf("used count = %d, want %d", l.UsedCount, dailyCount)
+	}
+
+	// transfer which passed check of service before limit was reached is rejected by repository
+	if err = a1.Find(ctx, fromAccName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a1.Transfer(ctx, toAccName, money.New(1, 0), nil, nil, entity.Metadata{}, nil); err != entity.ErrDailyCountExceeded {
+		t.Errorf("Transfer() of entity error = %v, want %v", err, entity.ErrDailyCountExceeded)
+	}
+	if _, err = a1.Withdraw(ctx, money.New(1, 0), "card", nil); err != entity.ErrDailyCountExceeded {
+		t.Errorf("Withdraw() of entity error = %v, want %v", err, entity.ErrDailyCountExceeded)
+	}
+}
+
+func Test_ScheduledTransfer(t *testing.T) {
+	const (
+		fromAccName = "Testing987ha9871hgaf98786"
+		toAccName   = "Testing987ha9871hgaf98787"
+	)
+	initLogger()
+
+	a1, _ := entity.NewAccount(db)
+	a2, _ := entity.NewAccount(db)
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+
+	if err := a1.Register(ctx, fromAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(ctx) }()
+	if err := a2.Register(ctx, toAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(ctx) }()
+	if _, err := srv.Deposit(ctx, fromAccName, money.New(10, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name, to   entity.AccountName
+			amount     money.Amount
+			recurrence string
+			day        int
+			want       error
+		}{
+			{fromAccName, fromAccName, money.New(1, 0), "", 0, ErrScheduleSelfToSelfError},
+			{"wrongAccountName", toAccName, money.New(1, 0), "", 0, ErrScheduleFromNotFound},
+			{fromAccName, "wrongAccountName", money.New(1, 0), "", 0, ErrScheduleToNotFound},
+			{fromAccName, toAccName, money.New(-1, 0), "", 0, ErrScheduleAmountError},
+			{fromAccName, toAccName, money.New(1, 0), "yearly", 0, ErrScheduleRecurrenceError},
+			{fromAccName, toAccName, money.New(1, 0), entity.RecurrenceMonthly, 32, ErrScheduleDayError},
+		}
+		for _, tt := range tests {
+			if _, err := srv.ScheduleTransfer(ctx, tt.name, tt.to, tt.amount, time.Time{}, tt.recurrence, tt.day); err != tt.want {
+				t.Errorf("ScheduleTransfer(%s, %s, %s, %s, %d) error = %v, want %v",
+					tt.name, tt.to, tt.amount, tt.recurrence, tt.day, err, tt.want)
+			}
+		}
+		if _, err := srv.GetScheduledTransfer(ctx, fromAccName, 0); err != ErrScheduleNotFound {
+			t.Errorf("GetScheduledTransfer() error = %v, want ErrScheduleNotFound", err)
+		}
+	})
+
+	t.Run("execute", func(t *testing.T) {
+		once, err := srv.ScheduleTransfer(ctx, fromAccName, toAccName, money.MustParse("3.00"), time.Now().Add(-time.Minute), "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		daily, err := srv.ScheduleTransfer(ctx, fromAccName, toAccName, money.MustParse("1.00"), time.Now().Add(-time.Hour), entity.RecurrenceDaily, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		failed, err := srv.ScheduleTransfer(ctx, fromAccName, toAccName, money.MustParse("100.00"), time.Time{}, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// transfer was executed before restart of wallet, but its execution was not recorded
+		key := fmt.Sprintf("scheduled-%d-%d", once.ID, once.NextRunAt.Unix())
+		p, err := srv.Transfer(ctx, fromAccName, toAccName, money.MustParse("3.00"), entity.Metadata{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.ExecuteScheduledTransfers(ctx); err != nil {
+			t.Fatal(err)
+		}
+		_ = a1.Find(ctx, fromAccName)
+		if a1.Balance.String() != "6.00" {
+			t.Errorf("balance = %s, want 6.00", a1.Balance)
+		}
+
+		st, err := srv.GetScheduledTransfer(ctx, fromAccName, once.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Status != entity.ScheduleStatusCompleted || len(st.Executions) != 1 || st.Executions[0].PaymentID != p.ID {
+			t.Errorf("scheduled transfer once = %+v, want completed by payment %d", st, p.ID)
+		}
+		st, _ = srv.GetScheduledTransfer(ctx, fromAccName, daily.ID)
+		if st.Status != entity.ScheduleStatusActive || !st.NextRunAt.After(time.Now()) || len(st.Executions) != 1 {
+			t.Errorf("scheduled transfer daily = %+v", st)
+		}
+		st, _ = srv.GetScheduledTransfer(ctx, fromAccName, failed.ID)
+		if st.Status != entity.ScheduleStatusCompleted || len(st.Executions) != 1 ||
+			st.Executions[0].PaymentID != 0 || st.Executions[0].Error != ErrTransferNoMoneyError.Error() {
+			t.Errorf("failed scheduled transfer = %+v", st)
+		}
+
+		// nothing is due until tomorrow
+		if _, err = srv.ExecuteScheduledTransfers(ctx); err != nil {
+			t.Fatal(err)
+		}
+		_ = a1.Find(ctx, fromAccName)
+		if a1.Balance.String() != "6.00" {
+			t.Errorf("balance after repeated run = %s, want 6.00", a1.Balance)
+		}
+	})
+
+	t.Run("update and cancel", func(t *testing.T) {
+		st, err := srv.ScheduleTransfer(ctx, fromAccName, toAccName, money.MustParse("1.00"),
+			time.Now().Add(time.Hour), entity.RecurrenceMonthly, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st, err = srv.UpdateScheduledTransfer(ctx, fromAccName, st.ID, money.MustParse("2.50"), time.Time{}, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		if st.Amount.String() != "2.50" || st.Recurrence != entity.RecurrenceMonthly || st.Day == 0 {
+			t.Errorf("updated scheduled transfer = %+v", st)
+		}
+		if _, err = srv.UpdateScheduledTransfer(ctx, fromAccName, st.ID, money.Amount{}, time.Time{}, "", 40); err != ErrScheduleDayError {
+			t.Errorf("UpdateScheduledTransfer() error = %v, want ErrScheduleDayError", err)
+		}
+		if st, err = srv.CancelScheduledTransfer(ctx, fromAccName, st.ID); err != nil || st.Status != entity.ScheduleStatusCancelled {
+			t.Fatalf("CancelScheduledTransfer() = %+v, %v", st, err)
+		}
+		if _, err = srv.CancelScheduledTransfer(ctx, fromAccName, st.ID); err != ErrScheduleNotActive {
+			t.Errorf("CancelScheduledTransfer() error = %v, want ErrScheduleNotActive", err)
+		}
+		lst, err := srv.ScheduledTransfersList(ctx, fromAccName, 0, -1)
+		if err != nil || len(lst) != 4 {
+			t.Errorf("ScheduledTransfersList() = %+v, %v", lst, err)
+		}
+	})
+}
+
+func Test_AccountStatus(t *testing.T) {
+	const (
+		fromAccName = "Testing987ha9871hgaf98786"
+		toAccName   = "Testing987ha9871hgaf98787"
+	)
+	initLogger()
+
+	a1, _ := entity.NewAccount(db)
+	a2, _ := entity.NewAccount(db)
+	srv := NewService(logger, nil, nil, db)
+
+	if err := a1.Register(context.Background(), fromAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a1.Delete(context.Background()) }()
+	if err := a2.Register(context.Background(), toAccName, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a2.Delete(context.Background()) }()
+	if _, err := srv.Deposit(context.Background(), fromAccName, money.New(10, 0), entity.Metadata{}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("freeze", func(t *testing.T) {
+		acc, err := srv.FreezeAccount(context.Background(), fromAccName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Status != entity.AccountStatusFrozen {
+			t.Errorf("status = %s, want %s", acc.Status, entity.AccountStatusFrozen)
+		}
+		if _, err = srv.Deposit(context.Background(), fromAccName, money.New(1, 0), entity.Metadata{}, ""); err != ErrAccountFrozen {
+			t.Errorf("Deposit() error = %v, want ErrAccountFrozen", err)
+		}
+		if _, err = srv.Transfer(context.Background(), fromAccName, toAccName, money.New(1, 0), entity.Metadata{}, ""); err != ErrAccountFrozen {
+			t.Errorf("Transfer() error = %v, want ErrAccountFrozen", err)
+		}
+		if _, err = srv.UnfreezeAccount(context.Background(), fromAccName); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("close", func(t *testing.T) {
+		if _, err := srv.CloseAccount(context.Background(), fromAccName); err != ErrAccountNotEmpty {
+			t.Errorf("CloseAccount() error = %v, want ErrAccountNotEmpty", err)
+		}
+		acc, err := srv.CloseAccount(context.Background(), toAccName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Status != entity.AccountStatusClosed {
+			t.Errorf("status = %s, want %s", acc.Status, entity.AccountStatusClosed)
+		}
+		if _, err = srv.Transfer(context.Background(), fromAccName, toAccName, money.New(1, 0), entity.Metadata{}, ""); err != ErrToAccountClosed {
+			t.Errorf("Transfer() error = %v, want ErrToAccountClosed", err)
+		}
+		if _, err = srv.FreezeAccount(context.Background(), toAccName); err != ErrAccountClosed {
+			t.Errorf("FreezeAccount() error = %v, want ErrAccountClosed", err)
+		}
+		if _, err = srv.CloseAccount(context.Background(), "wrongAccountName"); err != ErrAccountStatusNotFound {
+			t.Errorf("CloseAccount() error = %v, want ErrAccountStatusNotFound", err)
+		}
+	})
+}
+
 func Test_PaymentsList(t *testing.T) {
 	const validAccName = "Testing987ha9871hgaf92c8782"
 	initLogger()
 
-	a, err := entity.NewAccount()
+	a, err := entity.NewAccount(db)
 	if err != nil {
 		t.Fatal(err)
 	}
-	srv := NewService(logger)
+	srv := NewService(logger, nil, nil, db)
 
 	t.Run("create temp account", func(t *testing.T) {
-		if err := a.Register(validAccName); err != nil {
+		if err := a.Register(context.Background(), validAccName, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("deposit ", func(t *testing.T) {
-		if _, err := srv.Deposit(context.Background(), validAccName, 6); err != nil {
+		if _, err := srv.Deposit(context.Background(), validAccName, money.New(6, 0), entity.Metadata{}, ""); err != nil {
 			t.Error(err)
 		}
 	})
 	t.Run("run service ", func(t *testing.T) {
 		var lst []PaymentEntity
-		if lst, err = srv.PaymentsList(context.Background(), validAccName, 0, -1); err != nil {
+		if lst, _, err = srv.PaymentsList(context.Background(), validAccName, PaymentsFilter{}, "", 0, -1); err != nil {
+			t.Error(err)
+		}
+		if len(lst) != 1 {
+			t.Errorf("list length must be 1, got: %d", len(lst))
+		}
+	})
+	t.Run("filter", func(t *testing.T) {
+		lst, _, err := srv.PaymentsList(context.Background(), validAccName, PaymentsFilter{Direction: entity.PaymentDirectionDeposit, MinAmount: money.New(5, 0)}, "", 0, -1)
+		if err != nil {
 			t.Error(err)
 		}
 		if len(lst) != 1 {
 			t.Errorf("list length must be 1, got: %d", len(lst))
 		}
+		if lst, _, err = srv.PaymentsList(context.Background(), validAccName, PaymentsFilter{Direction: entity.PaymentDirectionIncoming}, "", 0, -1); err != nil {
+			t.Error(err)
+		}
+		if len(lst) != 0 {
+			t.Errorf("list length must be 0, got: %d", len(lst))
+		}
+	})
+	t.Run("filter errors", func(t *testing.T) {
+		now := time.Now()
+		for _, f := range []PaymentsFilter{
+			{Direction: "sideways"},
+			{MinAmount: money.New(-1, 0)},
+			{MinAmount: money.New(5, 0), MaxAmount: money.New(1, 0)},
+			// amounts of different scales are compared without overflow
+			{MinAmount: money.New(1<<63-1, 0), MaxAmount: money.MustParse("0.000001")},
+			{Since: now, Until: now.Add(-time.Hour)},
+		} {
+			if _, _, err := srv.PaymentsList(context.Background(), validAccName, f, "", 0, -1); err != ErrPaymentsListFilterError {
+				t.Errorf("PaymentsList(%+v) error = %v, want ErrPaymentsListFilterError", f, err)
+			}
+		}
+		if _, _, err := srv.PaymentsList(context.Background(), validAccName, PaymentsFilter{Counterparty: "wrongAccountName"}, "", 0, -1); err != ErrPaymentsListCounterpartyNotFound {
+			t.Errorf("PaymentsList() error = %v, want ErrPaymentsListCounterpartyNotFound", err)
+		}
+	})
+	t.Run("cursor", func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := srv.Deposit(ctx, validAccName, money.New(1, 0), entity.Metadata{}, ""); err != nil {
+			t.Fatal(err)
+		}
+		var (
+			ids    []entity.ID
+			cursor string
+		)
+		for i := 0; i < 3; i++ {
+			lst, next, err := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, cursor, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range lst {
+				ids = append(ids, p.ID)
+			}
+			if cursor = next; cursor == "" {
+				break
+			}
+		}
+		// the last full page returns cursor of empty page
+		if len(ids) != 2 || ids[0] <= ids[1] || cursor != "" {
+			t.Errorf("payments by cursor = %v, last cursor %q", ids, cursor)
+		}
+		if _, _, err := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, "wrong cursor", 0, 1); err != ErrListCursorError {
+			t.Errorf("PaymentsList() error = %v, want ErrListCursorError", err)
+		}
+		_, next, _ := srv.AccountsList(ctx, "", 0, 1)
+		if _, _, err := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, next, 0, 1); err != ErrListCursorError {
+			t.Errorf("PaymentsList() with cursor of accounts error = %v, want ErrListCursorError", err)
+		}
+
+		// page of cursor begins right after the last item of previous page, offset can't move it
+		_, first, _ := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, "", 0, 1)
+		if _, _, err := srv.PaymentsList(ctx, validAccName, PaymentsFilter{}, first, 1, 1); err != ErrListCursorOffsetError {
+			t.Errorf("PaymentsList() with cursor and offset error = %v, want ErrListCursorOffsetError", err)
+		}
+		if _, _, err := srv.AllPaymentsList(ctx, first, 1, 1); err != ErrListCursorOffsetError {
+			t.Errorf("AllPaymentsList() with cursor and offset error = %v, want ErrListCursorOffsetError", err)
+		}
+		if _, _, err := srv.AccountsList(ctx, next, 1, 1); err != ErrListCursorOffsetError {
+			t.Errorf("AccountsList() with cursor and offset error = %v, want ErrListCursorOffsetError", err)
+		}
 	})
 
 	t.Run("delete temp account", func(t *testing.T) {
-		if err := a.Delete(); err != nil {
+		if err := a.Delete(context.Background()); err != nil {
 			t.Error(err)
 		}
 	})
@@ -164,10 +1168,10 @@ func Test_PaymentsList(t *testing.T) {
 func Test_AllPaymentsList(t *testing.T) {
 	initLogger()
 
-	srv := NewService(logger)
+	srv := NewService(logger, nil, nil, db)
 
 	t.Run("run service ", func(t *testing.T) {
-		if _, err := srv.AllPaymentsList(context.Background(), 0, -1); err != nil {
+		if _, _, err := srv.AllPaymentsList(context.Background(), "", 0, -1); err != nil {
 			t.Error(err)
 		}
 	})
@@ -176,11 +1180,104 @@ func Test_AllPaymentsList(t *testing.T) {
 func Test_AccountsList(t *testing.T) {
 	initLogger()
 
-	srv := NewService(logger)
+	srv := NewService(logger, nil, nil, db)
 
 	t.Run("run service ", func(t *testing.T) {
-		if _, err := srv.AccountsList(context.Background(), 0, -1); err != nil {
+		if _, _, err := srv.AccountsList(context.Background(), "", 0, -1); err != nil {
 			t.Error(err)
 		}
 	})
 }
+
+func Test_BatchTransfer(t *testing.T) {
+	const payer = "Testing987ha9871hgbatch0"
+	payees := []entity.AccountName{"Testing987ha9871hgbatch1", "Testing987ha9871hgbatch2"}
+	initLogger()
+
+	srv := NewService(logger, nil, nil, db)
+	ctx := context.Background()
+	balance := func(name entity.AccountName) money.Amount {
+		a, err := entity.NewAccount(db)
+		if err == nil {
+			err = a.Find(ctx, name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.Balance
+	}
+
+	t.Run("create accounts", func(t *testing.T) {
+		if _, err := srv.CreateAccount(ctx, payer, "", money.New(10, 0)); err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range payees {
+			if _, err := srv.CreateAccount(ctx, n, "", money.Amount{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	t.Run("empty batch", func(t *testing.T) {
+		if _, err := srv.BatchTransfer(ctx, nil); err != ErrBatchEmpty {
+			t.Errorf("wait %v, got %v", ErrBatchEmpty, err)
+		}
+	})
+	t.Run("invalid leg", func(t *testing.T) {
+		_, err := srv.BatchTransfer(ctx, []TransferLeg{
+			{From: payer, To: payees[0], Amount: money.New(1, 0)},
+			{From: payer, To: "Testing987ha9871hgbatch9", Amount: money.New(1, 0)},
+		})
+		var be *BatchError
+		if !errors.As(err, &be) || be.Leg != 1 || be.Err != ErrTransferToNotFound {
+			t.Errorf("wait leg 1: %v, got %v", ErrTransferToNotFound, err)
+		}
+	})
+	t.Run("failed leg rolls back batch", func(t *testing.T) {
+		// the first two legs spend all money of payer
+		_, err := srv.BatchTransfer(ctx, []TransferLeg{
+			{From: payer, To: payees[0], Amount: money.New(6, 0)},
+			{From: payer, To: payees[1], Amount: money.New(4, 0)},
+			{From: payer, To: payees[0], Amount: money.New(1, 0)},
+		})
+		var be *BatchError
+		if !errors.As(err, &be) || be.Leg != 2 || be.Err != ErrTransferNoMoneyError {
+			t.Errorf("wait leg 2: %v, got %v", ErrTransferNoMoneyError, err)
+		}
+		if b := balance(payer); b.Cmp(money.New(10, 0)) != 0 {
+			t.Errorf("balance of payer = %s, want 10", b)
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		ids, err := srv.BatchTransfer(ctx, []TransferLeg{
+			{From: payer, To: payees[0], Amount: money.New(6, 0)},
+			{From: payer, To: payees[1], Amount: money.New(4, 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 2 || ids[0] == 0 || ids[1] <= ids[0] {
+			t.Errorf("payment ids = %v", ids)
+		}
+		for n, want := range map[entity.AccountName]int64{payer: 0, payees[0]: 6, payees[1]: 4} {
+			if b := balance(n); b.Cmp(money.New(want, 0)) != 0 {
+				t.Errorf("balance of %s = %s, want %d", n, b, want)
+			}
+		}
+	})
+
+	t.Run("delete temp accounts", func(t *testing.T) {
+		for _, n := range append(payees, payer) {
+			a, err := entity.NewAccount(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = a.Find(ctx, n); err != nil {
+				t.Error(err)
+				continue
+			}
+			if err = a.Delete(ctx); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
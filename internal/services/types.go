@@ -1,23 +1,157 @@
 package services
 
-import "github.com/rurick/coinswallet/internal/domain/wallet/entity"
+import (
+	"fmt"
+	"time"
+
+	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
+	"github.com/rurick/coinswallet/pkg/money"
+)
 
 const (
-	PaymentDirectionIncoming = "incoming"
-	PaymentDirectionOutgoing = "outgoing"
+	PaymentDirectionIncoming   = "incoming"
+	PaymentDirectionOutgoing   = "outgoing"
+	PaymentDirectionWithdrawal = "withdrawal"
 )
 
+// DefaultHoldTTL - time of life of hold when it is not set in request
+const DefaultHoldTTL = 15 * time.Minute
+
+// MaxHoldTTL - maximal time of life of hold
+const MaxHoldTTL = 7 * 24 * time.Hour
+
+// MaxCounterpartyLength - maximal length of reference to external counterparty of withdrawal
+const MaxCounterpartyLength = 255
+
+// MaxBatchLegs - maximal count of transfers in one batch
+const MaxBatchLegs = 1000
+
+// MaxScheduledTransfersPerRun - maximal count of scheduled transfers executed by one run of scheduler
+const MaxScheduledTransfersPerRun = 1000
+
+// TransferLeg - one transfer of batch
+type TransferLeg struct {
+	From   entity.AccountName `json:"from"`
+	To     entity.AccountName `json:"to"`
+	Amount money.Amount       `json:"amount"`
+}
+
+// PaymentsFilter - conditions of payments list of account, zero value of field means condition is not set
+// Since is included and Until is not, MinAmount and MaxAmount are in account currency and both are included
+// Direction is incoming, outgoing, deposit or withdrawal, Counterparty is name of the other account of payment
+type PaymentsFilter struct {
+	Since        time.Time
+	Until        time.Time
+	MinAmount    money.Amount
+	MaxAmount    money.Amount
+	Direction    string
+	Counterparty entity.AccountName
+}
+
+// BatchError - reason of rejection of batch transfer, Leg is index of failed leg from 0
+type BatchError struct {
+	Leg int
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("leg %d: %v", e.Leg, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
 // PaymentEntity using for service response
+// Amount is in payer currency, ToAmount is in recipient currency
+// Rate and RateDate are set only for cross-currency transfers
+// Kind is kind of payment: deposit, transfer, withdrawal or reversal
+// Counterparty is set only for withdrawals, ReversalOf is set only for reversals
+// Fee and Total (Amount with Fee, debited from payer) are set only for transfers with fee, they are not shown to recipient
+// Description, ExternalRef and Tags are metadata of deposits and transfers, ExternalRef and Tags are shown only
+// to account which created payment
 type PaymentEntity struct {
-	Account   entity.AccountName `json:"account"`
-	ToAccount entity.AccountName `json:"to_account"`
-	Amount    float64            `json:"amount"`
-	Direction string             `json:"direction"`
+	ID           entity.ID          `json:"id"`
+	ReversalOf   entity.ID          `json:"reversal_of,omitempty"`
+	Kind         string             `json:"kind"`
+	Account      entity.AccountName `json:"account"`
+	ToAccount    entity.AccountName `json:"to_account"`
+	Amount       money.Amount       `json:"amount"`
+	Fee          *money.Amount      `json:"fee,omitempty"`
+	Total        *money.Amount      `json:"total,omitempty"`
+	Currency     string             `json:"currency"`
+	ToAmount     money.Amount       `json:"to_amount"`
+	ToCurrency   string             `json:"to_currency"`
+	Rate         *money.Amount      `json:"rate,omitempty"`
+	RateDate     *time.Time         `json:"rate_date,omitempty"`
+	Counterparty string             `json:"counterparty,omitempty"`
+	Description  string             `json:"description,omitempty"`
+	ExternalRef  string             `json:"external_ref,omitempty"`
+	Tags         map[string]string  `json:"tags,omitempty"`
+	Direction    string             `json:"direction"`
 }
 
 // AccountEntity using for service response
+// AvailableBalance is balance without active holds, Status is active, frozen or closed
+// CreditLimit is overdraft limit, Available is amount which account can spend: AvailableBalance plus CreditLimit
 type AccountEntity struct {
-	Id       entity.AccountName `json:"id"`
-	Balance  float64            `json:"balance"`
-	Currency string             `json:"currency"`
+	Id               entity.AccountName `json:"id"`
+	Balance          money.Amount       `json:"balance"`
+	AvailableBalance money.Amount       `json:"available_balance"`
+	CreditLimit      money.Amount       `json:"credit_limit"`
+	Available        money.Amount       `json:"available"`
+	Currency         string             `json:"currency"`
+	Status           string             `json:"status"`
+}
+
+// HoldEntity using for service response
+// Amount is in currency of account, PaymentID is set only for captured hold
+type HoldEntity struct {
+	ID        int64              `json:"id"`
+	Account   entity.AccountName `json:"account"`
+	ToAccount entity.AccountName `json:"to_account"`
+	Amount    money.Amount       `json:"amount"`
+	Currency  string             `json:"currency"`
+	Status    string             `json:"status"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	PaymentID int64              `json:"payment_id,omitempty"`
+}
+
+// LimitsEntity using for service response
+// zero limit means the limit is not set. UsedAmount and UsedCount are totals of outgoing transfers
+// since the start of the current day Since (days of limits are days of UTC)
+type LimitsEntity struct {
+	Account     entity.AccountName `json:"account"`
+	Currency    string             `json:"currency"`
+	MaxAmount   money.Amount       `json:"max_amount"`
+	DailyAmount money.Amount       `json:"daily_amount"`
+	DailyCount  int64              `json:"daily_count"`
+	UsedAmount  money.Amount       `json:"used_amount"`
+	UsedCount   int64              `json:"used_count"`
+	Since       time.Time          `json:"since"`
+}
+
+// ScheduledTransferEntity using for service response
+// Amount is in currency of account, Day is set only for monthly transfer.
+// Executions are set only for single scheduled transfer
+type ScheduledTransferEntity struct {
+	ID         int64                     `json:"id"`
+	Account    entity.AccountName        `json:"account"`
+	ToAccount  entity.AccountName        `json:"to_account"`
+	Amount     money.Amount              `json:"amount"`
+	Currency   string                    `json:"currency"`
+	Recurrence string                    `json:"recurrence"`
+	Day        int                       `json:"day,omitempty"`
+	NextRunAt  time.Time                 `json:"next_run_at"`
+	Status     string                    `json:"status"`
+	Executions []ScheduleExecutionEntity `json:"executions,omitempty"`
+}
+
+// ScheduleExecutionEntity - result of execution of scheduled transfer
+// PaymentID is set for executed transfer, Error is set for failed one
+type ScheduleExecutionEntity struct {
+	RunAt     time.Time `json:"run_at"`
+	PaymentID entity.ID `json:"payment_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Date      time.Time `json:"date"`
 }
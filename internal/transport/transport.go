@@ -2,10 +2,14 @@ package transport
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/transport"
@@ -14,15 +18,39 @@ import (
 	"github.com/rurick/coinswallet/internal/domain/wallet/entity"
 	"github.com/rurick/coinswallet/internal/endpoints"
 	"github.com/rurick/coinswallet/internal/services"
+	"github.com/rurick/coinswallet/pkg/money"
 )
 
+// IdempotencyKeyHeader - header with client supplied key for safe retry of deposit, transfer and withdraw requests
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// AdminTokenHeader - header with token of administrator, required for privileged operations
+const AdminTokenHeader = "X-Admin-Token"
+
 var (
 	// ErrBadRouting is returned when an expected path variable is missing.
 	// It always indicates programmer error.
 	ErrBadRouting = errors.New("inconsistent mapping between route and handler (programmer error)")
+	// ErrRequestTimeout is returned when request is not handled before its deadline
+	ErrRequestTimeout = errors.New("request timeout")
 )
 
-func MakeHTTPHandler(s services.Service, logger log.Logger) http.Handler {
+// WithTimeout - set deadline of handling request by h, when it is over context of request is cancelled
+// and database queries of request are interrupted. Zero timeout means no deadline
+func WithTimeout(h http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// MakeHTTPHandler - create router of REST API
+// adminToken is compared with AdminTokenHeader of privileged requests, if it is empty privileged operations are disabled
+func MakeHTTPHandler(s services.Service, logger log.Logger, adminToken string) http.Handler {
 	r := mux.NewRouter()
 	e := endpoints.MakeEndpoints(s)
 	options := []httptransport.ServerOption{
@@ -33,9 +61,28 @@ func MakeHTTPHandler(s services.Service, logger log.Logger) http.Handler {
 	// POST 	/account/						create new wallet account
 	// PATCH 	/account/deposit/				deposit amount of currency to the wallet account
 	// PATCH 	/account/transfer/				send amount of currency between two wallet accounts
+	// POST 	/transfers/batch				execute list of transfers atomically
+	// PATCH 	/account/withdraw/				withdraw amount of currency from the wallet account to external counterparty
+	// POST 	/account/hold/					reserve amount of the wallet account for transfer
+	// PATCH 	/account/hold/capture/			turn hold into transfer
+	// PATCH 	/account/hold/release/			release hold
+	// POST 	/scheduled/						plan transfer at time once or repeatedly
+	// GET	 	/scheduled/:name/:offset/:limit/	list of scheduled transfers of the account
+	// GET	 	/scheduled/:name/:id			scheduled transfer with results of its executions
+	// PATCH 	/scheduled/:name/:id			change scheduled transfer
+	// DELETE 	/scheduled/:name/:id			cancel scheduled transfer
+	// PATCH 	/account/freeze/				suspend the wallet account (administrator only)
+	// PATCH 	/account/unfreeze/				make frozen wallet account active (administrator only)
+	// PATCH 	/account/close/					close the wallet account with zero balance
+	// PATCH 	/account/credit/				set overdraft limit of the wallet account (administrator only)
+	// GET	 	/limits/:name					spending limits of the account and their usage today (administrator only)
+	// PUT	 	/limits/:name					set spending limits of the account (administrator only)
+	// POST 	/payments/:id/reverse			return amount of payment back to payer
+	// GET	 	/payments/:name/ref/:ref		payment created by the account with external reference
 	// GET	 	/accounts/:offset/:limit/		list of all registered accounts
-	// GET	 	/payments/:name:/offset/:limit/	list of payments of the account
+	// GET	 	/payments/:name:/offset/:limit/	list of payments of the account, filtered by query params
 	// GET	 	/payments/:offset/:limit/		list of all payments
+	// lists accept query param cursor with next_cursor of previous page to continue list after it
 
 	r.Methods("POST").Path("/account/").Handler(httptransport.NewServer(
 		e.CreateAccount,
@@ -55,6 +102,114 @@ func MakeHTTPHandler(s services.Service, logger log.Logger) http.Handler {
 		encodeResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/transfers/batch").Handler(httptransport.NewServer(
+		e.BatchTransfer,
+		decodeBatchTransfer,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/withdraw/").Handler(httptransport.NewServer(
+		e.Withdraw,
+		decodeWithdraw,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/account/hold/").Handler(httptransport.NewServer(
+		e.Hold,
+		decodeHold,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/hold/capture/").Handler(httptransport.NewServer(
+		e.Capture,
+		decodeCapture,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/hold/release/").Handler(httptransport.NewServer(
+		e.Release,
+		decodeRelease,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/scheduled/").Handler(httptransport.NewServer(
+		e.ScheduleTransfer,
+		decodeScheduleTransfer,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/scheduled/{name}/{offset}/{limit}/").Handler(httptransport.NewServer(
+		e.ScheduledTransfersList,
+		decodeScheduledTransfersList,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/scheduled/{name}/{id}").Handler(httptransport.NewServer(
+		e.GetScheduledTransfer,
+		decodeScheduledTransfer,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/scheduled/{name}/{id}").Handler(httptransport.NewServer(
+		e.UpdateScheduledTransfer,
+		decodeUpdateScheduledTransfer,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("DELETE").Path("/scheduled/{name}/{id}").Handler(httptransport.NewServer(
+		e.CancelScheduledTransfer,
+		decodeScheduledTransfer,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/freeze/").Handler(httptransport.NewServer(
+		e.FreezeAccount,
+		makeDecodeAccountStatus(adminToken, true),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/unfreeze/").Handler(httptransport.NewServer(
+		e.UnfreezeAccount,
+		makeDecodeAccountStatus(adminToken, true),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/close/").Handler(httptransport.NewServer(
+		e.CloseAccount,
+		makeDecodeAccountStatus(adminToken, false),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PATCH").Path("/account/credit/").Handler(httptransport.NewServer(
+		e.SetCreditLimit,
+		makeDecodeSetCreditLimit(adminToken),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/limits/{name}").Handler(httptransport.NewServer(
+		e.GetLimits,
+		makeDecodeGetLimits(adminToken),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/limits/{name}").Handler(httptransport.NewServer(
+		e.SetLimits,
+		makeDecodeSetLimits(adminToken),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/payments/{id}/reverse").Handler(httptransport.NewServer(
+		e.Reverse,
+		makeDecodeReverse(adminToken),
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/payments/{name}/ref/{ref}").Handler(httptransport.NewServer(
+		e.PaymentByExternalRef,
+		decodePaymentByExternalRef,
+		encodeResponse,
+		options...,
+	))
 	r.Methods("GET").Path("/payments/{name}/{offset}/{limit}/").Handler(httptransport.NewServer(
 		e.PaymentsList,
 		decodePaymentsList,
@@ -89,6 +244,7 @@ func decodeDeposit(_ context.Context, r *http.Request) (request interface{}, err
 	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
 		return nil, e
 	}
+	req.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
 	return req, nil
 }
 
@@ -97,9 +253,222 @@ func decodeTransfer(_ context.Context, r *http.Request) (request interface{}, er
 	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
 		return nil, e
 	}
+	req.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+	return req, nil
+}
+
+func decodeBatchTransfer(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.BatchTransferRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeWithdraw(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.WithdrawRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	req.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+	return req, nil
+}
+
+func decodeHold(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.HoldRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeCapture(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.CaptureRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeRelease(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.ReleaseRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeScheduleTransfer(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.ScheduleTransferRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+func decodeScheduledTransfer(_ context.Context, r *http.Request) (request interface{}, err error) {
+	name, id, err := scheduledTransferVars(r)
+	if err != nil {
+		return nil, err
+	}
+	return endpoints.ScheduledTransferRequest{Name: name, ID: id}, nil
+}
+
+func decodeUpdateScheduledTransfer(_ context.Context, r *http.Request) (request interface{}, err error) {
+	var req endpoints.UpdateScheduledTransferRequest
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		return nil, e
+	}
+	if req.Name, req.ID, err = scheduledTransferVars(r); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
 
+// scheduledTransferVars - read name of account and id of scheduled transfer from URI
+func scheduledTransferVars(r *http.Request) (entity.AccountName, int64, error) {
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		return "", 0, ErrBadRouting
+	}
+	id, e := strconv.ParseInt(vars["id"], 10, 64)
+	if e != nil {
+		return "", 0, ErrBadRouting
+	}
+	return entity.AccountName(name), id, nil
+}
+
+func decodeScheduledTransfersList(_ context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+
+	o, ok := vars["offset"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	offset, e := strconv.ParseInt(o, 10, 64)
+	if e != nil {
+		return nil, ErrBadRouting
+	}
+
+	l, ok := vars["limit"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	limit, e := strconv.ParseInt(l, 10, 64)
+	if e != nil {
+		return nil, ErrBadRouting
+	}
+
+	return endpoints.ScheduledTransfersListRequest{Name: entity.AccountName(name), Offset: offset, Limit: limit}, nil
+}
+
+func decodePaymentByExternalRef(_ context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	ref, ok := vars["ref"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	return endpoints.PaymentByExternalRefRequest{Name: entity.AccountName(name), ExternalRef: ref}, nil
+}
+
+// makeDecodeAccountStatus - create decoder of request which changes status of account
+// if admin is set request is allowed only for administrator
+func makeDecodeAccountStatus(adminToken string, admin bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (request interface{}, err error) {
+		var req endpoints.AccountStatusRequest
+		if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+			return nil, e
+		}
+		if admin && !isAdmin(r, adminToken) {
+			return nil, services.ErrAccountStatusForbidden
+		}
+		return req, nil
+	}
+}
+
+// makeDecodeSetCreditLimit - create decoder of request which sets credit limit of account,
+// it is allowed only for administrator
+func makeDecodeSetCreditLimit(adminToken string) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (request interface{}, err error) {
+		var req endpoints.CreditLimitRequest
+		if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+			return nil, e
+		}
+		if !isAdmin(r, adminToken) {
+			return nil, services.ErrCreditLimitForbidden
+		}
+		return req, nil
+	}
+}
+
+// makeDecodeGetLimits - create decoder of request of account limits, it is allowed only for administrator
+func makeDecodeGetLimits(adminToken string) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (request interface{}, err error) {
+		if !isAdmin(r, adminToken) {
+			return nil, services.ErrLimitsForbidden
+		}
+		name, ok := mux.Vars(r)["name"]
+		if !ok {
+			return nil, ErrBadRouting
+		}
+		return endpoints.LimitsRequest{Name: entity.AccountName(name)}, nil
+	}
+}
+
+// makeDecodeSetLimits - create decoder of request of setting account limits, it is allowed only for administrator
+func makeDecodeSetLimits(adminToken string) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (request interface{}, err error) {
+		if !isAdmin(r, adminToken) {
+			return nil, services.ErrLimitsForbidden
+		}
+		var req endpoints.SetLimitsRequest
+		if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+			return nil, e
+		}
+		name, ok := mux.Vars(r)["name"]
+		if !ok {
+			return nil, ErrBadRouting
+		}
+		req.Name = entity.AccountName(name)
+		return req, nil
+	}
+}
+
+// makeDecodeReverse - create decoder of reverse request
+// body of request is optional, without it the whole payment is reversed
+func makeDecodeReverse(adminToken string) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (request interface{}, err error) {
+		var req endpoints.ReverseRequest
+		if e := json.NewDecoder(r.Body).Decode(&req); e != nil && e != io.EOF {
+			return nil, e
+		}
+		id, e := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if e != nil {
+			return nil, ErrBadRouting
+		}
+		req.ID = entity.ID(id)
+		if req.Force && !isAdmin(r, adminToken) {
+			return nil, services.ErrReverseForbidden
+		}
+		return req, nil
+	}
+}
+
+// isAdmin checking that request is sent by administrator
+func isAdmin(r *http.Request, adminToken string) bool {
+	token := r.Header.Get(AdminTokenHeader)
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
 func decodePaymentsList(_ context.Context, r *http.Request) (request interface{}, err error) {
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
@@ -125,7 +494,46 @@ func decodePaymentsList(_ context.Context, r *http.Request) (request interface{}
 		return nil, ErrBadRouting
 	}
 
-	return endpoints.PaymentsListRequest{Name: entity.AccountName(name), Offset: offset, Limit: limit}, nil
+	filter, err := decodePaymentsFilter(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints.PaymentsListRequest{
+		Name:   entity.AccountName(name),
+		Offset: offset,
+		Limit:  limit,
+		Filter: filter,
+		Cursor: r.URL.Query().Get("cursor"),
+	}, nil
+}
+
+// decodePaymentsFilter - read filter of payments list from query params
+// since and until are RFC 3339 time, min_amount and max_amount are decimal numbers
+func decodePaymentsFilter(q url.Values) (f services.PaymentsFilter, err error) {
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, services.ErrPaymentsListFilterError
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, services.ErrPaymentsListFilterError
+		}
+	}
+	if v := q.Get("min_amount"); v != "" {
+		if f.MinAmount, err = money.Parse(v); err != nil {
+			return f, services.ErrPaymentsListFilterError
+		}
+	}
+	if v := q.Get("max_amount"); v != "" {
+		if f.MaxAmount, err = money.Parse(v); err != nil {
+			return f, services.ErrPaymentsListFilterError
+		}
+	}
+	f.Direction = q.Get("direction")
+	f.Counterparty = entity.AccountName(q.Get("counterparty"))
+	return f, nil
 }
 
 func decodeAllPaymentsList(_ context.Context, r *http.Request) (request interface{}, err error) {
@@ -148,7 +556,7 @@ func decodeAllPaymentsList(_ context.Context, r *http.Request) (request interfac
 		return nil, ErrBadRouting
 	}
 
-	return endpoints.AllPaymentsListRequest{Offset: offset, Limit: limit}, nil
+	return endpoints.AllPaymentsListRequest{Offset: offset, Limit: limit, Cursor: r.URL.Query().Get("cursor")}, nil
 }
 
 func decodeAccountsList(_ context.Context, r *http.Request) (request interface{}, err error) {
@@ -171,7 +579,7 @@ func decodeAccountsList(_ context.Context, r *http.Request) (request interface{}
 		return nil, ErrBadRouting
 	}
 
-	return endpoints.AccountsListRequest{Offset: offset, Limit: limit}, nil
+	return endpoints.AccountsListRequest{Offset: offset, Limit: limit, Cursor: r.URL.Query().Get("cursor")}, nil
 }
 
 // encodeResponse is the common method to encode all response types to the
@@ -194,15 +602,26 @@ type errorer interface {
 }
 
 // encode errors from business-logic
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
+	body := map[string]interface{}{}
+	// rejected batch reports index of failed transfer
+	var batchErr *services.BatchError
+	if errors.As(err, &batchErr) {
+		body["failed_leg"] = batchErr.Leg
+		err = batchErr.Err
+	}
+	code := codeFrom(err)
+	// error of service is caused by interrupted database query, whatever service reports
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		code, err = http.StatusGatewayTimeout, ErrRequestTimeout
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(codeFrom(err))
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+	w.WriteHeader(code)
+	body["error"] = err.Error()
+	_ = json.NewEncoder(w).Encode(body)
 }
 
 func codeFrom(err error) int {
@@ -210,21 +629,95 @@ func codeFrom(err error) int {
 	case services.ErrDepositNotFound,
 		services.ErrTransferFromNotFound,
 		services.ErrTransferToNotFound,
+		services.ErrWithdrawNotFound,
+		services.ErrHoldNotFound,
+		services.ErrHoldFromNotFound,
+		services.ErrHoldToNotFound,
+		services.ErrReverseNotFound,
+		services.ErrReverseAccountNotFound,
+		services.ErrScheduleNotFound,
+		services.ErrScheduleFromNotFound,
+		services.ErrScheduleToNotFound,
+		services.ErrAccountStatusNotFound,
+		services.ErrCreditLimitNotFound,
+		services.ErrLimitsNotFound,
+		services.ErrPaymentRefAccountNotFound,
+		services.ErrPaymentRefNotFound,
+		services.ErrPaymentsListCounterpartyNotFound,
 		services.ErrPaymentsListNotFound:
 
 		return http.StatusNotFound
 
 	case services.ErrCreateAccountInvalidName,
 		services.ErrCreateAccount,
+		services.ErrCreateAccountCurrency,
 		services.ErrDepositAmountError,
 		services.ErrTransferAmountError,
 		services.ErrTransferSelfToSelfError,
 		services.ErrTransferNoMoneyError,
+		services.ErrTransferCurrencyError,
+		services.ErrTransferNoExchangeRate,
+		services.ErrBatchEmpty,
+		services.ErrBatchTooLarge,
+		services.ErrWithdrawAmountError,
+		services.ErrWithdrawNoMoneyError,
+		services.ErrWithdrawCounterpartyError,
+		services.ErrHoldAmountError,
+		services.ErrHoldTTLError,
+		services.ErrHoldNoMoneyError,
+		services.ErrHoldSelfToSelfError,
+		services.ErrHoldExceedsAmount,
+		services.ErrScheduleAmountError,
+		services.ErrScheduleSelfToSelfError,
+		services.ErrScheduleRecurrenceError,
+		services.ErrScheduleDayError,
+		services.ErrScheduleOffsetLimitError,
+		services.ErrReverseAmountError,
+		services.ErrReverseExceedsAmount,
+		services.ErrReverseNotAllowed,
+		services.ErrReverseNoMoney,
 		services.ErrPaymentsListOffsetLimitError,
-		services.ErrAccountsListOffsetLimitError:
+		services.ErrPaymentsListFilterError,
+		services.ErrAccountsListOffsetLimitError,
+		services.ErrListCursorError,
+		services.ErrListCursorOffsetError,
+		services.ErrCreditLimitValueError,
+		services.ErrLimitsValueError,
+		services.ErrIdempotencyKeyInvalid,
+		services.ErrDescriptionInvalid,
+		services.ErrExternalRefInvalid,
+		services.ErrTagsInvalid:
 
 		return http.StatusBadRequest
 
+	case services.ErrIdempotencyConflict,
+		services.ErrExternalRefDuplicate,
+		services.ErrHoldNotActive,
+		services.ErrScheduleNotActive,
+		services.ErrAccountFrozen,
+		services.ErrAccountClosed,
+		services.ErrToAccountFrozen,
+		services.ErrToAccountClosed,
+		services.ErrAccountNotEmpty:
+
+		return http.StatusConflict
+
+	case services.ErrTransferLimitExceeded,
+		services.ErrDailyAmountLimitExceeded:
+
+		return http.StatusUnprocessableEntity
+
+	case services.ErrDailyCountLimitExceeded:
+
+		return http.StatusTooManyRequests
+
+	case services.ErrReverseForbidden,
+		services.ErrAccountStatusForbidden,
+		services.ErrCreditLimitForbidden,
+		services.ErrLimitsForbidden:
+
+		return http.StatusForbidden
+
 	default:
 		return http.StatusInternalServerError
 	}
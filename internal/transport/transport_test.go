@@ -0,0 +1,321 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rurick/coinswallet/internal/domain/wallet/repository"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/memory"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/postgresql"
+	_ "github.com/rurick/coinswallet/internal/domain/wallet/repository/driver/sqlite"
+	"github.com/rurick/coinswallet/internal/services"
+)
+
+// db - storage used by tests
+var db repository.Driver
+
+// tests are run with memory driver unless DB_DRIVER environment is set
+func TestMain(m *testing.M) {
+	cfg := repository.ConfigFromEnv()
+	if os.Getenv("DB_DRIVER") == "" {
+		cfg.Driver = "memory"
+	}
+	// test database is created from scratch by migrations
+	cfg.AutoMigrate = true
+	var err error
+	if db, err = repository.Open(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = db.Close()
+	os.Exit(code)
+}
+
+func Test_HTTPHandler(t *testing.T) {
+	const adminToken = "secret"
+	logger := log.NewNopLogger()
+	h := MakeHTTPHandler(services.NewService(logger, nil, nil, db), logger, adminToken)
+
+	do := func(method, path, body string, header map[string]string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var res map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s %s: %v, body %s", method, path, err, w.Body)
+		}
+		return w.Code, res
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		code   int
+	}{
+		{"create account", "POST", "/account/", `{"name":"httpwallet1"}`, nil, http.StatusOK},
+		{"create second account", "POST", "/account/", `{"name":"httpwallet2"}`, nil, http.StatusOK},
+		{"create account with invalid initial deposit", "POST", "/account/", `{"name":"httpwallet3","initial_deposit":-1}`, nil, http.StatusBadRequest},
+		{"deposit", "PATCH", "/account/deposit/", `{"name":"httpwallet1","amount":10}`, nil, http.StatusOK},
+		{"deposit unknown account", "PATCH", "/account/deposit/", `{"name":"httpwallet9","amount":10}`, nil, http.StatusNotFound},
+		{"transfer", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet2","amount":4}`, nil, http.StatusOK},
+		{"transfer no money", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet2","amount":40}`, nil, http.StatusBadRequest},
+		{"freeze without token", "PATCH", "/account/freeze/", `{"name":"httpwallet2"}`, nil, http.StatusForbidden},
+		{"freeze", "PATCH", "/account/freeze/", `{"name":"httpwallet2"}`, map[string]string{AdminTokenHeader: adminToken}, http.StatusOK},
+		{"transfer to frozen", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet2","amount":1}`, nil, http.StatusConflict},
+		{"payments list", "GET", "/payments/httpwallet1/0/-1/", "", nil, http.StatusOK},
+		{"payments list with invalid filter", "GET", "/payments/httpwallet1/0/-1/?min_amount=abc", "", nil, http.StatusBadRequest},
+		{"payments list with unknown counterparty", "GET", "/payments/httpwallet1/0/-1/?counterparty=httpwallet9", "", nil, http.StatusNotFound},
+		{"accounts list", "GET", "/accounts/0/-1/", "", nil, http.StatusOK},
+		{"accounts list with invalid cursor", "GET", "/accounts/0/1/?cursor=abc", "", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		code, res := do(tt.method, tt.path, tt.body, tt.header)
+		if code != tt.code {
+			t.Errorf("%s: code = %d, want %d, response %v", tt.name, code, tt.code, res)
+		}
+	}
+
+	t.Run("balances", func(t *testing.T) {
+		_, res := do("GET", "/accounts/0/-1/", "", nil)
+		lst, _ := res["list"].([]interface{})
+		if len(lst) != 2 {
+			t.Fatalf("list = %v", res)
+		}
+		for i, want := range []float64{6, 4} {
+			a := lst[i].(map[string]interface{})
+			if a["balance"] != want {
+				t.Errorf("balance of %v = %v, want %v", a["id"], a["balance"], want)
+			}
+		}
+	})
+
+	t.Run("filtered payments list", func(t *testing.T) {
+		_, res := do("GET", "/payments/httpwallet1/0/-1/?direction=outgoing&counterparty=httpwallet2&min_amount=1", "", nil)
+		lst, _ := res["list"].([]interface{})
+		if len(lst) != 1 {
+			t.Fatalf("list = %v", res)
+		}
+		if p := lst[0].(map[string]interface{}); p["amount"] != float64(4) {
+			t.Errorf("amount = %v, want 4", p["amount"])
+		}
+	})
+
+	t.Run("accounts list by cursor", func(t *testing.T) {
+		_, res := do("GET", "/accounts/0/1/", "", nil)
+		next, _ := res["next_cursor"].(string)
+		if next == "" {
+			t.Fatalf("no next_cursor in %v", res)
+		}
+		_, res = do("GET", "/accounts/0/1/?cursor="+next, "", nil)
+		lst, _ := res["list"].([]interface{})
+		if len(lst) != 1 || lst[0].(map[string]interface{})["id"] != "httpwallet2" {
+			t.Errorf("second page = %v", res)
+		}
+	})
+
+	t.Run("batch transfer", func(t *testing.T) {
+		code, res := do("POST", "/transfers/batch",
+			`{"transfers":[{"from":"httpwallet1","to":"httpwallet2","amount":1},{"from":"httpwallet1","to":"httpwallet9","amount":1}]}`, nil)
+		if code != http.StatusNotFound || res["failed_leg"] != float64(1) {
+			t.Errorf("rejected batch: code = %d, response %v", code, res)
+		}
+
+		// httpwallet2 is frozen
+		code, res = do("POST", "/transfers/batch", `{"transfers":[{"from":"httpwallet1","to":"httpwallet2","amount":1}]}`, nil)
+		if code != http.StatusConflict || res["failed_leg"] != float64(0) {
+			t.Errorf("batch to frozen account: code = %d, response %v", code, res)
+		}
+
+		if code, res = do("POST", "/account/", `{"name":"httpwallet3"}`, nil); code != http.StatusOK {
+			t.Fatalf("create account: code = %d, response %v", code, res)
+		}
+		code, res = do("POST", "/transfers/batch",
+			`{"transfers":[{"from":"httpwallet1","to":"httpwallet3","amount":1},{"from":"httpwallet1","to":"httpwallet3","amount":2}]}`, nil)
+		if ids, _ := res["payment_ids"].([]interface{}); code != http.StatusOK || len(ids) != 2 {
+			t.Errorf("batch: code = %d, response %v", code, res)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		admin := map[string]string{AdminTokenHeader: adminToken}
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			header map[string]string
+			code   int
+		}{
+			{"get without token", "GET", "/limits/httpwallet1", "", nil, http.StatusForbidden},
+			{"set without token", "PUT", "/limits/httpwallet1", `{"max_amount":1.5}`, nil, http.StatusForbidden},
+			{"set unknown account", "PUT", "/limits/httpwallet9", `{"max_amount":1.5}`, admin, http.StatusNotFound},
+			{"set negative", "PUT", "/limits/httpwallet1", `{"max_amount":-1}`, admin, http.StatusBadRequest},
+			// 3 transfers of httpwallet1 are already done today
+			{"set", "PUT", "/limits/httpwallet1", `{"max_amount":1.5,"daily_count":5}`, admin, http.StatusOK},
+			{"transfer over limit", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet3","amount":2}`, nil, http.StatusUnprocessableEntity},
+			{"transfer", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet3","amount":1}`, nil, http.StatusOK},
+			{"batch over daily count", "POST", "/transfers/batch",
+				`{"transfers":[{"from":"httpwallet1","to":"httpwallet3","amount":0.5},{"from":"httpwallet1","to":"httpwallet3","amount":0.5}]}`, nil, http.StatusTooManyRequests},
+			{"last transfer of day", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet3","amount":1}`, nil, http.StatusOK},
+			{"transfer over daily count", "PATCH", "/account/transfer/", `{"from":"httpwallet1","to":"httpwallet3","amount":1}`, nil, http.StatusTooManyRequests},
+		}
+		for _, tt := range tests {
+			code, res := do(tt.method, tt.path, tt.body, tt.header)
+			if code != tt.code {
+				t.Errorf("%s: code = %d, want %d, response %v", tt.name, code, tt.code, res)
+			}
+		}
+
+		_, res := do("GET", "/limits/httpwallet1", "", admin)
+		l, _ := res["limits"].(map[string]interface{})
+		if l["max_amount"] != 1.5 || l["daily_count"] != float64(5) || l["used_count"] != float64(5) || l["used_amount"] != float64(9) {
+			t.Errorf("limits = %v", res)
+		}
+	})
+
+	t.Run("scheduled transfer", func(t *testing.T) {
+		code, res := do("POST", "/scheduled/",
+			`{"name":"httpwallet1","to":"httpwallet3","amount":1,"run_at":"2030-01-31T10:00:00Z","recurrence":"monthly"}`, nil)
+		st, _ := res["scheduled_transfer"].(map[string]interface{})
+		if code != http.StatusOK || st["day"] != float64(31) || st["status"] != "active" {
+			t.Fatalf("schedule: code = %d, response %v", code, res)
+		}
+		path := fmt.Sprintf("/scheduled/httpwallet1/%v", st["id"])
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			code   int
+		}{
+			{"unknown recurrence", "POST", "/scheduled/", `{"name":"httpwallet1","to":"httpwallet3","amount":1,"recurrence":"yearly"}`, http.StatusBadRequest},
+			{"to unknown account", "POST", "/scheduled/", `{"name":"httpwallet1","to":"httpwallet9","amount":1}`, http.StatusNotFound},
+			{"get", "GET", path, "", http.StatusOK},
+			{"get of another account", "GET", fmt.Sprintf("/scheduled/httpwallet3/%v", st["id"]), "", http.StatusNotFound},
+			{"update", "PATCH", path, `{"amount":2}`, http.StatusOK},
+			{"list", "GET", "/scheduled/httpwallet1/0/-1/", "", http.StatusOK},
+			{"cancel", "DELETE", path, "", http.StatusOK},
+			{"cancel again", "DELETE", path, "", http.StatusConflict},
+			{"update cancelled", "PATCH", path, `{"amount":3}`, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			code, res := do(tt.method, tt.path, tt.body, nil)
+			if code != tt.code {
+				t.Errorf("%s: code = %d, want %d, response %v", tt.name, code, tt.code, res)
+			}
+		}
+
+		_, res = do("GET", path, "", nil)
+		if st, _ = res["scheduled_transfer"].(map[string]interface{}); st["amount"] != float64(2) || st["status"] != "cancelled" {
+			t.Errorf("cancelled scheduled transfer = %v", res)
+		}
+	})
+
+	t.Run("credit limit", func(t *testing.T) {
+		admin := map[string]string{AdminTokenHeader: adminToken}
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			header map[string]string
+			code   int
+		}{
+			{"create account", "POST", "/account/", `{"name":"httpwallet4"}`, nil, http.StatusOK},
+			{"transfer without credit", "PATCH", "/account/transfer/", `{"from":"httpwallet4","to":"httpwallet3","amount":1}`, nil, http.StatusBadRequest},
+			{"set without token", "PATCH", "/account/credit/", `{"name":"httpwallet4","credit_limit":5}`, nil, http.StatusForbidden},
+			{"set unknown account", "PATCH", "/account/credit/", `{"name":"httpwallet9","credit_limit":5}`, admin, http.StatusNotFound},
+			{"set negative", "PATCH", "/account/credit/", `{"name":"httpwallet4","credit_limit":-5}`, admin, http.StatusBadRequest},
+			{"set", "PATCH", "/account/credit/", `{"name":"httpwallet4","credit_limit":5}`, admin, http.StatusOK},
+			{"transfer on credit", "PATCH", "/account/transfer/", `{"from":"httpwallet4","to":"httpwallet3","amount":3}`, nil, http.StatusOK},
+			{"transfer over credit", "PATCH", "/account/transfer/", `{"from":"httpwallet4","to":"httpwallet3","amount":3}`, nil, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			code, res := do(tt.method, tt.path, tt.body, tt.header)
+			if code != tt.code {
+				t.Errorf("%s: code = %d, want %d, response %v", tt.name, code, tt.code, res)
+			}
+		}
+
+		_, res := do("GET", "/accounts/0/-1/", "", nil)
+		lst, _ := res["list"].([]interface{})
+		a, _ := lst[len(lst)-1].(map[string]interface{})
+		if a["id"] != "httpwallet4" || a["balance"] != float64(-3) || a["credit_limit"] != float64(5) || a["available"] != float64(2) {
+			t.Errorf("account on credit = %v", a)
+		}
+	})
+
+	t.Run("payment metadata", func(t *testing.T) {
+		tests := []struct {
+			name string
+			path string
+			body string
+			code int
+		}{
+			{"deposit with reference", "/account/deposit/", `{"name":"httpwallet3","amount":1,"external_ref":"dep-1"}`, http.StatusOK},
+			{"deposit with used reference", "/account/deposit/", `{"name":"httpwallet3","amount":1,"external_ref":"dep-1"}`, http.StatusConflict},
+			{"transfer with invalid reference", "/account/transfer/",
+				`{"from":"httpwallet3","to":"httpwallet4","amount":1,"external_ref":"inv 1"}`, http.StatusBadRequest},
+			{"transfer with metadata", "/account/transfer/",
+				`{"from":"httpwallet3","to":"httpwallet4","amount":1,"description":"invoice","external_ref":"inv-1","tags":{"order":"1"}}`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			code, res := do("PATCH", tt.path, tt.body, nil)
+			if code != tt.code {
+				t.Errorf("%s: code = %d, want %d, response %v", tt.name, code, tt.code, res)
+			}
+		}
+
+		code, res := do("GET", "/payments/httpwallet3/ref/inv-1", "", nil)
+		p, _ := res["payment"].(map[string]interface{})
+		tags, _ := p["tags"].(map[string]interface{})
+		if code != http.StatusOK || p["description"] != "invoice" || p["external_ref"] != "inv-1" || tags["order"] != "1" {
+			t.Errorf("payment by reference: code = %d, response %v", code, res)
+		}
+		if code, res = do("GET", "/payments/httpwallet4/ref/inv-1", "", nil); code != http.StatusNotFound {
+			t.Errorf("payment by reference of recipient: code = %d, response %v", code, res)
+		}
+	})
+}
+
+func Test_WithTimeout(t *testing.T) {
+	var deadline bool
+	h := WithTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		deadline = errors.Is(r.Context().Err(), context.DeadlineExceeded)
+		encodeError(r.Context(), services.ErrDepositNotFound, w)
+	}), time.Millisecond)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PATCH", "/account/deposit/", nil))
+	if !deadline {
+		t.Fatal("context of request is not cancelled by deadline")
+	}
+	// error of service caused by interrupted query is reported as timeout
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), ErrRequestTimeout.Error()) {
+		t.Errorf("response = %d %s, want %d", w.Code, w.Body, http.StatusGatewayTimeout)
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright 2021 (c) Yuriy Iovkov aka Rurick.
+// yuriyiovkov@gmail.com; telegram: @yuriyiovkov
+
+// this package provide exact fixed-point decimal type for monetary values
+// amount is stored as integer count of minor units plus scale (count of digits after decimal point)
+// so 12.50 is stored as units=1250, scale=2
+// Important!
+// never convert amounts through float64, use Parse and String for exchange with outer world
+
+// Usage:
+// a, err := money.Parse("10.25")
+// ...
+// b, err := a.Add(money.New(5, 0)) // 15.25
+// ...
+// fmt.Println(b.String())
+//
+
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxScale - maximal count of digits after decimal point
+const MaxScale = 18
+
+// MaxAmount - maximal absolute value of amount accepted by Parse, 10^12
+// storage keeps amounts with 4 digits after decimal point in int64, so sums of many such amounts still fit
+var MaxAmount = New(1000000000000, 0)
+
+var (
+	// ErrSyntax is returned when string can't be parsed as decimal amount
+	ErrSyntax = errors.New("money: invalid amount syntax")
+	// ErrRange is returned when amount does not fit into int64 minor units or exceeds MaxAmount
+	ErrRange = errors.New("money: amount out of range")
+	// ErrPrecision is returned when amount has more digits after decimal point than allowed
+	ErrPrecision = errors.New("money: amount precision is too high")
+)
+
+// Amount - exact decimal amount of money
+// zero value is valid amount equal to 0
+type Amount struct {
+	units int64
+	scale uint8
+}
+
+// New - create amount from count of minor units and scale
+// New(1250, 2) is 12.50
+func New(units int64, scale uint8) Amount {
+	return Amount{units: units, scale: scale}
+}
+
+// Parse - parse decimal string like "12", "-0.5" or "12.3400"
+// scale of result is equal to count of digits after decimal point in string
+// returns ErrRange if absolute value of amount is greater than MaxAmount
+func Parse(s string) (Amount, error) {
+	a, err := parse(s)
+	if err != nil {
+		return Amount{}, err
+	}
+	if a.Abs().Cmp(MaxAmount) > 0 {
+		return Amount{}, ErrRange
+	}
+	return a, nil
+}
+
+// parse - parse decimal string without limit of value, any amount which fits into int64 minor units is accepted
+func parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Amount{}, ErrSyntax
+	}
+
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Amount{}, ErrSyntax
+	}
+	if len(fracPart) > MaxScale {
+		return Amount{}, ErrPrecision
+	}
+
+	var units int64
+	for _, part := range []string{intPart, fracPart} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return Amount{}, ErrSyntax
+			}
+			d := int64(c - '0')
+			if units > (1<<63-1-d)/10 {
+				return Amount{}, ErrRange
+			}
+			units = units*10 + d
+		}
+	}
+	if neg {
+		units = -units
+	}
+	return Amount{units: units, scale: uint8(len(fracPart))}, nil
+}
+
+// MustParse - same as Parse but panic on error. Use only for constants
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: MustParse(%q): %v", s, err))
+	}
+	return a
+}
+
+// Units return count of minor units
+func (a Amount) Units() int64 {
+	return a.units
+}
+
+// Scale return count of digits after decimal point
+func (a Amount) Scale() uint8 {
+	return a.scale
+}
+
+// Sign return -1, 0 or 1
+func (a Amount) Sign() int {
+	switch {
+	case a.units < 0:
+		return -1
+	case a.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero return true when amount is equal to zero
+func (a Amount) IsZero() bool {
+	return a.units == 0
+}
+
+// Neg return negative amount
+func (a Amount) Neg() Amount {
+	return Amount{units: -a.units, scale: a.scale}
+}
+
+// Abs return absolute value of amount
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// Precision return count of significant digits after decimal point
+// 12.5000 has precision 1
+func (a Amount) Precision() uint8 {
+	return a.Trim(0).scale
+}
+
+// Rescale - change scale of amount
+// return ErrPrecision if non zero digits will be lost and ErrRange on overflow
+func (a Amount) Rescale(scale uint8) (Amount, error) {
+	if scale > MaxScale {
+		return Amount{}, ErrPrecision
+	}
+	units := a.units
+	for s := a.scale; s < scale; s++ {
+		if units > (1<<63-1)/10 || units < -(1<<63-1)/10 {
+			return Amount{}, ErrRange
+		}
+		units *= 10
+	}
+	for s := a.scale; s > scale; s-- {
+		if units%10 != 0 {
+			return Amount{}, ErrPrecision
+		}
+		units /= 10
+	}
+	return Amount{units: units, scale: scale}, nil
+}
+
+// Trim - remove trailing zeros after decimal point but keep at least minScale digits
+// Trim(2) of 12.5000 is 12.50; Trim(2) of 12.3456 is 12.3456
+func (a Amount) Trim(minScale uint8) Amount {
+	for a.scale > minScale && a.units%10 == 0 {
+		a.units /= 10
+		a.scale--
+	}
+	return a
+}
+
+// Cmp compare two amounts and return -1 if a < b, 0 if a == b and 1 if a > b
+// amounts with different scales are compared exactly without overflow
+func (a Amount) Cmp(b Amount) int {
+	if a.scale == b.scale {
+		switch {
+		case a.units < b.units:
+			return -1
+		case a.units > b.units:
+			return 1
+		}
+		return 0
+	}
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.bigUnits(scale).Cmp(b.bigUnits(scale))
+}
+
+// Add return a + b. Scale of result is the greater of scales
+// returns ErrRange if result does not fit into int64 minor units
+func (a Amount) Add(b Amount) (Amount, error) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	a, err := a.Rescale(scale)
+	if err != nil {
+		return Amount{}, err
+	}
+	if b, err = b.Rescale(scale); err != nil {
+		return Amount{}, err
+	}
+	units := a.units + b.units
+	if (b.units > 0 && units < a.units) || (b.units < 0 && units > a.units) {
+		return Amount{}, ErrRange
+	}
+	return Amount{units: units, scale: scale}, nil
+}
+
+// Sub return a - b. Scale of result is the greater of scales
+// returns ErrRange if result does not fit into int64 minor units
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b.units == -1<<63 {
+		return Amount{}, ErrRange
+	}
+	return a.Add(b.Neg())
+}
+
+// MulRound return a * b rounded to scale digits after decimal point
+// half is rounded away from zero. Used for currency conversion: amount.MulRound(rate, precision)
+func (a Amount) MulRound(b Amount, scale uint8) (Amount, error) {
+	if scale > MaxScale {
+		return Amount{}, ErrPrecision
+	}
+	p := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(b.units))
+	pScale := int(a.scale) + int(b.scale)
+
+	if pScale > int(scale) {
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(pScale-int(scale))), nil)
+		q, r := new(big.Int).QuoRem(p, div, new(big.Int))
+		// round half away from zero
+		if r.Abs(r).Mul(r, big.NewInt(2)).Cmp(div) >= 0 {
+			if p.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+		p = q
+	} else {
+		mul := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(int(scale)-pScale)), nil)
+		p.Mul(p, mul)
+	}
+
+	if !p.IsInt64() {
+		return Amount{}, ErrRange
+	}
+	return Amount{units: p.Int64(), scale: scale}, nil
+}
+
+// String return decimal representation of amount with exactly Scale digits after decimal point
+func (a Amount) String() string {
+	neg := a.units < 0
+	digits := strconv.FormatUint(abs(a.units), 10)
+	if a.scale > 0 {
+		if len(digits) <= int(a.scale) {
+			digits = strings.Repeat("0", int(a.scale)-len(digits)+1) + digits
+		}
+		p := len(digits) - int(a.scale)
+		digits = digits[:p] + "." + digits[p:]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON - amount encoded as JSON number with exact digits
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON - amount can be decoded from JSON number or from string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if uq, err := strconv.Unquote(s); err == nil {
+		s = uq
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Scan implements the sql.Scanner interface
+func (a *Amount) Scan(src interface{}) error {
+	var (
+		v   Amount
+		err error
+	)
+	switch src := src.(type) {
+	case nil:
+		v = Amount{}
+	case string:
+		v, err = parseScanned(src)
+	case []byte:
+		v, err = parseScanned(string(src))
+	case int64:
+		v = Amount{units: src}
+	case float64:
+		// some drivers return numeric columns as float, use shortest exact representation of it
+		v, err = parse(strconv.FormatFloat(src, 'f', -1, 64))
+	default:
+		return fmt.Errorf("money: can't scan %T into Amount", src)
+	}
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// parseScanned - parse amount read from database
+// pgx returns numeric column decoded from binary format as integer with exponent like "1250e-2"
+func parseScanned(s string) (Amount, error) {
+	i := strings.IndexAny(s, "eE")
+	if i < 0 {
+		return parse(s)
+	}
+	exp, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return Amount{}, ErrSyntax
+	}
+	if strings.IndexByte(s[:i], '.') >= 0 {
+		return Amount{}, ErrSyntax
+	}
+	a, err := parse(s[:i])
+	if err != nil {
+		return Amount{}, err
+	}
+	for ; exp > 0; exp-- {
+		if a.units > (1<<63-1)/10 || a.units < -(1<<63-1)/10 {
+			return Amount{}, ErrRange
+		}
+		a.units *= 10
+	}
+	// drop trailing zeros which don't fit into MaxScale
+	for ; exp < -MaxScale && a.units%10 == 0; exp++ {
+		a.units /= 10
+	}
+	if exp < -MaxScale {
+		return Amount{}, ErrPrecision
+	}
+	a.scale = uint8(-exp)
+	return a, nil
+}
+
+// bigUnits return count of minor units of amount with greater or the same scale
+func (a Amount) bigUnits(scale uint8) *big.Int {
+	u := big.NewInt(a.units)
+	if scale > a.scale {
+		u.Mul(u, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-a.scale)), nil))
+	}
+	return u
+}
+
+func abs(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
@@ -0,0 +1,216 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		in      string
+		units   int64
+		scale   uint8
+		wantErr bool
+	}{
+		{"12", 12, 0, false},
+		{"12.50", 1250, 2, false},
+		{"-0.5", -5, 1, false},
+		{"+3.0001", 30001, 4, false},
+		{".25", 25, 2, false},
+		{"", 0, 0, true},
+		{"-", 0, 0, true},
+		{"1.2.3", 0, 0, true},
+		{"1e3", 0, 0, true},
+		{"99999999999999999999", 0, 0, true},
+		{"1000000000000", 1000000000000, 0, false},
+		{"-1000000000000.0000", -10000000000000000, 4, false},
+		{"1000000000000.0001", 0, 0, true},
+		{"9223372036854775807", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			a, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (a.Units() != tt.units || a.Scale() != tt.scale) {
+				t.Errorf("Parse() = %d/%d, want %d/%d", a.Units(), a.Scale(), tt.units, tt.scale)
+			}
+		})
+	}
+}
+
+func Test_String(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		want string
+	}{
+		{New(1250, 2), "12.50"},
+		{New(-5, 1), "-0.5"},
+		{New(5, 3), "0.005"},
+		{New(0, 2), "0.00"},
+		{New(42, 0), "42"},
+	}
+	for _, tt := range tests {
+		if got := tt.a.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+// sum of many small deposits must be exact
+func Test_AddNoDrift(t *testing.T) {
+	sum := Amount{}
+	step := MustParse("0.1")
+	for i := 0; i < 1000; i++ {
+		var err error
+		if sum, err = sum.Add(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sum.Cmp(MustParse("100")) != 0 {
+		t.Errorf("sum = %s, want 100", sum)
+	}
+}
+
+func Test_Overflow(t *testing.T) {
+	max := New(1<<63-1, 4)
+	if _, err := max.Add(New(1, 4)); err != ErrRange {
+		t.Errorf("Add() error = %v, want ErrRange", err)
+	}
+	if _, err := max.Neg().Sub(New(2, 4)); err != ErrRange {
+		t.Errorf("Sub() error = %v, want ErrRange", err)
+	}
+	// the same value can't be rescaled to scale of other amount, but still can be compared with it
+	if _, err := max.Add(New(1, 6)); err != ErrRange {
+		t.Errorf("Add() with rescale error = %v, want ErrRange", err)
+	}
+	if got := max.Cmp(New(1, 6)); got != 1 {
+		t.Errorf("Cmp() = %d, want 1", got)
+	}
+	if got := New(-1<<63+1, 0).Cmp(MustParse("0.000001")); got != -1 {
+		t.Errorf("Cmp() = %d, want -1", got)
+	}
+	if got := New(12, 0).Cmp(MustParse("12.000000")); got != 0 {
+		t.Errorf("Cmp() = %d, want 0", got)
+	}
+}
+
+func Test_Rescale(t *testing.T) {
+	if _, err := MustParse("1.234").Rescale(2); err != ErrPrecision {
+		t.Errorf("Rescale() error = %v, want ErrPrecision", err)
+	}
+	a, err := MustParse("1.2300").Rescale(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != "1.23" {
+		t.Errorf("Rescale() = %s, want 1.23", a)
+	}
+	if got := MustParse("10.5000").Trim(2).String(); got != "10.50" {
+		t.Errorf("Trim() = %s, want 10.50", got)
+	}
+	if got := MustParse("10.5000").Precision(); got != 1 {
+		t.Errorf("Precision() = %d, want 1", got)
+	}
+}
+
+func Test_JSON(t *testing.T) {
+	var v struct {
+		A Amount `json:"a"`
+		B Amount `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":31.2314,"b":"0.10"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "31.2314" || v.B.String() != "0.10" {
+		t.Errorf("Unmarshal() = %s, %s", v.A, v.B)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"a":31.2314,"b":0.10}` {
+		t.Errorf("Marshal() = %s", out)
+	}
+}
+
+func Test_Scan(t *testing.T) {
+	var a Amount
+	for _, src := range []interface{}{"10.5000", []byte("10.5000"), float64(10.5)} {
+		if err := a.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if a.Cmp(MustParse("10.5")) != 0 {
+			t.Errorf("Scan(%v) = %s", src, a)
+		}
+	}
+	if err := a.Scan(nil); err != nil || !a.IsZero() {
+		t.Errorf("Scan(nil) = %s, %v", a, err)
+	}
+	for src, want := range map[string]string{"1250e-2": "12.50", "-5e-1": "-0.5", "12e3": "12000", "0e0": "0"} {
+		if err := a.Scan(src); err != nil || a.String() != want {
+			t.Errorf("Scan(%q) = %s, %v, want %s", src, a, err, want)
+		}
+	}
+	for _, src := range []string{"1.5e2", "1e", "9223372036854775807e1"} {
+		if err := a.Scan(src); err == nil {
+			t.Errorf("Scan(%q) = %s, want error", src, a)
+		}
+	}
+}
+
+// pgx reads numeric columns in binary format and passes them to Scan as text of pgtype.Numeric
+func Test_ScanPgNumeric(t *testing.T) {
+	for _, s := range []string{"12.50", "-0.0001", "1000.0000", "922337203685477.5807", "0"} {
+		var n pgtype.Numeric
+		if err := n.Set(s); err != nil {
+			t.Fatal(err)
+		}
+		buf, err := n.EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded pgtype.Numeric
+		if err := decoded.DecodeBinary(nil, buf); err != nil {
+			t.Fatal(err)
+		}
+		src, err := decoded.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var a Amount
+		if err := a.Scan(src); err != nil {
+			t.Fatalf("Scan(%v) error = %v", src, err)
+		}
+		if a.String() != s {
+			t.Errorf("Scan(%v) = %s, want %s", src, a, s)
+		}
+	}
+}
+
+func Test_MulRound(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		scale uint8
+		want  string
+	}{
+		{"10.00", "0.8187", 2, "8.19"},
+		{"10.00", "0.8185", 2, "8.19"},
+		{"10.00", "0.8184", 2, "8.18"},
+		{"-10.00", "0.8185", 2, "-8.19"},
+		{"100", "110.25", 0, "11025"},
+		{"1", "2", 3, "2.000"},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.a).MulRound(MustParse(tt.b), tt.scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s * %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}